@@ -38,6 +38,7 @@ import (
 	"github.com/praxisllmlab/tianjiLLM/internal/router/strategy"
 	"github.com/praxisllmlab/tianjiLLM/internal/router/strategy/auto"
 	"github.com/praxisllmlab/tianjiLLM/internal/scheduler"
+	"github.com/praxisllmlab/tianjiLLM/internal/scim"
 	"github.com/praxisllmlab/tianjiLLM/internal/spend"
 	"github.com/praxisllmlab/tianjiLLM/internal/ui"
 
@@ -203,8 +204,9 @@ func main() {
 	}
 
 	// Register spend tracker as callback (writes SpendLogs to DB)
+	var spendTracker *spend.Tracker
 	if queries != nil {
-		spendTracker := spend.NewTracker(queries, nil)
+		spendTracker = spend.NewTracker(queries, nil)
 		callbackRegistry.Register(spendTracker)
 		log.Println("spend tracker registered")
 	}
@@ -222,59 +224,80 @@ func main() {
 		log.Printf("guardrail registered: %s (fail_open=%v)", gc.GuardrailName, failOpen)
 	}
 
+	// Apply router_settings changed at runtime via PATCH /router/settings
+	if queries != nil {
+		if row, err := queries.GetProxyConfig(ctx, handler.RouterSettingsParam); err == nil {
+			var overrides map[string]any
+			if err := json.Unmarshal(row.ParamValue, &overrides); err != nil {
+				log.Printf("warn: stored router_settings: %v", err)
+			} else if patched, err := config.PatchRouterSettings(cfg.RouterSettings, overrides); err != nil {
+				log.Printf("warn: stored router_settings: %v", err)
+			} else {
+				cfg.RouterSettings = patched
+				log.Println("router_settings overrides loaded from database")
+			}
+		}
+	}
+
 	// Init router (config-driven)
 	var rtr *router.Router
+	var providerSpend *strategy.ProviderSpend
 	if cfg.RouterSettings != nil {
 		strategyName := cfg.RouterSettings.RoutingStrategy
-		routeStrategy, err := strategy.NewFromConfig(strategyName)
+		if len(cfg.RouterSettings.ProviderBudgetConfig) > 0 {
+			budgets, err := strategy.ParseProviderBudgets(cfg.RouterSettings.ProviderBudgetConfig)
+			if err != nil {
+				log.Printf("warn: %v, provider budgets disabled", err)
+				cfg.RouterSettings.ProviderBudgetConfig = nil
+			} else {
+				providerSpend = strategy.NewProviderSpend(budgets)
+				callbackRegistry.Register(providerSpend)
+			}
+		}
+		routeStrategy, err := strategy.NewFromSettings(cfg.RouterSettings, providerSpend)
 		if err != nil {
 			log.Printf("warn: routing strategy %q: %v, using shuffle", strategyName, err)
 			routeStrategy = strategy.NewShuffle()
 		}
 
-		settings := router.RouterSettings{
-			NumRetries: 2,
-		}
-		if cfg.RouterSettings.NumRetries != nil {
-			settings.NumRetries = *cfg.RouterSettings.NumRetries
-		}
-		if cfg.RouterSettings.AllowedFails != nil {
-			settings.AllowedFails = *cfg.RouterSettings.AllowedFails
-		}
-		if cfg.RouterSettings.CooldownTime != nil {
-			settings.CooldownTime = time.Duration(*cfg.RouterSettings.CooldownTime) * time.Second
-		}
-
-		// Wire model group alias
-		settings.ModelGroupAlias = parseModelGroupAlias(cfg.RouterSettings.ModelGroupAlias)
+		settings := router.SettingsFromConfig(cfg)
 
-		// Wire fallbacks (from both router_settings and tianji_settings)
-		settings.Fallbacks = parseFallbackMaps(cfg.RouterSettings.Fallbacks)
-		if len(settings.Fallbacks) == 0 {
-			settings.Fallbacks = parseFallbackStringMaps(cfg.TianjiSettings.Fallbacks)
-		}
-		settings.DefaultFallbacks = cfg.RouterSettings.DefaultFallbacks
-		if len(settings.DefaultFallbacks) == 0 {
-			settings.DefaultFallbacks = cfg.TianjiSettings.DefaultFallbacks
+		// Wire shared router state across replicas
+		if cfg.RouterSettings.RedisHost != "" {
+			port := 6379
+			if cfg.RouterSettings.RedisPort != nil {
+				port = *cfg.RouterSettings.RedisPort
+			}
+			stateClient := redis.NewClient(&redis.Options{
+				Addr:     fmt.Sprintf("%s:%d", cfg.RouterSettings.RedisHost, port),
+				Password: cfg.RouterSettings.RedisPassword,
+			})
+			if err := stateClient.Ping(ctx).Err(); err != nil {
+				log.Printf("warn: router redis not available, using local router state: %v", err)
+			} else {
+				settings.State = router.NewRedisStateStore(stateClient)
+				log.Println("router state shared via redis")
+			}
 		}
-		settings.ContentPolicyFallbacks = parseFallbackMaps(cfg.RouterSettings.ContentPolicyFallbacks)
-
-		// Wire context window fallbacks
-		settings.ContextWindowFallbacks = parseFallbackStringMaps(cfg.TianjiSettings.ContextWindowFallbacks)
-
-		// Wire per-group retry policy
-		settings.ModelGroupRetryPolicy = parseRetryPolicies(cfg.RouterSettings.ModelGroupRetryPolicy)
-
-		// Wire tag filtering
-		settings.EnableTagFiltering = cfg.RouterSettings.EnableTagFiltering
-		settings.TagFilteringMatchAny = cfg.RouterSettings.TagFilteringMatchAny
 
 		rtr = router.New(cfg.ModelList, routeStrategy, settings)
+		if err := rtr.ProviderErr(); err != nil {
+			log.Fatalf("load config: %v", err)
+		}
 		log.Printf("router configured: strategy=%s", strategyName)
 
 		// Init auto-routers for model entries with "auto_router/" prefix
 		initAutoRouters(cfg, rtr)
 	}
+	if rtr == nil && queries != nil {
+		// DB-managed models are only routable through the Router.
+		rtr = router.New(cfg.ModelList, strategy.NewShuffle(), router.RouterSettings{})
+		if err := rtr.ProviderErr(); err != nil {
+			log.Fatalf("load config: %v", err)
+		}
+		initAutoRouters(cfg, rtr)
+		log.Println("router configured for DB-managed models: strategy=simple-shuffle")
+	}
 
 	// Init policy engine (requires DB)
 	var policyEng *policy.Engine
@@ -401,9 +424,53 @@ func main() {
 		}
 	}()
 
+	// Cache key lookups in front of the database. Key changes invalidate
+	// them on every replica through Redis pub/sub.
+	var authCache *middleware.AuthCache
+	if queries != nil {
+		var authRedis *cache.RedisCache
+		if redisClient != nil {
+			authRedis = cache.NewRedisCache(redisClient)
+		}
+		var ttl, grace time.Duration
+		if v := cfg.GeneralSettings.UserAPIKeyCacheTTL; v != nil {
+			ttl = time.Duration(*v) * time.Second
+		}
+		if v := cfg.GeneralSettings.AuthCacheGracePeriod; v != nil {
+			grace = time.Duration(*v) * time.Second
+		}
+		authCache = middleware.NewAuthCache(&middleware.DBValidator{DB: queries},
+			cache.NewDualCache(cache.NewMemoryCache(), authRedis), redisClient, ttl, grace)
+		go authCache.Subscribe(ctx)
+		// Budget checks read spend through the cache; drop rows it changes.
+		spendTracker.Invalidator = authCache
+	}
+	var roleGrants *middleware.RoleGrants
+	if queries != nil {
+		roleGrants = middleware.NewRoleGrants(queries, 0)
+	}
+
+	// IP allow-list: config entries plus those stored with /ip/add. The
+	// stored entries are loaded before serving so a replica never starts
+	// open to addresses the list excludes.
+	trustedProxies, err := middleware.ParseIPPrefixes(cfg.GeneralSettings.TrustedProxies)
+	if err != nil {
+		log.Fatalf("trusted_proxies: %v", err)
+	}
+	if _, err := middleware.ParseIPPrefixes(cfg.GeneralSettings.AllowedIPs); err != nil {
+		log.Fatalf("allowed_ips: %v", err)
+	}
+	ipWhitelist := middleware.NewIPWhitelist(cfg.GeneralSettings.AllowedIPs)
+	var ipReload *scheduler.IPWhitelistReloadJob
+	if queries != nil {
+		ipReload = &scheduler.IPWhitelistReloadJob{DB: queries, Whitelist: ipWhitelist}
+		if err := ipReload.Run(ctx); err != nil {
+			log.Fatalf("load IP allow-list: %v", err)
+		}
+	}
+
 	handlers := &handler.Handlers{
 		Config:          cfg,
-		DB:              queries,
 		Cache:           cacheBackend,
 		Router:          rtr,
 		Callbacks:       callbackRegistry,
@@ -414,6 +481,14 @@ func main() {
 		EventDispatcher: eventDispatcher,
 		DiscordAlerter:  discordAlerter,
 		RateLimitStore:  rateLimitStore,
+		ProviderSpend:   providerSpend,
+		AuthCache:       authCache,
+		RoleGrants:      roleGrants,
+		IPWhitelist:     ipWhitelist,
+	}
+	if queries != nil {
+		// Assign only when set so handlers see a nil db.Store without a DB.
+		handlers.DB = queries
 	}
 
 	// Init scheduler
@@ -424,6 +499,10 @@ func main() {
 		if policyEng != nil {
 			sched.Add(&scheduler.PolicyHotReloadJob{Engine: policyEng}, 30*time.Second)
 		}
+		if rtr != nil {
+			sched.AddWithStartupRun(&scheduler.ModelReloadJob{DB: queries, Router: rtr}, 30*time.Second)
+		}
+		sched.Add(ipReload, 30*time.Second)
 	}
 	sched.Start()
 
@@ -448,27 +527,84 @@ func main() {
 		MasterKey:      cfg.GeneralSettings.MasterKey,
 		Pricing:        pricingCalc,
 		RateLimitStore: rateLimitStore,
+		Router:         rtr,
+		AuthCache:      authCache,
+		RoleGrants:     roleGrants,
 	}
 
 	// Create server
 	var dbValidator middleware.TokenValidator
 	var authErrLogger middleware.AuthErrorLogger
+	var budgetEnforcer *middleware.BudgetEnforcer
+	var rateLimitEnforcer *middleware.RateLimitEnforcer
 	if queries != nil {
-		dbValidator = &middleware.DBValidator{DB: queries}
+		dbValidator = authCache
 		authErrLogger = &middleware.DBAuthErrorLogger{DB: queries}
+		// Limits and spend are read through the auth cache.
+		limitStore := authCache.Store(queries)
+		budgetEnforcer = middleware.NewBudgetEnforcer(limitStore)
+		// Counts in Redis when configured, otherwise per replica in memory.
+		rateLimitEnforcer = middleware.NewRateLimitEnforcer(middleware.NewRateLimiter(redisClient), limitStore)
+	}
+	authCfg := middleware.AuthConfig{
+		MasterKey:   cfg.GeneralSettings.MasterKey,
+		Validator:   dbValidator,
+		ErrorLogger: authErrLogger,
+		HeaderName:  cfg.GeneralSettings.AuthHeaderName,
 	}
-	srv := proxy.NewServer(proxy.ServerConfig{
+	if url := cfg.GeneralSettings.CustomAuth; url != "" {
+		authCfg.CustomAuth = newCustomAuth(url, cfg.GeneralSettings.CustomAuthSettings)
+		log.Printf("custom auth enabled (%s)", url)
+	}
+	rbac, err := newRBACEngine(cfg.GeneralSettings.RolePermissions)
+	if err != nil {
+		log.Fatalf("role_permissions: %v", err)
+	}
+	authCfg.RBACEngine = rbac
+	if roleGrants != nil {
+		authCfg.Grants = roleGrants
+	}
+	if jc := cfg.GeneralSettings.TianjiJWTAuth; jc != nil {
+		jwtValidator, err := newJWTAuth(jc)
+		if err != nil {
+			log.Fatalf("tianji_jwtauth: %v", err)
+		}
+		authCfg.EnableJWTAuth = true
+		authCfg.JWTValidator = jwtValidator
+		if queries != nil && (jc.UserIDUpsert || jc.TeamIDUpsert) {
+			authCfg.JWTProvisioner = middleware.NewJWTProvisioner(queries, jc.UserIDUpsert, jc.TeamIDUpsert)
+		}
+		log.Printf("JWT auth enabled (jwks=%s)", jc.PublicKeyURL)
+	}
+	var scimHandler http.Handler
+	if sc := cfg.GeneralSettings.SCIM; sc != nil {
+		if queries == nil {
+			log.Printf("warn: scim requires database_url, SCIM disabled")
+		} else {
+			scimHandler, err = newSCIMHandler(sc, queries, authCache)
+			if err != nil {
+				log.Fatalf("scim: %v", err)
+			}
+			log.Printf("SCIM provisioning enabled at /scim/v2 (%d group mappings)", len(sc.GroupMappings))
+		}
+	}
+	srv := proxy.NewServerWithAuth(proxy.ServerConfig{
 		Handlers:           handlers,
 		MasterKey:          cfg.GeneralSettings.MasterKey,
 		DBQueries:          dbValidator,
 		AuthErrorLogger:    authErrLogger,
 		RedisClient:        redisClient,
+		BudgetEnforcer:     budgetEnforcer,
+		RateLimitEnforcer:  rateLimitEnforcer,
+		SCIMHandler:        scimHandler,
 		PassthroughHandler: passthroughHandler,
 		MCPSSEHandler:      mcpSSEHandler,
 		MCPStreamHandler:   mcpStreamHandler,
 		MCPRESTHandler:     mcpRESTHandler,
 		UIHandler:          uiHandler,
-	})
+		TrustedProxies:     trustedProxies,
+		IPWhitelist:        ipWhitelist,
+	}, authCfg)
 
 	// Start HTTP server
 	addr := fmt.Sprintf(":%d", cfg.GeneralSettings.Port)
@@ -504,66 +640,120 @@ func main() {
 	}
 }
 
-// parseModelGroupAlias converts config map[string]any to typed alias map.
-// Supports both string shorthand ("alias": "model") and object form ("alias": {"model": "x", "hidden": true}).
-func parseModelGroupAlias(raw map[string]any) map[string]router.ModelGroupAliasItem {
-	if len(raw) == 0 {
-		return nil
-	}
-	result := make(map[string]router.ModelGroupAliasItem, len(raw))
-	for alias, v := range raw {
-		switch val := v.(type) {
-		case string:
-			result[alias] = router.ModelGroupAliasItem{Model: val}
-		case map[string]any:
-			item := router.ModelGroupAliasItem{}
-			if m, ok := val["model"].(string); ok {
-				item.Model = m
-			}
-			if h, ok := val["hidden"].(bool); ok {
-				item.Hidden = h
-			}
-			result[alias] = item
-		}
+// newCustomAuth builds the custom_auth client from custom_auth_settings.
+func newCustomAuth(url string, settings *config.CustomAuthSettings) *middleware.CustomAuth {
+	if settings == nil {
+		return middleware.NewCustomAuth(url, 0, 0)
+	}
+	var timeout, ttl time.Duration
+	if settings.Timeout != nil {
+		timeout = time.Duration(*settings.Timeout * float64(time.Second))
 	}
-	return result
+	if settings.CacheTTL != nil {
+		ttl = time.Duration(*settings.CacheTTL) * time.Second
+	}
+	ca := middleware.NewCustomAuth(url, timeout, ttl)
+	ca.Headers = settings.Headers
+	ca.FailOpen = settings.FailOpen
+	ca.Fallback = settings.Mode == "auto"
+	return ca
 }
 
-// parseFallbackMaps converts []map[string]any to map[string][]string.
-// Input format: [{"gpt-4": ["claude-3", "gemini"]}]
-func parseFallbackMaps(raw []map[string]any) map[string][]string {
-	if len(raw) == 0 {
-		return nil
+// newJWTAuth builds the JWT validator from general_settings.tianji_jwtauth.
+func newJWTAuth(jc *config.JWTAuthConfig) (*auth.JWTValidator, error) {
+	if jc.PublicKeyURL == "" {
+		return nil, fmt.Errorf("public_key_url is required")
 	}
-	result := make(map[string][]string)
-	for _, m := range raw {
+	parseRoles := func(m map[string]string) (map[string]auth.Role, error) {
+		out := make(map[string]auth.Role, len(m))
 		for k, v := range m {
-			if arr, ok := v.([]any); ok {
-				strs := make([]string, 0, len(arr))
-				for _, item := range arr {
-					if s, ok := item.(string); ok {
-						strs = append(strs, s)
-					}
-				}
-				result[k] = strs
+			role, err := auth.ParseRole(v)
+			if err != nil {
+				return nil, fmt.Errorf("mapping %q: %w %q", k, err, v)
 			}
+			out[k] = role
 		}
+		return out, nil
 	}
-	return result
+	scopes, err := parseRoles(jc.ScopeMappings)
+	if err != nil {
+		return nil, fmt.Errorf("scope_mappings: %w", err)
+	}
+	roles, err := parseRoles(jc.RoleMappings)
+	if err != nil {
+		return nil, fmt.Errorf("role_mappings: %w", err)
+	}
+
+	var ttl time.Duration
+	if jc.PublicKeyTTL != nil {
+		ttl = time.Duration(*jc.PublicKeyTTL) * time.Second
+	}
+	return auth.NewJWTValidator(auth.JWTConfig{
+		JWKSURL:   jc.PublicKeyURL,
+		Issuer:    jc.Issuer,
+		Audiences: jc.Audiences,
+		CacheTTL:  ttl,
+		Claims: auth.ClaimFields{
+			UserID: jc.UserIDJWTField,
+			Email:  jc.UserEmailJWTField,
+			TeamID: jc.TeamIDJWTField,
+			OrgID:  jc.OrgIDJWTField,
+			Roles:  jc.RolesJWTField,
+			Scopes: jc.ScopeJWTField,
+		},
+		Roles: auth.RoleMapping{
+			AdminScope: jc.AdminJWTScope,
+			Scopes:     scopes,
+			Roles:      roles,
+		},
+	}), nil
 }
 
-// parseFallbackStringMaps converts []map[string][]string to map[string][]string.
-func parseFallbackStringMaps(raw []map[string][]string) map[string][]string {
-	if len(raw) == 0 {
-		return nil
+// newRBACEngine builds the RBAC engine with the role_permissions overrides.
+func newRBACEngine(perms []config.RolePermission) (*auth.RBACEngine, error) {
+	rbac := auth.NewRBACEngine()
+	if len(perms) == 0 {
+		return rbac, nil
 	}
-	result := make(map[string][]string)
-	for _, m := range raw {
-		for k, v := range m {
-			result[k] = v
+	rolePerms := make(map[auth.Role]auth.RolePermission, len(perms))
+	for _, p := range perms {
+		role, err := auth.ParseRole(p.Role)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q", err, p.Role)
 		}
+		for _, route := range p.Routes {
+			if err := auth.ValidatePermission(route); err != nil {
+				return nil, err
+			}
+		}
+		rolePerms[role] = auth.RolePermission{Routes: p.Routes, Models: p.Models}
 	}
-	return result
+	rbac.SetRolePermissions(rolePerms)
+	return rbac, nil
+}
+
+// newSCIMHandler builds the SCIM 2.0 server from the scim settings. Keys
+// blocked on deprovisioning are dropped from authCache.
+func newSCIMHandler(sc *config.SCIMConfig, queries *db.Queries, authCache *middleware.AuthCache) (http.Handler, error) {
+	mappings := make([]scim.GroupMapping, 0, len(sc.GroupMappings))
+	for _, m := range sc.GroupMappings {
+		mappings = append(mappings, scim.GroupMapping{
+			Group:    m.Group,
+			TeamID:   m.TeamID,
+			Role:     auth.Role(m.Role),
+			TeamRole: m.TeamRole,
+		})
+	}
+	cfg := scim.Config{
+		DB:            queries,
+		Token:         sc.Token,
+		UpsertUser:    sc.UpsertUser,
+		GroupMappings: mappings,
+	}
+	if authCache != nil {
+		cfg.Invalidator = authCache
+	}
+	return scim.NewSCIMServer(cfg)
 }
 
 // initAutoRouters scans model_list for entries with "auto_router/" prefix,
@@ -619,29 +809,3 @@ func initAutoRouters(cfg *config.ProxyConfig, rtr *router.Router) {
 		log.Printf("auto_router registered: %s (%d routes, default=%s)", m.ModelName, len(routes), defaultModel)
 	}
 }
-
-// parseRetryPolicies converts config map[string]any to typed retry policies.
-func parseRetryPolicies(raw map[string]any) map[string]router.RetryPolicy {
-	if len(raw) == 0 {
-		return nil
-	}
-	result := make(map[string]router.RetryPolicy, len(raw))
-	for model, v := range raw {
-		m, ok := v.(map[string]any)
-		if !ok {
-			continue
-		}
-		p := router.RetryPolicy{}
-		if n, ok := m["num_retries"].(int); ok {
-			p.NumRetries = n
-		}
-		if t, ok := m["timeout"].(int); ok {
-			p.TimeoutSeconds = t
-		}
-		if r, ok := m["retry_after"].(int); ok {
-			p.RetryAfterSeconds = r
-		}
-		result[model] = p
-	}
-	return result
-}
@@ -4,22 +4,34 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// minJWKSRefresh bounds how often an unknown key ID triggers a JWKS fetch.
+const minJWKSRefresh = 30 * time.Second
+
+// DefaultAdminScope is the scope that grants RoleProxyAdmin.
+const DefaultAdminScope = "tianji_proxy_admin"
+
 // JWTValidator validates JWT tokens using a JWKS endpoint.
 type JWTValidator struct {
 	jwksURL    string
 	keyFunc    jwt.Keyfunc
 	issuer     string
 	audiences  []string
+	claims     ClaimFields
+	roles      RoleMapping
+	client     *http.Client
 	mu         sync.RWMutex
 	cachedKeys map[string]any
 	cacheTime  time.Time
 	cacheTTL   time.Duration
+	lastFetch  time.Time // last JWKS fetch attempt, successful or not
 }
 
 // JWTConfig holds JWT validation configuration.
@@ -28,6 +40,37 @@ type JWTConfig struct {
 	Issuer    string
 	Audiences []string
 	CacheTTL  time.Duration
+
+	// Claims locates the caller's identity in the token.
+	Claims ClaimFields
+	// Roles maps scopes and IdP roles to Tianji roles.
+	Roles RoleMapping
+	// HTTPClient fetches the JWKS; nil uses a client with a 10s timeout.
+	HTTPClient *http.Client
+}
+
+// ClaimFields are the claims holding a caller's identity. Each is a
+// dot-separated path into the token's claims, e.g. "realm_access.roles".
+// Empty fields use the defaults: sub, email, team_id, org_id, role and
+// scopes (or the space-separated "scope").
+type ClaimFields struct {
+	UserID string
+	Email  string
+	TeamID string
+	OrgID  string
+	Roles  string
+	Scopes string
+}
+
+// RoleMapping turns scopes and IdP roles into Tianji roles.
+type RoleMapping struct {
+	// AdminScope grants RoleProxyAdmin; empty uses DefaultAdminScope.
+	AdminScope string
+	// Scopes maps token scopes to roles.
+	Scopes map[string]Role
+	// Roles maps values of the roles claim to roles. Values that are
+	// already Tianji role names need no entry.
+	Roles map[string]Role
 }
 
 // NewJWTValidator creates a new JWT validator.
@@ -35,16 +78,23 @@ func NewJWTValidator(cfg JWTConfig) *JWTValidator {
 	if cfg.CacheTTL == 0 {
 		cfg.CacheTTL = 5 * time.Minute
 	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
 
 	v := &JWTValidator{
 		jwksURL:    cfg.JWKSURL,
 		issuer:     cfg.Issuer,
 		audiences:  cfg.Audiences,
+		claims:     cfg.Claims.withDefaults(),
+		roles:      cfg.Roles,
+		client:     cfg.HTTPClient,
 		cachedKeys: make(map[string]any),
 		cacheTTL:   cfg.CacheTTL,
 	}
 
-	// Use static key func that delegates to cached JWKS
+	// Static key func that delegates to the cached JWKS, refetching it when
+	// it is stale or does not know the token's key ID.
 	v.keyFunc = func(token *jwt.Token) (any, error) {
 		kid, ok := token.Header["kid"].(string)
 		if !ok {
@@ -53,8 +103,21 @@ func NewJWTValidator(cfg JWTConfig) *JWTValidator {
 
 		v.mu.RLock()
 		key, exists := v.cachedKeys[kid]
+		stale := time.Since(v.cacheTime) > v.cacheTTL
+		canFetch := time.Since(v.lastFetch) > minJWKSRefresh
 		v.mu.RUnlock()
 
+		if v.jwksURL != "" && canFetch && (stale || !exists) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := v.RefreshKeys(ctx); err != nil && !exists {
+				return nil, err
+			}
+			v.mu.RLock()
+			key, exists = v.cachedKeys[kid]
+			v.mu.RUnlock()
+		}
+
 		if exists {
 			return key, nil
 		}
@@ -65,6 +128,20 @@ func NewJWTValidator(cfg JWTConfig) *JWTValidator {
 	return v
 }
 
+func (f ClaimFields) withDefaults() ClaimFields {
+	def := func(s *string, d string) {
+		if *s == "" {
+			*s = d
+		}
+	}
+	def(&f.UserID, "sub")
+	def(&f.Email, "email")
+	def(&f.TeamID, "team_id")
+	def(&f.OrgID, "org_id")
+	def(&f.Roles, "role")
+	return f
+}
+
 // JWTClaims holds the parsed JWT claims relevant to TianjiLLM.
 type JWTClaims struct {
 	UserID string   `json:"sub"`
@@ -73,28 +150,31 @@ type JWTClaims struct {
 	TeamID string   `json:"team_id"`
 	OrgID  string   `json:"org_id"`
 	Scopes []string `json:"scopes"`
+	// Roles holds every value of the roles claim; Role is the first.
+	Roles []string `json:"-"`
 	jwt.RegisteredClaims
 }
 
 // ValidateToken parses and validates a JWT token string.
 func (v *JWTValidator) ValidateToken(_ context.Context, tokenStr string) (*JWTClaims, error) {
 	opts := []jwt.ParserOption{
-		jwt.WithValidMethods([]string{"RS256", "ES256", "HS256"}),
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "ES256", "ES384", "HS256"}),
 	}
 
 	if v.issuer != "" {
 		opts = append(opts, jwt.WithIssuer(v.issuer))
 	}
 
-	token, err := jwt.ParseWithClaims(tokenStr, &JWTClaims{}, v.keyFunc, opts...)
+	token, err := jwt.ParseWithClaims(tokenStr, jwt.MapClaims{}, v.keyFunc, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("invalid JWT: %w", err)
 	}
 
-	claims, ok := token.Claims.(*JWTClaims)
+	raw, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
 		return nil, errors.New("invalid JWT claims")
 	}
+	claims := v.mapClaims(raw)
 
 	// Validate audience if configured
 	if len(v.audiences) > 0 {
@@ -115,6 +195,122 @@ func (v *JWTValidator) ValidateToken(_ context.Context, tokenStr string) (*JWTCl
 	return claims, nil
 }
 
+// mapClaims reads the caller's identity from the configured claim paths.
+func (v *JWTValidator) mapClaims(raw jwt.MapClaims) *JWTClaims {
+	c := &JWTClaims{
+		UserID: claimString(raw, v.claims.UserID),
+		Email:  claimString(raw, v.claims.Email),
+		TeamID: claimString(raw, v.claims.TeamID),
+		OrgID:  claimString(raw, v.claims.OrgID),
+		Roles:  claimStrings(raw, v.claims.Roles),
+	}
+	if len(c.Roles) > 0 {
+		c.Role = c.Roles[0]
+	}
+	if v.claims.Scopes != "" {
+		c.Scopes = claimStrings(raw, v.claims.Scopes)
+	} else {
+		c.Scopes = append(claimStrings(raw, "scopes"), claimStrings(raw, "scope")...)
+	}
+
+	c.Issuer, _ = raw.GetIssuer()
+	c.Subject, _ = raw.GetSubject()
+	c.Audience, _ = raw.GetAudience()
+	c.ExpiresAt, _ = raw.GetExpirationTime()
+	c.IssuedAt, _ = raw.GetIssuedAt()
+	c.NotBefore, _ = raw.GetNotBefore()
+	return c
+}
+
+// claim follows a dot-separated path through nested claims.
+func claim(raw map[string]any, path string) any {
+	var cur any = raw
+	for _, part := range strings.Split(path, ".") {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[part]
+	}
+	return cur
+}
+
+// claimString reads a string claim; the first element of a list counts.
+func claimString(raw map[string]any, path string) string {
+	if vals := claimStrings(raw, path); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
+
+// claimStrings reads a list claim, or a space-separated string claim such
+// as the OAuth "scope".
+func claimStrings(raw map[string]any, path string) []string {
+	switch val := claim(raw, path).(type) {
+	case string:
+		return strings.Fields(val)
+	case []any:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+		return out
+	case float64:
+		return []string{fmt.Sprint(val)}
+	}
+	return nil
+}
+
+// ResolveRole determines the RBAC role of a validated token.
+// Follows Python's JWTHandler.get_rbac_role() priority:
+//  1. Admin scope → PROXY_ADMIN
+//  2. Mapped scopes, highest role first
+//  3. Role claim, mapped or a Tianji role name, highest role first
+//  4. TeamID present → TEAM
+//  5. Default → INTERNAL_USER
+func (v *JWTValidator) ResolveRole(claims *JWTClaims) Role {
+	adminScope := v.roles.AdminScope
+	if adminScope == "" {
+		adminScope = DefaultAdminScope
+	}
+
+	var best Role
+	consider := func(role Role) {
+		if roleLevel[role] > roleLevel[best] {
+			best = role
+		}
+	}
+	for _, scope := range claims.Scopes {
+		if scope == adminScope {
+			return RoleProxyAdmin
+		}
+		if role, ok := v.roles.Scopes[scope]; ok {
+			consider(role)
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	for _, name := range claims.Roles {
+		if role, ok := v.roles.Roles[name]; ok {
+			consider(role)
+		} else if role, err := ParseRole(name); err == nil {
+			consider(role)
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	if claims.TeamID != "" {
+		return RoleTeam
+	}
+	return RoleInternalUser
+}
+
 // SetKeys manually sets the JWKS keys (for testing or static config).
 func (v *JWTValidator) SetKeys(keys map[string]any) {
 	v.mu.Lock()
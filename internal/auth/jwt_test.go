@@ -3,12 +3,20 @@ package auth
 import (
 	"context"
 	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 func TestNewJWTValidator(t *testing.T) {
@@ -161,3 +169,121 @@ func TestValidateTokenWithIssuer(t *testing.T) {
 		t.Fatal("expected error for wrong issuer")
 	}
 }
+
+func TestValidateTokenFetchesJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "rsa1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer srv.Close()
+
+	v := NewJWTValidator(JWTConfig{JWKSURL: srv.URL})
+	sign := func(kid string) string {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"sub": "user-1",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+		tok.Header["kid"] = kid
+		s, err := tok.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	for range 2 {
+		claims, err := v.ValidateToken(context.Background(), sign("rsa1"))
+		if err != nil {
+			t.Fatalf("ValidateToken: %v", err)
+		}
+		if claims.UserID != "user-1" {
+			t.Errorf("UserID = %q, want user-1", claims.UserID)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times, want 1", n)
+	}
+
+	// An unknown kid right after a fetch does not hit the endpoint again.
+	if _, err := v.ValidateToken(context.Background(), sign("rsa2")); err == nil {
+		t.Fatal("expected error for unknown kid")
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("JWKS fetched %d times after unknown kid, want 1", n)
+	}
+}
+
+func TestValidateTokenClaimPaths(t *testing.T) {
+	secret := []byte("super-secret-key-at-least-32-bytes!")
+	v := NewJWTValidator(JWTConfig{Claims: ClaimFields{
+		UserID: "preferred_username",
+		TeamID: "groups",
+		OrgID:  "tenant.id",
+		Roles:  "realm_access.roles",
+		Scopes: "scp",
+	}})
+	v.SetKeys(map[string]any{"k1": secret})
+
+	token := makeHS256Token(t, secret, "k1", map[string]any{
+		"sub":                "opaque",
+		"preferred_username": "alice",
+		"groups":             []any{"team-a", "team-b"},
+		"tenant":             map[string]any{"id": "org-1"},
+		"realm_access":       map[string]any{"roles": []any{"offline_access", "team"}},
+		"scp":                "read write",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	})
+	claims, err := v.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.UserID != "alice" || claims.TeamID != "team-a" || claims.OrgID != "org-1" {
+		t.Errorf("identity = %q/%q/%q, want alice/team-a/org-1", claims.UserID, claims.TeamID, claims.OrgID)
+	}
+	if strings.Join(claims.Roles, ",") != "offline_access,team" {
+		t.Errorf("Roles = %v", claims.Roles)
+	}
+	if strings.Join(claims.Scopes, ",") != "read,write" {
+		t.Errorf("Scopes = %v", claims.Scopes)
+	}
+}
+
+func TestResolveRole(t *testing.T) {
+	v := NewJWTValidator(JWTConfig{Roles: RoleMapping{
+		AdminScope: "admin",
+		Scopes:     map[string]Role{"llm:team": RoleTeam, "llm:user": RoleInternalUser},
+		Roles:      map[string]Role{"platform-admins": RoleProxyAdmin},
+	}})
+
+	tests := []struct {
+		name   string
+		claims JWTClaims
+		want   Role
+	}{
+		{"admin scope", JWTClaims{Scopes: []string{"read", "admin"}}, RoleProxyAdmin},
+		{"highest mapped scope", JWTClaims{Scopes: []string{"llm:user", "llm:team"}}, RoleTeam},
+		{"scope wins over role", JWTClaims{Scopes: []string{"llm:user"}, Roles: []string{"platform-admins"}}, RoleInternalUser},
+		{"mapped role", JWTClaims{Roles: []string{"platform-admins"}}, RoleProxyAdmin},
+		{"tianji role name", JWTClaims{Roles: []string{"unknown", "team"}}, RoleTeam},
+		{"team inferred", JWTClaims{TeamID: "t1"}, RoleTeam},
+		{"default", JWTClaims{UserID: "u1"}, RoleInternalUser},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := v.ResolveRole(&tt.claims); got != tt.want {
+				t.Errorf("ResolveRole = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
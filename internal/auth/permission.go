@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Grant is a custom role granted to a user. A grant scoped to a team
+// applies only to requests that target that team, and one scoped to an
+// organization only to requests that target that organization, whatever
+// team or organization the caller belongs to.
+type Grant struct {
+	Role        string
+	Permissions []string
+	OrgID       string
+	TeamID      string
+}
+
+// Allows reports whether the grant lets a caller call method on route for
+// the organization orgID and team teamID that the request targets. A team
+// scope implies its organization, so only the team is compared for grants
+// scoped to both.
+func (g Grant) Allows(method, route, orgID, teamID string) bool {
+	switch {
+	case g.TeamID != "":
+		if g.TeamID != teamID {
+			return false
+		}
+	case g.OrgID != "":
+		if g.OrgID != orgID {
+			return false
+		}
+	}
+	for _, p := range g.Permissions {
+		if PermissionAllows(p, method, route) {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionAllows reports whether permission p covers method on route.
+// A permission is "[METHOD ]ROUTE": ROUTE matches exactly, or by prefix
+// when it ends in "*"; "*" alone matches every route. Without a method any
+// method matches.
+func PermissionAllows(p, method, route string) bool {
+	pMethod, pRoute, ok := strings.Cut(p, " ")
+	if !ok {
+		pMethod, pRoute = "", p
+	} else if !strings.EqualFold(pMethod, method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(pRoute, "*"); ok {
+		return strings.HasPrefix(route, prefix)
+	}
+	return route == pRoute
+}
+
+// ValidatePermission reports whether p is a well-formed permission.
+func ValidatePermission(p string) error {
+	pMethod, pRoute, ok := strings.Cut(p, " ")
+	if !ok {
+		pRoute = p
+	} else {
+		switch strings.ToUpper(pMethod) {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			return fmt.Errorf("permission %q: unknown method %q", p, pMethod)
+		}
+	}
+	if pRoute != "*" && !strings.HasPrefix(pRoute, "/") {
+		return fmt.Errorf("permission %q: route must start with / or be *", p)
+	}
+	if i := strings.Index(pRoute, "*"); i >= 0 && i != len(pRoute)-1 {
+		return fmt.Errorf("permission %q: * is only allowed at the end", p)
+	}
+	return nil
+}
+
+// ValidateCustomRole checks a custom role's name and permissions. Built-in
+// role names are reserved.
+func ValidateCustomRole(name string, permissions []string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("role_name is required")
+	}
+	if _, err := ParseRole(name); err == nil {
+		return fmt.Errorf("role_name %s is a built-in role", name)
+	}
+	for _, p := range permissions {
+		if err := ValidatePermission(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
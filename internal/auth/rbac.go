@@ -28,84 +28,124 @@ var roleLevel = map[Role]int{
 	RoleEndUser:      10,
 }
 
-// RBACEngine enforces role-based access control.
+// RolePermission overrides what a role may call. Routes are permissions
+// as accepted by PermissionAllows; when set they replace the default
+// route table for the role. Models restricts the models the role may
+// call; empty allows all.
+type RolePermission struct {
+	Routes []string
+	Models []string
+}
+
+// RBACEngine enforces role-based access control. Routes are denied by
+// default: only proxy admins may call a route without an entry in the
+// route table, or a permission granting it.
 type RBACEngine struct {
-	routePermissions map[string]Role // route prefix → minimum required role
+	routePermissions map[string]Role // route or route prefix → minimum required role
+	rolePermissions  map[Role]RolePermission
+}
+
+// llmRoutes are the LLM API routes open to internal users. Entries ending
+// in "/" match by prefix; others match the route and its sub-paths.
+var llmRoutes = []string{
+	"/v1/", "/v1beta/models/", "/engines/", "/openai/deployments/",
+	"/chat/completions", "/completions", "/embeddings", "/images",
+	"/audio", "/moderations", "/responses", "/models", "/files", "/batches",
+	"/fine_tuning", "/rerank", "/assistants", "/threads", "/vector_stores",
+	"/search", "/messages", "/ocr", "/videos", "/containers", "/agents",
+	"/skills", "/rag", "/realtime", "/anthropic/v1/messages/batches",
+	"/mcp", "/mcp/sse", "/mcp-rest", "/a2a/",
 }
 
 // NewRBACEngine creates a new RBAC engine with default route permissions.
 func NewRBACEngine() *RBACEngine {
-	return &RBACEngine{
-		routePermissions: map[string]Role{
-			// Admin-only routes
-			"/key/generate":       RoleProxyAdmin,
-			"/key/delete":         RoleProxyAdmin,
-			"/key/update":         RoleProxyAdmin,
-			"/team/new":           RoleProxyAdmin,
-			"/team/delete":        RoleProxyAdmin,
-			"/team/update":        RoleProxyAdmin,
-			"/user/new":           RoleProxyAdmin,
-			"/user/delete":        RoleProxyAdmin,
-			"/organization":       RoleProxyAdmin,
-			"/credentials":        RoleProxyAdmin,
-			"/model_access_group": RoleProxyAdmin,
-			"/budget":             RoleProxyAdmin,
-			"/callback":           RoleProxyAdmin,
-			"/cache":              RoleProxyAdmin,
-			"/router":             RoleProxyAdmin,
-			"/sso":                RoleProxyAdmin,
-
-			// Team-level routes
-			"/key/info":  RoleTeam,
-			"/key/list":  RoleTeam,
-			"/team/list": RoleTeam,
-
-			// Internal user routes (completions, models, etc.)
-			"/v1/chat/completions": RoleInternalUser,
-			"/v1/completions":      RoleInternalUser,
-			"/v1/embeddings":       RoleInternalUser,
-			"/v1/models":           RoleInternalUser,
-			"/v1/files":            RoleInternalUser,
-			"/v1/batches":          RoleInternalUser,
-			"/v1/fine_tuning":      RoleInternalUser,
-			"/v1/rerank":           RoleInternalUser,
-			"/v1/images":           RoleInternalUser,
-			"/v1/audio":            RoleInternalUser,
-			"/v1/moderations":      RoleInternalUser,
-			"/v1/responses":        RoleInternalUser,
-
-			// Health endpoints — no auth required (handled separately)
-		},
+	perms := map[string]Role{
+		// Team-level routes
+		"/key/info":  RoleTeam,
+		"/key/list":  RoleTeam,
+		"/team/list": RoleTeam,
+
+		// Internal user routes besides the LLM API. Keys generated below
+		// proxy admin are always children of the calling key.
+		"/key/generate":     RoleInternalUser,
+		"/key/health":       RoleInternalUser,
+		"/model_group/info": RoleInternalUser,
+		"/utils/":           RoleInternalUser,
+
+		// Everything else — key, team, user, org, budget, spend, policy,
+		// guardrail, prompt, MCP server, audit and config management — is
+		// admin-only by default.
+	}
+	for _, route := range llmRoutes {
+		perms[route] = RoleInternalUser
 	}
+	return &RBACEngine{routePermissions: perms}
+}
+
+// SetRolePermissions sets per-role overrides, replacing any set before.
+func (e *RBACEngine) SetRolePermissions(perms map[Role]RolePermission) {
+	e.rolePermissions = perms
+}
+
+// RoleModels returns the models the role is restricted to; nil allows all.
+func (e *RBACEngine) RoleModels(role Role) []string {
+	return e.rolePermissions[role].Models
 }
 
 // CheckRouteAccess verifies the user's role has access to the given route.
 func (e *RBACEngine) CheckRouteAccess(role Role, route string) error {
-	requiredRole := e.findRequiredRole(route)
-	if requiredRole == "" {
-		// No permission defined — allow by default
-		return nil
-	}
-
 	userLevel, ok := roleLevel[role]
 	if !ok {
 		return ErrInvalidRole
 	}
-
-	requiredLevel, ok := roleLevel[requiredRole]
-	if !ok {
-		return ErrInvalidRole
+	if role == RoleProxyAdmin {
+		return nil
 	}
 
-	if userLevel < requiredLevel {
+	if perm, ok := e.rolePermissions[role]; ok && len(perm.Routes) > 0 {
+		for _, p := range perm.Routes {
+			if PermissionAllows(p, "", route) {
+				return nil
+			}
+		}
 		return ErrAccessDenied
 	}
 
+	requiredRole := e.findRequiredRole(route)
+	if requiredRole == "" {
+		return ErrAccessDenied
+	}
+	if userLevel < roleLevel[requiredRole] {
+		return ErrAccessDenied
+	}
 	return nil
 }
 
+// CheckAccess allows the request when the role may call route, or when one
+// of the caller's custom role grants permits method on route for the
+// organization and team the request targets.
+func (e *RBACEngine) CheckAccess(role Role, grants []Grant, method, route, orgID, teamID string) error {
+	err := e.CheckRouteAccess(role, route)
+	if err == nil {
+		return nil
+	}
+	for _, g := range grants {
+		if g.Allows(method, route, orgID, teamID) {
+			return nil
+		}
+	}
+	return err
+}
+
 // CheckModelAccess verifies the user has access to the requested model.
 func (e *RBACEngine) CheckModelAccess(allowedModels []string, requestedModel string) error {
+	return CheckModelAccess(allowedModels, requestedModel)
+}
+
+// CheckModelAccess reports ErrAccessDenied unless requestedModel is in
+// allowedModels, exactly or through a "provider/*" wildcard. An empty list
+// allows every model.
+func CheckModelAccess(allowedModels []string, requestedModel string) error {
 	if len(allowedModels) == 0 {
 		return nil // no model restrictions
 	}
@@ -126,21 +166,31 @@ func (e *RBACEngine) CheckModelAccess(allowedModels []string, requestedModel str
 	return ErrAccessDenied
 }
 
-// findRequiredRole finds the minimum role for a route using prefix matching.
+// findRequiredRole finds the minimum role for a route. The longest
+// matching entry wins.
 func (e *RBACEngine) findRequiredRole(route string) Role {
-	// Exact match first
-	if role, ok := e.routePermissions[route]; ok {
-		return role
+	var best string
+	for prefix := range e.routePermissions {
+		if len(prefix) > len(best) && routeHasPrefix(route, prefix) {
+			best = prefix
+		}
 	}
+	return e.routePermissions[best]
+}
 
-	// Prefix match
-	for prefix, role := range e.routePermissions {
-		if strings.HasPrefix(route, prefix) {
-			return role
-		}
+// routeHasPrefix reports whether route is prefix or lies under it. A prefix
+// ending in "/" matches anything below it.
+func routeHasPrefix(route, prefix string) bool {
+	if strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(route, prefix)
 	}
+	return route == prefix || strings.HasPrefix(route, prefix+"/")
+}
 
-	return ""
+// Outranks reports whether r is a more privileged role than other. Unknown
+// roles rank below every known one.
+func (r Role) Outranks(other Role) bool {
+	return roleLevel[r] > roleLevel[other]
 }
 
 // ParseRole converts a string to a Role, returning ErrInvalidRole if unknown.
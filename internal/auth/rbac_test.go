@@ -15,10 +15,17 @@ func TestRBACEngine_AdminAccessAdmin(t *testing.T) {
 
 func TestRBACEngine_TeamDeniedAdmin(t *testing.T) {
 	e := NewRBACEngine()
-	err := e.CheckRouteAccess(RoleTeam, "/key/generate")
+	err := e.CheckRouteAccess(RoleTeam, "/key/delete")
 	assert.ErrorIs(t, err, ErrAccessDenied)
 }
 
+func TestRBACEngine_InternalUserGeneratesKeys(t *testing.T) {
+	e := NewRBACEngine()
+	assert.NoError(t, e.CheckRouteAccess(RoleInternalUser, "/key/generate"))
+	assert.ErrorIs(t, e.CheckRouteAccess(RoleEndUser, "/key/generate"), ErrAccessDenied)
+	assert.ErrorIs(t, e.CheckRouteAccess(RoleInternalUser, "/key/update"), ErrAccessDenied)
+}
+
 func TestRBACEngine_InternalUserAccessCompletions(t *testing.T) {
 	e := NewRBACEngine()
 	err := e.CheckRouteAccess(RoleInternalUser, "/v1/chat/completions")
@@ -31,10 +38,21 @@ func TestRBACEngine_EndUserDeniedCompletions(t *testing.T) {
 	assert.ErrorIs(t, err, ErrAccessDenied)
 }
 
-func TestRBACEngine_UnknownRouteAllowed(t *testing.T) {
+func TestRBACEngine_UnknownRouteDenied(t *testing.T) {
 	e := NewRBACEngine()
-	err := e.CheckRouteAccess(RoleEndUser, "/health")
-	assert.NoError(t, err)
+	assert.ErrorIs(t, e.CheckRouteAccess(RoleEndUser, "/health"), ErrAccessDenied)
+	assert.ErrorIs(t, e.CheckRouteAccess(RoleTeam, "/guardrails/list"), ErrAccessDenied)
+	assert.ErrorIs(t, e.CheckRouteAccess(RoleInternalUser, "/audit/"), ErrAccessDenied)
+}
+
+func TestRBACEngine_LongestPrefixWins(t *testing.T) {
+	e := NewRBACEngine()
+	assert.NoError(t, e.CheckRouteAccess(RoleInternalUser, "/mcp/sse"))
+	assert.NoError(t, e.CheckRouteAccess(RoleInternalUser, "/a2a/agent-1/"))
+	assert.NoError(t, e.CheckRouteAccess(RoleInternalUser, "/v1/models"))
+	assert.ErrorIs(t, e.CheckRouteAccess(RoleInternalUser, "/mcp_server/list"), ErrAccessDenied)
+	assert.ErrorIs(t, e.CheckRouteAccess(RoleInternalUser, "/key/info"), ErrAccessDenied)
+	assert.ErrorIs(t, e.CheckRouteAccess(RoleInternalUser, "/model/new"), ErrAccessDenied)
 }
 
 func TestRBACEngine_InvalidRole(t *testing.T) {
@@ -91,3 +109,53 @@ func TestParseRole_Invalid(t *testing.T) {
 	_, err := ParseRole("superadmin")
 	assert.ErrorIs(t, err, ErrInvalidRole)
 }
+
+func TestRole_Outranks(t *testing.T) {
+	assert.True(t, RoleProxyAdmin.Outranks(RoleTeam))
+	assert.True(t, RoleInternalUser.Outranks(""))
+	assert.False(t, RoleInternalUser.Outranks(RoleInternalUser))
+	assert.False(t, RoleEndUser.Outranks(RoleTeam))
+}
+
+func TestRBACEngine_RolePermissions(t *testing.T) {
+	e := NewRBACEngine()
+	e.SetRolePermissions(map[Role]RolePermission{
+		RoleInternalUser: {Routes: []string{"/v1/chat/completions", "/key/info*"}, Models: []string{"gpt-4o"}},
+	})
+
+	assert.NoError(t, e.CheckRouteAccess(RoleInternalUser, "/v1/chat/completions"))
+	assert.NoError(t, e.CheckRouteAccess(RoleInternalUser, "/key/info"))
+	assert.ErrorIs(t, e.CheckRouteAccess(RoleInternalUser, "/v1/embeddings"), ErrAccessDenied)
+	assert.NoError(t, e.CheckRouteAccess(RoleTeam, "/v1/embeddings"), "other roles keep the defaults")
+
+	assert.Equal(t, []string{"gpt-4o"}, e.RoleModels(RoleInternalUser))
+	assert.Nil(t, e.RoleModels(RoleTeam))
+}
+
+func TestRBACEngine_CheckAccessGrants(t *testing.T) {
+	e := NewRBACEngine()
+	grants := []Grant{
+		{Role: "billing_viewer", Permissions: []string{"GET /spend/*"}, TeamID: "team-a"},
+		{Role: "guardrail_admin", Permissions: []string{"/guardrails/*"}},
+	}
+
+	assert.NoError(t, e.CheckAccess(RoleInternalUser, grants, "GET", "/spend/logs", "", "team-a"))
+	assert.ErrorIs(t, e.CheckAccess(RoleInternalUser, grants, "GET", "/spend/logs", "", "team-b"), ErrAccessDenied)
+	assert.ErrorIs(t, e.CheckAccess(RoleInternalUser, grants, "GET", "/spend/logs", "", ""), ErrAccessDenied, "a team-scoped grant needs the request to target its team")
+	assert.ErrorIs(t, e.CheckAccess(RoleInternalUser, grants, "POST", "/spend/logs", "", "team-a"), ErrAccessDenied)
+	assert.NoError(t, e.CheckAccess(RoleInternalUser, grants, "DELETE", "/guardrails/g1", "org-1", ""))
+	assert.NoError(t, e.CheckAccess(RoleInternalUser, nil, "POST", "/v1/chat/completions", "", ""))
+
+	org := []Grant{{Role: "org_viewer", Permissions: []string{"GET /spend/*"}, OrgID: "org-1"}}
+	assert.NoError(t, e.CheckAccess(RoleInternalUser, org, "GET", "/spend/logs", "org-1", ""))
+	assert.ErrorIs(t, e.CheckAccess(RoleInternalUser, org, "GET", "/spend/logs", "org-2", ""), ErrAccessDenied)
+}
+
+func TestValidatePermission(t *testing.T) {
+	for _, p := range []string{"*", "/spend/*", "GET /team/info", "post /key/generate"} {
+		assert.NoError(t, ValidatePermission(p), p)
+	}
+	for _, p := range []string{"spend", "FETCH /spend", "/sp*end", ""} {
+		assert.Error(t, ValidatePermission(p), p)
+	}
+}
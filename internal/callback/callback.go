@@ -25,11 +25,27 @@ type LogData struct {
 	Cost                     float64
 	UserID                   string
 	TeamID                   string
+	OrgID                    string
+	EndUserID                string // the request's "user" field
 	CallType                 string
 	RequestTags              []string
 	CacheHit                 bool
 	CacheReadInputTokens     int
 	CacheCreationInputTokens int
+
+	// ModelGroup is the model name the client requested.
+	ModelGroup string
+
+	// DeploymentID is the router deployment that served the call.
+	// AttemptedDeployments lists every deployment tried, in order,
+	// including retries and fallbacks.
+	DeploymentID         string
+	AttemptedDeployments []string
+
+	// HedgeCancelled marks a hedged call that lost the race to the
+	// deployment that served the request and was cancelled. It is logged
+	// as a success so the prompt it consumed is reflected in spend.
+	HedgeCancelled bool
 }
 
 // CustomLogger is the interface for observability callbacks.
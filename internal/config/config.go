@@ -82,6 +82,37 @@ type TianjiParams struct {
 	Timeout    *int    `yaml:"timeout,omitempty"`
 	Region     string  `yaml:"region,omitempty"`
 
+	// AWS credentials for Bedrock. Without an access key, credentials come
+	// from the default AWS chain; AWSRoleName is the ARN of an IAM role to
+	// assume through STS with them.
+	AWSAccessKeyID     string `yaml:"aws_access_key_id,omitempty"`
+	AWSSecretAccessKey string `yaml:"aws_secret_access_key,omitempty"`
+	AWSSessionToken    string `yaml:"aws_session_token,omitempty"`
+	AWSRegionName      string `yaml:"aws_region_name,omitempty"`
+	AWSRoleName        string `yaml:"aws_role_name,omitempty"`
+	AWSSessionName     string `yaml:"aws_session_name,omitempty"`
+
+	// Vertex AI project and location, defaulting to VERTEX_PROJECT and
+	// VERTEX_LOCATION. VertexCredentials is a service account key, as JSON
+	// or a file path; without it Application Default Credentials are used.
+	VertexProject     string `yaml:"vertex_project,omitempty"`
+	VertexLocation    string `yaml:"vertex_location,omitempty"`
+	VertexCredentials string `yaml:"vertex_credentials,omitempty"`
+
+	// MaxParallelRequests caps concurrent in-flight requests to this deployment.
+	MaxParallelRequests *int `yaml:"max_parallel_requests,omitempty"`
+
+	// Upstream HTTP client. Timeout is in seconds; StreamTimeout aborts a
+	// stream that produces nothing for that many seconds.
+	StreamTimeout      *int   `yaml:"stream_timeout,omitempty"`
+	MaxIdleConnections *int   `yaml:"max_idle_connections,omitempty"`
+	DisableHTTP2       bool   `yaml:"disable_http2,omitempty"`
+	SSLVerify          *bool  `yaml:"ssl_verify,omitempty"`
+	SSLCACert          string `yaml:"ssl_ca_cert,omitempty"`
+	SSLClientCert      string `yaml:"ssl_client_cert,omitempty"`
+	SSLClientKey       string `yaml:"ssl_client_key,omitempty"`
+	ProxyURL           string `yaml:"proxy_url,omitempty"`
+
 	// AutoRouter configuration (for model prefix "auto_router/").
 	AutoRouterConfig         string `yaml:"auto_router_config,omitempty"`
 	AutoRouterConfigPath     string `yaml:"auto_router_config_path,omitempty"`
@@ -183,8 +214,24 @@ type GeneralSettings struct {
 	DatabaseConnectionTimeout   *float64 `yaml:"database_connection_timeout,omitempty"`
 
 	// Auth
-	CustomAuth     string `yaml:"custom_auth,omitempty"`
+	// CustomAuth is the URL of an external authorization service that
+	// decides every request not made with the master key.
+	CustomAuth         string              `yaml:"custom_auth,omitempty"`
+	CustomAuthSettings *CustomAuthSettings `yaml:"custom_auth_settings,omitempty"`
+	// AuthHeaderName is a header read for the API key before the standard ones.
 	AuthHeaderName string `yaml:"auth_header_name,omitempty"`
+	// UserAPIKeyCacheTTL is how long, in seconds, a validated key is cached.
+	UserAPIKeyCacheTTL *int `yaml:"user_api_key_cache_ttl,omitempty"`
+	// AuthCacheGracePeriod is how long, in seconds, cached keys are still
+	// served past their TTL while the database is unavailable.
+	AuthCacheGracePeriod *int `yaml:"auth_cache_grace_period,omitempty"`
+	// AllowedIPs are addresses and CIDR ranges allowed to call the proxy,
+	// in addition to those added with /ip/add. Empty with no stored
+	// entries allows every address.
+	AllowedIPs []string `yaml:"allowed_ips,omitempty"`
+	// TrustedProxies are the addresses and CIDR ranges of reverse proxies
+	// whose X-Forwarded-For and X-Real-IP headers are believed.
+	TrustedProxies []string `yaml:"trusted_proxies,omitempty"`
 
 	// Rate limiting
 	MaxParallelRequests                        *int `yaml:"max_parallel_requests,omitempty"`
@@ -224,10 +271,13 @@ type GeneralSettings struct {
 	UIAccessMode string `yaml:"ui_access_mode,omitempty"`
 
 	// JWT
-	TianjiJWTAuth map[string]any `yaml:"tianji_jwtauth,omitempty"`
+	TianjiJWTAuth *JWTAuthConfig `yaml:"tianji_jwtauth,omitempty"`
 
 	// RBAC
-	RolePermissions []map[string]any `yaml:"role_permissions,omitempty"`
+	RolePermissions []RolePermission `yaml:"role_permissions,omitempty"`
+
+	// SCIM provisioning
+	SCIM *SCIMConfig `yaml:"scim,omitempty"`
 
 	// Pass-through endpoints (also at top level)
 	PassThroughEndpoints []PassThroughEndpoint `yaml:"pass_through_endpoints,omitempty"`
@@ -253,6 +303,81 @@ type GeneralSettings struct {
 	Overflow map[string]any `yaml:",inline"`
 }
 
+// CustomAuthSettings tunes the custom_auth authorization service.
+type CustomAuthSettings struct {
+	// Mode "auto" falls back to the built-in key and JWT checks when the
+	// service denies a request; "on" (the default) makes its denial final.
+	Mode string `yaml:"mode,omitempty"`
+	// Timeout is the per-call timeout in seconds; defaults to 5.
+	Timeout *float64 `yaml:"timeout,omitempty"`
+	// CacheTTL is how long, in seconds, a decision is cached; 0 disables.
+	CacheTTL *int `yaml:"cache_ttl,omitempty"`
+	// FailOpen admits requests, with no identity, when the service is
+	// unreachable or fails. By default they are rejected with 503.
+	FailOpen bool `yaml:"fail_open,omitempty"`
+	// Headers are added to every call to the service.
+	Headers map[string]string `yaml:"headers,omitempty"`
+}
+
+// JWTAuthConfig configures authentication with JWTs issued by an identity
+// provider. Claim fields are dot-separated paths into the token's claims.
+type JWTAuthConfig struct {
+	PublicKeyURL string   `yaml:"public_key_url"`
+	Issuer       string   `yaml:"issuer,omitempty"`
+	Audiences    []string `yaml:"audiences,omitempty"`
+	PublicKeyTTL *int     `yaml:"public_key_ttl,omitempty"` // seconds
+
+	UserIDJWTField    string `yaml:"user_id_jwt_field,omitempty"`
+	UserEmailJWTField string `yaml:"user_email_jwt_field,omitempty"`
+	TeamIDJWTField    string `yaml:"team_id_jwt_field,omitempty"`
+	OrgIDJWTField     string `yaml:"org_id_jwt_field,omitempty"`
+	RolesJWTField     string `yaml:"roles_jwt_field,omitempty"`
+	ScopeJWTField     string `yaml:"scope_jwt_field,omitempty"`
+
+	// AdminJWTScope grants proxy_admin; defaults to "tianji_proxy_admin".
+	AdminJWTScope string `yaml:"admin_jwt_scope,omitempty"`
+	// ScopeMappings maps token scopes to roles.
+	ScopeMappings map[string]string `yaml:"scope_mappings,omitempty"`
+	// RoleMappings maps values of the roles claim to roles.
+	RoleMappings map[string]string `yaml:"role_mappings,omitempty"`
+
+	// UserIDUpsert creates a user row for unknown JWT users.
+	UserIDUpsert bool `yaml:"user_id_upsert,omitempty"`
+	// TeamIDUpsert creates a team row for unknown JWT teams.
+	TeamIDUpsert bool `yaml:"team_id_upsert,omitempty"`
+}
+
+// SCIMConfig enables the SCIM 2.0 provisioning API at /scim/v2, which
+// identity providers such as Okta and Entra ID call with Token.
+type SCIMConfig struct {
+	Token string `yaml:"token"`
+	// UpsertUser creates unknown users added to a group instead of
+	// rejecting the request.
+	UpsertUser bool `yaml:"upsert_user,omitempty"`
+	// GroupMappings maps IdP groups to teams and roles.
+	GroupMappings []SCIMGroupMapping `yaml:"group_mappings,omitempty"`
+}
+
+// SCIMGroupMapping applies to a SCIM group whose displayName or externalId
+// is Group. TeamID, when set, is the existing team the group manages
+// instead of a new one. Members are given user role Role, unless another
+// of their groups maps to a higher one, and team role TeamRole ("admin" or
+// "user", the default).
+type SCIMGroupMapping struct {
+	Group    string `yaml:"group"`
+	TeamID   string `yaml:"team_id,omitempty"`
+	Role     string `yaml:"role,omitempty"`
+	TeamRole string `yaml:"team_role,omitempty"`
+}
+
+// RolePermission restricts what a role may call. Routes match exactly or,
+// with a trailing "*", by prefix. Empty lists leave the role's defaults.
+type RolePermission struct {
+	Role   string   `yaml:"role"`
+	Routes []string `yaml:"routes,omitempty"`
+	Models []string `yaml:"models,omitempty"`
+}
+
 // RouterSettings holds load balancing configuration.
 type RouterSettings struct {
 	// Strategy
@@ -287,6 +412,9 @@ type RouterSettings struct {
 	RetryPolicy           map[string]any `yaml:"retry_policy,omitempty"`
 	ModelGroupRetryPolicy map[string]any `yaml:"model_group_retry_policy,omitempty"`
 
+	// Hedged requests, e.g. {"gpt-4o": {"delay_ms": 500}}
+	ModelGroupHedgePolicy map[string]any `yaml:"model_group_hedge_policy,omitempty"`
+
 	// Tag filtering
 	EnableTagFiltering   bool `yaml:"enable_tag_filtering"`
 	TagFilteringMatchAny bool `yaml:"tag_filtering_match_any"`
@@ -299,6 +427,11 @@ type RouterSettings struct {
 	DefaultTianjiParams        map[string]any `yaml:"default_tianji_params,omitempty"`
 	DefaultMaxParallelRequests *int           `yaml:"default_max_parallel_requests,omitempty"`
 
+	// Shared state across replicas (cooldowns, in-flight and usage counters)
+	RedisHost     string `yaml:"redis_host,omitempty"`
+	RedisPort     *int   `yaml:"redis_port,omitempty"`
+	RedisPassword string `yaml:"redis_password,omitempty"`
+
 	// Other
 	EnablePreCallChecks      bool `yaml:"enable_pre_call_checks"`
 	SetVerbose               bool `yaml:"set_verbose"`
@@ -45,3 +45,107 @@ func TestAccessControlIsAllowed(t *testing.T) {
 		t.Fatal("nil (public) should allow all")
 	}
 }
+
+func TestModelConfigFromJSON(t *testing.T) {
+	t.Setenv("TEST_DB_MODEL_KEY", "sk-from-env")
+
+	m, err := ModelConfigFromJSON("gpt-4o",
+		[]byte(`{"model":"openai/gpt-4o","api_key":"os.environ/TEST_DB_MODEL_KEY","tpm":1000,"max_parallel_requests":4,"organization":"org-1"}`),
+		[]byte(`{"mode":"chat","access_control":{"allowed_teams":["team-a"]},"tags":["prod"]}`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.TianjiParams.Model != "openai/gpt-4o" {
+		t.Fatalf("model = %q", m.TianjiParams.Model)
+	}
+	if m.TianjiParams.APIKey == nil || *m.TianjiParams.APIKey != "sk-from-env" {
+		t.Fatal("api_key should be resolved from the environment")
+	}
+	if m.TianjiParams.TPM == nil || *m.TianjiParams.TPM != 1000 {
+		t.Fatal("tpm not parsed")
+	}
+	if m.TianjiParams.MaxParallelRequests == nil || *m.TianjiParams.MaxParallelRequests != 4 {
+		t.Fatal("max_parallel_requests not parsed")
+	}
+	if m.TianjiParams.Overflow["organization"] != "org-1" {
+		t.Fatalf("overflow = %v", m.TianjiParams.Overflow)
+	}
+	if m.ModelInfo == nil || m.ModelInfo.Mode != "chat" {
+		t.Fatal("model_info not parsed")
+	}
+	if m.AccessControl == nil || len(m.AccessControl.AllowedTeams) != 1 {
+		t.Fatal("access_control not parsed")
+	}
+	if len(m.Tags) != 1 || m.Tags[0] != "prod" {
+		t.Fatalf("tags = %v", m.Tags)
+	}
+}
+
+func TestModelConfigFromJSON_AWSParams(t *testing.T) {
+	t.Setenv("TEST_AWS_SECRET", "secret-from-env")
+
+	m, err := ModelConfigFromJSON("claude",
+		[]byte(`{"model":"bedrock/anthropic.claude-3-haiku-20240307-v1:0","aws_access_key_id":"AKIDEXAMPLE","aws_secret_access_key":"os.environ/TEST_AWS_SECRET","aws_region_name":"eu-central-1","aws_role_name":"arn:aws:iam::123456789012:role/bedrock"}`),
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := m.TianjiParams
+	if tp.AWSAccessKeyID != "AKIDEXAMPLE" || tp.AWSRegionName != "eu-central-1" || tp.AWSRoleName != "arn:aws:iam::123456789012:role/bedrock" {
+		t.Fatalf("aws params = %+v", tp)
+	}
+	if tp.AWSSecretAccessKey != "secret-from-env" {
+		t.Fatal("aws_secret_access_key should be resolved from the environment")
+	}
+	if len(tp.Overflow) != 0 {
+		t.Fatalf("aws params leaked into overflow: %v", tp.Overflow)
+	}
+}
+
+func TestModelConfigFromJSON_RequiresModel(t *testing.T) {
+	if _, err := ModelConfigFromJSON("x", []byte(`{"api_key":"k"}`), nil); err == nil {
+		t.Fatal("expected error for missing model")
+	}
+}
+
+func TestPatchRouterSettings(t *testing.T) {
+	retries := 2
+	rs := &RouterSettings{
+		RoutingStrategy:  "simple-shuffle",
+		NumRetries:       &retries,
+		DefaultFallbacks: []string{"gpt-4o"},
+	}
+
+	patched, err := PatchRouterSettings(rs, map[string]any{
+		"routing_strategy":  "least-busy",
+		"allowed_fails":     float64(5),
+		"default_fallbacks": nil,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.RoutingStrategy != "least-busy" {
+		t.Fatalf("routing_strategy = %q", patched.RoutingStrategy)
+	}
+	if patched.AllowedFails == nil || *patched.AllowedFails != 5 {
+		t.Fatal("allowed_fails not applied")
+	}
+	if patched.NumRetries == nil || *patched.NumRetries != 2 {
+		t.Fatal("unpatched num_retries should be kept")
+	}
+	if len(patched.DefaultFallbacks) != 0 {
+		t.Fatal("null should reset default_fallbacks")
+	}
+	if rs.RoutingStrategy != "simple-shuffle" {
+		t.Fatal("original settings must not be modified")
+	}
+
+	if _, err := PatchRouterSettings(rs, map[string]any{"redis_host": "x"}); err == nil {
+		t.Fatal("expected error for startup-only key")
+	}
+	if _, err := PatchRouterSettings(rs, map[string]any{"num_retries": "many"}); err == nil {
+		t.Fatal("expected error for invalid value")
+	}
+}
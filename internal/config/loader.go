@@ -68,15 +68,51 @@ func resolveEnvVars(cfg *ProxyConfig) {
 	cfg.GeneralSettings.DatabaseURL = ResolveEnvVar(cfg.GeneralSettings.DatabaseURL)
 
 	for i := range cfg.ModelList {
-		m := &cfg.ModelList[i]
-		m.TianjiParams.APIKey = ResolveEnvVarPtr(m.TianjiParams.APIKey)
-		m.TianjiParams.APIBase = ResolveEnvVarPtr(m.TianjiParams.APIBase)
-		m.TianjiParams.APIVersion = ResolveEnvVarPtr(m.TianjiParams.APIVersion)
+		resolveTianjiParamsEnv(&cfg.ModelList[i].TianjiParams)
 	}
 
 	if cfg.TianjiSettings.CacheParams != nil {
 		cfg.TianjiSettings.CacheParams.Password = ResolveEnvVar(cfg.TianjiSettings.CacheParams.Password)
 	}
+
+	if cfg.RouterSettings != nil {
+		cfg.RouterSettings.RedisHost = ResolveEnvVar(cfg.RouterSettings.RedisHost)
+		cfg.RouterSettings.RedisPassword = ResolveEnvVar(cfg.RouterSettings.RedisPassword)
+	}
+
+	cfg.GeneralSettings.CustomAuth = ResolveEnvVar(cfg.GeneralSettings.CustomAuth)
+	if ca := cfg.GeneralSettings.CustomAuthSettings; ca != nil {
+		for k, v := range ca.Headers {
+			ca.Headers[k] = ResolveEnvVar(v)
+		}
+	}
+
+	if jwt := cfg.GeneralSettings.TianjiJWTAuth; jwt != nil {
+		jwt.PublicKeyURL = ResolveEnvVar(jwt.PublicKeyURL)
+		jwt.Issuer = ResolveEnvVar(jwt.Issuer)
+	}
+
+	if scim := cfg.GeneralSettings.SCIM; scim != nil {
+		scim.Token = ResolveEnvVar(scim.Token)
+	}
+}
+
+// resolveTianjiParamsEnv resolves the os.environ/ references of a model's
+// tianji_params.
+func resolveTianjiParamsEnv(tp *TianjiParams) {
+	tp.APIKey = ResolveEnvVarPtr(tp.APIKey)
+	tp.APIBase = ResolveEnvVarPtr(tp.APIBase)
+	tp.APIVersion = ResolveEnvVarPtr(tp.APIVersion)
+	tp.ProxyURL = ResolveEnvVar(tp.ProxyURL)
+	tp.AWSAccessKeyID = ResolveEnvVar(tp.AWSAccessKeyID)
+	tp.AWSSecretAccessKey = ResolveEnvVar(tp.AWSSecretAccessKey)
+	tp.AWSSessionToken = ResolveEnvVar(tp.AWSSessionToken)
+	tp.AWSRegionName = ResolveEnvVar(tp.AWSRegionName)
+	tp.AWSRoleName = ResolveEnvVar(tp.AWSRoleName)
+	tp.AWSSessionName = ResolveEnvVar(tp.AWSSessionName)
+	tp.VertexProject = ResolveEnvVar(tp.VertexProject)
+	tp.VertexLocation = ResolveEnvVar(tp.VertexLocation)
+	tp.VertexCredentials = ResolveEnvVar(tp.VertexCredentials)
 }
 
 // resolveSecrets resolves os.environ/ references via the secret manager.
@@ -120,6 +156,11 @@ func resolveSecrets(ctx context.Context, cfg *ProxyConfig, resolver SecretResolv
 		if m.TianjiParams.APIKey, err = resolvePtr(m.TianjiParams.APIKey); err != nil {
 			unresolved = append(unresolved, err.Error())
 		}
+		for _, v := range []*string{&m.TianjiParams.AWSAccessKeyID, &m.TianjiParams.AWSSecretAccessKey, &m.TianjiParams.AWSSessionToken, &m.TianjiParams.VertexCredentials} {
+			if *v, err = resolve(*v); err != nil {
+				unresolved = append(unresolved, err.Error())
+			}
+		}
 	}
 
 	if cfg.TianjiSettings.CacheParams != nil {
@@ -128,6 +169,18 @@ func resolveSecrets(ctx context.Context, cfg *ProxyConfig, resolver SecretResolv
 		}
 	}
 
+	if cfg.RouterSettings != nil {
+		if cfg.RouterSettings.RedisPassword, err = resolve(cfg.RouterSettings.RedisPassword); err != nil {
+			unresolved = append(unresolved, err.Error())
+		}
+	}
+
+	if scim := cfg.GeneralSettings.SCIM; scim != nil {
+		if scim.Token, err = resolve(scim.Token); err != nil {
+			unresolved = append(unresolved, err.Error())
+		}
+	}
+
 	if len(unresolved) > 0 {
 		return fmt.Errorf("unresolved secrets: %s", strings.Join(unresolved, "; "))
 	}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ModelConfigFromJSON builds a ModelConfig from a DB-managed model's JSON
+// columns (ProxyModelTable.tianji_params and model_info). The JSON uses the
+// same keys as model_list entries in proxy_config.yaml, so it is decoded with
+// the YAML tags; model_info may also carry access_control and tags.
+func ModelConfigFromJSON(modelName string, tianjiParams, modelInfo []byte) (ModelConfig, error) {
+	m := ModelConfig{ModelName: modelName}
+
+	if len(tianjiParams) > 0 {
+		if err := yaml.Unmarshal(tianjiParams, &m.TianjiParams); err != nil {
+			return ModelConfig{}, fmt.Errorf("tianji_params: %w", err)
+		}
+	}
+	if m.TianjiParams.Model == "" {
+		return ModelConfig{}, fmt.Errorf("tianji_params.model is required")
+	}
+	resolveTianjiParamsEnv(&m.TianjiParams)
+
+	if len(modelInfo) > 0 {
+		var info struct {
+			ModelInfo     `yaml:",inline"`
+			AccessControl *AccessControl `yaml:"access_control,omitempty"`
+			Tags          []string       `yaml:"tags,omitempty"`
+		}
+		if err := yaml.Unmarshal(modelInfo, &info); err != nil {
+			return ModelConfig{}, fmt.Errorf("model_info: %w", err)
+		}
+		m.ModelInfo = &info.ModelInfo
+		m.AccessControl = info.AccessControl
+		m.Tags = info.Tags
+	}
+	return m, nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// routerSettingsStartupOnly lists router_settings keys that are wired once at
+// startup (shared state client, provider spend tracker) and cannot be changed
+// on a running proxy.
+var routerSettingsStartupOnly = map[string]bool{
+	"redis_host":             true,
+	"redis_port":             true,
+	"redis_password":         true,
+	"provider_budget_config": true,
+}
+
+// PatchRouterSettings returns a copy of rs with the top-level keys of patch
+// replaced. Keys use the proxy_config.yaml names; a null value resets a key
+// to its default. rs may be nil. Startup-only keys are rejected.
+func PatchRouterSettings(rs *RouterSettings, patch map[string]any) (*RouterSettings, error) {
+	current, err := routerSettingsToMap(rs)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range patch {
+		if routerSettingsStartupOnly[k] {
+			return nil, fmt.Errorf("router_settings.%s cannot be changed at runtime", k)
+		}
+		if v == nil {
+			delete(current, k)
+			continue
+		}
+		current[k] = v
+	}
+
+	data, err := yaml.Marshal(current)
+	if err != nil {
+		return nil, fmt.Errorf("router_settings: %w", err)
+	}
+	var patched RouterSettings
+	if err := yaml.Unmarshal(data, &patched); err != nil {
+		return nil, fmt.Errorf("router_settings: %w", err)
+	}
+	return &patched, nil
+}
+
+// RuntimeRouterSettings returns the keys of rs that may be changed at runtime,
+// keyed like proxy_config.yaml. It is the form persisted to the database.
+func RuntimeRouterSettings(rs *RouterSettings) (map[string]any, error) {
+	m, err := routerSettingsToMap(rs)
+	if err != nil {
+		return nil, err
+	}
+	for k := range routerSettingsStartupOnly {
+		delete(m, k)
+	}
+	return m, nil
+}
+
+func routerSettingsToMap(rs *RouterSettings) (map[string]any, error) {
+	m := map[string]any{}
+	if rs == nil {
+		return m, nil
+	}
+	data, err := yaml.Marshal(rs)
+	if err != nil {
+		return nil, fmt.Errorf("router_settings: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("router_settings: %w", err)
+	}
+	return m, nil
+}
@@ -128,6 +128,38 @@ func (q *Queries) ListBudgets(ctx context.Context) ([]BudgetTable, error) {
 	return items, nil
 }
 
+const listExpiredBudgetDurations = `-- name: ListExpiredBudgetDurations :many
+SELECT budget_duration FROM "VerificationToken" WHERE budget_duration IS NOT NULL AND budget_reset_at <= NOW()
+UNION
+SELECT budget_duration FROM "UserTable" WHERE budget_duration IS NOT NULL AND budget_reset_at <= NOW()
+UNION
+SELECT budget_duration FROM "TeamTable" WHERE budget_duration IS NOT NULL AND budget_reset_at <= NOW()
+UNION
+SELECT budget_duration FROM "OrganizationTable" WHERE budget_duration IS NOT NULL AND budget_reset_at <= NOW()
+`
+
+// Lists the budget_duration of every key, user, team and organization
+// whose budget window has ended.
+func (q *Queries) ListExpiredBudgetDurations(ctx context.Context) ([]*string, error) {
+	rows, err := q.db.Query(ctx, listExpiredBudgetDurations)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*string
+	for rows.Next() {
+		var budget_duration *string
+		if err := rows.Scan(&budget_duration); err != nil {
+			return nil, err
+		}
+		items = append(items, budget_duration)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateBudget = `-- name: UpdateBudget :one
 UPDATE "BudgetTable"
 SET max_budget = COALESCE($2, max_budget),
@@ -128,6 +128,27 @@ func (q *Queries) GetEndUserByExternalID(ctx context.Context, endUserID string)
 	return i, err
 }
 
+const getEndUserSpend = `-- name: GetEndUserSpend :one
+SELECT user_id, alias, spend, allowed_model_region, default_model, tianji_budget_table, blocked, created_at, updated_at FROM "EndUserTable" WHERE user_id = $1
+`
+
+func (q *Queries) GetEndUserSpend(ctx context.Context, userID string) (EndUserTable, error) {
+	row := q.db.QueryRow(ctx, getEndUserSpend, userID)
+	var i EndUserTable
+	err := row.Scan(
+		&i.UserID,
+		&i.Alias,
+		&i.Spend,
+		&i.AllowedModelRegion,
+		&i.DefaultModel,
+		&i.TianjiBudgetTable,
+		&i.Blocked,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
 const listEndUsers = `-- name: ListEndUsers :many
 SELECT id, end_user_id, alias, allowed_model_region, default_model, budget, blocked, metadata, created_at, updated_at FROM "EndUserTable2" ORDER BY created_at DESC
 `
@@ -232,3 +253,20 @@ func (q *Queries) UpdateEndUser(ctx context.Context, arg UpdateEndUserParams) (E
 	)
 	return i, err
 }
+
+const updateEndUserSpend = `-- name: UpdateEndUserSpend :exec
+INSERT INTO "EndUserTable" (user_id, spend)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET spend = "EndUserTable".spend + EXCLUDED.spend, updated_at = NOW()
+`
+
+type UpdateEndUserSpendParams struct {
+	UserID string  `json:"user_id"`
+	Spend  float64 `json:"spend"`
+}
+
+func (q *Queries) UpdateEndUserSpend(ctx context.Context, arg UpdateEndUserSpendParams) error {
+	_, err := q.db.Exec(ctx, updateEndUserSpend, arg.UserID, arg.Spend)
+	return err
+}
@@ -125,6 +125,10 @@ type Store interface {
 	GetPromptVersions(ctx context.Context, name string) ([]PromptTemplateTable, error)
 	ListPromptTemplates(ctx context.Context) ([]PromptTemplateTable, error)
 
+	// Proxy config
+	GetProxyConfig(ctx context.Context, paramName string) (ProxyConfigTable, error)
+	UpsertProxyConfig(ctx context.Context, arg UpsertProxyConfigParams) (ProxyConfigTable, error)
+
 	// Proxy models
 	CreateProxyModel(ctx context.Context, arg CreateProxyModelParams) (ProxyModelTable, error)
 	DeleteProxyModel(ctx context.Context, modelID string) error
@@ -132,6 +136,16 @@ type Store interface {
 	ListProxyModels(ctx context.Context) ([]ProxyModelTable, error)
 	UpdateProxyModel(ctx context.Context, arg UpdateProxyModelParams) (ProxyModelTable, error)
 
+	// Roles
+	CreateRole(ctx context.Context, arg CreateRoleParams) (RoleTable, error)
+	CreateRoleAssignment(ctx context.Context, arg CreateRoleAssignmentParams) (RoleAssignmentTable, error)
+	DeleteRole(ctx context.Context, roleName string) error
+	DeleteRoleAssignment(ctx context.Context, id string) error
+	GetRole(ctx context.Context, roleName string) (RoleTable, error)
+	ListRoleAssignments(ctx context.Context, arg ListRoleAssignmentsParams) ([]RoleAssignmentTable, error)
+	ListRoles(ctx context.Context) ([]RoleTable, error)
+	UpdateRole(ctx context.Context, arg UpdateRoleParams) (RoleTable, error)
+
 	// Skills
 	CreateSkill(ctx context.Context, arg CreateSkillParams) (SkillsTable, error)
 	DeleteSkill(ctx context.Context, skillID string) error
@@ -184,6 +198,7 @@ type Store interface {
 	ListTeams(ctx context.Context) ([]TeamTable, error)
 	RemoveTeamMember(ctx context.Context, arg RemoveTeamMemberParams) error
 	RemoveTeamModel(ctx context.Context, arg RemoveTeamModelParams) error
+	SetTeamAllowedIPs(ctx context.Context, arg SetTeamAllowedIPsParams) error
 	SetTeamCallback(ctx context.Context, arg SetTeamCallbackParams) error
 	SetTeamPermissions(ctx context.Context, arg SetTeamPermissionsParams) error
 	UnblockTeam(ctx context.Context, teamID string) error
@@ -207,8 +222,11 @@ type Store interface {
 	DeleteVerificationToken(ctx context.Context, token string) error
 	GetVerificationToken(ctx context.Context, token string) (VerificationToken, error)
 	GetVerificationTokenBatch(ctx context.Context, dollar_1 []string) ([]VerificationToken, error)
+	ListVerificationTokenChildren(ctx context.Context, parentToken *string) ([]VerificationToken, error)
+	ListVerificationTokensByTeam(ctx context.Context, teamID *string) ([]VerificationToken, error)
 	ListVerificationTokensFiltered(ctx context.Context, arg ListVerificationTokensFilteredParams) ([]VerificationToken, error)
 	RegenerateVerificationToken(ctx context.Context, arg RegenerateVerificationTokenParams) (VerificationToken, error)
+	SetVerificationTokenAllowedIPs(ctx context.Context, arg SetVerificationTokenAllowedIPsParams) error
 	UnblockVerificationToken(ctx context.Context, token string) error
 	UpdateVerificationToken(ctx context.Context, arg UpdateVerificationTokenParams) (VerificationToken, error)
 }
@@ -372,6 +372,13 @@ type PromptTemplateTable struct {
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
+type ProxyConfigTable struct {
+	ParamName  string             `json:"param_name"`
+	ParamValue []byte             `json:"param_value"`
+	UpdatedAt  pgtype.Timestamptz `json:"updated_at"`
+	UpdatedBy  string             `json:"updated_by"`
+}
+
 type ProxyModelTable struct {
 	ModelID      string             `json:"model_id"`
 	ModelName    string             `json:"model_name"`
@@ -383,6 +390,26 @@ type ProxyModelTable struct {
 	UpdatedBy    string             `json:"updated_by"`
 }
 
+type RoleAssignmentTable struct {
+	ID             string             `json:"id"`
+	RoleName       string             `json:"role_name"`
+	UserID         string             `json:"user_id"`
+	OrganizationID *string            `json:"organization_id"`
+	TeamID         *string            `json:"team_id"`
+	CreatedAt      pgtype.Timestamptz `json:"created_at"`
+	CreatedBy      string             `json:"created_by"`
+}
+
+type RoleTable struct {
+	RoleName    string             `json:"role_name"`
+	Description string             `json:"description"`
+	Permissions []string           `json:"permissions"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	CreatedBy   string             `json:"created_by"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+	UpdatedBy   string             `json:"updated_by"`
+}
+
 type SkillsTable struct {
 	SkillID       string             `json:"skill_id"`
 	DisplayTitle  string             `json:"display_title"`
@@ -521,4 +548,5 @@ type VerificationToken struct {
 	CreatedBy            *string            `json:"created_by"`
 	UpdatedAt            pgtype.Timestamptz `json:"updated_at"`
 	UpdatedBy            *string            `json:"updated_by"`
+	ParentToken          *string            `json:"parent_token"`
 }
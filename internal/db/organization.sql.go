@@ -7,6 +7,8 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const countMembersPerOrganization = `-- name: CountMembersPerOrganization :many
@@ -191,6 +193,24 @@ func (q *Queries) ListOrganizations(ctx context.Context) ([]OrganizationTable, e
 	return items, nil
 }
 
+const resetBudgetForExpiredOrganizations = `-- name: ResetBudgetForExpiredOrganizations :exec
+UPDATE "OrganizationTable"
+SET spend = 0, budget_reset_at = $1, updated_at = NOW()
+WHERE budget_duration = $2 AND budget_reset_at <= NOW()
+`
+
+type ResetBudgetForExpiredOrganizationsParams struct {
+	BudgetResetAt  pgtype.Timestamptz `json:"budget_reset_at"`
+	BudgetDuration *string            `json:"budget_duration"`
+}
+
+// Resets rows with the given budget_duration whose budget window has ended
+// and starts their next window, which ends at budget_reset_at.
+func (q *Queries) ResetBudgetForExpiredOrganizations(ctx context.Context, arg ResetBudgetForExpiredOrganizationsParams) error {
+	_, err := q.db.Exec(ctx, resetBudgetForExpiredOrganizations, arg.BudgetResetAt, arg.BudgetDuration)
+	return err
+}
+
 const updateOrganization = `-- name: UpdateOrganization :one
 UPDATE "OrganizationTable"
 SET organization_alias = COALESCE($2, organization_alias),
@@ -228,3 +248,19 @@ func (q *Queries) UpdateOrganization(ctx context.Context, arg UpdateOrganization
 	)
 	return i, err
 }
+
+const updateOrganizationSpend = `-- name: UpdateOrganizationSpend :exec
+UPDATE "OrganizationTable"
+SET spend = spend + $2, updated_at = NOW()
+WHERE organization_id = $1
+`
+
+type UpdateOrganizationSpendParams struct {
+	OrganizationID string  `json:"organization_id"`
+	Spend          float64 `json:"spend"`
+}
+
+func (q *Queries) UpdateOrganizationSpend(ctx context.Context, arg UpdateOrganizationSpendParams) error {
+	_, err := q.db.Exec(ctx, updateOrganizationSpend, arg.OrganizationID, arg.Spend)
+	return err
+}
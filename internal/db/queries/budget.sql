@@ -22,3 +22,14 @@ RETURNING *;
 
 -- name: DeleteBudget :exec
 DELETE FROM "BudgetTable" WHERE budget_id = $1;
+
+-- name: ListExpiredBudgetDurations :many
+-- Lists the budget_duration of every key, user, team and organization
+-- whose budget window has ended.
+SELECT budget_duration FROM "VerificationToken" WHERE budget_duration IS NOT NULL AND budget_reset_at <= NOW()
+UNION
+SELECT budget_duration FROM "UserTable" WHERE budget_duration IS NOT NULL AND budget_reset_at <= NOW()
+UNION
+SELECT budget_duration FROM "TeamTable" WHERE budget_duration IS NOT NULL AND budget_reset_at <= NOW()
+UNION
+SELECT budget_duration FROM "OrganizationTable" WHERE budget_duration IS NOT NULL AND budget_reset_at <= NOW();
@@ -35,3 +35,12 @@ RETURNING *;
 UPDATE "EndUserTable2" SET blocked = FALSE, updated_at = NOW()
 WHERE id = $1
 RETURNING *;
+
+-- name: GetEndUserSpend :one
+SELECT * FROM "EndUserTable" WHERE user_id = $1;
+
+-- name: UpdateEndUserSpend :exec
+INSERT INTO "EndUserTable" (user_id, spend)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET spend = "EndUserTable".spend + EXCLUDED.spend, updated_at = NOW();
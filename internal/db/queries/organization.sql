@@ -30,3 +30,15 @@ GROUP BY organization_id;
 SELECT organization_id, COUNT(*)::bigint AS member_count
 FROM "OrganizationMembership"
 GROUP BY organization_id;
+
+-- name: UpdateOrganizationSpend :exec
+UPDATE "OrganizationTable"
+SET spend = spend + $2, updated_at = NOW()
+WHERE organization_id = $1;
+
+-- name: ResetBudgetForExpiredOrganizations :exec
+-- Resets rows with the given budget_duration whose budget window has ended
+-- and starts their next window, which ends at budget_reset_at.
+UPDATE "OrganizationTable"
+SET spend = 0, budget_reset_at = sqlc.arg(budget_reset_at), updated_at = NOW()
+WHERE budget_duration = sqlc.arg(budget_duration) AND budget_reset_at <= NOW();
@@ -0,0 +1,15 @@
+-- name: GetProxyConfig :one
+SELECT * FROM "ProxyConfigTable"
+WHERE param_name = $1;
+
+-- name: UpsertProxyConfig :one
+INSERT INTO "ProxyConfigTable" (
+    param_name, param_value, updated_by
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (param_name) DO UPDATE SET
+    param_value = EXCLUDED.param_value,
+    updated_at  = NOW(),
+    updated_by  = EXCLUDED.updated_by
+RETURNING *;
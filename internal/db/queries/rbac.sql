@@ -0,0 +1,52 @@
+-- name: CreateRole :one
+INSERT INTO "RoleTable" (
+    role_name, description, permissions, created_by, updated_by
+) VALUES (
+    $1, $2, $3, $4, $4
+)
+RETURNING *;
+
+-- name: GetRole :one
+SELECT * FROM "RoleTable"
+WHERE role_name = $1;
+
+-- name: ListRoles :many
+SELECT * FROM "RoleTable"
+ORDER BY role_name ASC;
+
+-- name: UpdateRole :one
+UPDATE "RoleTable"
+SET description = COALESCE(sqlc.narg(description), description),
+    permissions = COALESCE(sqlc.narg(permissions), permissions),
+    updated_at  = NOW(),
+    updated_by  = sqlc.arg(updated_by)
+WHERE role_name = sqlc.arg(role_name)
+RETURNING *;
+
+-- name: DeleteRole :exec
+DELETE FROM "RoleTable"
+WHERE role_name = $1;
+
+-- name: CreateRoleAssignment :one
+INSERT INTO "RoleAssignmentTable" (
+    role_name, user_id, organization_id, team_id, created_by
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: DeleteRoleAssignment :exec
+DELETE FROM "RoleAssignmentTable"
+WHERE id = $1;
+
+-- name: ListRoleAssignments :many
+SELECT * FROM "RoleAssignmentTable"
+WHERE (sqlc.arg(role_name)::text = '' OR role_name = sqlc.arg(role_name))
+  AND (sqlc.arg(user_id)::text = '' OR user_id = sqlc.arg(user_id))
+ORDER BY created_at ASC;
+
+-- name: ListUserRoleGrants :many
+SELECT a.role_name, r.permissions, a.organization_id, a.team_id
+FROM "RoleAssignmentTable" a
+JOIN "RoleTable" r ON r.role_name = a.role_name
+WHERE a.user_id = $1;
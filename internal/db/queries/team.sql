@@ -5,8 +5,8 @@ SELECT * FROM "TeamTable" WHERE team_id = $1;
 SELECT * FROM "TeamTable" ORDER BY created_at DESC;
 
 -- name: CreateTeam :one
-INSERT INTO "TeamTable" (team_id, team_alias, organization_id, admins, members, max_budget, models, tpm_limit, rpm_limit, budget_duration, created_by)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+INSERT INTO "TeamTable" (team_id, team_alias, organization_id, admins, members, max_budget, models, tpm_limit, rpm_limit, budget_duration, created_by, budget_reset_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 RETURNING *;
 
 -- name: UpdateTeam :one
@@ -82,6 +82,11 @@ UPDATE "TeamTable"
 SET metadata = jsonb_set(COALESCE(metadata, '{}'), '{permissions}', $2::jsonb), updated_at = NOW()
 WHERE team_id = $1;
 
+-- name: SetTeamAllowedIPs :exec
+UPDATE "TeamTable"
+SET metadata = jsonb_set(COALESCE(metadata, '{}'), '{allowed_ips}', sqlc.arg(allowed_ips)::jsonb), updated_at = NOW()
+WHERE team_id = sqlc.arg(team_id);
+
 -- name: SetTeamCallback :exec
 UPDATE "TeamTable"
 SET metadata = jsonb_set(COALESCE(metadata, '{}'), '{callback_settings}', $2::jsonb), updated_at = NOW()
@@ -104,3 +109,15 @@ WHERE team_id = ANY(sqlc.arg(team_ids)::text[]);
 SELECT * FROM "TeamTable"
 WHERE organization_id = $1
 ORDER BY created_at DESC;
+
+-- name: UpdateTeamSpend :exec
+UPDATE "TeamTable"
+SET spend = spend + $2, updated_at = NOW()
+WHERE team_id = $1;
+
+-- name: ResetBudgetForExpiredTeams :exec
+-- Resets rows with the given budget_duration whose budget window has ended
+-- and starts their next window, which ends at budget_reset_at.
+UPDATE "TeamTable"
+SET spend = 0, budget_reset_at = sqlc.arg(budget_reset_at), updated_at = NOW()
+WHERE budget_duration = sqlc.arg(budget_duration) AND budget_reset_at <= NOW();
@@ -5,8 +5,8 @@ SELECT * FROM "UserTable" WHERE user_id = $1;
 SELECT * FROM "UserTable" ORDER BY created_at DESC;
 
 -- name: CreateUser :one
-INSERT INTO "UserTable" (user_id, user_alias, user_email, user_role, teams, max_budget, models, tpm_limit, rpm_limit, budget_duration, created_by)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+INSERT INTO "UserTable" (user_id, user_alias, user_email, user_role, teams, max_budget, models, tpm_limit, rpm_limit, budget_duration, created_by, budget_reset_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 RETURNING *;
 
 -- name: UpdateUser :one
@@ -33,3 +33,15 @@ WHERE user_id = $1;
 
 -- name: DeleteUser :exec
 DELETE FROM "UserTable" WHERE user_id = $1;
+
+-- name: UpdateUserSpend :exec
+UPDATE "UserTable"
+SET spend = spend + $2, updated_at = NOW()
+WHERE user_id = $1;
+
+-- name: ResetBudgetForExpiredUsers :exec
+-- Resets rows with the given budget_duration whose budget window has ended
+-- and starts their next window, which ends at budget_reset_at.
+UPDATE "UserTable"
+SET spend = 0, budget_reset_at = sqlc.arg(budget_reset_at), updated_at = NOW()
+WHERE budget_duration = sqlc.arg(budget_duration) AND budget_reset_at <= NOW();
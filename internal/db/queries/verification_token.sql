@@ -12,23 +12,51 @@ SELECT * FROM "VerificationToken"
 WHERE team_id = $1
 ORDER BY created_at DESC;
 
+-- name: ListVerificationTokenChildren :many
+SELECT * FROM "VerificationToken"
+WHERE parent_token = $1
+ORDER BY created_at DESC;
+
 -- name: CreateVerificationToken :one
 INSERT INTO "VerificationToken" (
     token, key_name, key_alias, spend, max_budget, expires,
     models, user_id, team_id, organization_id,
     permissions, metadata, tpm_limit, rpm_limit,
-    budget_duration, budget_id, created_by
+    budget_duration, budget_id, created_by, parent_token, budget_reset_at
 ) VALUES (
     $1, $2, $3, $4, $5, $6,
     $7, $8, $9, $10,
     $11, $12, $13, $14,
-    $15, $16, $17
+    $15, $16, $17, $18, $19
 )
 RETURNING *;
 
 -- name: UpdateVerificationTokenSpend :exec
+-- Adds spend to the key and, when model is set, to its model_spend entry.
+-- A delegated key's spend also rolls up to every parent key above it.
+WITH RECURSIVE chain AS (
+    SELECT token, parent_token FROM "VerificationToken" WHERE token = sqlc.arg(token)
+    UNION ALL
+    SELECT v.token, v.parent_token FROM "VerificationToken" v
+    JOIN chain c ON v.token = c.parent_token
+)
+UPDATE "VerificationToken"
+SET spend = spend + sqlc.arg(spend)::float8,
+    model_spend = CASE WHEN sqlc.arg(model)::text = '' THEN model_spend
+        ELSE jsonb_set(model_spend, ARRAY[sqlc.arg(model)::text],
+            to_jsonb(COALESCE((model_spend->>sqlc.arg(model)::text)::float8, 0) + sqlc.arg(spend)::float8))
+        END,
+    updated_at = NOW()
+WHERE token IN (SELECT token FROM chain);
+
+-- name: SetVerificationTokenAllowedIPs :exec
+UPDATE "VerificationToken"
+SET metadata = jsonb_set(COALESCE(metadata, '{}'), '{allowed_ips}', sqlc.arg(allowed_ips)::jsonb), updated_at = NOW()
+WHERE token = sqlc.arg(token);
+
+-- name: SetVerificationTokenSoftBudgetCooldown :exec
 UPDATE "VerificationToken"
-SET spend = spend + $2, updated_at = NOW()
+SET soft_budget_cooldown = TRUE, updated_at = NOW()
 WHERE token = $1;
 
 -- name: BlockVerificationToken :exec
@@ -56,14 +84,18 @@ SET
     tpm_limit = COALESCE($7, tpm_limit),
     rpm_limit = COALESCE($8, rpm_limit),
     budget_duration = COALESCE($9, budget_duration),
+    budget_reset_at = COALESCE($10, budget_reset_at),
     updated_at = NOW()
 WHERE token = $1
 RETURNING *;
 
 -- name: ResetBudgetForExpiredTokens :exec
+-- Resets keys with the given budget_duration whose budget window has ended
+-- and starts their next window, which ends at budget_reset_at.
 UPDATE "VerificationToken"
-SET spend = 0, budget_reset_at = NOW() + (budget_duration || ' seconds')::INTERVAL, updated_at = NOW()
-WHERE budget_reset_at IS NOT NULL AND budget_reset_at <= NOW();
+SET spend = 0, model_spend = '{}', soft_budget_cooldown = FALSE,
+    budget_reset_at = sqlc.arg(budget_reset_at), updated_at = NOW()
+WHERE budget_duration = sqlc.arg(budget_duration) AND budget_reset_at <= NOW();
 
 -- name: ResetVerificationTokenSpend :exec
 UPDATE "VerificationToken"
@@ -127,6 +159,7 @@ SET
     tpm_limit = COALESCE(sqlc.narg(new_tpm_limit), tpm_limit),
     rpm_limit = COALESCE(sqlc.narg(new_rpm_limit), rpm_limit),
     budget_duration = COALESCE(sqlc.narg(new_budget_duration), budget_duration),
+    budget_reset_at = COALESCE(sqlc.narg(new_budget_reset_at), budget_reset_at),
     updated_at = NOW()
 WHERE token = sqlc.arg(old_token)
 RETURNING *;
@@ -0,0 +1,10 @@
+-- 014_proxy_config.sql
+-- ProxyConfigTable — runtime overrides of proxy_config.yaml sections
+-- (e.g. router_settings changed via PATCH /router/settings).
+
+CREATE TABLE IF NOT EXISTS "ProxyConfigTable" (
+    param_name  TEXT PRIMARY KEY,
+    param_value JSONB NOT NULL DEFAULT '{}',
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_by  TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "RoleAssignmentTable";
+DROP TABLE IF EXISTS "RoleTable";
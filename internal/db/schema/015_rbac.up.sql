@@ -0,0 +1,27 @@
+-- 015_rbac.sql
+-- RoleTable — custom RBAC roles and the routes they may call.
+-- RoleAssignmentTable — custom roles granted to users, optionally only
+-- within one organization or team.
+
+CREATE TABLE IF NOT EXISTS "RoleTable" (
+    role_name   TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT '',
+    permissions TEXT[] NOT NULL DEFAULT '{}',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_by  TEXT NOT NULL DEFAULT '',
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_by  TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS "RoleAssignmentTable" (
+    id              TEXT PRIMARY KEY DEFAULT gen_random_uuid()::TEXT,
+    role_name       TEXT NOT NULL REFERENCES "RoleTable"(role_name) ON DELETE CASCADE,
+    user_id         TEXT NOT NULL,
+    organization_id TEXT,
+    team_id         TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_by      TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_role_assignment_user ON "RoleAssignmentTable"(user_id);
+CREATE INDEX IF NOT EXISTS idx_role_assignment_role ON "RoleAssignmentTable"(role_name);
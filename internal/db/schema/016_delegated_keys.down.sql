@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_verification_token_parent;
+ALTER TABLE "VerificationToken" DROP COLUMN IF EXISTS parent_token;
@@ -0,0 +1,9 @@
+-- 016_delegated_keys.sql
+-- parent_token links a delegated child key to the key it was minted from.
+-- Deleting the parent deletes its children; regenerating it follows.
+
+ALTER TABLE "VerificationToken"
+    ADD COLUMN IF NOT EXISTS parent_token TEXT
+        REFERENCES "VerificationToken"(token) ON UPDATE CASCADE ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_verification_token_parent ON "VerificationToken" (parent_token);
@@ -318,7 +318,7 @@ func (q *Queries) GetGlobalSpendReportByKey(ctx context.Context, arg GetGlobalSp
 }
 
 const getVerificationTokenBatch = `-- name: GetVerificationTokenBatch :many
-SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by FROM "VerificationToken"
+SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by, parent_token FROM "VerificationToken"
 WHERE token = ANY($1::text[])
 `
 
@@ -364,6 +364,7 @@ func (q *Queries) GetVerificationTokenBatch(ctx context.Context, dollar_1 []stri
 			&i.CreatedBy,
 			&i.UpdatedAt,
 			&i.UpdatedBy,
+			&i.ParentToken,
 		); err != nil {
 			return nil, err
 		}
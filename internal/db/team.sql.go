@@ -7,6 +7,8 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const addTeamMember = `-- name: AddTeamMember :exec
@@ -53,23 +55,24 @@ func (q *Queries) BlockTeam(ctx context.Context, teamID string) error {
 }
 
 const createTeam = `-- name: CreateTeam :one
-INSERT INTO "TeamTable" (team_id, team_alias, organization_id, admins, members, max_budget, models, tpm_limit, rpm_limit, budget_duration, created_by)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+INSERT INTO "TeamTable" (team_id, team_alias, organization_id, admins, members, max_budget, models, tpm_limit, rpm_limit, budget_duration, created_by, budget_reset_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 RETURNING team_id, team_alias, organization_id, admins, members, members_with_roles, metadata, max_budget, spend, models, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, budget_id, created_at, created_by, updated_at, updated_by
 `
 
 type CreateTeamParams struct {
-	TeamID         string   `json:"team_id"`
-	TeamAlias      *string  `json:"team_alias"`
-	OrganizationID *string  `json:"organization_id"`
-	Admins         []string `json:"admins"`
-	Members        []string `json:"members"`
-	MaxBudget      *float64 `json:"max_budget"`
-	Models         []string `json:"models"`
-	TpmLimit       *int64   `json:"tpm_limit"`
-	RpmLimit       *int64   `json:"rpm_limit"`
-	BudgetDuration *string  `json:"budget_duration"`
-	CreatedBy      string   `json:"created_by"`
+	TeamID         string             `json:"team_id"`
+	TeamAlias      *string            `json:"team_alias"`
+	OrganizationID *string            `json:"organization_id"`
+	Admins         []string           `json:"admins"`
+	Members        []string           `json:"members"`
+	MaxBudget      *float64           `json:"max_budget"`
+	Models         []string           `json:"models"`
+	TpmLimit       *int64             `json:"tpm_limit"`
+	RpmLimit       *int64             `json:"rpm_limit"`
+	BudgetDuration *string            `json:"budget_duration"`
+	CreatedBy      string             `json:"created_by"`
+	BudgetResetAt  pgtype.Timestamptz `json:"budget_reset_at"`
 }
 
 func (q *Queries) CreateTeam(ctx context.Context, arg CreateTeamParams) (TeamTable, error) {
@@ -85,6 +88,7 @@ func (q *Queries) CreateTeam(ctx context.Context, arg CreateTeamParams) (TeamTab
 		arg.RpmLimit,
 		arg.BudgetDuration,
 		arg.CreatedBy,
+		arg.BudgetResetAt,
 	)
 	var i TeamTable
 	err := row.Scan(
@@ -410,6 +414,24 @@ func (q *Queries) RemoveTeamModel(ctx context.Context, arg RemoveTeamModelParams
 	return err
 }
 
+const resetBudgetForExpiredTeams = `-- name: ResetBudgetForExpiredTeams :exec
+UPDATE "TeamTable"
+SET spend = 0, budget_reset_at = $1, updated_at = NOW()
+WHERE budget_duration = $2 AND budget_reset_at <= NOW()
+`
+
+type ResetBudgetForExpiredTeamsParams struct {
+	BudgetResetAt  pgtype.Timestamptz `json:"budget_reset_at"`
+	BudgetDuration *string            `json:"budget_duration"`
+}
+
+// Resets rows with the given budget_duration whose budget window has ended
+// and starts their next window, which ends at budget_reset_at.
+func (q *Queries) ResetBudgetForExpiredTeams(ctx context.Context, arg ResetBudgetForExpiredTeamsParams) error {
+	_, err := q.db.Exec(ctx, resetBudgetForExpiredTeams, arg.BudgetResetAt, arg.BudgetDuration)
+	return err
+}
+
 const resetTeamSpend = `-- name: ResetTeamSpend :exec
 UPDATE "TeamTable"
 SET spend = 0, updated_at = NOW()
@@ -421,6 +443,22 @@ func (q *Queries) ResetTeamSpend(ctx context.Context, teamID string) error {
 	return err
 }
 
+const setTeamAllowedIPs = `-- name: SetTeamAllowedIPs :exec
+UPDATE "TeamTable"
+SET metadata = jsonb_set(COALESCE(metadata, '{}'), '{allowed_ips}', $1::jsonb), updated_at = NOW()
+WHERE team_id = $2
+`
+
+type SetTeamAllowedIPsParams struct {
+	AllowedIps []byte `json:"allowed_ips"`
+	TeamID     string `json:"team_id"`
+}
+
+func (q *Queries) SetTeamAllowedIPs(ctx context.Context, arg SetTeamAllowedIPsParams) error {
+	_, err := q.db.Exec(ctx, setTeamAllowedIPs, arg.AllowedIps, arg.TeamID)
+	return err
+}
+
 const setTeamCallback = `-- name: SetTeamCallback :exec
 UPDATE "TeamTable"
 SET metadata = jsonb_set(COALESCE(metadata, '{}'), '{callback_settings}', $2::jsonb), updated_at = NOW()
@@ -557,3 +595,19 @@ func (q *Queries) UpdateTeamMetadata(ctx context.Context, arg UpdateTeamMetadata
 	_, err := q.db.Exec(ctx, updateTeamMetadata, arg.TeamID, arg.Metadata)
 	return err
 }
+
+const updateTeamSpend = `-- name: UpdateTeamSpend :exec
+UPDATE "TeamTable"
+SET spend = spend + $2, updated_at = NOW()
+WHERE team_id = $1
+`
+
+type UpdateTeamSpendParams struct {
+	TeamID string  `json:"team_id"`
+	Spend  float64 `json:"spend"`
+}
+
+func (q *Queries) UpdateTeamSpend(ctx context.Context, arg UpdateTeamSpendParams) error {
+	_, err := q.db.Exec(ctx, updateTeamSpend, arg.TeamID, arg.Spend)
+	return err
+}
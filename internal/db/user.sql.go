@@ -7,26 +7,29 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO "UserTable" (user_id, user_alias, user_email, user_role, teams, max_budget, models, tpm_limit, rpm_limit, budget_duration, created_by)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+INSERT INTO "UserTable" (user_id, user_alias, user_email, user_role, teams, max_budget, models, tpm_limit, rpm_limit, budget_duration, created_by, budget_reset_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 RETURNING user_id, user_alias, user_email, user_role, teams, max_budget, spend, models, metadata, tpm_limit, rpm_limit, budget_duration, budget_reset_at, budget_id, created_at, created_by, updated_at, updated_by
 `
 
 type CreateUserParams struct {
-	UserID         string   `json:"user_id"`
-	UserAlias      *string  `json:"user_alias"`
-	UserEmail      *string  `json:"user_email"`
-	UserRole       string   `json:"user_role"`
-	Teams          []string `json:"teams"`
-	MaxBudget      *float64 `json:"max_budget"`
-	Models         []string `json:"models"`
-	TpmLimit       *int64   `json:"tpm_limit"`
-	RpmLimit       *int64   `json:"rpm_limit"`
-	BudgetDuration *string  `json:"budget_duration"`
-	CreatedBy      string   `json:"created_by"`
+	UserID         string             `json:"user_id"`
+	UserAlias      *string            `json:"user_alias"`
+	UserEmail      *string            `json:"user_email"`
+	UserRole       string             `json:"user_role"`
+	Teams          []string           `json:"teams"`
+	MaxBudget      *float64           `json:"max_budget"`
+	Models         []string           `json:"models"`
+	TpmLimit       *int64             `json:"tpm_limit"`
+	RpmLimit       *int64             `json:"rpm_limit"`
+	BudgetDuration *string            `json:"budget_duration"`
+	CreatedBy      string             `json:"created_by"`
+	BudgetResetAt  pgtype.Timestamptz `json:"budget_reset_at"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (UserTable, error) {
@@ -42,6 +45,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (UserTab
 		arg.RpmLimit,
 		arg.BudgetDuration,
 		arg.CreatedBy,
+		arg.BudgetResetAt,
 	)
 	var i UserTable
 	err := row.Scan(
@@ -179,6 +183,24 @@ func (q *Queries) ListUsers(ctx context.Context) ([]UserTable, error) {
 	return items, nil
 }
 
+const resetBudgetForExpiredUsers = `-- name: ResetBudgetForExpiredUsers :exec
+UPDATE "UserTable"
+SET spend = 0, budget_reset_at = $1, updated_at = NOW()
+WHERE budget_duration = $2 AND budget_reset_at <= NOW()
+`
+
+type ResetBudgetForExpiredUsersParams struct {
+	BudgetResetAt  pgtype.Timestamptz `json:"budget_reset_at"`
+	BudgetDuration *string            `json:"budget_duration"`
+}
+
+// Resets rows with the given budget_duration whose budget window has ended
+// and starts their next window, which ends at budget_reset_at.
+func (q *Queries) ResetBudgetForExpiredUsers(ctx context.Context, arg ResetBudgetForExpiredUsersParams) error {
+	_, err := q.db.Exec(ctx, resetBudgetForExpiredUsers, arg.BudgetResetAt, arg.BudgetDuration)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE "UserTable"
 SET user_alias = COALESCE($2, user_alias),
@@ -257,3 +279,19 @@ func (q *Queries) UpdateUserMetadata(ctx context.Context, arg UpdateUserMetadata
 	_, err := q.db.Exec(ctx, updateUserMetadata, arg.UserID, arg.Metadata)
 	return err
 }
+
+const updateUserSpend = `-- name: UpdateUserSpend :exec
+UPDATE "UserTable"
+SET spend = spend + $2, updated_at = NOW()
+WHERE user_id = $1
+`
+
+type UpdateUserSpendParams struct {
+	UserID string  `json:"user_id"`
+	Spend  float64 `json:"spend"`
+}
+
+func (q *Queries) UpdateUserSpend(ctx context.Context, arg UpdateUserSpendParams) error {
+	_, err := q.db.Exec(ctx, updateUserSpend, arg.UserID, arg.Spend)
+	return err
+}
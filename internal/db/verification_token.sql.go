@@ -81,14 +81,14 @@ INSERT INTO "VerificationToken" (
     token, key_name, key_alias, spend, max_budget, expires,
     models, user_id, team_id, organization_id,
     permissions, metadata, tpm_limit, rpm_limit,
-    budget_duration, budget_id, created_by
+    budget_duration, budget_id, created_by, parent_token, budget_reset_at
 ) VALUES (
     $1, $2, $3, $4, $5, $6,
     $7, $8, $9, $10,
     $11, $12, $13, $14,
-    $15, $16, $17
+    $15, $16, $17, $18, $19
 )
-RETURNING token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by
+RETURNING token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by, parent_token
 `
 
 type CreateVerificationTokenParams struct {
@@ -109,6 +109,8 @@ type CreateVerificationTokenParams struct {
 	BudgetDuration *string            `json:"budget_duration"`
 	BudgetID       *string            `json:"budget_id"`
 	CreatedBy      *string            `json:"created_by"`
+	ParentToken    *string            `json:"parent_token"`
+	BudgetResetAt  pgtype.Timestamptz `json:"budget_reset_at"`
 }
 
 func (q *Queries) CreateVerificationToken(ctx context.Context, arg CreateVerificationTokenParams) (VerificationToken, error) {
@@ -130,6 +132,8 @@ func (q *Queries) CreateVerificationToken(ctx context.Context, arg CreateVerific
 		arg.BudgetDuration,
 		arg.BudgetID,
 		arg.CreatedBy,
+		arg.ParentToken,
+		arg.BudgetResetAt,
 	)
 	var i VerificationToken
 	err := row.Scan(
@@ -165,6 +169,7 @@ func (q *Queries) CreateVerificationToken(ctx context.Context, arg CreateVerific
 		&i.CreatedBy,
 		&i.UpdatedAt,
 		&i.UpdatedBy,
+		&i.ParentToken,
 	)
 	return i, err
 }
@@ -180,7 +185,7 @@ func (q *Queries) DeleteVerificationToken(ctx context.Context, token string) err
 }
 
 const getVerificationToken = `-- name: GetVerificationToken :one
-SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by FROM "VerificationToken"
+SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by, parent_token FROM "VerificationToken"
 WHERE token = $1
 `
 
@@ -220,6 +225,7 @@ func (q *Queries) GetVerificationToken(ctx context.Context, token string) (Verif
 		&i.CreatedBy,
 		&i.UpdatedAt,
 		&i.UpdatedBy,
+		&i.ParentToken,
 	)
 	return i, err
 }
@@ -275,8 +281,68 @@ func (q *Queries) ListExpiredTokens(ctx context.Context) ([]ListExpiredTokensRow
 	return items, nil
 }
 
+const listVerificationTokenChildren = `-- name: ListVerificationTokenChildren :many
+SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by, parent_token FROM "VerificationToken"
+WHERE parent_token = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListVerificationTokenChildren(ctx context.Context, parentToken *string) ([]VerificationToken, error) {
+	rows, err := q.db.Query(ctx, listVerificationTokenChildren, parentToken)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []VerificationToken
+	for rows.Next() {
+		var i VerificationToken
+		if err := rows.Scan(
+			&i.Token,
+			&i.KeyName,
+			&i.KeyAlias,
+			&i.Spend,
+			&i.MaxBudget,
+			&i.Expires,
+			&i.Models,
+			&i.Aliases,
+			&i.Config,
+			&i.UserID,
+			&i.TeamID,
+			&i.OrganizationID,
+			&i.Permissions,
+			&i.Metadata,
+			&i.Blocked,
+			&i.TpmLimit,
+			&i.RpmLimit,
+			&i.BudgetDuration,
+			&i.BudgetResetAt,
+			&i.AllowedCacheControls,
+			&i.AllowedRoutes,
+			&i.Policies,
+			&i.AccessGroupIds,
+			&i.ModelSpend,
+			&i.ModelMaxBudget,
+			&i.SoftBudgetCooldown,
+			&i.BudgetID,
+			&i.ObjectPermissionID,
+			&i.CreatedAt,
+			&i.CreatedBy,
+			&i.UpdatedAt,
+			&i.UpdatedBy,
+			&i.ParentToken,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listVerificationTokens = `-- name: ListVerificationTokens :many
-SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by FROM "VerificationToken"
+SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by, parent_token FROM "VerificationToken"
 ORDER BY created_at DESC
 LIMIT $1 OFFSET $2
 `
@@ -328,6 +394,7 @@ func (q *Queries) ListVerificationTokens(ctx context.Context, arg ListVerificati
 			&i.CreatedBy,
 			&i.UpdatedAt,
 			&i.UpdatedBy,
+			&i.ParentToken,
 		); err != nil {
 			return nil, err
 		}
@@ -340,7 +407,7 @@ func (q *Queries) ListVerificationTokens(ctx context.Context, arg ListVerificati
 }
 
 const listVerificationTokensByTeam = `-- name: ListVerificationTokensByTeam :many
-SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by FROM "VerificationToken"
+SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by, parent_token FROM "VerificationToken"
 WHERE team_id = $1
 ORDER BY created_at DESC
 `
@@ -387,6 +454,7 @@ func (q *Queries) ListVerificationTokensByTeam(ctx context.Context, teamID *stri
 			&i.CreatedBy,
 			&i.UpdatedAt,
 			&i.UpdatedBy,
+			&i.ParentToken,
 		); err != nil {
 			return nil, err
 		}
@@ -399,7 +467,7 @@ func (q *Queries) ListVerificationTokensByTeam(ctx context.Context, teamID *stri
 }
 
 const listVerificationTokensByUser = `-- name: ListVerificationTokensByUser :many
-SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by FROM "VerificationToken"
+SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by, parent_token FROM "VerificationToken"
 WHERE user_id = $1
 ORDER BY created_at DESC
 `
@@ -446,6 +514,7 @@ func (q *Queries) ListVerificationTokensByUser(ctx context.Context, userID *stri
 			&i.CreatedBy,
 			&i.UpdatedAt,
 			&i.UpdatedBy,
+			&i.ParentToken,
 		); err != nil {
 			return nil, err
 		}
@@ -458,7 +527,7 @@ func (q *Queries) ListVerificationTokensByUser(ctx context.Context, userID *stri
 }
 
 const listVerificationTokensFiltered = `-- name: ListVerificationTokensFiltered :many
-SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by FROM "VerificationToken"
+SELECT token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by, parent_token FROM "VerificationToken"
 WHERE
   ($1::text IS NULL OR team_id = $1) AND
   ($2::text IS NULL OR key_alias = $2) AND
@@ -526,6 +595,7 @@ func (q *Queries) ListVerificationTokensFiltered(ctx context.Context, arg ListVe
 			&i.CreatedBy,
 			&i.UpdatedAt,
 			&i.UpdatedBy,
+			&i.ParentToken,
 		); err != nil {
 			return nil, err
 		}
@@ -541,7 +611,7 @@ const regenerateVerificationToken = `-- name: RegenerateVerificationToken :one
 UPDATE "VerificationToken"
 SET token = $2, spend = 0, updated_at = NOW()
 WHERE token = $1
-RETURNING token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by
+RETURNING token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by, parent_token
 `
 
 type RegenerateVerificationTokenParams struct {
@@ -585,6 +655,7 @@ func (q *Queries) RegenerateVerificationToken(ctx context.Context, arg Regenerat
 		&i.CreatedBy,
 		&i.UpdatedAt,
 		&i.UpdatedBy,
+		&i.ParentToken,
 	)
 	return i, err
 }
@@ -598,18 +669,20 @@ SET
     tpm_limit = COALESCE($3, tpm_limit),
     rpm_limit = COALESCE($4, rpm_limit),
     budget_duration = COALESCE($5, budget_duration),
+    budget_reset_at = COALESCE($6, budget_reset_at),
     updated_at = NOW()
-WHERE token = $6
-RETURNING token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by
+WHERE token = $7
+RETURNING token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by, parent_token
 `
 
 type RegenerateVerificationTokenWithParamsParams struct {
-	NewToken          string   `json:"new_token"`
-	NewMaxBudget      *float64 `json:"new_max_budget"`
-	NewTpmLimit       *int64   `json:"new_tpm_limit"`
-	NewRpmLimit       *int64   `json:"new_rpm_limit"`
-	NewBudgetDuration *string  `json:"new_budget_duration"`
-	OldToken          string   `json:"old_token"`
+	NewToken          string             `json:"new_token"`
+	NewMaxBudget      *float64           `json:"new_max_budget"`
+	NewTpmLimit       *int64             `json:"new_tpm_limit"`
+	NewRpmLimit       *int64             `json:"new_rpm_limit"`
+	NewBudgetDuration *string            `json:"new_budget_duration"`
+	NewBudgetResetAt  pgtype.Timestamptz `json:"new_budget_reset_at"`
+	OldToken          string             `json:"old_token"`
 }
 
 func (q *Queries) RegenerateVerificationTokenWithParams(ctx context.Context, arg RegenerateVerificationTokenWithParamsParams) (VerificationToken, error) {
@@ -619,6 +692,7 @@ func (q *Queries) RegenerateVerificationTokenWithParams(ctx context.Context, arg
 		arg.NewTpmLimit,
 		arg.NewRpmLimit,
 		arg.NewBudgetDuration,
+		arg.NewBudgetResetAt,
 		arg.OldToken,
 	)
 	var i VerificationToken
@@ -655,18 +729,27 @@ func (q *Queries) RegenerateVerificationTokenWithParams(ctx context.Context, arg
 		&i.CreatedBy,
 		&i.UpdatedAt,
 		&i.UpdatedBy,
+		&i.ParentToken,
 	)
 	return i, err
 }
 
 const resetBudgetForExpiredTokens = `-- name: ResetBudgetForExpiredTokens :exec
 UPDATE "VerificationToken"
-SET spend = 0, budget_reset_at = NOW() + (budget_duration || ' seconds')::INTERVAL, updated_at = NOW()
-WHERE budget_reset_at IS NOT NULL AND budget_reset_at <= NOW()
+SET spend = 0, model_spend = '{}', soft_budget_cooldown = FALSE,
+    budget_reset_at = $1, updated_at = NOW()
+WHERE budget_duration = $2 AND budget_reset_at <= NOW()
 `
 
-func (q *Queries) ResetBudgetForExpiredTokens(ctx context.Context) error {
-	_, err := q.db.Exec(ctx, resetBudgetForExpiredTokens)
+type ResetBudgetForExpiredTokensParams struct {
+	BudgetResetAt  pgtype.Timestamptz `json:"budget_reset_at"`
+	BudgetDuration *string            `json:"budget_duration"`
+}
+
+// Resets keys with the given budget_duration whose budget window has ended
+// and starts their next window, which ends at budget_reset_at.
+func (q *Queries) ResetBudgetForExpiredTokens(ctx context.Context, arg ResetBudgetForExpiredTokensParams) error {
+	_, err := q.db.Exec(ctx, resetBudgetForExpiredTokens, arg.BudgetResetAt, arg.BudgetDuration)
 	return err
 }
 
@@ -681,6 +764,33 @@ func (q *Queries) ResetVerificationTokenSpend(ctx context.Context, token string)
 	return err
 }
 
+const setVerificationTokenAllowedIPs = `-- name: SetVerificationTokenAllowedIPs :exec
+UPDATE "VerificationToken"
+SET metadata = jsonb_set(COALESCE(metadata, '{}'), '{allowed_ips}', $1::jsonb), updated_at = NOW()
+WHERE token = $2
+`
+
+type SetVerificationTokenAllowedIPsParams struct {
+	AllowedIps []byte `json:"allowed_ips"`
+	Token      string `json:"token"`
+}
+
+func (q *Queries) SetVerificationTokenAllowedIPs(ctx context.Context, arg SetVerificationTokenAllowedIPsParams) error {
+	_, err := q.db.Exec(ctx, setVerificationTokenAllowedIPs, arg.AllowedIps, arg.Token)
+	return err
+}
+
+const setVerificationTokenSoftBudgetCooldown = `-- name: SetVerificationTokenSoftBudgetCooldown :exec
+UPDATE "VerificationToken"
+SET soft_budget_cooldown = TRUE, updated_at = NOW()
+WHERE token = $1
+`
+
+func (q *Queries) SetVerificationTokenSoftBudgetCooldown(ctx context.Context, token string) error {
+	_, err := q.db.Exec(ctx, setVerificationTokenSoftBudgetCooldown, token)
+	return err
+}
+
 const unblockVerificationToken = `-- name: UnblockVerificationToken :exec
 UPDATE "VerificationToken"
 SET blocked = FALSE, updated_at = NOW()
@@ -703,21 +813,23 @@ SET
     tpm_limit = COALESCE($7, tpm_limit),
     rpm_limit = COALESCE($8, rpm_limit),
     budget_duration = COALESCE($9, budget_duration),
+    budget_reset_at = COALESCE($10, budget_reset_at),
     updated_at = NOW()
 WHERE token = $1
-RETURNING token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by
+RETURNING token, key_name, key_alias, spend, max_budget, expires, models, aliases, config, user_id, team_id, organization_id, permissions, metadata, blocked, tpm_limit, rpm_limit, budget_duration, budget_reset_at, allowed_cache_controls, allowed_routes, policies, access_group_ids, model_spend, model_max_budget, soft_budget_cooldown, budget_id, object_permission_id, created_at, created_by, updated_at, updated_by, parent_token
 `
 
 type UpdateVerificationTokenParams struct {
-	Token          string   `json:"token"`
-	KeyName        *string  `json:"key_name"`
-	KeyAlias       *string  `json:"key_alias"`
-	MaxBudget      *float64 `json:"max_budget"`
-	Models         []string `json:"models"`
-	Metadata       []byte   `json:"metadata"`
-	TpmLimit       *int64   `json:"tpm_limit"`
-	RpmLimit       *int64   `json:"rpm_limit"`
-	BudgetDuration *string  `json:"budget_duration"`
+	Token          string             `json:"token"`
+	KeyName        *string            `json:"key_name"`
+	KeyAlias       *string            `json:"key_alias"`
+	MaxBudget      *float64           `json:"max_budget"`
+	Models         []string           `json:"models"`
+	Metadata       []byte             `json:"metadata"`
+	TpmLimit       *int64             `json:"tpm_limit"`
+	RpmLimit       *int64             `json:"rpm_limit"`
+	BudgetDuration *string            `json:"budget_duration"`
+	BudgetResetAt  pgtype.Timestamptz `json:"budget_reset_at"`
 }
 
 func (q *Queries) UpdateVerificationToken(ctx context.Context, arg UpdateVerificationTokenParams) (VerificationToken, error) {
@@ -731,6 +843,7 @@ func (q *Queries) UpdateVerificationToken(ctx context.Context, arg UpdateVerific
 		arg.TpmLimit,
 		arg.RpmLimit,
 		arg.BudgetDuration,
+		arg.BudgetResetAt,
 	)
 	var i VerificationToken
 	err := row.Scan(
@@ -766,22 +879,37 @@ func (q *Queries) UpdateVerificationToken(ctx context.Context, arg UpdateVerific
 		&i.CreatedBy,
 		&i.UpdatedAt,
 		&i.UpdatedBy,
+		&i.ParentToken,
 	)
 	return i, err
 }
 
 const updateVerificationTokenSpend = `-- name: UpdateVerificationTokenSpend :exec
+WITH RECURSIVE chain AS (
+    SELECT token, parent_token FROM "VerificationToken" WHERE token = $1
+    UNION ALL
+    SELECT v.token, v.parent_token FROM "VerificationToken" v
+    JOIN chain c ON v.token = c.parent_token
+)
 UPDATE "VerificationToken"
-SET spend = spend + $2, updated_at = NOW()
-WHERE token = $1
+SET spend = spend + $2::float8,
+    model_spend = CASE WHEN $3::text = '' THEN model_spend
+        ELSE jsonb_set(model_spend, ARRAY[$3::text],
+            to_jsonb(COALESCE((model_spend->>$3::text)::float8, 0) + $2::float8))
+        END,
+    updated_at = NOW()
+WHERE token IN (SELECT token FROM chain)
 `
 
 type UpdateVerificationTokenSpendParams struct {
 	Token string  `json:"token"`
 	Spend float64 `json:"spend"`
+	Model string  `json:"model"`
 }
 
+// Adds spend to the key and, when model is set, to its model_spend entry.
+// A delegated key's spend also rolls up to every parent key above it.
 func (q *Queries) UpdateVerificationTokenSpend(ctx context.Context, arg UpdateVerificationTokenSpendParams) error {
-	_, err := q.db.Exec(ctx, updateVerificationTokenSpend, arg.Token, arg.Spend)
+	_, err := q.db.Exec(ctx, updateVerificationTokenSpend, arg.Token, arg.Spend, arg.Model)
 	return err
 }
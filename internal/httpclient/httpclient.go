@@ -0,0 +1,191 @@
+// Package httpclient builds the HTTP clients used for upstream LLM calls.
+//
+// Clients are built from Options and shared between deployments with the
+// same options, so each distinct configuration gets one connection pool.
+// Besides the transport settings (connection pooling, HTTP/2, TLS, egress
+// proxy) a client enforces two timeouts:
+//
+//   - Timeout bounds a call until its response is complete. For server-sent
+//     event streams it only bounds the wait for response headers, since a
+//     stream may legitimately run for much longer.
+//   - StreamIdleTimeout aborts a server-sent event stream that produces no
+//     data for that long.
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultMaxIdleConnsPerHost is the idle connection pool size per upstream
+// host when Options.MaxIdleConnsPerHost is unset. net/http's default of 2
+// forces new connections under any real concurrency.
+const DefaultMaxIdleConnsPerHost = 100
+
+// ErrTimeout is returned when a call exceeds its Timeout, and
+// ErrStreamIdle when a stream exceeds its StreamIdleTimeout. Both wrap
+// context.DeadlineExceeded so they are treated as timeouts.
+var (
+	ErrTimeout    = fmt.Errorf("upstream request timed out: %w", context.DeadlineExceeded)
+	ErrStreamIdle = fmt.Errorf("upstream stream idle timeout: %w", context.DeadlineExceeded)
+)
+
+// Options configures an upstream HTTP client. The zero value behaves like
+// http.DefaultClient with a larger idle pool. Options is comparable and
+// used as the cache key of a Factory.
+type Options struct {
+	// Timeout bounds a call; 0 means no limit.
+	Timeout time.Duration
+	// StreamIdleTimeout aborts a stream that is silent this long; 0 means no limit.
+	StreamIdleTimeout time.Duration
+
+	// MaxIdleConnsPerHost sizes the idle connection pool per host.
+	MaxIdleConnsPerHost int
+	// DisableHTTP2 keeps connections on HTTP/1.1.
+	DisableHTTP2 bool
+
+	// InsecureSkipVerify disables upstream certificate verification.
+	InsecureSkipVerify bool
+	// CACertFile is a PEM bundle trusted in addition to the system roots.
+	CACertFile string
+	// ClientCertFile and ClientKeyFile are a PEM client certificate for mTLS.
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// ProxyURL routes calls through an egress proxy. When empty the
+	// HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables apply.
+	ProxyURL string
+}
+
+// New builds a client for opts.
+func New(opts Options) (*http.Client, error) {
+	transport, err := newTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &timeoutTransport{
+		base:        transport,
+		timeout:     opts.Timeout,
+		idleTimeout: opts.StreamIdleTimeout,
+	}}, nil
+}
+
+func newTransport(opts Options) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ForceAttemptHTTP2 = !opts.DisableHTTP2
+	if opts.DisableHTTP2 {
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	if t.MaxIdleConnsPerHost <= 0 {
+		t.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
+	}
+	if t.MaxIdleConns < t.MaxIdleConnsPerHost {
+		t.MaxIdleConns = t.MaxIdleConnsPerHost
+	}
+
+	if opts.ProxyURL != "" {
+		u, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("proxy url: %w", err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig, err := newTLSConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+func newTLSConfig(opts Options) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: opts.InsecureSkipVerify, //nolint:gosec // opt-in per deployment
+	}
+
+	if opts.CACertFile != "" {
+		pem, err := os.ReadFile(opts.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("ca cert: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca cert: no certificates in %s", opts.CACertFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if opts.ClientCertFile != "" || opts.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCertFile, opts.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("client cert: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// Factory hands out clients, building one per distinct Options.
+type Factory struct {
+	mu      sync.Mutex
+	clients map[Options]*http.Client
+}
+
+// NewFactory creates an empty Factory.
+func NewFactory() *Factory {
+	return &Factory{clients: make(map[Options]*http.Client)}
+}
+
+// Client returns the shared client for opts, building it on first use.
+func (f *Factory) Client(opts Options) (*http.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if c, ok := f.clients[opts]; ok {
+		return c, nil
+	}
+	c, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	f.clients[opts] = c
+	return c, nil
+}
+
+// Failing returns a client whose calls all fail with err. It stands in for
+// a deployment whose client options are invalid, so the error surfaces on
+// every call to that deployment instead of silently using other settings.
+func Failing(err error) *http.Client {
+	return &http.Client{Transport: failingTransport{err: err}}
+}
+
+type failingTransport struct{ err error }
+
+func (t failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+	return nil, t.err
+}
+
+var defaultFactory = NewFactory()
+
+// Default returns the shared client for the zero Options.
+func Default() *http.Client {
+	c, _ := defaultFactory.Client(Options{})
+	return c
+}
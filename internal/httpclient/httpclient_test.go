@@ -0,0 +1,170 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_TimeoutBoundsNonStreamingCall(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	c, err := New(Options{Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	_, err = c.Get(srv.URL)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrTimeout)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_TimeoutCoversBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	c, err := New(Options{Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	resp, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	_, err = io.ReadAll(resp.Body)
+	assert.ErrorIs(t, err, ErrTimeout)
+}
+
+// sse writes one event per interval for n events.
+func sse(n int, interval time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for i := 0; i < n; i++ {
+			_, _ = io.WriteString(w, "data: {}\n\n")
+			w.(http.Flusher).Flush()
+			select {
+			case <-time.After(interval):
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}
+}
+
+func TestClient_StreamOutlivesTimeoutWhileActive(t *testing.T) {
+	srv := httptest.NewServer(sse(6, 20*time.Millisecond))
+	defer srv.Close()
+
+	c, err := New(Options{Timeout: 50 * time.Millisecond, StreamIdleTimeout: 100 * time.Millisecond})
+	require.NoError(t, err)
+
+	resp, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Len(t, body, 6*len("data: {}\n\n"))
+}
+
+func TestClient_StreamIdleTimeoutAbortsStalledStream(t *testing.T) {
+	srv := httptest.NewServer(sse(2, time.Second))
+	defer srv.Close()
+
+	c, err := New(Options{StreamIdleTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+
+	resp, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	start := time.Now()
+	_, err = io.ReadAll(resp.Body)
+	assert.ErrorIs(t, err, ErrStreamIdle)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestClient_ProxyURL(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	c, err := New(Options{ProxyURL: proxy.URL})
+	require.NoError(t, err)
+
+	resp, err := c.Get("http://upstream.invalid/v1/models")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "http://upstream.invalid/v1/models", proxied)
+}
+
+func TestClient_TLSOptions(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	c, err := New(Options{})
+	require.NoError(t, err)
+	_, err = c.Get(srv.URL)
+	assert.Error(t, err, "self-signed certificate must not be trusted by default")
+
+	c, err = New(Options{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	resp, err := c.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	_, err = New(Options{CACertFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.ErrorContains(t, err, "ca cert")
+
+	bad := filepath.Join(t.TempDir(), "bad.pem")
+	require.NoError(t, os.WriteFile(bad, []byte("not a cert"), 0o600))
+	_, err = New(Options{CACertFile: bad})
+	assert.ErrorContains(t, err, "no certificates")
+}
+
+func TestFactory_SharesClientsPerOptions(t *testing.T) {
+	f := NewFactory()
+	a, err := f.Client(Options{Timeout: time.Second})
+	require.NoError(t, err)
+	b, err := f.Client(Options{Timeout: time.Second})
+	require.NoError(t, err)
+	c, err := f.Client(Options{Timeout: 2 * time.Second})
+	require.NoError(t, err)
+
+	assert.Same(t, a, b)
+	assert.NotSame(t, a, c)
+
+	_, err = f.Client(Options{ProxyURL: "://bad"})
+	var urlErr *url.Error
+	assert.ErrorAs(t, err, &urlErr)
+}
+
+func TestFailing(t *testing.T) {
+	_, err := Failing(io.ErrUnexpectedEOF).Get("http://example.invalid")
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,106 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// timeoutTransport enforces Options.Timeout and Options.StreamIdleTimeout.
+// Both are implemented by cancelling the request context with a cause, so
+// they can be told apart from the caller cancelling.
+type timeoutTransport struct {
+	base        http.RoundTripper
+	timeout     time.Duration
+	idleTimeout time.Duration
+}
+
+func (t *timeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 && t.idleTimeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancelCause(req.Context())
+	var deadline *time.Timer
+	if t.timeout > 0 {
+		deadline = time.AfterFunc(t.timeout, func() { cancel(ErrTimeout) })
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		stop(deadline)
+		cancel(nil)
+		return nil, timeoutCause(ctx, err)
+	}
+
+	body := &timeoutBody{ReadCloser: resp.Body, ctx: ctx, cancel: cancel, deadline: deadline}
+	if isEventStream(resp) {
+		// A stream is bounded by inactivity, not by its total duration.
+		stop(deadline)
+		body.deadline = nil
+		if t.idleTimeout > 0 {
+			body.idleTimeout = t.idleTimeout
+			body.idle = time.AfterFunc(t.idleTimeout, func() { cancel(ErrStreamIdle) })
+		}
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// timeoutBody reports reads aborted by a timeout as ErrTimeout or
+// ErrStreamIdle, pushes the idle deadline back on every read, and releases
+// the request context once closed.
+type timeoutBody struct {
+	io.ReadCloser
+	ctx         context.Context
+	cancel      context.CancelCauseFunc
+	deadline    *time.Timer
+	idle        *time.Timer
+	idleTimeout time.Duration
+	closeOnce   sync.Once
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 && b.idle != nil {
+		b.idle.Reset(b.idleTimeout)
+	}
+	if err != nil && err != io.EOF {
+		err = timeoutCause(b.ctx, err)
+	}
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.closeOnce.Do(func() {
+		stop(b.deadline)
+		stop(b.idle)
+		b.cancel(nil)
+	})
+	return err
+}
+
+// timeoutCause replaces err with the timeout that cancelled ctx, if any.
+func timeoutCause(ctx context.Context, err error) error {
+	cause := context.Cause(ctx)
+	if errors.Is(cause, ErrTimeout) || errors.Is(cause, ErrStreamIdle) {
+		return cause
+	}
+	return err
+}
+
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream" || mediaType == "application/vnd.amazon.eventstream"
+}
+
+func stop(t *time.Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
@@ -3,6 +3,7 @@ package model
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // Sentinel errors for LLM provider error classification.
@@ -73,3 +74,95 @@ func MapHTTPStatusToError(status int) error {
 		return fmt.Errorf("unexpected status code: %d", status)
 	}
 }
+
+// Substrings providers use in error messages for oversized prompts.
+var contextWindowPatterns = []string{
+	"context_length_exceeded",
+	"maximum context length",
+	"context window",
+	"context length",
+	"prompt is too long",
+	"input is too long",
+	"too many input tokens",
+	"too many tokens",
+	"exceeds the maximum number of tokens",
+	"input token count",
+	"exceed context limit",
+	"request_too_large",
+}
+
+// Substrings providers use in error messages for moderated content.
+var contentPolicyPatterns = []string{
+	"content_policy_violation",
+	"content_filter",
+	"content management policy",
+	"content filtering policy",
+	"safety system",
+	"blocked due to safety",
+	"content filters",
+	"guardrail",
+}
+
+// ClassifyProviderError maps a provider error response to a sentinel error.
+// code is the provider's own error type or code (e.g. OpenAI's "code",
+// Anthropic's "type", Gemini's "status", Bedrock's x-amzn-ErrorType). Context
+// window and content policy failures are recognized from code and message
+// before falling back to MapHTTPStatusToError.
+func ClassifyProviderError(status int, code, message string) error {
+	if status >= 400 && status < 500 && status != 401 && status != 403 && status != 429 {
+		text := strings.ToLower(code + " " + message)
+		if containsAny(text, contextWindowPatterns) {
+			return ErrContextWindowExceeded
+		}
+		if containsAny(text, contentPolicyPatterns) {
+			return ErrContentPolicyViolation
+		}
+	}
+
+	switch code {
+	case "rate_limit_error", "ThrottlingException", "RESOURCE_EXHAUSTED":
+		return ErrRateLimit
+	case "authentication_error", "UNAUTHENTICATED", "UnrecognizedClientException":
+		return ErrAuthentication
+	case "permission_error", "PERMISSION_DENIED", "AccessDeniedException":
+		return ErrPermission
+	}
+	return MapHTTPStatusToError(status)
+}
+
+func containsAny(s string, patterns []string) bool {
+	for _, p := range patterns {
+		if strings.Contains(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorCode returns the stable error code reported to clients for err, or ""
+// when err is not a classified provider error.
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrContextWindowExceeded):
+		return "context_length_exceeded"
+	case errors.Is(err, ErrContentPolicyViolation):
+		return "content_policy_violation"
+	case errors.Is(err, ErrRateLimit):
+		return "rate_limit"
+	case errors.Is(err, ErrAuthentication):
+		return "auth"
+	case errors.Is(err, ErrPermission):
+		return "permission_denied"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrTimeout):
+		return "timeout"
+	case errors.Is(err, ErrServiceUnavailable):
+		return "service_unavailable"
+	case errors.Is(err, ErrBudgetExceeded):
+		return "budget_exceeded"
+	case errors.Is(err, ErrInvalidRequest):
+		return "invalid_request"
+	}
+	return ""
+}
@@ -34,6 +34,15 @@ type ImageGenerationRequest struct {
 type ImageGenerationResponse struct {
 	Created int64       `json:"created"`
 	Data    []ImageData `json:"data"`
+	// Usage is reported by token-priced image models such as gpt-image-1.
+	Usage *ImageUsage `json:"usage,omitempty"`
+}
+
+// ImageUsage holds the token usage of an image generation.
+type ImageUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
 }
 
 type ImageData struct {
@@ -44,16 +53,38 @@ type ImageData struct {
 
 // AudioTranscriptionRequest is decoded from multipart form data.
 type AudioTranscriptionRequest struct {
-	Model          string   `json:"model"`
-	Language       *string  `json:"language,omitempty"`
-	Prompt         *string  `json:"prompt,omitempty"`
-	ResponseFormat *string  `json:"response_format,omitempty"`
-	Temperature    *float64 `json:"temperature,omitempty"`
+	Model                  string   `json:"model"`
+	Language               *string  `json:"language,omitempty"`
+	Prompt                 *string  `json:"prompt,omitempty"`
+	ResponseFormat         *string  `json:"response_format,omitempty"`
+	Temperature            *float64 `json:"temperature,omitempty"`
+	TimestampGranularities []string `json:"timestamp_granularities,omitempty"`
+
+	// File is the uploaded audio and FileName its name in the form.
+	File     []byte `json:"-"`
+	FileName string `json:"-"`
 }
 
-// AudioTranscriptionResponse represents an OpenAI-compatible transcription response.
+// AudioTranscriptionResponse represents an OpenAI-compatible transcription
+// response. For the text, srt and vtt response formats Text holds the whole
+// upstream body.
 type AudioTranscriptionResponse struct {
-	Text string `json:"text"`
+	Text     string              `json:"text"`
+	Language string              `json:"language,omitempty"`
+	Duration float64             `json:"duration,omitempty"`
+	Words    any                 `json:"words,omitempty"`
+	Segments any                 `json:"segments,omitempty"`
+	Usage    *TranscriptionUsage `json:"usage,omitempty"`
+}
+
+// TranscriptionUsage is billed either by audio duration (Type "duration")
+// or by tokens (Type "tokens").
+type TranscriptionUsage struct {
+	Type         string  `json:"type"`
+	Seconds      float64 `json:"seconds,omitempty"`
+	InputTokens  int     `json:"input_tokens,omitempty"`
+	OutputTokens int     `json:"output_tokens,omitempty"`
+	TotalTokens  int     `json:"total_tokens,omitempty"`
 }
 
 // AudioSpeechRequest represents an OpenAI-compatible TTS request.
@@ -65,6 +96,12 @@ type AudioSpeechRequest struct {
 	Speed          *float64 `json:"speed,omitempty"`
 }
 
+// AudioSpeechResponse is the audio synthesized for a TTS request.
+type AudioSpeechResponse struct {
+	Audio       []byte
+	ContentType string
+}
+
 // ModerationRequest represents an OpenAI-compatible moderation request.
 type ModerationRequest struct {
 	Model string `json:"model,omitempty"`
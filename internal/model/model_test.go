@@ -56,6 +56,44 @@ func TestMapHTTPStatusToError(t *testing.T) {
 	}
 }
 
+func TestClassifyProviderError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		code    string
+		message string
+		want    error
+	}{
+		{"openai context", 400, "context_length_exceeded", "This model's maximum context length is 8192 tokens", ErrContextWindowExceeded},
+		{"anthropic context", 400, "invalid_request_error", "prompt is too long: 210000 tokens > 200000 maximum", ErrContextWindowExceeded},
+		{"gemini context", 400, "INVALID_ARGUMENT", "The input token count (1200000) exceeds the maximum number of tokens allowed", ErrContextWindowExceeded},
+		{"bedrock context", 400, "ValidationException", "Input is too long for requested model.", ErrContextWindowExceeded},
+		{"openai content policy", 400, "content_policy_violation", "Your request was rejected as a result of our safety system.", ErrContentPolicyViolation},
+		{"azure content filter", 400, "content_filter", "The response was filtered due to the prompt triggering Azure OpenAI's content management policy.", ErrContentPolicyViolation},
+		{"plain bad request", 400, "invalid_request_error", "messages: field required", ErrInvalidRequest},
+		{"rate limit status", 429, "", "too many tokens per minute", ErrRateLimit},
+		{"bedrock throttling", 400, "ThrottlingException", "Rate exceeded", ErrRateLimit},
+		{"anthropic auth", 401, "authentication_error", "invalid x-api-key", ErrAuthentication},
+		{"server error", 500, "", "context length", ErrServiceUnavailable},
+	}
+	for _, tt := range tests {
+		got := ClassifyProviderError(tt.status, tt.code, tt.message)
+		if !errors.Is(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	e := &TianjiError{StatusCode: 400, Err: ErrContextWindowExceeded}
+	if got := ErrorCode(e); got != "context_length_exceeded" {
+		t.Errorf("ErrorCode = %q, want context_length_exceeded", got)
+	}
+	if got := ErrorCode(errors.New("boom")); got != "" {
+		t.Errorf("ErrorCode(unclassified) = %q, want empty", got)
+	}
+}
+
 func TestIsStreaming(t *testing.T) {
 	r := &ChatCompletionRequest{}
 	if r.IsStreaming() {
@@ -105,3 +143,26 @@ func TestUnmarshalJSONBasic(t *testing.T) {
 		t.Fatalf("Messages = %d", len(r.Messages))
 	}
 }
+
+func TestEmbeddingRequestInputTexts(t *testing.T) {
+	var req EmbeddingRequest
+	if err := json.Unmarshal([]byte(`{"model":"m","input":["a","b"]}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	texts, err := req.InputTexts()
+	if err != nil || len(texts) != 2 || texts[1] != "b" {
+		t.Fatalf("InputTexts() = %v, %v", texts, err)
+	}
+
+	req.Input = "a"
+	if texts, err := req.InputTexts(); err != nil || len(texts) != 1 {
+		t.Fatalf("InputTexts() = %v, %v", texts, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"model":"m","input":[1,2,3]}`), &req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := req.InputTexts(); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected an invalid request error for token input, got %v", err)
+	}
+}
@@ -1,15 +1,71 @@
 package model
 
+import "encoding/json"
+
 // RerankRequest represents a rerank API request.
 type RerankRequest struct {
-	Model     string   `json:"model"`
-	Query     string   `json:"query"`
-	Documents []string `json:"documents"`
-	TopN      *int     `json:"top_n,omitempty"`
+	Model string `json:"model"`
+	Query string `json:"query"`
+	// Documents are strings or, for backends that rank structured
+	// documents, objects such as {"text": ...}.
+	Documents []any `json:"documents"`
+	TopN      *int  `json:"top_n,omitempty"`
+	// ReturnDocuments asks for each result to carry its document.
+	ReturnDocuments *bool `json:"return_documents,omitempty"`
+	// Extra holds the fields not declared above, such as rank_fields and
+	// max_chunks_per_doc, which are passed through to the backend.
+	Extra map[string]any `json:"-"`
+}
+
+// UnmarshalJSON captures unknown fields into Extra.
+func (r *RerankRequest) UnmarshalJSON(data []byte) error {
+	type Alias RerankRequest
+	var alias Alias
+	if err := json.Unmarshal(data, &alias); err != nil {
+		return err
+	}
+	*r = RerankRequest(alias)
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil
+	}
+	for _, key := range []string{"model", "query", "documents", "top_n", "return_documents"} {
+		delete(raw, key)
+	}
+	for key, v := range raw {
+		if r.Extra == nil {
+			r.Extra = make(map[string]any)
+		}
+		var val any
+		_ = json.Unmarshal(v, &val)
+		r.Extra[key] = val
+	}
+	return nil
+}
+
+// MarshalJSON merges Extra into the declared fields, which take precedence.
+func (r RerankRequest) MarshalJSON() ([]byte, error) {
+	type Alias RerankRequest
+	data, err := json.Marshal(Alias(r))
+	if err != nil || len(r.Extra) == 0 {
+		return data, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	for k, v := range r.Extra {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+	return json.Marshal(m)
 }
 
 // RerankResponse represents a rerank API response.
 type RerankResponse struct {
+	ID      string         `json:"id,omitempty"`
 	Results []RerankResult `json:"results"`
 	Model   string         `json:"model,omitempty"`
 	Usage   *RerankUsage   `json:"usage,omitempty"`
@@ -21,6 +77,7 @@ type RerankResponse struct {
 type RerankResult struct {
 	Index          int     `json:"index"`
 	RelevanceScore float64 `json:"relevance_score"`
+	Document       any     `json:"document,omitempty"`
 }
 
 // RerankUsage holds token usage for rerank requests.
@@ -30,7 +87,13 @@ type RerankUsage struct {
 
 // RerankMeta holds Cohere-style usage metadata.
 type RerankMeta struct {
-	Tokens *RerankMetaTokens `json:"tokens,omitempty"`
+	BilledUnits *RerankBilledUnits `json:"billed_units,omitempty"`
+	Tokens      *RerankMetaTokens  `json:"tokens,omitempty"`
+}
+
+// RerankBilledUnits holds the units a Cohere-style rerank was billed for.
+type RerankBilledUnits struct {
+	SearchUnits int `json:"search_units,omitempty"`
 }
 
 // RerankMetaTokens holds token counts from Cohere-style meta field.
@@ -68,6 +68,45 @@ type EmbeddingRequest struct {
 	EncodingFormat string `json:"encoding_format,omitempty"`
 	Dimensions     *int   `json:"dimensions,omitempty"`
 	User           string `json:"user,omitempty"`
+	// InputType is the intended use of the embeddings, such as
+	// "search_document" or "search_query", for providers that need one.
+	InputType string `json:"input_type,omitempty"`
+}
+
+// InputError reports request input a provider cannot send, such as token
+// array embedding input for a provider that takes only text. It matches
+// ErrInvalidRequest.
+type InputError struct {
+	Msg string
+}
+
+func (e *InputError) Error() string { return e.Msg }
+
+func (e *InputError) Unwrap() error { return ErrInvalidRequest }
+
+// InputTexts returns Input as a list of strings. Token array inputs are
+// rejected with an *InputError, as only OpenAI-compatible providers accept
+// them.
+func (r *EmbeddingRequest) InputTexts() ([]string, error) {
+	errInput := &InputError{Msg: "input must be a string or an array of strings"}
+	switch in := r.Input.(type) {
+	case string:
+		return []string{in}, nil
+	case []string:
+		return in, nil
+	case []any:
+		texts := make([]string, len(in))
+		for i, v := range in {
+			s, ok := v.(string)
+			if !ok {
+				return nil, errInput
+			}
+			texts[i] = s
+		}
+		return texts, nil
+	default:
+		return nil, errInput
+	}
 }
 
 // EmbeddingResponse represents an OpenAI-compatible embedding response.
@@ -1,7 +1,10 @@
 package pricing
 
 import (
+	"math"
 	"testing"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
 )
 
 func TestCostKnownModel(t *testing.T) {
@@ -71,3 +74,36 @@ func TestCostWithCustomPricing(t *testing.T) {
 	delete(c.overrides, "my-custom-model")
 	c.mu.Unlock()
 }
+
+func TestUnitCostEmbedded(t *testing.T) {
+	c := Default()
+	tests := []struct {
+		model string
+		usage UnitUsage
+		want  float64
+	}{
+		{"dall-e-3", UnitUsage{Images: 2}, 0.08},
+		{"tts-1", UnitUsage{Characters: 1000}, 0.015},
+		{"whisper-1", UnitUsage{Seconds: 60}, 0.006},
+		{"rerank-english-v3.0", UnitUsage{Queries: 1}, 0.002},
+		{"nonexistent-model-xyz", UnitUsage{Images: 1, Seconds: 10}, 0},
+	}
+	for _, tt := range tests {
+		if got := c.UnitCost(tt.model, tt.usage); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("UnitCost(%s) = %f, want %f", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestUnitCostKeptAcrossDBReload(t *testing.T) {
+	c := &Calculator{
+		embedded:  map[string]ModelInfo{"tts-x": {InputCostPerCharacter: 0.001}},
+		models:    map[string]ModelInfo{},
+		overrides: map[string]ModelInfo{},
+	}
+	c.ReloadFromDB([]db.ModelPricing{{ModelName: "tts-x", Mode: "audio_speech"}})
+
+	if got := c.UnitCost("tts-x", UnitUsage{Characters: 10}); math.Abs(got-0.01) > 1e-9 {
+		t.Fatalf("UnitCost = %f, want 0.01", got)
+	}
+}
@@ -31,6 +31,13 @@ type ModelInfo struct {
 	OutputCostPerTokenAbove200k        float64 `json:"output_cost_per_token_above_200k_tokens"`
 	CacheReadCostPerTokenAbove200k     float64 `json:"cache_read_input_token_cost_above_200k_tokens"`
 	CacheCreationCostPerTokenAbove200k float64 `json:"cache_creation_input_token_cost_above_200k_tokens"`
+
+	// Unit pricing of image, audio and rerank models
+	InputCostPerImage     float64 `json:"input_cost_per_image,omitempty"`
+	OutputCostPerImage    float64 `json:"output_cost_per_image,omitempty"`
+	InputCostPerCharacter float64 `json:"input_cost_per_character,omitempty"`
+	InputCostPerSecond    float64 `json:"input_cost_per_second,omitempty"`
+	InputCostPerQuery     float64 `json:"input_cost_per_query,omitempty"`
 }
 
 // Calculator calculates LLM request costs from token counts.
@@ -77,7 +84,7 @@ func Default() *Calculator {
 func (c *Calculator) ReloadFromDB(entries []db.ModelPricing) {
 	newModels := make(map[string]ModelInfo, len(entries))
 	for _, e := range entries {
-		newModels[e.ModelName] = ModelInfo{
+		info := ModelInfo{
 			InputCostPerToken:                  e.InputCostPerToken,
 			OutputCostPerToken:                 e.OutputCostPerToken,
 			MaxInputTokens:                     int(e.MaxInputTokens),
@@ -90,6 +97,16 @@ func (c *Calculator) ReloadFromDB(entries []db.ModelPricing) {
 			CacheReadCostPerTokenAbove200k:     e.CacheReadInputTokenCostAbove200k,
 			CacheCreationCostPerTokenAbove200k: e.CacheCreationInputTokenCostAbove200k,
 		}
+		// The DB stores token pricing only; unit pricing comes from the
+		// embedded data.
+		if emb, ok := c.embedded[e.ModelName]; ok {
+			info.InputCostPerImage = emb.InputCostPerImage
+			info.OutputCostPerImage = emb.OutputCostPerImage
+			info.InputCostPerCharacter = emb.InputCostPerCharacter
+			info.InputCostPerSecond = emb.InputCostPerSecond
+			info.InputCostPerQuery = emb.InputCostPerQuery
+		}
+		newModels[e.ModelName] = info
 	}
 	c.mu.Lock()
 	c.models = newModels
@@ -151,6 +168,27 @@ func (c *Calculator) TotalCost(model string, usage TokenUsage) float64 {
 	return prompt + completion
 }
 
+// UnitUsage carries the non-token units a call is billed by.
+type UnitUsage struct {
+	Images     int     // images generated
+	Characters int     // characters of text synthesized to speech
+	Seconds    float64 // seconds of audio transcribed
+	Queries    int     // rerank queries or search units
+}
+
+// UnitCost calculates the cost in USD of the units a call consumed. Image
+// generation models are priced per image by either of the image rates.
+func (c *Calculator) UnitCost(model string, usage UnitUsage) float64 {
+	info := c.lookup(model)
+	if info == nil {
+		return 0
+	}
+	return float64(usage.Images)*(info.InputCostPerImage+info.OutputCostPerImage) +
+		float64(usage.Characters)*info.InputCostPerCharacter +
+		usage.Seconds*info.InputCostPerSecond +
+		float64(usage.Queries)*info.InputCostPerQuery
+}
+
 // SetCustomPricing registers a custom pricing override for a model.
 func (c *Calculator) SetCustomPricing(model string, info ModelInfo) {
 	c.mu.Lock()
@@ -250,6 +288,12 @@ type modelInfoJSON struct {
 	OutputCostPerTokenAbove200k        float64 `json:"output_cost_per_token_above_200k_tokens"`
 	CacheReadCostPerTokenAbove200k     float64 `json:"cache_read_input_token_cost_above_200k_tokens"`
 	CacheCreationCostPerTokenAbove200k float64 `json:"cache_creation_input_token_cost_above_200k_tokens"`
+
+	InputCostPerImage     float64 `json:"input_cost_per_image"`
+	OutputCostPerImage    float64 `json:"output_cost_per_image"`
+	InputCostPerCharacter float64 `json:"input_cost_per_character"`
+	InputCostPerSecond    float64 `json:"input_cost_per_second"`
+	InputCostPerQuery     float64 `json:"input_cost_per_query"`
 }
 
 // UnmarshalJSON implements json.Unmarshaler for ModelInfo.
@@ -272,5 +316,10 @@ func (m *ModelInfo) UnmarshalJSON(data []byte) error {
 	m.OutputCostPerTokenAbove200k = raw.OutputCostPerTokenAbove200k
 	m.CacheReadCostPerTokenAbove200k = raw.CacheReadCostPerTokenAbove200k
 	m.CacheCreationCostPerTokenAbove200k = raw.CacheCreationCostPerTokenAbove200k
+	m.InputCostPerImage = raw.InputCostPerImage
+	m.OutputCostPerImage = raw.OutputCostPerImage
+	m.InputCostPerCharacter = raw.InputCostPerCharacter
+	m.InputCostPerSecond = raw.InputCostPerSecond
+	m.InputCostPerQuery = raw.InputCostPerQuery
 	return nil
 }
@@ -407,7 +407,7 @@ func parseErrorResponse(resp *http.Response) error {
 		Message:    msg,
 		Type:       errType,
 		Provider:   "anthropic",
-		Err:        model.MapHTTPStatusToError(resp.StatusCode),
+		Err:        model.ClassifyProviderError(resp.StatusCode, errType, msg),
 	}
 }
 
@@ -98,6 +98,65 @@ func (p *Provider) TransformResponse(_ context.Context, resp *http.Response) (*m
 	}, nil
 }
 
+// TransformSpeechRequest maps an OpenAI audio/speech request to Polly
+// SynthesizeSpeech. The voice is the request's voice, or without one the
+// model name as in TransformRequest.
+func (p *Provider) TransformSpeechRequest(ctx context.Context, req *model.AudioSpeechRequest, apiKey string) (*http.Request, error) {
+	voiceID := req.Voice
+	if voiceID == "" {
+		voiceID = req.Model
+		if idx := strings.LastIndex(voiceID, "/"); idx >= 0 {
+			voiceID = voiceID[idx+1:]
+		}
+	}
+	format := "mp3"
+	if req.ResponseFormat != nil && *req.ResponseFormat == "pcm" {
+		format = "pcm"
+	}
+
+	data, err := json.Marshal(pollyRequest{
+		OutputFormat: format,
+		Text:         req.Input,
+		VoiceId:      voiceID,
+		Engine:       "neural",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal aws_polly request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/speech", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create aws_polly request: %w", err)
+	}
+
+	p.SetupHeaders(httpReq, apiKey)
+	return httpReq, nil
+}
+
+func (p *Provider) TransformSpeechResponse(_ context.Context, resp *http.Response) (*model.AudioSpeechResponse, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &model.TianjiError{
+			StatusCode: resp.StatusCode,
+			Message:    string(body),
+			Provider:   "aws_polly",
+			Err:        model.MapHTTPStatusToError(resp.StatusCode),
+		}
+	}
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read aws_polly response: %w", err)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "audio/mpeg"
+	}
+	return &model.AudioSpeechResponse{Audio: audio, ContentType: contentType}, nil
+}
+
 func (p *Provider) TransformStreamChunk(_ context.Context, _ []byte) (*model.StreamChunk, bool, error) {
 	return nil, true, fmt.Errorf("aws_polly TTS does not support streaming chunks")
 }
@@ -1,8 +1,12 @@
 package awspolly
 
 import (
+	"context"
+	"io"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,3 +29,31 @@ func TestGetRequestURL(t *testing.T) {
 	url := p.GetRequestURL("test-model")
 	assert.NotEmpty(t, url)
 }
+
+func TestTransformSpeechRequest(t *testing.T) {
+	p := &Provider{baseURL: "https://polly.us-east-1.amazonaws.com"}
+	format := "pcm"
+
+	httpReq, err := p.TransformSpeechRequest(context.Background(), &model.AudioSpeechRequest{
+		Model:          "aws_polly/Joanna",
+		Input:          "Hello",
+		ResponseFormat: &format,
+	}, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "https://polly.us-east-1.amazonaws.com/v1/speech", httpReq.URL.String())
+
+	body, _ := io.ReadAll(httpReq.Body)
+	assert.JSONEq(t, `{"OutputFormat":"pcm","Text":"Hello","VoiceId":"Joanna","Engine":"neural"}`, string(body))
+}
+
+func TestTransformSpeechResponse(t *testing.T) {
+	p := &Provider{}
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set("Content-Type", "audio/pcm")
+	rec.WriteString("audio-bytes")
+	result, err := p.TransformSpeechResponse(context.Background(), rec.Result())
+	require.NoError(t, err)
+	assert.Equal(t, []byte("audio-bytes"), result.Audio)
+	assert.Equal(t, "audio/pcm", result.ContentType)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
@@ -30,6 +31,9 @@ func New() *Provider {
 }
 
 func NewWithConfig(apiBase, apiVersion string) *Provider {
+	if apiVersion == "" {
+		apiVersion = defaultAPIVersion
+	}
 	return &Provider{
 		apiBase:    apiBase,
 		apiVersion: apiVersion,
@@ -130,6 +134,10 @@ func (p *Provider) GetRequestURL(modelName string) string {
 	if p.apiBase != "" {
 		// Custom api_base: append /chat/completions?api-version=...
 		base := strings.TrimSuffix(p.apiBase, "/")
+		if u, err := url.Parse(base); err == nil && u.Path == "" {
+			// A resource endpoint, e.g. https://myresource.openai.azure.com
+			base += "/openai/deployments/" + modelName
+		}
 		if !strings.Contains(base, "chat/completions") {
 			base += "/chat/completions"
 		}
@@ -167,9 +175,11 @@ func parseErrorResponse(resp *http.Response) error {
 		} `json:"error"`
 	}
 	errType := "api_error"
+	code := ""
 	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
 		msg = errResp.Error.Message
 		errType = errResp.Error.Type
+		code = errResp.Error.Code
 	}
 
 	return &model.TianjiError{
@@ -177,10 +187,13 @@ func parseErrorResponse(resp *http.Response) error {
 		Message:    msg,
 		Type:       errType,
 		Provider:   "azure",
-		Err:        model.MapHTTPStatusToError(resp.StatusCode),
+		Err:        model.ClassifyProviderError(resp.StatusCode, code, msg),
 	}
 }
 
 func init() {
 	provider.Register("azure", New())
+	provider.RegisterFactory("azure", func(params provider.Params) (provider.Provider, error) {
+		return NewWithConfig(params.APIBase, params.APIVersion), nil
+	})
 }
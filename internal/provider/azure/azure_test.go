@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -102,6 +103,23 @@ func TestGetRequestURL_CustomBase(t *testing.T) {
 	assert.Contains(t, url, "api-version=2024-10-21")
 }
 
+func TestGetRequestURL_ResourceEndpoint(t *testing.T) {
+	p := NewWithConfig("https://myresource.openai.azure.com/", "")
+	assert.Equal(t,
+		"https://myresource.openai.azure.com/openai/deployments/gpt-4o/chat/completions?api-version="+defaultAPIVersion,
+		p.GetRequestURL("gpt-4o"))
+}
+
+func TestGetConfigured(t *testing.T) {
+	a, err := provider.GetConfigured("azure", provider.Params{APIBase: "https://eastus.openai.azure.com", APIVersion: "2024-02-15-preview"})
+	require.NoError(t, err)
+	b, err := provider.GetConfigured("azure", provider.Params{APIBase: "https://westeu.openai.azure.com", APIVersion: "2024-10-21"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://eastus.openai.azure.com/openai/deployments/gpt-4o/chat/completions?api-version=2024-02-15-preview", a.GetRequestURL("gpt-4o"))
+	assert.Equal(t, "https://westeu.openai.azure.com/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21", b.GetRequestURL("gpt-4o"))
+}
+
 func TestSetupHeaders_APIKey(t *testing.T) {
 	p := New()
 	req, _ := http.NewRequest(http.MethodPost, "http://test", nil)
@@ -3,33 +3,90 @@ package bedrock
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"strings"
-
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 )
 
+// signingName is the SigV4 service name of bedrock-runtime.
+const signingName = "bedrock"
+
+// Config configures a Provider for one deployment.
+type Config struct {
+	// Region defaults to AWS_REGION_NAME, AWS_REGION or AWS_DEFAULT_REGION,
+	// then us-east-1.
+	Region string
+	// Endpoint replaces the regional bedrock-runtime endpoint, e.g. with a
+	// VPC endpoint.
+	Endpoint string
+
+	// Static credentials. Without AccessKeyID the default AWS chain
+	// (environment, shared config, web identity, instance role) is used.
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// RoleARN is an IAM role assumed through STS with the credentials above.
+	RoleARN     string
+	SessionName string
+}
+
 // Provider implements the AWS Bedrock Converse API translation layer.
+// Requests are signed with SigV4, or carry a Bedrock API key as a bearer
+// token when the deployment has an api_key.
 type Provider struct {
-	region string
+	cfg Config
+
+	credsOnce sync.Once
+	creds     aws.CredentialsProvider
+	credsErr  error
+	now       func() time.Time
 }
 
 func New() *Provider {
-	return &Provider{region: "us-east-1"}
+	return NewWithConfig(Config{})
 }
 
 func NewWithRegion(region string) *Provider {
-	return &Provider{region: region}
+	return NewWithConfig(Config{Region: region})
+}
+
+// NewWithConfig creates a provider for cfg. Credentials are loaded on first
+// use and cached until they expire.
+func NewWithConfig(cfg Config) *Provider {
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion()
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &Provider{cfg: cfg, now: time.Now}
+}
+
+func defaultRegion() string {
+	for _, env := range []string{"AWS_REGION_NAME", "AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if r := os.Getenv(env); r != "" {
+			return r
+		}
+	}
+	return "us-east-1"
 }
 
 func (p *Provider) TransformRequest(ctx context.Context, req *model.ChatCompletionRequest, apiKey string) (*http.Request, error) {
-	// For Bedrock, we use the AWS SDK directly via Converse API.
-	// However, for the Provider interface we still return an http.Request
-	// that will be used by the handler.
 	body := p.transformRequestBody(req)
 
 	data, err := json.Marshal(body)
@@ -37,13 +94,21 @@ func (p *Provider) TransformRequest(ctx context.Context, req *model.ChatCompleti
 		return nil, fmt.Errorf("marshal bedrock request: %w", err)
 	}
 
-	url := p.GetRequestURL(req.Model)
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	reqURL := p.GetRequestURL(req.Model)
+	if req.IsStreaming() {
+		reqURL += "-stream"
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(data))
 	if err != nil {
 		return nil, fmt.Errorf("create bedrock request: %w", err)
 	}
 
 	p.SetupHeaders(httpReq, apiKey)
+	if apiKey == "" {
+		if err := p.sign(ctx, httpReq, data); err != nil {
+			return nil, fmt.Errorf("sign bedrock request: %w", err)
+		}
+	}
 	return httpReq, nil
 }
 
@@ -92,13 +157,70 @@ func (p *Provider) MapParams(params map[string]any) map[string]any {
 }
 
 func (p *Provider) GetRequestURL(modelName string) string {
-	return fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/converse",
-		p.region, modelName)
+	return fmt.Sprintf("%s/model/%s/converse", p.endpoint(), url.PathEscape(modelName))
+}
+
+func (p *Provider) endpoint() string {
+	if p.cfg.Endpoint != "" {
+		return p.cfg.Endpoint
+	}
+	return fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", p.cfg.Region)
 }
 
-func (p *Provider) SetupHeaders(req *http.Request, _ string) {
+// SetupHeaders sets the content headers and, with a Bedrock API key, the
+// bearer token. Without one the request is signed in TransformRequest.
+func (p *Provider) SetupHeaders(req *http.Request, apiKey string) {
 	req.Header.Set("Content-Type", "application/json")
-	// SigV4 signing is handled by AWS SDK
+	if strings.HasSuffix(req.URL.Path, "/converse-stream") {
+		req.Header.Set("Accept", eventStreamContentType)
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+}
+
+// sign signs req with SigV4 using the provider's credentials.
+func (p *Provider) sign(ctx context.Context, req *http.Request, body []byte) error {
+	creds, err := p.credentials(ctx)
+	if err != nil {
+		return err
+	}
+	h := sha256.Sum256(body)
+	return v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(h[:]), signingName, p.cfg.Region, p.now())
+}
+
+func (p *Provider) credentials(ctx context.Context) (aws.Credentials, error) {
+	p.credsOnce.Do(func() {
+		p.creds, p.credsErr = loadCredentials(p.cfg)
+	})
+	if p.credsErr != nil {
+		return aws.Credentials{}, p.credsErr
+	}
+	return p.creds.Retrieve(ctx)
+}
+
+// loadCredentials returns a caching credentials provider for cfg.
+func loadCredentials(cfg Config) (aws.CredentialsProvider, error) {
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(cfg.Region)}
+	if cfg.AccessKeyID != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, cfg.SessionToken)))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("load aws config: %w", err)
+	}
+	if cfg.RoleARN == "" {
+		return awsCfg.Credentials, nil
+	}
+	assume := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(awsCfg), cfg.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+		if cfg.SessionName != "" {
+			o.RoleSessionName = cfg.SessionName
+		}
+	})
+	return aws.NewCredentialsCache(assume), nil
 }
 
 // transformRequestBody converts OpenAI format to Bedrock Converse format.
@@ -106,7 +228,6 @@ func (p *Provider) transformRequestBody(req *model.ChatCompletionRequest) map[st
 	messages := transformMessages(req.Messages)
 
 	body := map[string]any{
-		"modelId":  req.Model,
 		"messages": messages,
 	}
 
@@ -354,16 +475,29 @@ func parseErrorResponse(resp *http.Response) error {
 	if json.Unmarshal(body, &errResp) == nil && errResp.Message != "" {
 		msg = errResp.Message
 	}
+	// x-amzn-ErrorType looks like "ValidationException:http://internal.amazon.com/...".
+	errType, _, _ := strings.Cut(resp.Header.Get("X-Amzn-Errortype"), ":")
 
 	return &model.TianjiError{
 		StatusCode: resp.StatusCode,
 		Message:    msg,
 		Type:       "api_error",
 		Provider:   "bedrock",
-		Err:        model.MapHTTPStatusToError(resp.StatusCode),
+		Err:        model.ClassifyProviderError(resp.StatusCode, errType, msg),
 	}
 }
 
 func init() {
 	provider.Register("bedrock", New())
+	provider.RegisterFactory("bedrock", func(params provider.Params) (provider.Provider, error) {
+		return NewWithConfig(Config{
+			Region:          params.AWSRegionName,
+			Endpoint:        params.APIBase,
+			AccessKeyID:     params.AWSAccessKeyID,
+			SecretAccessKey: params.AWSSecretAccessKey,
+			SessionToken:    params.AWSSessionToken,
+			RoleARN:         params.AWSRoleName,
+			SessionName:     params.AWSSessionName,
+		}), nil
+	})
 }
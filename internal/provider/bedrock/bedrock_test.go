@@ -6,7 +6,10 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/stretchr/testify/assert"
@@ -14,6 +17,8 @@ import (
 )
 
 func TestTransformRequest_BasicMessage(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
 	p := New()
 	ctx := context.Background()
 
@@ -34,10 +39,12 @@ func TestTransformRequest_BasicMessage(t *testing.T) {
 
 	assert.Contains(t, httpReq.URL.String(), "anthropic.claude-v2")
 	assert.Contains(t, httpReq.URL.String(), "converse")
+	assert.True(t, strings.HasPrefix(httpReq.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "))
 
 	body, _ := io.ReadAll(httpReq.Body)
 	var parsed map[string]any
 	require.NoError(t, json.Unmarshal(body, &parsed))
+	assert.NotContains(t, parsed, "modelId")
 
 	// System messages separated
 	system, ok := parsed["system"].([]any)
@@ -137,6 +144,68 @@ func TestGetRequestURL(t *testing.T) {
 	assert.Contains(t, url, "converse")
 }
 
+func TestGetRequestURL_EscapesARN(t *testing.T) {
+	p := NewWithRegion("us-east-1")
+	got := p.GetRequestURL("arn:aws:bedrock:us-east-1:123456789012:inference-profile/us.anthropic.claude-3-5-sonnet-20240620-v1:0")
+	assert.Equal(t, "https://bedrock-runtime.us-east-1.amazonaws.com/model/arn:aws:bedrock:us-east-1:123456789012:inference-profile%2Fus.anthropic.claude-3-5-sonnet-20240620-v1:0/converse", got)
+}
+
+func TestGetRequestURL_Endpoint(t *testing.T) {
+	p := NewWithConfig(Config{Region: "eu-west-1", Endpoint: "https://vpce-0abc.bedrock-runtime.eu-west-1.vpce.amazonaws.com/"})
+	assert.Equal(t, "https://vpce-0abc.bedrock-runtime.eu-west-1.vpce.amazonaws.com/model/amazon.nova-pro-v1:0/converse", p.GetRequestURL("amazon.nova-pro-v1:0"))
+}
+
+func TestTransformRequest_SigV4(t *testing.T) {
+	p := NewWithConfig(Config{
+		Region:          "us-west-2",
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		SessionToken:    "session-token",
+	})
+	p.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	req := &model.ChatCompletionRequest{
+		Model:    "anthropic.claude-3-haiku-20240307-v1:0",
+		Messages: []model.Message{{Role: "user", Content: "Hello"}},
+	}
+	httpReq, err := p.TransformRequest(context.Background(), req, "")
+	require.NoError(t, err)
+
+	auth := httpReq.Header.Get("Authorization")
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20260301/us-west-2/bedrock/aws4_request"), auth)
+	assert.Contains(t, auth, "SignedHeaders=")
+	assert.Equal(t, "20260301T120000Z", httpReq.Header.Get("X-Amz-Date"))
+	assert.Equal(t, "session-token", httpReq.Header.Get("X-Amz-Security-Token"))
+}
+
+func TestTransformRequest_Streaming(t *testing.T) {
+	p := NewWithConfig(Config{Region: "us-west-2", AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"})
+	stream := true
+	req := &model.ChatCompletionRequest{
+		Model:    "anthropic.claude-3-haiku-20240307-v1:0",
+		Messages: []model.Message{{Role: "user", Content: "Hello"}},
+		Stream:   &stream,
+	}
+	httpReq, err := p.TransformRequest(context.Background(), req, "")
+	require.NoError(t, err)
+
+	assert.Equal(t, "/model/anthropic.claude-3-haiku-20240307-v1:0/converse-stream", httpReq.URL.Path)
+	assert.Equal(t, "application/vnd.amazon.eventstream", httpReq.Header.Get("Accept"))
+}
+
+func TestTransformRequest_APIKey(t *testing.T) {
+	p := NewWithRegion("us-east-1")
+	req := &model.ChatCompletionRequest{
+		Model:    "anthropic.claude-3-haiku-20240307-v1:0",
+		Messages: []model.Message{{Role: "user", Content: "Hello"}},
+	}
+	httpReq, err := p.TransformRequest(context.Background(), req, "bedrock-api-key")
+	require.NoError(t, err)
+
+	assert.Equal(t, "Bearer bedrock-api-key", httpReq.Header.Get("Authorization"))
+	assert.Empty(t, httpReq.Header.Get("X-Amz-Date"))
+}
+
 func TestGetSupportedParams(t *testing.T) {
 	p := New()
 	params := p.GetSupportedParams()
@@ -169,3 +238,210 @@ func TestMapParams(t *testing.T) {
 		t.Fatal("expected maxTokens in result")
 	}
 }
+
+func TestTransformResponse_ClassifiesErrors(t *testing.T) {
+	p := New()
+
+	tests := []struct {
+		name      string
+		status    int
+		errorType string
+		body      string
+		want      error
+	}{
+		{"context window", 400, "ValidationException:http://internal.amazon.com/coral/com.amazon.bedrock/", `{"message":"Input is too long for requested model."}`, model.ErrContextWindowExceeded},
+		{"throttling", 400, "ThrottlingException", `{"message":"Too many requests, please wait before trying again."}`, model.ErrRateLimit},
+		{"access denied", 403, "AccessDeniedException", `{"message":"You don't have access to the model"}`, model.ErrPermission},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.status,
+				Header:     http.Header{"X-Amzn-Errortype": []string{tt.errorType}},
+				Body:       io.NopCloser(bytes.NewReader([]byte(tt.body))),
+			}
+			_, err := p.TransformResponse(context.Background(), resp)
+			require.Error(t, err)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestStreamReader_ConverseStream(t *testing.T) {
+	f, err := os.Open("testdata/converse_stream.bin")
+	require.NoError(t, err)
+	defer f.Close()
+
+	var (
+		text, args, toolName string
+		finish               string
+		usage                *model.Usage
+		done                 bool
+	)
+	sr := NewStreamReader(f)
+	for !done {
+		event, err := sr.Next()
+		require.NoError(t, err)
+		chunk, isDone, err := ParseStreamEvent(event)
+		require.NoError(t, err)
+		done = isDone
+		if chunk == nil {
+			continue
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		for _, c := range chunk.Choices {
+			if c.Delta.Content != nil {
+				text += *c.Delta.Content
+			}
+			for _, tc := range c.Delta.ToolCalls {
+				toolName += tc.Function.Name
+				args += tc.Function.Arguments
+			}
+			if c.FinishReason != nil {
+				finish = *c.FinishReason
+			}
+		}
+	}
+	_, err = sr.Next()
+	assert.ErrorIs(t, err, io.EOF)
+
+	assert.Equal(t, "Let me check the weather.", text)
+	assert.Equal(t, "get_weather", toolName)
+	assert.Equal(t, `{"city":"Paris"}`, args)
+	assert.Equal(t, "tool_calls", finish)
+	require.NotNil(t, usage)
+	assert.Equal(t, 412, usage.PromptTokens)
+	assert.Equal(t, 58, usage.CompletionTokens)
+	assert.Equal(t, 470, usage.TotalTokens)
+}
+
+func TestStreamReader_Exception(t *testing.T) {
+	data, err := os.ReadFile("testdata/converse_stream_throttled.bin")
+	require.NoError(t, err)
+
+	sr := NewStreamReader(bytes.NewReader(data))
+	for i := 0; i < 2; i++ {
+		_, err := sr.Next()
+		require.NoError(t, err)
+	}
+	_, err = sr.Next()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, model.ErrRateLimit)
+	var te *model.TianjiError
+	require.ErrorAs(t, err, &te)
+	assert.Equal(t, "Too many requests, please wait before trying again.", te.Message)
+}
+
+func TestStreamReader_CorruptFrame(t *testing.T) {
+	data, err := os.ReadFile("testdata/converse_stream.bin")
+	require.NoError(t, err)
+	data[20] ^= 0xff
+
+	_, err = NewStreamReader(bytes.NewReader(data)).Next()
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, io.EOF)
+}
+
+func TestDecodeStream(t *testing.T) {
+	p := New()
+	frames, err := os.ReadFile("testdata/converse_stream.bin")
+	require.NoError(t, err)
+
+	resp := &http.Response{
+		Header: http.Header{"Content-Type": []string{"application/vnd.amazon.eventstream"}},
+		Body:   io.NopCloser(bytes.NewReader(frames)),
+	}
+	event, err := p.DecodeStream(resp).Next()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"messageStart":{"p":"abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVW","role":"assistant"}}`, string(event))
+
+	// An OpenAI-compatible endpoint behind api_base streams SSE.
+	resp = &http.Response{
+		Header: http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:   io.NopCloser(strings.NewReader("data: {\"id\":\"1\"}\n\n")),
+	}
+	event, err = p.DecodeStream(resp).Next()
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"1"}`, string(event))
+}
+
+func TestTransformEmbeddingRequest_Titan(t *testing.T) {
+	p := NewWithConfig(Config{Region: "us-east-1", AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"})
+	dims := 512
+
+	httpReq, err := p.TransformEmbeddingRequest(context.Background(), &model.EmbeddingRequest{
+		Model:      "amazon.titan-embed-text-v2:0",
+		Input:      "hello",
+		Dimensions: &dims,
+	}, "")
+	require.NoError(t, err)
+	assert.Equal(t, "/model/amazon.titan-embed-text-v2:0/invoke", httpReq.URL.Path)
+	assert.True(t, strings.HasPrefix(httpReq.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "))
+
+	body, _ := io.ReadAll(httpReq.Body)
+	assert.JSONEq(t, `{"inputText":"hello","dimensions":512}`, string(body))
+
+	_, err = p.TransformEmbeddingRequest(context.Background(), &model.EmbeddingRequest{
+		Model: "amazon.titan-embed-text-v2:0",
+		Input: []any{"a", "b"},
+	}, "")
+	assert.ErrorIs(t, err, model.ErrInvalidRequest)
+}
+
+func TestSplitEmbeddingRequest(t *testing.T) {
+	p := New()
+
+	parts := p.SplitEmbeddingRequest(&model.EmbeddingRequest{
+		Model: "amazon.titan-embed-text-v2:0",
+		Input: []any{"a", "b"},
+	})
+	require.Len(t, parts, 2)
+	assert.Equal(t, "a", parts[0].Input)
+	assert.Equal(t, "b", parts[1].Input)
+	assert.Equal(t, "amazon.titan-embed-text-v2:0", parts[1].Model)
+
+	assert.Nil(t, p.SplitEmbeddingRequest(&model.EmbeddingRequest{Model: "amazon.titan-embed-text-v2:0", Input: "a"}))
+	assert.Nil(t, p.SplitEmbeddingRequest(&model.EmbeddingRequest{Model: "cohere.embed-english-v3", Input: []any{"a", "b"}}))
+}
+
+func TestTransformEmbeddingRequest_Cohere(t *testing.T) {
+	p := NewWithRegion("us-east-1")
+
+	httpReq, err := p.TransformEmbeddingRequest(context.Background(), &model.EmbeddingRequest{
+		Model:     "cohere.embed-english-v3",
+		Input:     []any{"a", "b"},
+		InputType: "search_query",
+	}, "bedrock-api-key")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer bedrock-api-key", httpReq.Header.Get("Authorization"))
+
+	body, _ := io.ReadAll(httpReq.Body)
+	assert.JSONEq(t, `{"texts":["a","b"],"input_type":"search_query"}`, string(body))
+}
+
+func TestTransformEmbeddingResponse(t *testing.T) {
+	p := New()
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"embedding":[0.1,0.2],"inputTextTokenCount":2}`))),
+	}
+	result, err := p.TransformEmbeddingResponse(context.Background(), resp)
+	require.NoError(t, err)
+	require.Len(t, result.Data, 1)
+	assert.Equal(t, []float64{0.1, 0.2}, result.Data[0].Embedding)
+	assert.Equal(t, 2, result.Usage.TotalTokens)
+
+	resp = &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"X-Amzn-Bedrock-Input-Token-Count": {"5"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"id":"e1","embeddings":[[0.1],[0.2]],"texts":["a","b"]}`))),
+	}
+	result, err = p.TransformEmbeddingResponse(context.Background(), resp)
+	require.NoError(t, err)
+	require.Len(t, result.Data, 2)
+	assert.Equal(t, 1, result.Data[1].Index)
+	assert.Equal(t, 5, result.Usage.PromptTokens)
+}
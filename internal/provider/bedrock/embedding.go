@@ -0,0 +1,134 @@
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+// titanEmbedRequest is the InvokeModel body of Amazon Titan text
+// embeddings, which embed one text per call; see SplitEmbeddingRequest.
+type titanEmbedRequest struct {
+	InputText  string `json:"inputText"`
+	Dimensions *int   `json:"dimensions,omitempty"`
+}
+
+// cohereEmbedRequest is the InvokeModel body of Cohere embed models.
+type cohereEmbedRequest struct {
+	Texts     []string `json:"texts"`
+	InputType string   `json:"input_type"`
+}
+
+// embedResponse holds the fields of both Titan ("embedding") and Cohere
+// ("embeddings") responses.
+type embedResponse struct {
+	Embedding           []float64   `json:"embedding"`
+	InputTextTokenCount int         `json:"inputTextTokenCount"`
+	Embeddings          [][]float64 `json:"embeddings"`
+}
+
+// TransformEmbeddingRequest maps an embedding request to InvokeModel for
+// Cohere embed models or, for any other model, Titan text embeddings.
+func (p *Provider) TransformEmbeddingRequest(ctx context.Context, req *model.EmbeddingRequest, apiKey string) (*http.Request, error) {
+	texts, err := req.InputTexts()
+	if err != nil {
+		return nil, err
+	}
+
+	var body any
+	if strings.Contains(req.Model, "cohere.") {
+		inputType := req.InputType
+		if inputType == "" {
+			inputType = "search_document"
+		}
+		body = cohereEmbedRequest{Texts: texts, InputType: inputType}
+	} else {
+		if len(texts) != 1 {
+			return nil, &model.InputError{Msg: "titan embedding models take a single input"}
+		}
+		body = titanEmbedRequest{InputText: texts[0], Dimensions: req.Dimensions}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal bedrock embedding request: %w", err)
+	}
+
+	reqURL := fmt.Sprintf("%s/model/%s/invoke", p.endpoint(), url.PathEscape(req.Model))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create bedrock embedding request: %w", err)
+	}
+
+	p.SetupHeaders(httpReq, apiKey)
+	if apiKey == "" {
+		if err := p.sign(ctx, httpReq, data); err != nil {
+			return nil, fmt.Errorf("sign bedrock request: %w", err)
+		}
+	}
+	return httpReq, nil
+}
+
+// SplitEmbeddingRequest sends each text of a multi-input request to a
+// Titan model as its own call. Cohere models embed a list in one call.
+func (p *Provider) SplitEmbeddingRequest(req *model.EmbeddingRequest) []*model.EmbeddingRequest {
+	if strings.Contains(req.Model, "cohere.") {
+		return nil
+	}
+	texts, err := req.InputTexts()
+	if err != nil || len(texts) < 2 {
+		return nil
+	}
+	parts := make([]*model.EmbeddingRequest, len(texts))
+	for i, text := range texts {
+		part := *req
+		part.Input = text
+		parts[i] = &part
+	}
+	return parts
+}
+
+// TransformEmbeddingResponse converts a Titan or Cohere response to OpenAI
+// format. Cohere responses carry no token count, so it is taken from the
+// X-Amzn-Bedrock-Input-Token-Count header.
+func (p *Provider) TransformEmbeddingResponse(_ context.Context, resp *http.Response) (*model.EmbeddingResponse, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read bedrock embedding response: %w", err)
+	}
+
+	var embResp embedResponse
+	if err := json.Unmarshal(body, &embResp); err != nil {
+		return nil, fmt.Errorf("parse bedrock embedding response: %w", err)
+	}
+
+	embeddings := embResp.Embeddings
+	if embResp.Embedding != nil {
+		embeddings = [][]float64{embResp.Embedding}
+	}
+	result := &model.EmbeddingResponse{Object: "list"}
+	for i, e := range embeddings {
+		result.Data = append(result.Data, model.EmbeddingData{Object: "embedding", Index: i, Embedding: e})
+	}
+
+	tokens := embResp.InputTextTokenCount
+	if tokens == 0 {
+		tokens, _ = strconv.Atoi(resp.Header.Get("X-Amzn-Bedrock-Input-Token-Count"))
+	}
+	result.Usage = model.EmbeddingUsage{PromptTokens: tokens, TotalTokens: tokens}
+	return result, nil
+}
@@ -2,11 +2,110 @@ package bedrock
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 )
 
+// eventStreamContentType is the content type of ConverseStream responses.
+const eventStreamContentType = "application/vnd.amazon.eventstream"
+
+// DecodeStream implements provider.StreamDecoder. ConverseStream responses
+// are AWS event streams; anything else, such as an OpenAI-compatible
+// endpoint behind api_base, is read as SSE.
+func (p *Provider) DecodeStream(resp *http.Response) provider.StreamReader {
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), eventStreamContentType) {
+		return NewStreamReader(resp.Body)
+	}
+	return provider.NewSSEReader(resp.Body)
+}
+
+// StreamReader decodes the binary AWS event stream of a ConverseStream
+// response. Each event message is returned in the form ParseStreamEvent
+// takes: its payload keyed by its :event-type header, e.g.
+// {"contentBlockDelta":{"contentBlockIndex":0,"delta":{"text":"Hi"}}}.
+type StreamReader struct {
+	r       io.Reader
+	decoder *eventstream.Decoder
+	buf     []byte
+}
+
+// NewStreamReader returns a StreamReader reading frames from r.
+func NewStreamReader(r io.Reader) *StreamReader {
+	return &StreamReader{r: r, decoder: eventstream.NewDecoder()}
+}
+
+// Next returns the next event. It returns io.EOF at the end of the stream
+// and a *model.TianjiError for exception and error messages.
+func (s *StreamReader) Next() ([]byte, error) {
+	for {
+		msg, err := s.decoder.Decode(s.r, s.buf)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("decode bedrock event stream: %w", err)
+		}
+		s.buf = msg.Payload[:0]
+
+		switch header(msg, ":message-type") {
+		case "event":
+			eventType := header(msg, ":event-type")
+			payload := msg.Payload
+			if len(payload) == 0 {
+				payload = []byte("{}")
+			}
+			out := make([]byte, 0, len(eventType)+len(payload)+5)
+			out = append(out, `{"`...)
+			out = append(out, eventType...)
+			out = append(out, `":`...)
+			out = append(out, payload...)
+			return append(out, '}'), nil
+		case "exception":
+			var body struct {
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(msg.Payload, &body)
+			return nil, streamError(header(msg, ":exception-type"), body.Message)
+		case "error":
+			return nil, streamError(header(msg, ":error-code"), header(msg, ":error-message"))
+		}
+	}
+}
+
+// streamError reports an exception sent in place of the rest of a stream.
+// Their types match the HTTP errors of the same name, e.g.
+// "throttlingException" for ThrottlingException.
+func streamError(errType, message string) error {
+	if message == "" {
+		message = errType
+	}
+	code := errType
+	if code != "" {
+		code = strings.ToUpper(code[:1]) + code[1:]
+	}
+	return &model.TianjiError{
+		StatusCode: http.StatusBadGateway,
+		Message:    message,
+		Type:       "api_error",
+		Provider:   "bedrock",
+		Err:        model.ClassifyProviderError(http.StatusBadGateway, code, message),
+	}
+}
+
+func header(msg eventstream.Message, name string) string {
+	if v := msg.Headers.Get(name); v != nil {
+		return v.String()
+	}
+	return ""
+}
+
 // StreamEvent represents a Bedrock Converse stream event.
 type StreamEvent struct {
 	ContentBlockStart *struct {
@@ -46,7 +145,8 @@ type StreamEvent struct {
 	} `json:"metadata,omitempty"`
 }
 
-// ParseStreamEvent parses a Bedrock Converse stream event.
+// ParseStreamEvent parses a Bedrock Converse stream event. The stream is done
+// at the metadata event carrying usage, which follows messageStop.
 func ParseStreamEvent(data []byte) (*model.StreamChunk, bool, error) {
 	data = []byte(strings.TrimSpace(string(data)))
 
@@ -140,7 +240,7 @@ func ParseStreamEvent(data []byte) (*model.StreamChunk, bool, error) {
 					FinishReason: &finishReason,
 				},
 			},
-		}, true, nil
+		}, false, nil
 
 	case event.Metadata != nil:
 		return &model.StreamChunk{
@@ -150,7 +250,7 @@ func ParseStreamEvent(data []byte) (*model.StreamChunk, bool, error) {
 				CompletionTokens: event.Metadata.Usage.OutputTokens,
 				TotalTokens:      event.Metadata.Usage.TotalTokens,
 			},
-		}, false, nil
+		}, true, nil
 	}
 
 	return nil, false, nil
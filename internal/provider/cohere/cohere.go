@@ -159,6 +159,6 @@ func parseErrorResponse(resp *http.Response) error {
 		Message:    msg,
 		Type:       "api_error",
 		Provider:   "cohere",
-		Err:        model.MapHTTPStatusToError(resp.StatusCode),
+		Err:        model.ClassifyProviderError(resp.StatusCode, "", msg),
 	}
 }
@@ -146,3 +146,95 @@ func TestTransformStreamChunk(t *testing.T) {
 	_, _, err := p.TransformStreamChunk(context.Background(), data)
 	_ = err // just ensure no panic
 }
+
+func TestTransformRerankRequest(t *testing.T) {
+	p := newTestProvider()
+	topN := 1
+	returnDocs := true
+
+	httpReq, err := p.TransformRerankRequest(context.Background(), &model.RerankRequest{
+		Model:           "rerank-english-v3.0",
+		Query:           "capital of France",
+		Documents:       []any{"Paris", "Berlin"},
+		TopN:            &topN,
+		ReturnDocuments: &returnDocs,
+	}, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.cohere.ai/v2/rerank", httpReq.URL.String())
+
+	body, _ := io.ReadAll(httpReq.Body)
+	assert.JSONEq(t, `{"model":"rerank-english-v3.0","query":"capital of France","documents":["Paris","Berlin"],"top_n":1}`, string(body))
+}
+
+func TestTransformRerankRequest_PassesThroughFields(t *testing.T) {
+	p := newTestProvider()
+	var req model.RerankRequest
+	require.NoError(t, json.Unmarshal([]byte(`{"model":"rerank-v3.5","query":"q","documents":[{"text":"a"}],"max_tokens_per_doc":512}`), &req))
+
+	httpReq, err := p.TransformRerankRequest(context.Background(), &req, "test-key")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(httpReq.Body)
+	assert.JSONEq(t, `{"model":"rerank-v3.5","query":"q","documents":[{"text":"a"}],"max_tokens_per_doc":512}`, string(body))
+}
+
+func TestTransformRerankResponse(t *testing.T) {
+	p := newTestProvider()
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"id":"r1","results":[{"index":0,"relevance_score":0.99}],"meta":{"billed_units":{"search_units":1}}}`))),
+	}
+
+	result, err := p.TransformRerankResponse(context.Background(), resp)
+	require.NoError(t, err)
+	assert.Equal(t, "r1", result.ID)
+	require.Len(t, result.Results, 1)
+	assert.Equal(t, 0.99, result.Results[0].RelevanceScore)
+	require.NotNil(t, result.Meta)
+	require.NotNil(t, result.Meta.BilledUnits)
+	assert.Equal(t, 1, result.Meta.BilledUnits.SearchUnits)
+}
+
+func TestTransformEmbeddingRequest(t *testing.T) {
+	p := newTestProvider()
+
+	httpReq, err := p.TransformEmbeddingRequest(context.Background(), &model.EmbeddingRequest{
+		Model: "embed-english-v3.0",
+		Input: []any{"hello", "world"},
+	}, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.cohere.ai/v2/embed", httpReq.URL.String())
+	assert.Equal(t, "Bearer test-key", httpReq.Header.Get("Authorization"))
+
+	body, _ := io.ReadAll(httpReq.Body)
+	assert.JSONEq(t, `{"model":"embed-english-v3.0","texts":["hello","world"],"input_type":"search_document","embedding_types":["float"]}`, string(body))
+}
+
+func TestTransformEmbeddingRequest_InputType(t *testing.T) {
+	p := newTestProvider()
+
+	httpReq, err := p.TransformEmbeddingRequest(context.Background(), &model.EmbeddingRequest{
+		Model:     "embed-english-v3.0",
+		Input:     "query",
+		InputType: "search_query",
+	}, "test-key")
+	require.NoError(t, err)
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(httpReq.Body).Decode(&body))
+	assert.Equal(t, "search_query", body["input_type"])
+}
+
+func TestTransformEmbeddingResponse(t *testing.T) {
+	p := newTestProvider()
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"id":"e1","embeddings":{"float":[[0.1,0.2],[0.3,0.4]]},"texts":["hello","world"],"meta":{"billed_units":{"input_tokens":4}}}`))),
+	}
+
+	result, err := p.TransformEmbeddingResponse(context.Background(), resp)
+	require.NoError(t, err)
+	require.Len(t, result.Data, 2)
+	assert.Equal(t, []float64{0.3, 0.4}, result.Data[1].Embedding)
+	assert.Equal(t, 4, result.Usage.PromptTokens)
+	assert.Equal(t, 4, result.Usage.TotalTokens)
+}
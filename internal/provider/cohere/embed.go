@@ -0,0 +1,89 @@
+package cohere
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+// embedRequest is the v2 embed body. v2 requires input_type, so requests
+// without one embed documents.
+type embedRequest struct {
+	Model           string   `json:"model"`
+	Texts           []string `json:"texts"`
+	InputType       string   `json:"input_type"`
+	EmbeddingTypes  []string `json:"embedding_types"`
+	OutputDimension *int     `json:"output_dimension,omitempty"`
+}
+
+type embedResponse struct {
+	Embeddings struct {
+		Float [][]float64 `json:"float"`
+	} `json:"embeddings"`
+	Meta struct {
+		BilledUnits struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"billed_units"`
+	} `json:"meta"`
+}
+
+func (p *Provider) TransformEmbeddingRequest(ctx context.Context, req *model.EmbeddingRequest, apiKey string) (*http.Request, error) {
+	texts, err := req.InputTexts()
+	if err != nil {
+		return nil, err
+	}
+	inputType := req.InputType
+	if inputType == "" {
+		inputType = "search_document"
+	}
+
+	data, err := json.Marshal(embedRequest{
+		Model:           req.Model,
+		Texts:           texts,
+		InputType:       inputType,
+		EmbeddingTypes:  []string{"float"},
+		OutputDimension: req.Dimensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal cohere embed request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/embed", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create cohere embed request: %w", err)
+	}
+
+	p.SetupHeaders(httpReq, apiKey)
+	return httpReq, nil
+}
+
+func (p *Provider) TransformEmbeddingResponse(_ context.Context, resp *http.Response) (*model.EmbeddingResponse, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read cohere embed response: %w", err)
+	}
+
+	var embResp embedResponse
+	if err := json.Unmarshal(body, &embResp); err != nil {
+		return nil, fmt.Errorf("parse cohere embed response: %w", err)
+	}
+
+	result := &model.EmbeddingResponse{Object: "list"}
+	for i, e := range embResp.Embeddings.Float {
+		result.Data = append(result.Data, model.EmbeddingData{Object: "embedding", Index: i, Embedding: e})
+	}
+	tokens := embResp.Meta.BilledUnits.InputTokens
+	result.Usage = model.EmbeddingUsage{PromptTokens: tokens, TotalTokens: tokens}
+	return result, nil
+}
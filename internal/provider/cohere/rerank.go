@@ -0,0 +1,51 @@
+package cohere
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+// TransformRerankRequest builds a v2 rerank request. v2 always omits
+// documents from the results, so return_documents is not sent; other
+// fields, such as max_tokens_per_doc, are passed through.
+func (p *Provider) TransformRerankRequest(ctx context.Context, req *model.RerankRequest, apiKey string) (*http.Request, error) {
+	body := *req
+	body.ReturnDocuments = nil
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal cohere rerank request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/rerank", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create cohere rerank request: %w", err)
+	}
+
+	p.SetupHeaders(httpReq, apiKey)
+	return httpReq, nil
+}
+
+func (p *Provider) TransformRerankResponse(_ context.Context, resp *http.Response) (*model.RerankResponse, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read cohere rerank response: %w", err)
+	}
+
+	var result model.RerankResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse cohere rerank response: %w", err)
+	}
+	return &result, nil
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider"
@@ -23,9 +24,13 @@ func init() {
 }
 
 type deepgramResponse struct {
+	Metadata struct {
+		Duration float64 `json:"duration"`
+	} `json:"metadata"`
 	Results struct {
 		Channels []struct {
-			Alternatives []struct {
+			DetectedLanguage string `json:"detected_language"`
+			Alternatives     []struct {
 				Transcript string  `json:"transcript"`
 				Confidence float64 `json:"confidence"`
 			} `json:"alternatives"`
@@ -93,6 +98,70 @@ func (p *Provider) TransformResponse(_ context.Context, resp *http.Response) (*m
 	}, nil
 }
 
+// TransformTranscriptionRequest posts the uploaded audio to /v1/listen
+// with the request's model, "general" by default.
+func (p *Provider) TransformTranscriptionRequest(ctx context.Context, req *model.AudioTranscriptionRequest, apiKey string) (*http.Request, error) {
+	q := url.Values{}
+	q.Set("model", req.Model)
+	if req.Model == "" {
+		q.Set("model", "general")
+	}
+	q.Set("smart_format", "true")
+	if req.Language != nil {
+		q.Set("language", *req.Language)
+	} else {
+		q.Set("detect_language", "true")
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/listen?"+q.Encode(), bytes.NewReader(req.File))
+	if err != nil {
+		return nil, fmt.Errorf("create deepgram request: %w", err)
+	}
+
+	p.SetupHeaders(httpReq, apiKey)
+	httpReq.Header.Set("Content-Type", http.DetectContentType(req.File))
+	return httpReq, nil
+}
+
+// TransformTranscriptionResponse returns the first alternative of the first
+// channel, with usage billed by the audio's duration.
+func (p *Provider) TransformTranscriptionResponse(_ context.Context, resp *http.Response) (*model.AudioTranscriptionResponse, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &model.TianjiError{
+			StatusCode: resp.StatusCode,
+			Message:    string(body),
+			Provider:   "deepgram",
+			Err:        model.MapHTTPStatusToError(resp.StatusCode),
+		}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read deepgram response: %w", err)
+	}
+
+	var dgResp deepgramResponse
+	if err := json.Unmarshal(body, &dgResp); err != nil {
+		return nil, fmt.Errorf("parse deepgram response: %w", err)
+	}
+
+	result := &model.AudioTranscriptionResponse{
+		Duration: dgResp.Metadata.Duration,
+		Usage:    &model.TranscriptionUsage{Type: "duration", Seconds: dgResp.Metadata.Duration},
+	}
+	if len(dgResp.Results.Channels) > 0 {
+		ch := dgResp.Results.Channels[0]
+		result.Language = ch.DetectedLanguage
+		if len(ch.Alternatives) > 0 {
+			result.Text = ch.Alternatives[0].Transcript
+		}
+	}
+	return result, nil
+}
+
 func (p *Provider) TransformStreamChunk(_ context.Context, data []byte) (*model.StreamChunk, bool, error) {
 	return nil, true, fmt.Errorf("deepgram STT streaming not implemented via this path")
 }
@@ -120,3 +120,42 @@ func TestGetSupportedParams(t *testing.T) {
 	assert.Contains(t, params, "messages")
 	assert.Contains(t, params, "language")
 }
+
+func TestTransformTranscriptionRequest(t *testing.T) {
+	p := &Provider{baseURL: "https://api.deepgram.com"}
+
+	httpReq, err := p.TransformTranscriptionRequest(context.Background(), &model.AudioTranscriptionRequest{
+		Model: "nova-2",
+		File:  []byte("RIFF\x00\x00\x00\x00WAVEfmt "),
+	}, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "/v1/listen", httpReq.URL.Path)
+	assert.Equal(t, "nova-2", httpReq.URL.Query().Get("model"))
+	assert.Equal(t, "true", httpReq.URL.Query().Get("detect_language"))
+	assert.Equal(t, "audio/wave", httpReq.Header.Get("Content-Type"))
+	assert.Equal(t, "Token test-key", httpReq.Header.Get("Authorization"))
+
+	lang := "de"
+	httpReq, err = p.TransformTranscriptionRequest(context.Background(), &model.AudioTranscriptionRequest{
+		Language: &lang,
+		File:     []byte("data"),
+	}, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "general", httpReq.URL.Query().Get("model"))
+	assert.Equal(t, "de", httpReq.URL.Query().Get("language"))
+	assert.Empty(t, httpReq.URL.Query().Get("detect_language"))
+}
+
+func TestTransformTranscriptionResponse(t *testing.T) {
+	p := &Provider{}
+
+	rec := httptest.NewRecorder()
+	rec.WriteString(`{"metadata":{"duration":12.5},"results":{"channels":[{"detected_language":"en","alternatives":[{"transcript":"hello world","confidence":0.98}]}]}}`)
+	result, err := p.TransformTranscriptionResponse(context.Background(), rec.Result())
+	require.NoError(t, err)
+	assert.Equal(t, "hello world", result.Text)
+	assert.Equal(t, "en", result.Language)
+	assert.Equal(t, 12.5, result.Duration)
+	require.NotNil(t, result.Usage)
+	assert.Equal(t, 12.5, result.Usage.Seconds)
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
@@ -102,6 +103,71 @@ func (p *Provider) TransformResponse(_ context.Context, resp *http.Response) (*m
 	}, nil
 }
 
+// outputFormats maps OpenAI speech response formats to ElevenLabs output
+// formats. Others use the ElevenLabs default, mp3_44100_128.
+var outputFormats = map[string]string{
+	"mp3":  "mp3_44100_128",
+	"opus": "opus_48000_128",
+	"pcm":  "pcm_24000",
+}
+
+// TransformSpeechRequest maps an OpenAI audio/speech request to ElevenLabs
+// TTS. The voice is the request's voice, or without one the model name as
+// in TransformRequest.
+func (p *Provider) TransformSpeechRequest(ctx context.Context, req *model.AudioSpeechRequest, apiKey string) (*http.Request, error) {
+	voiceID, modelID := req.Voice, req.Model
+	if voiceID == "" {
+		voiceID = req.Model
+		if idx := strings.LastIndex(voiceID, "/"); idx >= 0 {
+			voiceID = voiceID[idx+1:]
+		}
+		modelID = "eleven_monolingual_v1"
+	}
+
+	data, err := json.Marshal(elevenlabsRequest{Text: req.Input, ModelID: modelID})
+	if err != nil {
+		return nil, fmt.Errorf("marshal elevenlabs request: %w", err)
+	}
+
+	u := fmt.Sprintf("%s/v1/text-to-speech/%s", p.baseURL, url.PathEscape(voiceID))
+	if req.ResponseFormat != nil {
+		if f, ok := outputFormats[*req.ResponseFormat]; ok {
+			u += "?output_format=" + f
+		}
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create elevenlabs request: %w", err)
+	}
+
+	p.SetupHeaders(httpReq, apiKey)
+	return httpReq, nil
+}
+
+func (p *Provider) TransformSpeechResponse(_ context.Context, resp *http.Response) (*model.AudioSpeechResponse, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &model.TianjiError{
+			StatusCode: resp.StatusCode,
+			Message:    string(body),
+			Provider:   "elevenlabs",
+			Err:        model.MapHTTPStatusToError(resp.StatusCode),
+		}
+	}
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read elevenlabs response: %w", err)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "audio/mpeg"
+	}
+	return &model.AudioSpeechResponse{Audio: audio, ContentType: contentType}, nil
+}
+
 func (p *Provider) TransformStreamChunk(_ context.Context, data []byte) (*model.StreamChunk, bool, error) {
 	return nil, true, fmt.Errorf("elevenlabs TTS does not support streaming chunks")
 }
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
@@ -114,3 +115,49 @@ func TestGetSupportedParams(t *testing.T) {
 	assert.Contains(t, params, "model")
 	assert.Contains(t, params, "messages")
 }
+
+func TestTransformSpeechRequest(t *testing.T) {
+	p := &Provider{baseURL: "https://api.elevenlabs.io"}
+	format := "pcm"
+
+	httpReq, err := p.TransformSpeechRequest(context.Background(), &model.AudioSpeechRequest{
+		Model:          "eleven_turbo_v2",
+		Input:          "Hello",
+		Voice:          "21m00Tcm4TlvDq8ikWAM",
+		ResponseFormat: &format,
+	}, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.elevenlabs.io/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM?output_format=pcm_24000", httpReq.URL.String())
+	assert.Equal(t, "test-key", httpReq.Header.Get("xi-api-key"))
+
+	body, _ := io.ReadAll(httpReq.Body)
+	assert.JSONEq(t, `{"text":"Hello","model_id":"eleven_turbo_v2"}`, string(body))
+}
+
+func TestTransformSpeechRequest_VoiceFromModel(t *testing.T) {
+	p := &Provider{baseURL: "https://api.elevenlabs.io"}
+
+	httpReq, err := p.TransformSpeechRequest(context.Background(), &model.AudioSpeechRequest{
+		Model: "elevenlabs/21m00Tcm4TlvDq8ikWAM",
+		Input: "Hello",
+	}, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.elevenlabs.io/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM", httpReq.URL.String())
+
+	body, _ := io.ReadAll(httpReq.Body)
+	assert.Contains(t, string(body), `"model_id":"eleven_monolingual_v1"`)
+}
+
+func TestTransformSpeechResponse(t *testing.T) {
+	p := &Provider{}
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("audio-bytes")),
+	}
+	result, err := p.TransformSpeechResponse(context.Background(), resp)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("audio-bytes"), result.Audio)
+	assert.Equal(t, "audio/mpeg", result.ContentType)
+}
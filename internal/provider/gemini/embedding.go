@@ -0,0 +1,147 @@
+package gemini
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+// taskTypes maps OpenAI-style input types to Gemini embedding task types.
+// Others are sent as given.
+var taskTypes = map[string]string{
+	"search_document": "RETRIEVAL_DOCUMENT",
+	"search_query":    "RETRIEVAL_QUERY",
+	"classification":  "CLASSIFICATION",
+	"clustering":      "CLUSTERING",
+}
+
+func taskType(inputType string) string {
+	if t, ok := taskTypes[inputType]; ok {
+		return t
+	}
+	return inputType
+}
+
+// Gemini batchEmbedContents types
+
+type embedContentRequest struct {
+	Model                string        `json:"model"`
+	Content              geminiContent `json:"content"`
+	TaskType             string        `json:"taskType,omitempty"`
+	OutputDimensionality *int          `json:"outputDimensionality,omitempty"`
+}
+
+type batchEmbedResponse struct {
+	Embeddings []struct {
+		Values []float64 `json:"values"`
+	} `json:"embeddings"`
+}
+
+// Vertex AI predict types
+
+type predictInstance struct {
+	Content  string `json:"content"`
+	TaskType string `json:"task_type,omitempty"`
+}
+
+type predictResponse struct {
+	Predictions []struct {
+		Embeddings struct {
+			Statistics struct {
+				TokenCount int `json:"token_count"`
+			} `json:"statistics"`
+			Values []float64 `json:"values"`
+		} `json:"embeddings"`
+	} `json:"predictions"`
+}
+
+// TransformEmbeddingRequest embeds the inputs in one batchEmbedContents
+// call, or on Vertex AI one predict call.
+func (p *Provider) TransformEmbeddingRequest(ctx context.Context, req *model.EmbeddingRequest, apiKey string) (*http.Request, error) {
+	texts, err := req.InputTexts()
+	if err != nil {
+		return nil, err
+	}
+
+	var body any
+	method := "batchEmbedContents"
+	if p.isVertex {
+		instances := make([]predictInstance, len(texts))
+		for i, text := range texts {
+			instances[i] = predictInstance{Content: text, TaskType: taskType(req.InputType)}
+		}
+		predict := map[string]any{"instances": instances}
+		if req.Dimensions != nil {
+			predict["parameters"] = map[string]any{"outputDimensionality": *req.Dimensions}
+		}
+		body, method = predict, "predict"
+	} else {
+		requests := make([]embedContentRequest, len(texts))
+		for i, text := range texts {
+			requests[i] = embedContentRequest{
+				Model:                "models/" + req.Model,
+				Content:              geminiContent{Parts: []geminiPart{{Text: text}}},
+				TaskType:             taskType(req.InputType),
+				OutputDimensionality: req.Dimensions,
+			}
+		}
+		body = map[string]any{"requests": requests}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal gemini embedding request: %w", err)
+	}
+
+	url := p.buildURL(req.Model, method, apiKey)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create gemini embedding request: %w", err)
+	}
+
+	p.SetupHeaders(httpReq, apiKey)
+	return httpReq, nil
+}
+
+// TransformEmbeddingResponse converts the embeddings to OpenAI format. Only
+// Vertex AI reports token counts.
+func (p *Provider) TransformEmbeddingResponse(_ context.Context, resp *http.Response) (*model.EmbeddingResponse, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read gemini embedding response: %w", err)
+	}
+
+	result := &model.EmbeddingResponse{Object: "list"}
+	if p.isVertex {
+		var predResp predictResponse
+		if err := json.Unmarshal(body, &predResp); err != nil {
+			return nil, fmt.Errorf("parse gemini embedding response: %w", err)
+		}
+		for i, pred := range predResp.Predictions {
+			result.Data = append(result.Data, model.EmbeddingData{Object: "embedding", Index: i, Embedding: pred.Embeddings.Values})
+			result.Usage.PromptTokens += pred.Embeddings.Statistics.TokenCount
+		}
+		result.Usage.TotalTokens = result.Usage.PromptTokens
+		return result, nil
+	}
+
+	var batchResp batchEmbedResponse
+	if err := json.Unmarshal(body, &batchResp); err != nil {
+		return nil, fmt.Errorf("parse gemini embedding response: %w", err)
+	}
+	for i, e := range batchResp.Embeddings {
+		result.Data = append(result.Data, model.EmbeddingData{Object: "embedding", Index: i, Embedding: e.Values})
+	}
+	return result, nil
+}
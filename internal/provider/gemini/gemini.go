@@ -28,8 +28,14 @@ func New() *Provider {
 }
 
 func NewVertex(projectID, location string) *Provider {
+	return NewVertexWithBaseURL(fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1", location), projectID, location)
+}
+
+// NewVertexWithBaseURL creates a Vertex AI provider calling baseURL, such as
+// a private endpoint, instead of the regional endpoint of location.
+func NewVertexWithBaseURL(baseURL, projectID, location string) *Provider {
 	return &Provider{
-		baseURL:   fmt.Sprintf("https://%s-aiplatform.googleapis.com/v1", location),
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
 		isVertex:  true,
 		projectID: projectID,
 		location:  location,
@@ -473,7 +479,7 @@ func parseErrorResponse(resp *http.Response) error {
 		Message:    msg,
 		Type:       "api_error",
 		Provider:   "gemini",
-		Err:        model.MapHTTPStatusToError(resp.StatusCode),
+		Err:        model.ClassifyProviderError(resp.StatusCode, errResp.Error.Status, msg),
 	}
 }
 
@@ -435,3 +435,74 @@ func TestTransformResponse_Error(t *testing.T) {
 	_, err := p.TransformResponse(context.Background(), resp)
 	assert.Error(t, err)
 }
+
+func TestTransformEmbeddingRequest(t *testing.T) {
+	p := New()
+	dims := 256
+	req := &model.EmbeddingRequest{
+		Model:      "text-embedding-004",
+		Input:      []any{"hello", "world"},
+		Dimensions: &dims,
+		InputType:  "search_query",
+	}
+
+	httpReq, err := p.TransformEmbeddingRequest(context.Background(), req, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "https://generativelanguage.googleapis.com/v1beta/models/text-embedding-004:batchEmbedContents?key=test-key", httpReq.URL.String())
+
+	var body struct {
+		Requests []map[string]any `json:"requests"`
+	}
+	require.NoError(t, json.NewDecoder(httpReq.Body).Decode(&body))
+	require.Len(t, body.Requests, 2)
+	assert.Equal(t, "models/text-embedding-004", body.Requests[0]["model"])
+	assert.Equal(t, "RETRIEVAL_QUERY", body.Requests[0]["taskType"])
+	assert.Equal(t, float64(256), body.Requests[0]["outputDimensionality"])
+	assert.Equal(t, map[string]any{"parts": []any{map[string]any{"text": "world"}}, "role": ""}, body.Requests[1]["content"])
+}
+
+func TestTransformEmbeddingRequest_TokenInput(t *testing.T) {
+	p := New()
+	_, err := p.TransformEmbeddingRequest(context.Background(), &model.EmbeddingRequest{
+		Model: "text-embedding-004",
+		Input: []any{float64(1), float64(2)},
+	}, "test-key")
+	assert.Error(t, err)
+}
+
+func TestTransformEmbeddingResponse(t *testing.T) {
+	p := New()
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"embeddings":[{"values":[0.1,0.2]},{"values":[0.3,0.4]}]}`))),
+	}
+
+	result, err := p.TransformEmbeddingResponse(context.Background(), resp)
+	require.NoError(t, err)
+	assert.Equal(t, "list", result.Object)
+	require.Len(t, result.Data, 2)
+	assert.Equal(t, 1, result.Data[1].Index)
+	assert.Equal(t, []float64{0.3, 0.4}, result.Data[1].Embedding)
+}
+
+func TestTransformEmbedding_Vertex(t *testing.T) {
+	p := NewVertex("my-project", "us-central1")
+	req := &model.EmbeddingRequest{Model: "text-embedding-005", Input: "hello"}
+
+	httpReq, err := p.TransformEmbeddingRequest(context.Background(), req, "token")
+	require.NoError(t, err)
+	assert.Equal(t, "https://us-central1-aiplatform.googleapis.com/v1/projects/my-project/locations/us-central1/publishers/google/models/text-embedding-005:predict", httpReq.URL.String())
+	body, _ := io.ReadAll(httpReq.Body)
+	assert.JSONEq(t, `{"instances":[{"content":"hello"}]}`, string(body))
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"predictions":[{"embeddings":{"statistics":{"token_count":3,"truncated":false},"values":[0.5,0.6]}}]}`))),
+	}
+	result, err := p.TransformEmbeddingResponse(context.Background(), resp)
+	require.NoError(t, err)
+	require.Len(t, result.Data, 1)
+	assert.Equal(t, []float64{0.5, 0.6}, result.Data[0].Embedding)
+	assert.Equal(t, 3, result.Usage.PromptTokens)
+	assert.Equal(t, 3, result.Usage.TotalTokens)
+}
@@ -0,0 +1,41 @@
+package mistral
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+// embeddingRequest is the Mistral embeddings body, which rejects the
+// OpenAI-only fields and names dimensions output_dimension. Responses are
+// in OpenAI format.
+type embeddingRequest struct {
+	Model           string `json:"model"`
+	Input           any    `json:"input"`
+	EncodingFormat  string `json:"encoding_format,omitempty"`
+	OutputDimension *int   `json:"output_dimension,omitempty"`
+}
+
+func (p *Provider) TransformEmbeddingRequest(ctx context.Context, req *model.EmbeddingRequest, apiKey string) (*http.Request, error) {
+	data, err := json.Marshal(embeddingRequest{
+		Model:           req.Model,
+		Input:           req.Input,
+		EncodingFormat:  req.EncodingFormat,
+		OutputDimension: req.Dimensions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal mistral embedding request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.BaseURL()+"/embeddings", bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create mistral embedding request: %w", err)
+	}
+
+	p.SetupHeaders(httpReq, apiKey)
+	return httpReq, nil
+}
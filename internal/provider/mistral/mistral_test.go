@@ -64,3 +64,21 @@ func TestTransformRequest(t *testing.T) {
 	body, _ := io.ReadAll(httpReq.Body)
 	assert.Contains(t, string(body), `"model":"mistral-large-latest"`)
 }
+
+func TestTransformEmbeddingRequest(t *testing.T) {
+	p := newTestProvider()
+	dims := 512
+
+	httpReq, err := p.TransformEmbeddingRequest(context.Background(), &model.EmbeddingRequest{
+		Model:      "mistral-embed",
+		Input:      []any{"hello"},
+		Dimensions: &dims,
+		User:       "user-1",
+	}, "ms-key")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.mistral.ai/v1/embeddings", httpReq.URL.String())
+	assert.Equal(t, "Bearer ms-key", httpReq.Header.Get("Authorization"))
+
+	body, _ := io.ReadAll(httpReq.Body)
+	assert.JSONEq(t, `{"model":"mistral-embed","input":["hello"],"output_dimension":512}`, string(body))
+}
@@ -0,0 +1,107 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+func (p *Provider) TransformSpeechRequest(ctx context.Context, req *model.AudioSpeechRequest, apiKey string) (*http.Request, error) {
+	return p.newJSONRequest(ctx, "/audio/speech", req, apiKey)
+}
+
+func (p *Provider) TransformSpeechResponse(_ context.Context, resp *http.Response) (*model.AudioSpeechResponse, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	audio, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read speech response: %w", err)
+	}
+	return &model.AudioSpeechResponse{Audio: audio, ContentType: resp.Header.Get("Content-Type")}, nil
+}
+
+// TransformTranscriptionRequest builds the multipart upload of
+// /audio/transcriptions.
+func (p *Provider) TransformTranscriptionRequest(ctx context.Context, req *model.AudioTranscriptionRequest, apiKey string) (*http.Request, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	fw, err := mw.CreateFormFile("file", req.FileName)
+	if err != nil {
+		return nil, fmt.Errorf("create transcription form: %w", err)
+	}
+	if _, err := fw.Write(req.File); err != nil {
+		return nil, fmt.Errorf("write transcription form: %w", err)
+	}
+
+	fields := [][2]string{{"model", req.Model}}
+	if req.Language != nil {
+		fields = append(fields, [2]string{"language", *req.Language})
+	}
+	if req.Prompt != nil {
+		fields = append(fields, [2]string{"prompt", *req.Prompt})
+	}
+	if req.ResponseFormat != nil {
+		fields = append(fields, [2]string{"response_format", *req.ResponseFormat})
+	}
+	if req.Temperature != nil {
+		fields = append(fields, [2]string{"temperature", strconv.FormatFloat(*req.Temperature, 'f', -1, 64)})
+	}
+	for _, g := range req.TimestampGranularities {
+		fields = append(fields, [2]string{"timestamp_granularities[]", g})
+	}
+	for _, f := range fields {
+		if err := mw.WriteField(f[0], f[1]); err != nil {
+			return nil, fmt.Errorf("write transcription form: %w", err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("write transcription form: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/audio/transcriptions", &buf)
+	if err != nil {
+		return nil, fmt.Errorf("create transcription request: %w", err)
+	}
+
+	p.SetupHeaders(httpReq, apiKey)
+	httpReq.Header.Set("Content-Type", mw.FormDataContentType())
+	return httpReq, nil
+}
+
+// TransformTranscriptionResponse parses a JSON transcription. The text, srt
+// and vtt response formats are returned as plain text in Text.
+func (p *Provider) TransformTranscriptionResponse(_ context.Context, resp *http.Response) (*model.AudioTranscriptionResponse, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, parseErrorResponse(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read transcription response: %w", err)
+	}
+
+	var result model.AudioTranscriptionResponse
+	if !strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		result.Text = string(body)
+		return &result, nil
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("parse transcription response: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,20 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+func (p *Provider) TransformImageRequest(ctx context.Context, req *model.ImageGenerationRequest, apiKey string) (*http.Request, error) {
+	return p.newJSONRequest(ctx, "/images/generations", req, apiKey)
+}
+
+func (p *Provider) TransformImageResponse(_ context.Context, resp *http.Response) (*model.ImageGenerationResponse, error) {
+	var result model.ImageGenerationResponse
+	if err := decodeJSONResponse(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
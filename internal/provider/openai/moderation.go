@@ -0,0 +1,20 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+func (p *Provider) TransformModerationRequest(ctx context.Context, req *model.ModerationRequest, apiKey string) (*http.Request, error) {
+	return p.newJSONRequest(ctx, "/moderations", req, apiKey)
+}
+
+func (p *Provider) TransformModerationResponse(_ context.Context, resp *http.Response) (*model.ModerationResponse, error) {
+	var result model.ModerationResponse
+	if err := decodeJSONResponse(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -85,6 +85,12 @@ func (p *Provider) GetRequestURL(modelName string) string {
 	return p.baseURL + "/chat/completions"
 }
 
+// BaseURL returns the API base URL, for providers built on Provider that
+// call endpoints of their own.
+func (p *Provider) BaseURL() string {
+	return p.baseURL
+}
+
 func (p *Provider) SetupHeaders(req *http.Request, apiKey string) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+apiKey)
@@ -151,6 +157,40 @@ func transformRequestBody(req *model.ChatCompletionRequest) map[string]any {
 	return body
 }
 
+// newJSONRequest builds a POST of v as JSON to path under the base URL.
+func (p *Provider) newJSONRequest(ctx context.Context, path string, v any, apiKey string) (*http.Request, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	p.SetupHeaders(httpReq, apiKey)
+	return httpReq, nil
+}
+
+// decodeJSONResponse decodes a successful response into v and closes it.
+func decodeJSONResponse(resp *http.Response, v any) error {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return parseErrorResponse(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("parse response: %w", err)
+	}
+	return nil
+}
+
 func parseErrorResponse(resp *http.Response) error {
 	body, _ := io.ReadAll(resp.Body)
 
@@ -164,9 +204,11 @@ func parseErrorResponse(resp *http.Response) error {
 
 	msg := string(body)
 	errType := "api_error"
+	code := ""
 	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
 		msg = errResp.Error.Message
 		errType = errResp.Error.Type
+		code = errResp.Error.Code
 	}
 
 	return &model.TianjiError{
@@ -174,7 +216,7 @@ func parseErrorResponse(resp *http.Response) error {
 		Message:    msg,
 		Type:       errType,
 		Provider:   "openai",
-		Err:        model.MapHTTPStatusToError(resp.StatusCode),
+		Err:        model.ClassifyProviderError(resp.StatusCode, code, msg),
 	}
 }
 
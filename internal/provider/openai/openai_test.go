@@ -365,3 +365,128 @@ func TestTransformEmbeddingResponse_Error(t *testing.T) {
 	_, err := p.TransformEmbeddingResponse(context.Background(), resp)
 	assert.Error(t, err)
 }
+
+func TestTransformImageRequest(t *testing.T) {
+	p := NewWithBaseURL("https://example.com/v1")
+	n := 2
+	httpReq, err := p.TransformImageRequest(context.Background(), &model.ImageGenerationRequest{Model: "dall-e-3", Prompt: "a cat", N: &n}, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/v1/images/generations", httpReq.URL.String())
+	assert.Equal(t, "Bearer test-key", httpReq.Header.Get("Authorization"))
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(httpReq.Body).Decode(&body))
+	assert.Equal(t, "a cat", body["prompt"])
+	assert.Equal(t, float64(2), body["n"])
+}
+
+func TestTransformImageResponse(t *testing.T) {
+	p := New()
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"created":1,"data":[{"b64_json":"aGk="}],"usage":{"input_tokens":10,"output_tokens":20,"total_tokens":30}}`))),
+	}
+	result, err := p.TransformImageResponse(context.Background(), resp)
+	require.NoError(t, err)
+	require.Len(t, result.Data, 1)
+	assert.Equal(t, "aGk=", result.Data[0].B64JSON)
+	assert.Equal(t, 30, result.Usage.TotalTokens)
+}
+
+func TestTransformSpeechResponse(t *testing.T) {
+	p := New()
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"audio/mpeg"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte("ID3"))),
+	}
+	result, err := p.TransformSpeechResponse(context.Background(), resp)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("ID3"), result.Audio)
+	assert.Equal(t, "audio/mpeg", result.ContentType)
+}
+
+func TestTransformTranscriptionRequest(t *testing.T) {
+	p := NewWithBaseURL("https://example.com/v1")
+	lang, temp := "en", 0.2
+	httpReq, err := p.TransformTranscriptionRequest(context.Background(), &model.AudioTranscriptionRequest{
+		Model:                  "whisper-1",
+		Language:               &lang,
+		Temperature:            &temp,
+		TimestampGranularities: []string{"word", "segment"},
+		File:                   []byte("RIFF"),
+		FileName:               "a.wav",
+	}, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/v1/audio/transcriptions", httpReq.URL.String())
+	assert.Equal(t, "Bearer test-key", httpReq.Header.Get("Authorization"))
+
+	require.NoError(t, httpReq.ParseMultipartForm(1<<20))
+	f, header, err := httpReq.FormFile("file")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(f)
+	assert.Equal(t, "RIFF", string(data))
+	assert.Equal(t, "a.wav", header.Filename)
+	assert.Equal(t, "whisper-1", httpReq.FormValue("model"))
+	assert.Equal(t, "en", httpReq.FormValue("language"))
+	assert.Equal(t, "0.2", httpReq.FormValue("temperature"))
+	assert.Equal(t, []string{"word", "segment"}, httpReq.MultipartForm.Value["timestamp_granularities[]"])
+}
+
+func TestTransformTranscriptionResponse(t *testing.T) {
+	p := New()
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"text":"hi","language":"english","duration":1.5}`))),
+	}
+	result, err := p.TransformTranscriptionResponse(context.Background(), resp)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", result.Text)
+	assert.Equal(t, 1.5, result.Duration)
+
+	resp = &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		Body:       io.NopCloser(bytes.NewReader([]byte("WEBVTT\n\nhi\n"))),
+	}
+	result, err = p.TransformTranscriptionResponse(context.Background(), resp)
+	require.NoError(t, err)
+	assert.Equal(t, "WEBVTT\n\nhi\n", result.Text)
+}
+
+func TestTransformRerank(t *testing.T) {
+	p := NewWithBaseURL("https://api.jina.ai/v1")
+	httpReq, err := p.TransformRerankRequest(context.Background(), &model.RerankRequest{Model: "jina-reranker-v2", Query: "q", Documents: []any{"a", "b"}}, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.jina.ai/v1/rerank", httpReq.URL.String())
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"model":"jina-reranker-v2","results":[{"index":1,"relevance_score":0.9,"document":{"text":"b"}}],"usage":{"total_tokens":12}}`))),
+	}
+	result, err := p.TransformRerankResponse(context.Background(), resp)
+	require.NoError(t, err)
+	require.Len(t, result.Results, 1)
+	assert.Equal(t, 1, result.Results[0].Index)
+	assert.Equal(t, map[string]any{"text": "b"}, result.Results[0].Document)
+	assert.Equal(t, 12, result.Usage.TotalTokens)
+}
+
+func TestTransformModeration(t *testing.T) {
+	p := NewWithBaseURL("https://example.com/v1")
+	httpReq, err := p.TransformModerationRequest(context.Background(), &model.ModerationRequest{Model: "omni-moderation-latest", Input: "text"}, "test-key")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/v1/moderations", httpReq.URL.String())
+
+	resp := &http.Response{
+		StatusCode: http.StatusUnauthorized,
+		Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":{"message":"bad key","type":"invalid_request_error"}}`))),
+	}
+	_, err = p.TransformModerationResponse(context.Background(), resp)
+	var tErr *model.TianjiError
+	require.ErrorAs(t, err, &tErr)
+	assert.Equal(t, http.StatusUnauthorized, tErr.StatusCode)
+	assert.Equal(t, "bad key", tErr.Message)
+}
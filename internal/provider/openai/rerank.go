@@ -0,0 +1,22 @@
+package openai
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+// TransformRerankRequest builds a request to the Cohere-compatible /rerank
+// endpoint served by Jina, vLLM, Infinity and similar backends.
+func (p *Provider) TransformRerankRequest(ctx context.Context, req *model.RerankRequest, apiKey string) (*http.Request, error) {
+	return p.newJSONRequest(ctx, "/rerank", req, apiKey)
+}
+
+func (p *Provider) TransformRerankResponse(_ context.Context, resp *http.Response) (*model.RerankResponse, error) {
+	var result model.RerankResponse
+	if err := decodeJSONResponse(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -145,12 +145,16 @@ func parseErrorResponse(resp *http.Response, providerName string) error {
 		Error struct {
 			Message string `json:"message"`
 			Type    string `json:"type"`
+			Code    any    `json:"code"`
 		} `json:"error"`
 	}
 	errType := "api_error"
+	code := ""
 	if json.Unmarshal(body, &errResp) == nil && errResp.Error.Message != "" {
 		msg = errResp.Error.Message
 		errType = errResp.Error.Type
+		// Some OpenAI-compatible APIs send numeric codes; only string codes classify.
+		code, _ = errResp.Error.Code.(string)
 	}
 
 	return &model.TianjiError{
@@ -158,6 +162,6 @@ func parseErrorResponse(resp *http.Response, providerName string) error {
 		Message:    msg,
 		Type:       errType,
 		Provider:   providerName,
-		Err:        model.MapHTTPStatusToError(resp.StatusCode),
+		Err:        model.ClassifyProviderError(resp.StatusCode, code, msg),
 	}
 }
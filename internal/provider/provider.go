@@ -18,8 +18,9 @@ type Provider interface {
 	// into an OpenAI-compatible ModelResponse.
 	TransformResponse(ctx context.Context, resp *http.Response) (*model.ModelResponse, error)
 
-	// TransformStreamChunk converts a single SSE data line from
-	// the provider into an OpenAI-compatible StreamChunk.
+	// TransformStreamChunk converts a single stream event from the
+	// provider, an SSE data line unless the provider is a StreamDecoder,
+	// into an OpenAI-compatible StreamChunk.
 	TransformStreamChunk(ctx context.Context, data []byte) (*model.StreamChunk, bool, error)
 
 	// GetSupportedParams returns the list of parameter names this
@@ -42,3 +43,43 @@ type EmbeddingProvider interface {
 	TransformEmbeddingRequest(ctx context.Context, req *model.EmbeddingRequest, apiKey string) (*http.Request, error)
 	TransformEmbeddingResponse(ctx context.Context, resp *http.Response) (*model.EmbeddingResponse, error)
 }
+
+// EmbeddingSplitter is implemented by embedding providers whose models may
+// embed only one input per call, such as Bedrock Titan. Each request
+// SplitEmbeddingRequest returns is sent as its own call and the results are
+// merged in input order.
+type EmbeddingSplitter interface {
+	// SplitEmbeddingRequest returns the requests to send for req, or nil
+	// when req is sent as it is.
+	SplitEmbeddingRequest(req *model.EmbeddingRequest) []*model.EmbeddingRequest
+}
+
+// ImageProvider extends Provider with image generation support.
+type ImageProvider interface {
+	TransformImageRequest(ctx context.Context, req *model.ImageGenerationRequest, apiKey string) (*http.Request, error)
+	TransformImageResponse(ctx context.Context, resp *http.Response) (*model.ImageGenerationResponse, error)
+}
+
+// SpeechProvider extends Provider with text-to-speech support.
+type SpeechProvider interface {
+	TransformSpeechRequest(ctx context.Context, req *model.AudioSpeechRequest, apiKey string) (*http.Request, error)
+	TransformSpeechResponse(ctx context.Context, resp *http.Response) (*model.AudioSpeechResponse, error)
+}
+
+// TranscriptionProvider extends Provider with speech-to-text support.
+type TranscriptionProvider interface {
+	TransformTranscriptionRequest(ctx context.Context, req *model.AudioTranscriptionRequest, apiKey string) (*http.Request, error)
+	TransformTranscriptionResponse(ctx context.Context, resp *http.Response) (*model.AudioTranscriptionResponse, error)
+}
+
+// RerankProvider extends Provider with document reranking support.
+type RerankProvider interface {
+	TransformRerankRequest(ctx context.Context, req *model.RerankRequest, apiKey string) (*http.Request, error)
+	TransformRerankResponse(ctx context.Context, resp *http.Response) (*model.RerankResponse, error)
+}
+
+// ModerationProvider extends Provider with content moderation support.
+type ModerationProvider interface {
+	TransformModerationRequest(ctx context.Context, req *model.ModerationRequest, apiKey string) (*http.Request, error)
+	TransformModerationResponse(ctx context.Context, resp *http.Response) (*model.ModerationResponse, error)
+}
@@ -11,8 +11,36 @@ var (
 	mu             sync.RWMutex
 	registry       = make(map[string]Provider)
 	baseURLFactory func(baseURL string) Provider
+	factories      = make(map[string]Factory)
+	configured     = make(map[configuredKey]Provider)
 )
 
+// Params are the settings of one deployment a provider may be configured
+// with, taken from its tianji_params.
+type Params struct {
+	APIBase    string
+	APIVersion string
+
+	AWSRegionName      string
+	AWSAccessKeyID     string
+	AWSSecretAccessKey string
+	AWSSessionToken    string
+	AWSRoleName        string
+	AWSSessionName     string
+
+	VertexProject     string
+	VertexLocation    string
+	VertexCredentials string
+}
+
+// Factory builds a provider configured for one deployment.
+type Factory func(Params) (Provider, error)
+
+type configuredKey struct {
+	name   string
+	params Params
+}
+
 // Register adds a provider to the global registry.
 // Typically called from provider package init() functions.
 func Register(name string, p Provider) {
@@ -30,6 +58,43 @@ func RegisterBaseURLFactory(f func(baseURL string) Provider) {
 	baseURLFactory = f
 }
 
+// RegisterFactory registers a factory for providers that need per-deployment
+// settings, such as credentials or a region.
+func RegisterFactory(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = f
+}
+
+// GetConfigured returns the provider for a deployment. Providers with a
+// factory are built once per distinct params and shared, so state such as
+// cached credentials outlives a request; others resolve as GetWithBaseURL.
+func GetConfigured(name string, params Params) (Provider, error) {
+	key := configuredKey{name: name, params: params}
+	mu.RLock()
+	f, ok := factories[name]
+	p := configured[key]
+	mu.RUnlock()
+	if !ok {
+		return GetWithBaseURL(name, params.APIBase)
+	}
+	if p != nil {
+		return p, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if p := configured[key]; p != nil {
+		return p, nil
+	}
+	p, err := f(params)
+	if err != nil {
+		return nil, fmt.Errorf("provider %q: %w", name, err)
+	}
+	configured[key] = p
+	return p, nil
+}
+
 // Get returns a provider by name. Returns an error if not found.
 func Get(name string) (Provider, error) {
 	mu.RLock()
@@ -30,3 +30,36 @@ func TestGetUnknown(t *testing.T) {
 		t.Fatal("expected error for unknown provider")
 	}
 }
+
+type stubProvider struct {
+	Provider
+	region string
+}
+
+func TestGetConfigured(t *testing.T) {
+	built := 0
+	RegisterFactory("test-configured", func(p Params) (Provider, error) {
+		built++
+		return &stubProvider{region: p.AWSRegionName}, nil
+	})
+
+	a, err := GetConfigured("test-configured", Params{AWSRegionName: "eu-west-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GetConfigured("test-configured", Params{AWSRegionName: "eu-west-1"})
+	c, _ := GetConfigured("test-configured", Params{AWSRegionName: "us-west-2"})
+	if a != b {
+		t.Error("same params should share a provider")
+	}
+	if a == c || c.(*stubProvider).region != "us-west-2" {
+		t.Error("different params should get their own provider")
+	}
+	if built != 2 {
+		t.Errorf("built %d providers, want 2", built)
+	}
+
+	if _, err := GetConfigured("nonexistent-provider-xyz", Params{}); err == nil {
+		t.Fatal("expected error for unknown provider")
+	}
+}
@@ -0,0 +1,62 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// StreamReader yields the events of a streaming upstream response, each in
+// the form the provider's TransformStreamChunk takes.
+type StreamReader interface {
+	// Next returns the next event, or io.EOF at the end of the stream.
+	Next() ([]byte, error)
+}
+
+// StreamDecoder is implemented by providers whose streaming responses are
+// not line-delimited SSE, such as newline-delimited JSON or AWS event
+// streams, or whose SSE events need more than their data lines.
+type StreamDecoder interface {
+	// DecodeStream returns a reader over the events of resp's body.
+	DecodeStream(resp *http.Response) StreamReader
+}
+
+// DecodeStream returns the events of a streaming response of p: through its
+// StreamDecoder when it has one, else as SSE data lines.
+func DecodeStream(p Provider, resp *http.Response) StreamReader {
+	if d, ok := p.(StreamDecoder); ok {
+		return d.DecodeStream(resp)
+	}
+	return NewSSEReader(resp.Body)
+}
+
+// NewSSEReader returns a StreamReader yielding the payload of each SSE
+// "data:" line of r. Other lines, such as "event:" names and comments, are
+// skipped.
+func NewSSEReader(r io.Reader) StreamReader {
+	return &sseReader{r: bufio.NewReader(r)}
+}
+
+type sseReader struct {
+	r *bufio.Reader
+}
+
+func (s *sseReader) Next() ([]byte, error) {
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if len(line) > 0 {
+			line = bytes.TrimRight(line, "\r\n")
+			if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+				return bytes.TrimPrefix(data, []byte(" ")), nil
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func readAll(t *testing.T, r StreamReader) []string {
+	t.Helper()
+	var events []string
+	for {
+		data, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, string(data))
+	}
+}
+
+func TestSSEReader(t *testing.T) {
+	body := "event: message_start\r\n" +
+		"data: {\"a\":1}\r\n" +
+		"\r\n" +
+		": keep-alive\n" +
+		"data:{\"b\":2}\n" +
+		"\n" +
+		"data: [DONE]"
+
+	got := readAll(t, NewSSEReader(strings.NewReader(body)))
+	want := []string{`{"a":1}`, `{"b":2}`, `[DONE]`}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("events = %q, want %q", got, want)
+	}
+}
+
+type ndjsonProvider struct {
+	Provider
+}
+
+func (ndjsonProvider) DecodeStream(resp *http.Response) StreamReader {
+	return &lineReader{s: bufio.NewScanner(resp.Body)}
+}
+
+type lineReader struct{ s *bufio.Scanner }
+
+func (l *lineReader) Next() ([]byte, error) {
+	for l.s.Scan() {
+		if line := bytes.TrimSpace(l.s.Bytes()); len(line) > 0 {
+			return line, nil
+		}
+	}
+	return nil, io.EOF
+}
+
+func TestDecodeStream(t *testing.T) {
+	body := "{\"message\":\"a\"}\n{\"message\":\"b\",\"done\":true}\n"
+	resp := &http.Response{Body: io.NopCloser(strings.NewReader(body))}
+	if got := readAll(t, DecodeStream(ndjsonProvider{}, resp)); len(got) != 2 {
+		t.Fatalf("decoder events = %q, want 2", got)
+	}
+
+	// Providers without a decoder are read as SSE.
+	resp = &http.Response{Body: io.NopCloser(strings.NewReader(body))}
+	if got := readAll(t, DecodeStream(&stubProvider{}, resp)); len(got) != 0 {
+		t.Fatalf("SSE events = %q, want none", got)
+	}
+}
@@ -5,20 +5,41 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider/gemini"
+	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// Config configures a Provider for one deployment.
+type Config struct {
+	// Project defaults to VERTEX_PROJECT, then the project of Credentials.
+	Project string
+	// Location defaults to VERTEX_LOCATION, then us-central1.
+	Location string
+	// Credentials is a service account key, as JSON or the path of a JSON
+	// file. Without it Application Default Credentials are used.
+	Credentials string
+	// APIBase replaces the regional endpoint
+	// https://{location}-aiplatform.googleapis.com/v1, for private
+	// endpoints and proxies.
+	APIBase string
+}
+
 // Provider wraps Gemini with Vertex AI regional endpoints and OAuth2 auth.
 type Provider struct {
 	inner     *gemini.Provider
 	projectID string
 	location  string
+	// tokenSource is nil to use Application Default Credentials.
+	tokenSource oauth2.TokenSource
 
 	mu          sync.RWMutex
 	accessToken string
@@ -36,6 +57,45 @@ func New(projectID, location string) *Provider {
 	}
 }
 
+// NewWithConfig creates a provider for cfg, loading its credentials.
+func NewWithConfig(cfg Config) (*Provider, error) {
+	if cfg.Project == "" {
+		cfg.Project = os.Getenv("VERTEX_PROJECT")
+	}
+	if cfg.Location == "" {
+		cfg.Location = os.Getenv("VERTEX_LOCATION")
+	}
+	if cfg.Credentials == "" {
+		return withAPIBase(New(cfg.Project, cfg.Location), cfg.APIBase), nil
+	}
+
+	data := []byte(cfg.Credentials)
+	if !strings.HasPrefix(strings.TrimSpace(cfg.Credentials), "{") {
+		var err error
+		if data, err = os.ReadFile(cfg.Credentials); err != nil {
+			return nil, fmt.Errorf("read vertex_credentials: %w", err)
+		}
+	}
+	creds, err := google.CredentialsFromJSONWithType(context.Background(), data, google.ServiceAccount, cloudPlatformScope)
+	if err != nil {
+		return nil, fmt.Errorf("parse vertex_credentials: %w", err)
+	}
+	if cfg.Project == "" {
+		cfg.Project = creds.ProjectID
+	}
+	p := withAPIBase(New(cfg.Project, cfg.Location), cfg.APIBase)
+	p.tokenSource = creds.TokenSource
+	return p, nil
+}
+
+// withAPIBase points p at apiBase when it is set.
+func withAPIBase(p *Provider, apiBase string) *Provider {
+	if apiBase != "" {
+		p.inner = gemini.NewVertexWithBaseURL(apiBase, p.projectID, p.location)
+	}
+	return p
+}
+
 func (p *Provider) TransformRequest(ctx context.Context, req *model.ChatCompletionRequest, apiKey string) (*http.Request, error) {
 	// If apiKey is empty, try to get an OAuth2 token via ADC.
 	if apiKey == "" {
@@ -52,6 +112,21 @@ func (p *Provider) TransformResponse(ctx context.Context, resp *http.Response) (
 	return p.inner.TransformResponse(ctx, resp)
 }
 
+func (p *Provider) TransformEmbeddingRequest(ctx context.Context, req *model.EmbeddingRequest, apiKey string) (*http.Request, error) {
+	if apiKey == "" {
+		token, err := p.getAccessToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("vertex_ai auth: %w", err)
+		}
+		apiKey = token
+	}
+	return p.inner.TransformEmbeddingRequest(ctx, req, apiKey)
+}
+
+func (p *Provider) TransformEmbeddingResponse(ctx context.Context, resp *http.Response) (*model.EmbeddingResponse, error) {
+	return p.inner.TransformEmbeddingResponse(ctx, resp)
+}
+
 func (p *Provider) TransformStreamChunk(ctx context.Context, data []byte) (*model.StreamChunk, bool, error) {
 	return p.inner.TransformStreamChunk(ctx, data)
 }
@@ -89,12 +164,16 @@ func (p *Provider) getAccessToken(ctx context.Context) (string, error) {
 		return p.accessToken, nil
 	}
 
-	creds, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloud-platform")
-	if err != nil {
-		return "", fmt.Errorf("find default credentials: %w", err)
+	ts := p.tokenSource
+	if ts == nil {
+		creds, err := google.FindDefaultCredentials(ctx, cloudPlatformScope)
+		if err != nil {
+			return "", fmt.Errorf("find default credentials: %w", err)
+		}
+		ts = creds.TokenSource
 	}
 
-	token, err := creds.TokenSource.Token()
+	token, err := ts.Token()
 	if err != nil {
 		return "", fmt.Errorf("get token: %w", err)
 	}
@@ -108,4 +187,12 @@ func init() {
 	projectID := os.Getenv("VERTEX_PROJECT")
 	location := os.Getenv("VERTEX_LOCATION")
 	provider.Register("vertex_ai", New(projectID, location))
+	provider.RegisterFactory("vertex_ai", func(params provider.Params) (provider.Provider, error) {
+		return NewWithConfig(Config{
+			Project:     params.VertexProject,
+			Location:    params.VertexLocation,
+			Credentials: params.VertexCredentials,
+			APIBase:     params.APIBase,
+		})
+	})
 }
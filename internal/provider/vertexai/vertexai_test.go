@@ -3,13 +3,20 @@ package vertexai
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -105,7 +112,76 @@ func TestSageMaker_MockResponse(t *testing.T) {
 	assert.Equal(t, "Hi from SageMaker", result.Choices[0].Message.Content)
 }
 
+func TestVertexAI_NewWithConfig_ServiceAccount(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.Form.Get("grant_type"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"sa-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	creds, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "sa-project",
+		"private_key_id": "key-1",
+		"private_key":    string(keyPEM),
+		"client_email":   "tianji@sa-project.iam.gserviceaccount.com",
+		"token_uri":      tokenServer.URL,
+	})
+	require.NoError(t, err)
+
+	t.Setenv("VERTEX_PROJECT", "")
+	t.Setenv("VERTEX_LOCATION", "")
+	p, err := NewWithConfig(Config{Location: "europe-west4", Credentials: string(creds)})
+	require.NoError(t, err)
+
+	req := &model.ChatCompletionRequest{
+		Model:    "gemini-2.0-flash",
+		Messages: []model.Message{{Role: "user", Content: "Hello"}},
+	}
+	httpReq, err := p.TransformRequest(context.Background(), req, "")
+	require.NoError(t, err)
+
+	assert.Contains(t, httpReq.URL.String(), "europe-west4-aiplatform.googleapis.com")
+	assert.Contains(t, httpReq.URL.String(), "projects/sa-project/locations/europe-west4")
+	assert.Equal(t, "Bearer sa-token", httpReq.Header.Get("Authorization"))
+}
+
+func TestVertexAI_NewWithConfig_CredentialsFile(t *testing.T) {
+	_, err := NewWithConfig(Config{Credentials: filepath.Join(t.TempDir(), "missing.json")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "vertex_credentials")
+}
+
+func TestVertexAI_NewWithConfig_APIBase(t *testing.T) {
+	p, err := NewWithConfig(Config{Project: "proj", Location: "europe-west4", APIBase: "https://vertex.internal/v1/"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://vertex.internal/v1/projects/proj/locations/europe-west4/publishers/google/models/gemini-2.0-flash:generateContent", p.GetRequestURL("gemini-2.0-flash"))
+
+	f, err := provider.GetConfigured("vertex_ai", provider.Params{VertexProject: "proj", VertexLocation: "us-east1", APIBase: "https://vertex.internal/v1"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(f.GetRequestURL("gemini-2.0-flash"), "https://vertex.internal/v1/projects/proj/locations/us-east1/"))
+}
+
 // Interface compliance
 var _ interface {
 	TransformRequest(context.Context, *model.ChatCompletionRequest, string) (*http.Request, error)
 } = (*Provider)(nil)
+
+func TestVertexAI_TransformEmbeddingRequest(t *testing.T) {
+	p := New("my-project", "europe-west4")
+
+	httpReq, err := p.TransformEmbeddingRequest(context.Background(), &model.EmbeddingRequest{
+		Model: "text-embedding-005",
+		Input: []any{"a", "b"},
+	}, "test-token")
+	require.NoError(t, err)
+
+	assert.Contains(t, httpReq.URL.String(), "europe-west4-aiplatform.googleapis.com")
+	assert.Contains(t, httpReq.URL.String(), "text-embedding-005:predict")
+	assert.Equal(t, "Bearer test-token", httpReq.Header.Get("Authorization"))
+}
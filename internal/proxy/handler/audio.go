@@ -1,15 +1,27 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/callback"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
-	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
+	"github.com/praxisllmlab/tianjiLLM/internal/provider"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
 )
 
-// AudioTranscription handles POST /v1/audio/transcriptions.
-// This is a multipart form upload — we proxy the raw request body.
+// AudioTranscription handles POST /v1/audio/transcriptions, a multipart
+// form upload of the audio file and the request's fields.
 func (h *Handlers) AudioTranscription(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
 	modelName := r.FormValue("model")
 	if modelName == "" {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
@@ -21,7 +33,12 @@ func (h *Handlers) AudioTranscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p, apiKey, _, err := h.resolveProviderFromConfig(modelName)
+	modelName, ok := authorizeModel(w, r, modelName)
+	if !ok {
+		return
+	}
+
+	req, err := transcriptionRequest(r)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
 			Error: model.ErrorDetail{
@@ -31,18 +48,92 @@ func (h *Handlers) AudioTranscription(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	req.Model = modelName
+
+	resp, exec, ok := h.dispatchCapability(w, r, req.Model, capTranscription, func(ctx context.Context, a router.Attempt) (*http.Request, error) {
+		attemptReq := *req
+		attemptReq.Model = a.Model
+		return a.Provider.(provider.TranscriptionProvider).TransformTranscriptionRequest(ctx, &attemptReq, a.APIKey)
+	})
+	if !ok {
+		return
+	}
+	defer resp.Body.Close()
 
-	url := p.GetRequestURL(modelName)
-	url = url[:len(url)-len("/chat/completions")] + "/audio/transcriptions"
+	result, err := exec.Final.Provider.(provider.TranscriptionProvider).TransformTranscriptionResponse(r.Context(), resp)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
 
-	// Phase 2: provider.resolved
-	middleware.LogProviderResolved(r.Context(), h.lookupProviderName(modelName), url, "audio_transcription", modelName)
+	data := callback.LogData{StartTime: startTime, CallType: capTranscription.callType}
+	seconds := result.Duration
+	if u := result.Usage; u != nil {
+		if u.Seconds > 0 {
+			seconds = u.Seconds
+		}
+		data.PromptTokens = u.InputTokens
+		data.CompletionTokens = u.OutputTokens
+		data.TotalTokens = u.TotalTokens
+	}
+	h.logCapabilitySuccess(r.Context(), exec, data, pricing.UnitUsage{Seconds: seconds})
 
-	proxyUpstream(w, r, url, apiKey, p)
+	if req.ResponseFormat != nil {
+		switch *req.ResponseFormat {
+		case "text", "srt", "vtt":
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			_, _ = io.WriteString(w, result.Text)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// transcriptionRequest reads the uploaded file and optional fields of a
+// transcription form. The model is left to the caller.
+func transcriptionRequest(r *http.Request) (*model.AudioTranscriptionRequest, error) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return nil, errors.New("file is required")
+	}
+	defer file.Close()
+	audio, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("read file: %w", err)
+	}
+
+	req := &model.AudioTranscriptionRequest{
+		File:                   audio,
+		FileName:               header.Filename,
+		Language:               formValue(r, "language"),
+		Prompt:                 formValue(r, "prompt"),
+		ResponseFormat:         formValue(r, "response_format"),
+		TimestampGranularities: r.MultipartForm.Value["timestamp_granularities[]"],
+	}
+	if v := formValue(r, "temperature"); v != nil {
+		t, err := strconv.ParseFloat(*v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid temperature %q", *v)
+		}
+		req.Temperature = &t
+	}
+	return req, nil
+}
+
+// formValue returns the form field key, or nil when it is empty.
+func formValue(r *http.Request, key string) *string {
+	v := r.FormValue(key)
+	if v == "" {
+		return nil
+	}
+	return &v
 }
 
 // AudioSpeech handles POST /v1/audio/speech.
 func (h *Handlers) AudioSpeech(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
 	var req model.AudioSpeechRequest
 	if err := decodeJSON(r, &req); err != nil {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
@@ -54,22 +145,37 @@ func (h *Handlers) AudioSpeech(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p, apiKey, _, err := h.resolveProviderFromConfig(req.Model)
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: err.Error(),
-				Type:    "invalid_request_error",
-			},
-		})
+	var ok bool
+	if req.Model, ok = authorizeModel(w, r, req.Model); !ok {
 		return
 	}
 
-	url := p.GetRequestURL(req.Model)
-	url = url[:len(url)-len("/chat/completions")] + "/audio/speech"
+	resp, exec, ok := h.dispatchCapability(w, r, req.Model, capSpeech, func(ctx context.Context, a router.Attempt) (*http.Request, error) {
+		attemptReq := req
+		attemptReq.Model = a.Model
+		return a.Provider.(provider.SpeechProvider).TransformSpeechRequest(ctx, &attemptReq, a.APIKey)
+	})
+	if !ok {
+		return
+	}
+	defer resp.Body.Close()
 
-	// Phase 2: provider.resolved
-	middleware.LogProviderResolved(r.Context(), h.lookupProviderName(req.Model), url, "audio_speech", req.Model)
+	result, err := exec.Final.Provider.(provider.SpeechProvider).TransformSpeechResponse(r.Context(), resp)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
 
-	proxyUpstream(w, r, url, apiKey, p)
+	h.logCapabilitySuccess(r.Context(), exec, callback.LogData{
+		StartTime: startTime,
+		CallType:  capSpeech.callType,
+	}, pricing.UnitUsage{Characters: utf8.RuneCountInString(req.Input)})
+
+	contentType := result.ContentType
+	if contentType == "" {
+		contentType = "audio/mpeg"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(result.Audio)
 }
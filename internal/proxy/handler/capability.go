@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/callback"
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
+	"github.com/praxisllmlab/tianjiLLM/internal/provider"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
+)
+
+// capability is a non-chat provider capability the proxy dispatches to.
+type capability struct {
+	// callType is the LogData.CallType of the capability's calls.
+	callType string
+	// name completes "provider does not support ...".
+	name     string
+	supports func(p provider.Provider) bool
+}
+
+var (
+	capEmbedding = capability{"embedding", "embeddings", func(p provider.Provider) bool {
+		_, ok := p.(provider.EmbeddingProvider)
+		return ok
+	}}
+	capImageGeneration = capability{"image_generation", "image generation", func(p provider.Provider) bool {
+		_, ok := p.(provider.ImageProvider)
+		return ok
+	}}
+	capSpeech = capability{"audio_speech", "audio speech", func(p provider.Provider) bool {
+		_, ok := p.(provider.SpeechProvider)
+		return ok
+	}}
+	capTranscription = capability{"audio_transcription", "audio transcription", func(p provider.Provider) bool {
+		_, ok := p.(provider.TranscriptionProvider)
+		return ok
+	}}
+	capRerank = capability{"rerank", "rerank", func(p provider.Provider) bool {
+		_, ok := p.(provider.RerankProvider)
+		return ok
+	}}
+	capModeration = capability{"moderation", "moderation", func(p provider.Provider) bool {
+		_, ok := p.(provider.ModerationProvider)
+		return ok
+	}}
+)
+
+// dispatchCapability resolves modelGroup and sends the request built by
+// build, which is only called with attempts whose provider supports c. With
+// the Router, deployments without c are skipped and failed attempts are
+// retried on other deployments and fallback model groups. On failure the
+// error response is written and ok is false.
+func (h *Handlers) dispatchCapability(w http.ResponseWriter, r *http.Request, modelGroup string, c capability, build func(ctx context.Context, a router.Attempt) (*http.Request, error)) (*http.Response, *router.Execution, bool) {
+	return h.dispatchCapabilityCall(w, r, modelGroup, c, func(ctx context.Context, a router.Attempt) (*http.Response, error) {
+		httpReq, err := build(ctx, a)
+		if err != nil {
+			return nil, buildError(err)
+		}
+		return upstreamClient(a).Do(httpReq)
+	})
+}
+
+// dispatchCapabilityCall is dispatchCapability for capabilities that make
+// their own upstream calls, which call performs for an attempt.
+func (h *Handlers) dispatchCapabilityCall(w http.ResponseWriter, r *http.Request, modelGroup string, c capability, call func(ctx context.Context, a router.Attempt) (*http.Response, error)) (*http.Response, *router.Execution, bool) {
+	d, p, apiKey, modelName, err := h.resolveDeployment(r.Context(), modelGroup, nil)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, router.ErrNoDeployments) || strings.Contains(err.Error(), "not found") {
+			status = http.StatusNotFound
+		} else if errors.Is(err, router.ErrAtCapacity) {
+			status = http.StatusTooManyRequests
+		}
+		writeJSON(w, status, model.ErrorResponse{
+			Error: model.ErrorDetail{
+				Message: err.Error(),
+				Type:    "invalid_request_error",
+			},
+		})
+		return nil, nil, false
+	}
+
+	// Phase 2: provider.resolved
+	middleware.LogProviderResolved(r.Context(), h.lookupProviderName(modelGroup), p.GetRequestURL(modelName), c.callType, modelName)
+
+	first := router.Attempt{ModelGroup: modelGroup, Deployment: d, Provider: p, APIKey: apiKey, Model: modelName}
+	upstreamStart := time.Now()
+	resp, exec, err := h.executeUpstream(r.Context(), first, nil, func(ctx context.Context, a router.Attempt) (*http.Response, error) {
+		if !c.supports(a.Provider) {
+			return nil, fmt.Errorf("provider does not support %s: %w", c.name, router.ErrUnsupported)
+		}
+		return call(ctx, a)
+	})
+	upstreamLatency := middleware.UpstreamLatencyMs(upstreamStart)
+	setExecutionHeaders(w, exec)
+	if err != nil {
+		if errors.Is(err, router.ErrUnsupported) {
+			msg := "provider does not support " + c.name
+			var inputErr *model.InputError
+			if errors.As(err, &inputErr) {
+				msg = inputErr.Error()
+			}
+			writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+				Error: model.ErrorDetail{
+					Message: msg,
+					Type:    "invalid_request_error",
+				},
+			})
+			return nil, nil, false
+		}
+		if errors.Is(err, router.ErrAtCapacity) {
+			writeAtCapacity(w, err)
+			return nil, nil, false
+		}
+		if errors.Is(err, errTransformRequest) {
+			writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+				Error: model.ErrorDetail{
+					Message: err.Error(),
+					Type:    "internal_error",
+				},
+			})
+			return nil, nil, false
+		}
+		middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
+			LatencyMs: upstreamLatency,
+			Error:     err.Error(),
+		})
+		writeJSON(w, http.StatusBadGateway, model.ErrorResponse{
+			Error: model.ErrorDetail{
+				Message: "upstream request failed: " + err.Error(),
+				Type:    "internal_error",
+			},
+		})
+		return nil, nil, false
+	}
+
+	// Phase 3: upstream.responded
+	middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
+		StatusCode: resp.StatusCode,
+		LatencyMs:  upstreamLatency,
+	})
+	return resp, exec, true
+}
+
+// buildError marks a failure to build an attempt's upstream request. Input
+// the provider cannot send skips the deployment, as another deployment's
+// provider may accept it; anything else is an internal error.
+func buildError(err error) error {
+	var inputErr *model.InputError
+	if errors.As(err, &inputErr) {
+		return fmt.Errorf("%w: %w", router.ErrUnsupported, err)
+	}
+	return fmt.Errorf("%w: %w", errTransformRequest, err)
+}
+
+// logCapabilitySuccess records the tokens of a successful capability call
+// against the caller's limits and reports the call to the callbacks. Calls
+// billed by units are priced here, tokens included; the rest are priced
+// from their tokens by the spend tracker.
+func (h *Handlers) logCapabilitySuccess(ctx context.Context, exec *router.Execution, data callback.LogData, units pricing.UnitUsage) {
+	h.recordUsage(router.WithExecution(ctx, exec), data.TotalTokens)
+	if h.Callbacks == nil {
+		return
+	}
+
+	data.Model = exec.Final.Model
+	data.EndTime = time.Now()
+	data.Latency = data.EndTime.Sub(data.StartTime)
+	data.DeploymentID = exec.DeploymentID()
+	data.AttemptedDeployments = exec.Tried
+	if exec.Final.Deployment != nil {
+		// Registry name (e.g. "groq"), which provider budgets are keyed by.
+		data.Provider = exec.Final.Deployment.ProviderName
+	}
+	if cost := pricing.Default().UnitCost(data.Model, units); cost > 0 {
+		data.Cost = cost + pricing.Default().TotalCost(data.Model, pricing.TokenUsage{
+			PromptTokens:     data.PromptTokens,
+			CompletionTokens: data.CompletionTokens,
+		})
+	}
+	setCaller(ctx, &data)
+	go h.Callbacks.LogSuccess(data)
+	h.logHedges(router.WithExecution(ctx, exec), data)
+}
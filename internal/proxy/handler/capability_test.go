@@ -0,0 +1,386 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/callback"
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
+	"github.com/praxisllmlab/tianjiLLM/internal/router/strategy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "github.com/praxisllmlab/tianjiLLM/internal/provider/bedrock"
+	_ "github.com/praxisllmlab/tianjiLLM/internal/provider/deepgram"
+	_ "github.com/praxisllmlab/tianjiLLM/internal/provider/openai"
+)
+
+// capabilityTestHandlers routes modelName to an OpenAI-compatible upstream.
+func capabilityTestHandlers(modelName, upstreamModel, upstreamURL string) (*Handlers, *spyLogger) {
+	apiKey := "test-key"
+	models := []config.ModelConfig{{
+		ModelName: modelName,
+		TianjiParams: config.TianjiParams{
+			Model:   upstreamModel,
+			APIKey:  &apiKey,
+			APIBase: &upstreamURL,
+		},
+	}}
+	spy := newSpyLogger()
+	reg := callback.NewRegistry()
+	reg.Register(spy)
+	return &Handlers{
+		Config:    &config.ProxyConfig{ModelList: models},
+		Router:    router.New(models, strategy.NewShuffle(), router.RouterSettings{}),
+		Callbacks: reg,
+	}, spy
+}
+
+func TestImageGeneration_RoutedWithSpend(t *testing.T) {
+	var gotPath, gotModel string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		gotModel, _ = body["model"].(string)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"created":1,"data":[{"url":"https://img/1"},{"url":"https://img/2"}]}`))
+	}))
+	defer upstream.Close()
+
+	h, spy := capabilityTestHandlers("images", "openai/dall-e-3", upstream.URL)
+	req := httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(`{"model":"images","prompt":"a cat","n":2}`))
+	w := httptest.NewRecorder()
+	h.ImageGeneration(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "/images/generations", gotPath)
+	assert.Equal(t, "dall-e-3", gotModel)
+	assert.NotEmpty(t, w.Header().Get("X-TianjiLLM-Model-ID"))
+	assert.Contains(t, w.Body.String(), "https://img/2")
+
+	spy.waitCalled(t, 2*time.Second)
+	data := spy.lastCall(t)
+	assert.Equal(t, "image_generation", data.CallType)
+	assert.Equal(t, "dall-e-3", data.Model)
+	assert.NotEmpty(t, data.DeploymentID)
+	assert.InDelta(t, 0.08, data.Cost, 1e-9, "two images at dall-e-3's per-image price")
+}
+
+func TestAudioSpeech_RelaysAudio(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/audio/speech", r.URL.Path)
+		w.Header().Set("Content-Type", "audio/opus")
+		_, _ = w.Write([]byte("OggS-audio"))
+	}))
+	defer upstream.Close()
+
+	h, spy := capabilityTestHandlers("tts", "openai/tts-1", upstream.URL)
+	req := httptest.NewRequest(http.MethodPost, "/v1/audio/speech", strings.NewReader(`{"model":"tts","input":"hello world","voice":"alloy","response_format":"opus"}`))
+	w := httptest.NewRecorder()
+	h.AudioSpeech(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "audio/opus", w.Header().Get("Content-Type"))
+	assert.Equal(t, "OggS-audio", w.Body.String())
+
+	spy.waitCalled(t, 2*time.Second)
+	data := spy.lastCall(t)
+	assert.Equal(t, "audio_speech", data.CallType)
+	assert.InDelta(t, 11*0.000015, data.Cost, 1e-12, "priced per input character")
+}
+
+func transcriptionForm(t *testing.T, fields map[string]string) (*bytes.Buffer, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("file", "speech.mp3")
+	require.NoError(t, err)
+	_, _ = fw.Write([]byte("ID3-audio"))
+	for k, v := range fields {
+		require.NoError(t, mw.WriteField(k, v))
+	}
+	require.NoError(t, mw.Close())
+	return &buf, mw.FormDataContentType()
+}
+
+func TestAudioTranscription_UploadsFile(t *testing.T) {
+	var gotFile, gotModel, gotLanguage string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/audio/transcriptions", r.URL.Path)
+		f, _, err := r.FormFile("file")
+		if assert.NoError(t, err) {
+			data, _ := io.ReadAll(f)
+			gotFile = string(data)
+		}
+		gotModel, gotLanguage = r.FormValue("model"), r.FormValue("language")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"text":"hello there","usage":{"type":"duration","seconds":30}}`))
+	}))
+	defer upstream.Close()
+
+	h, spy := capabilityTestHandlers("stt", "openai/whisper-1", upstream.URL)
+	body, contentType := transcriptionForm(t, map[string]string{"model": "stt", "language": "en"})
+	req := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	h.AudioTranscription(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "ID3-audio", gotFile)
+	assert.Equal(t, "whisper-1", gotModel)
+	assert.Equal(t, "en", gotLanguage)
+	assert.Contains(t, w.Body.String(), `"text":"hello there"`)
+
+	spy.waitCalled(t, 2*time.Second)
+	data := spy.lastCall(t)
+	assert.Equal(t, "audio_transcription", data.CallType)
+	assert.InDelta(t, 30*0.0001, data.Cost, 1e-12, "priced per second of audio")
+}
+
+func TestAudioTranscription_TextFormat(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("1\n00:00:00,000 --> 00:00:01,000\nhello\n"))
+	}))
+	defer upstream.Close()
+
+	h, _ := capabilityTestHandlers("stt", "openai/whisper-1", upstream.URL)
+	body, contentType := transcriptionForm(t, map[string]string{"model": "stt", "response_format": "srt"})
+	req := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", body)
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	h.AudioTranscription(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:01,000\nhello\n", w.Body.String())
+}
+
+func TestAudioTranscription_MissingFile(t *testing.T) {
+	h, _ := capabilityTestHandlers("stt", "openai/whisper-1", "http://unused")
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	require.NoError(t, mw.WriteField("model", "stt"))
+	require.NoError(t, mw.Close())
+	req := httptest.NewRequest(http.MethodPost, "/v1/audio/transcriptions", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.AudioTranscription(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "file is required")
+}
+
+func TestModeration_Routed(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/moderations", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"modr-1","model":"omni-moderation-latest","results":[{"flagged":true,"categories":{"violence":true},"category_scores":{"violence":0.9}}]}`))
+	}))
+	defer upstream.Close()
+
+	h, spy := capabilityTestHandlers("omni-moderation-latest", "openai/omni-moderation-latest", upstream.URL)
+	req := httptest.NewRequest(http.MethodPost, "/v1/moderations", strings.NewReader(`{"model":"omni-moderation-latest","input":"text"}`))
+	w := httptest.NewRecorder()
+	h.Moderation(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"flagged":true`)
+	spy.waitCalled(t, 2*time.Second)
+	assert.Equal(t, "moderation", spy.lastCall(t).CallType)
+}
+
+func TestCapability_UpstreamErrorRelayed(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"message":"prompt rejected","type":"invalid_request_error"}}`))
+	}))
+	defer upstream.Close()
+
+	h, spy := capabilityTestHandlers("images", "openai/dall-e-3", upstream.URL)
+	req := httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(`{"model":"images","prompt":"x"}`))
+	w := httptest.NewRecorder()
+	h.ImageGeneration(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "prompt rejected")
+	assert.Zero(t, spy.logCount())
+}
+
+func TestCapability_SkipsUnsupportedDeployments(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		_, _ = w.Write([]byte("audio"))
+	}))
+	defer upstream.Close()
+
+	apiKey := "test-key"
+	apiBase := upstream.URL
+	models := []config.ModelConfig{
+		// deepgram transcribes but cannot synthesize speech
+		{ModelName: "tts", TianjiParams: config.TianjiParams{Model: "deepgram/aura", APIKey: &apiKey}},
+		{ModelName: "tts", TianjiParams: config.TianjiParams{Model: "openai/tts-1", APIKey: &apiKey, APIBase: &apiBase}},
+	}
+	h := &Handlers{
+		Config: &config.ProxyConfig{ModelList: models},
+		Router: router.New(models, strategy.NewShuffle(), router.RouterSettings{NumRetries: intPtr(1)}),
+	}
+
+	for range 5 {
+		req := httptest.NewRequest(http.MethodPost, "/v1/audio/speech", strings.NewReader(`{"model":"tts","input":"hi","voice":"alloy"}`))
+		w := httptest.NewRecorder()
+		h.AudioSpeech(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "audio", w.Body.String())
+	}
+
+	// A group without any deployment supporting the capability is rejected.
+	req := httptest.NewRequest(http.MethodPost, "/v1/images/generations", strings.NewReader(`{"model":"tts","prompt":"x"}`))
+	h = &Handlers{
+		Config: &config.ProxyConfig{ModelList: models[:1]},
+		Router: router.New(models[:1], strategy.NewShuffle(), router.RouterSettings{}),
+	}
+	w := httptest.NewRecorder()
+	h.ImageGeneration(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "provider does not support image generation")
+}
+
+func TestEmbedding_RoutedFillsModel(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/embeddings", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[{"object":"embedding","index":0,"embedding":[0.1]}],"usage":{"prompt_tokens":2,"total_tokens":2}}`))
+	}))
+	defer upstream.Close()
+
+	h, spy := capabilityTestHandlers("embed", "openai/text-embedding-3-small", upstream.URL)
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"embed","input":"hi"}`))
+	w := httptest.NewRecorder()
+	h.Embedding(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("X-TianjiLLM-Model-ID"))
+	var body struct {
+		Model string `json:"model"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "text-embedding-3-small", body.Model)
+
+	spy.waitCalled(t, 2*time.Second)
+	data := spy.lastCall(t)
+	assert.Equal(t, "embedding", data.CallType)
+	assert.Equal(t, 2, data.PromptTokens)
+}
+
+func TestEmbedding_TitanFansOutArrayInput(t *testing.T) {
+	var inputs []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			InputText string `json:"inputText"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		inputs = append(inputs, body.InputText)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"embedding":[0.5],"inputTextTokenCount":3}`))
+	}))
+	defer upstream.Close()
+
+	h, spy := capabilityTestHandlers("titan", "bedrock/amazon.titan-embed-text-v2:0", upstream.URL)
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"titan","input":["a","b"]}`))
+	w := httptest.NewRecorder()
+	h.Embedding(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{"a", "b"}, inputs)
+	var body struct {
+		Data []struct {
+			Index int `json:"index"`
+		} `json:"data"`
+		Usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+		} `json:"usage"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data, 2)
+	assert.Equal(t, 1, body.Data[1].Index)
+	assert.Equal(t, 6, body.Usage.PromptTokens)
+
+	spy.waitCalled(t, 2*time.Second)
+	assert.Equal(t, 6, spy.lastCall(t).PromptTokens)
+}
+
+func TestEmbedding_UnsendableInputIsClientError(t *testing.T) {
+	var calls int
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+	}))
+	defer upstream.Close()
+
+	h, _ := capabilityTestHandlers("titan", "bedrock/amazon.titan-embed-text-v2:0", upstream.URL)
+	req := httptest.NewRequest(http.MethodPost, "/v1/embeddings", strings.NewReader(`{"model":"titan","input":[1,2,3]}`))
+	w := httptest.NewRecorder()
+	h.Embedding(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "input must be a string or an array of strings")
+	assert.Zero(t, calls)
+	for _, d := range h.Router.GetDeployments("titan") {
+		assert.Equal(t, router.FailureNone, d.LastFailure())
+		assert.True(t, d.IsHealthy())
+	}
+}
+
+func TestLogCapabilitySuccess_LogsCancelledHedges(t *testing.T) {
+	cap := newLogCapture()
+	reg := callback.NewRegistry()
+	reg.Register(cap)
+	h := &Handlers{Config: &config.ProxyConfig{}, Callbacks: reg}
+
+	winner := &router.Deployment{ID: "emb-1", ProviderName: "azure", ModelName: "text-embedding-3-small"}
+	loser := &router.Deployment{ID: "emb-0", ProviderName: "openai", ModelName: "text-embedding-3-small"}
+	start := time.Now()
+	exec := &router.Execution{
+		Final: router.Attempt{ModelGroup: "emb", Deployment: winner, Model: "text-embedding-3-small"},
+		Tried: []string{"emb-0", "emb-1"},
+		Hedges: []router.HedgedCall{{
+			Attempt: router.Attempt{ModelGroup: "emb", Deployment: loser, Model: "text-embedding-3-small"},
+			Start:   start,
+			End:     start.Add(500 * time.Millisecond),
+		}},
+	}
+	data := callback.LogData{CallType: "embedding", StartTime: start, PromptTokens: 40, TotalTokens: 40}
+	h.logCapabilitySuccess(context.Background(), exec, data, pricing.UnitUsage{})
+
+	require.Eventually(t, func() bool {
+		cap.mu.Lock()
+		defer cap.mu.Unlock()
+		return len(cap.logs) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cap.mu.Lock()
+	defer cap.mu.Unlock()
+	var hedged, won callback.LogData
+	for _, l := range cap.logs {
+		if l.HedgeCancelled {
+			hedged = l
+		} else {
+			won = l
+		}
+	}
+	assert.Equal(t, "emb-0", hedged.DeploymentID)
+	assert.Equal(t, 40, hedged.PromptTokens)
+	assert.Equal(t, "azure", won.Provider, "provider budgets see the deployment that served the call")
+}
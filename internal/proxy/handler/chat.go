@@ -1,7 +1,7 @@
 package handler
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
@@ -11,6 +11,7 @@ import (
 	"io"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,6 +21,7 @@ import (
 	"github.com/praxisllmlab/tianjiLLM/internal/callback"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/praxisllmlab/tianjiLLM/internal/guardrail"
+	"github.com/praxisllmlab/tianjiLLM/internal/httpclient"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider"
@@ -53,8 +55,13 @@ func (h *Handlers) ChatCompletion(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var ok bool
+	if req.Model, ok = authorizeModel(w, r, req.Model); !ok {
+		return
+	}
+
 	originalModel := req.Model
-	p, apiKey, modelName, err := h.resolveProvider(r.Context(), &req)
+	d, p, apiKey, modelName, err := h.resolveProvider(r.Context(), &req)
 	if err != nil {
 		var status int
 		var code string
@@ -62,6 +69,9 @@ func (h *Handlers) ChatCompletion(w http.ResponseWriter, r *http.Request) {
 		case errors.Is(err, router.ErrAccessDenied):
 			status = http.StatusForbidden
 			code = "access_denied"
+		case errors.Is(err, router.ErrAtCapacity):
+			status = http.StatusTooManyRequests
+			code = "max_parallel_requests"
 		case strings.Contains(err.Error(), "not found"):
 			status = http.StatusNotFound
 			code = "model_not_found"
@@ -123,36 +133,174 @@ func (h *Handlers) ChatCompletion(w http.ResponseWriter, r *http.Request) {
 		zerolog.Ctx(r.Context()).Warn().Strs("unknown_params", keys).Msg("unknown parameters forwarded to upstream")
 	}
 
+	first := router.Attempt{ModelGroup: originalModel, Deployment: d, Provider: p, APIKey: apiKey, Model: modelName}
 	if req.IsStreaming() {
-		h.handleStreamingCompletion(w, r, p, &req, apiKey)
+		h.handleStreamingCompletion(w, r, first, &req)
 		return
 	}
 
-	h.handleNonStreamingCompletion(w, r, p, &req, apiKey)
+	h.handleNonStreamingCompletion(w, r, first, &req)
 }
 
 // resolveProvider resolves the model to a provider, using Router if available.
 // On failure, tries general fallback chain before returning an error.
-func (h *Handlers) resolveProvider(ctx context.Context, req *model.ChatCompletionRequest) (provider.Provider, string, string, error) {
+// The returned deployment is nil when the Router is not configured.
+func (h *Handlers) resolveProvider(ctx context.Context, req *model.ChatCompletionRequest) (*router.Deployment, provider.Provider, string, string, error) {
+	return h.resolveDeployment(ctx, req.Model, req)
+}
+
+// resolveDeployment resolves modelName to a deployment and provider. req is
+// optional and only used for tag and auto-router decisions.
+func (h *Handlers) resolveDeployment(ctx context.Context, modelName string, req *model.ChatCompletionRequest) (*router.Deployment, provider.Provider, string, string, error) {
 	// Use Router if configured (multi-deployment load balancing)
 	if h.Router != nil {
-		d, p, err := h.Router.Route(ctx, req.Model, req)
+		d, p, err := h.Router.Route(ctx, modelName, req)
 		if err == nil {
-			return p, d.APIKey(), d.ModelName, nil
+			return d, p, d.APIKey(), d.ModelName, nil
 		}
 
 		// Try general fallback chain
-		d, p, fbErr := h.Router.GeneralFallback(req.Model)
+		d, p, fbErr := h.Router.GeneralFallback(modelName)
 		if fbErr == nil {
-			zerolog.Ctx(ctx).Info().Str("event", "model.fallback").Str("from", req.Model).Str("to", d.ModelName).Msg("fallback activated")
-			return p, d.APIKey(), d.ModelName, nil
+			zerolog.Ctx(ctx).Info().Str("event", "model.fallback").Str("from", modelName).Str("to", d.ModelName).Msg("fallback activated")
+			return d, p, d.APIKey(), d.ModelName, nil
 		}
 
-		return nil, "", "", err
+		return nil, nil, "", "", err
 	}
 
 	// Direct resolution (single deployment)
-	return h.resolveProviderFromConfig(req.Model)
+	p, apiKey, resolvedModel, err := h.resolveProviderFromConfig(modelName)
+	return nil, p, apiKey, resolvedModel, err
+}
+
+// errTransformRequest marks failures to build the upstream request, which are
+// reported as internal errors rather than upstream failures.
+var errTransformRequest = errors.New("transform request")
+
+// writeAtCapacity answers a request that found every deployment at its
+// max_parallel_requests cap.
+func writeAtCapacity(w http.ResponseWriter, err error) {
+	writeJSON(w, http.StatusTooManyRequests, model.ErrorResponse{
+		Error: model.ErrorDetail{
+			Message: err.Error(),
+			Type:    "invalid_request_error",
+			Code:    "max_parallel_requests",
+		},
+	})
+}
+
+// executeUpstream runs call against first. When the Router is in use, failed
+// attempts are retried on other deployments and fallback model groups.
+func (h *Handlers) executeUpstream(ctx context.Context, first router.Attempt, req *model.ChatCompletionRequest, call router.CallFunc) (*http.Response, *router.Execution, error) {
+	if h.Router == nil || first.Deployment == nil {
+		resp, err := call(ctx, first)
+		return resp, &router.Execution{Final: first}, err
+	}
+	return h.Router.Execute(ctx, first, req, call)
+}
+
+// chatCall returns a CallFunc that sends req to an attempt's deployment.
+func (h *Handlers) chatCall(req *model.ChatCompletionRequest) router.CallFunc {
+	return func(ctx context.Context, a router.Attempt) (*http.Response, error) {
+		attemptReq := *req
+		attemptReq.Model = a.Model
+		httpReq, err := a.Provider.TransformRequest(ctx, &attemptReq, a.APIKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errTransformRequest, err)
+		}
+		return upstreamClient(a).Do(httpReq)
+	}
+}
+
+// upstreamClient returns the HTTP client for an attempt: the deployment's
+// own client when routed, otherwise the shared default client.
+func upstreamClient(a router.Attempt) *http.Client {
+	if a.Deployment != nil {
+		return a.Deployment.HTTPClient()
+	}
+	return httpclient.Default()
+}
+
+// executeChat executes req like executeUpstream. When the final response is
+// a context window or content policy rejection, the request is re-issued to
+// the model group's ContextWindowFallbacks or ContentPolicyFallbacks.
+func (h *Handlers) executeChat(ctx context.Context, first router.Attempt, req *model.ChatCompletionRequest) (*http.Response, *router.Execution, error) {
+	call := h.chatCall(req)
+	resp, exec, err := h.executeUpstream(ctx, first, req, call)
+	if err != nil || h.Router == nil || resp.StatusCode < 400 || resp.StatusCode >= 500 {
+		return resp, exec, err
+	}
+
+	groups := h.Router.ErrorFallbacks(first.ModelGroup, classifyErrorResponse(ctx, exec.Final.Provider, resp))
+	for _, group := range groups {
+		fbResp, fbExec, fbErr := h.Router.Execute(ctx, router.Attempt{ModelGroup: group}, req, call)
+		exec.Tried = append(exec.Tried, fbExec.Tried...)
+		exec.Retries += fbExec.Retries
+		exec.Fallbacks += 1 + fbExec.Fallbacks
+		exec.Hedges = append(exec.Hedges, fbExec.Hedges...)
+		if fbErr != nil {
+			continue
+		}
+		if fbResp.StatusCode < 400 {
+			_ = resp.Body.Close()
+			exec.Final = fbExec.Final
+			return fbResp, exec, nil
+		}
+		_ = fbResp.Body.Close()
+	}
+	return resp, exec, nil
+}
+
+// classifyErrorResponse parses an upstream error response with p and returns
+// the classified error. resp.Body is restored so it can still be relayed.
+func classifyErrorResponse(ctx context.Context, p provider.Provider, resp *http.Response) error {
+	if p == nil {
+		return nil
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	parsed := *resp
+	parsed.Body = io.NopCloser(bytes.NewReader(body))
+	_, err := p.TransformResponse(ctx, &parsed)
+	return err
+}
+
+// writeUpstreamError relays a provider error as the proxy's error body,
+// keeping the upstream status and classified code when available.
+func writeUpstreamError(w http.ResponseWriter, err error) {
+	var tErr *model.TianjiError
+	if errors.As(err, &tErr) && tErr.StatusCode >= 400 {
+		writeJSON(w, tErr.StatusCode, model.ErrorResponse{
+			Error: model.ErrorDetail{
+				Message:  tErr.Message,
+				Type:     tErr.Type,
+				Code:     model.ErrorCode(tErr),
+				Provider: tErr.Provider,
+				Model:    tErr.Model,
+			},
+		})
+		return
+	}
+	writeJSON(w, http.StatusBadGateway, model.ErrorResponse{
+		Error: model.ErrorDetail{
+			Message: "transform response: " + err.Error(),
+			Type:    "internal_error",
+		},
+	})
+}
+
+// setExecutionHeaders reports the deployments tried for a request.
+func setExecutionHeaders(w http.ResponseWriter, exec *router.Execution) {
+	if exec == nil || len(exec.Tried) == 0 {
+		return
+	}
+	w.Header().Set("X-TianjiLLM-Model-ID", exec.DeploymentID())
+	w.Header().Set("X-TianjiLLM-Attempted-Deployments", strings.Join(exec.Tried, ","))
+	w.Header().Set("X-TianjiLLM-Attempted-Retries", strconv.Itoa(exec.Retries))
+	w.Header().Set("X-TianjiLLM-Attempted-Fallbacks", strconv.Itoa(exec.Fallbacks))
 }
 
 // cacheKey generates a deterministic cache key from model name and messages.
@@ -178,7 +326,7 @@ func cacheKey(modelName string, messages []model.Message) string {
 // defaultCacheTTL is the default cache TTL for LLM responses.
 const defaultCacheTTL = 5 * time.Minute
 
-func (h *Handlers) handleNonStreamingCompletion(w http.ResponseWriter, r *http.Request, p provider.Provider, req *model.ChatCompletionRequest, apiKey string) {
+func (h *Handlers) handleNonStreamingCompletion(w http.ResponseWriter, r *http.Request, first router.Attempt, req *model.ChatCompletionRequest) {
 	startTime := time.Now()
 
 	// Pre-call cache check
@@ -193,22 +341,29 @@ func (h *Handlers) handleNonStreamingCompletion(w http.ResponseWriter, r *http.R
 		}
 	}
 
-	httpReq, err := p.TransformRequest(r.Context(), req, apiKey)
-	if err != nil {
-		h.logFailure(r.Context(), req, p, startTime, fmt.Errorf("transform request: %w", err))
-		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "transform request: " + err.Error(),
-				Type:    "internal_error",
-			},
-		})
-		return
-	}
-
 	llmStart := time.Now()
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, exec, err := h.executeChat(r.Context(), first, req)
 	llmLatency := time.Since(llmStart)
+	r = r.WithContext(router.WithExecution(r.Context(), exec))
+	setExecutionHeaders(w, exec)
+	p := exec.Final.Provider
+	req.Model = exec.Final.Model
 	if err != nil {
+		if errors.Is(err, router.ErrAtCapacity) {
+			h.logFailure(r.Context(), req, p, startTime, err)
+			writeAtCapacity(w, err)
+			return
+		}
+		if errors.Is(err, errTransformRequest) {
+			h.logFailure(r.Context(), req, p, startTime, err)
+			writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+				Error: model.ErrorDetail{
+					Message: err.Error(),
+					Type:    "internal_error",
+				},
+			})
+			return
+		}
 		// Phase 3: upstream.responded (error)
 		middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
 			StatusCode: 0,
@@ -225,6 +380,8 @@ func (h *Handlers) handleNonStreamingCompletion(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	defer resp.Body.Close()
+
 	// Phase 3: upstream.responded
 	middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
 		StatusCode: resp.StatusCode,
@@ -234,12 +391,7 @@ func (h *Handlers) handleNonStreamingCompletion(w http.ResponseWriter, r *http.R
 	result, err := p.TransformResponse(r.Context(), resp)
 	if err != nil {
 		h.logFailure(r.Context(), req, p, startTime, fmt.Errorf("transform response: %w", err))
-		writeJSON(w, http.StatusBadGateway, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "transform response: " + err.Error(),
-				Type:    "internal_error",
-			},
-		})
+		writeUpstreamError(w, err)
 		return
 	}
 
@@ -257,12 +409,12 @@ func (h *Handlers) handleNonStreamingCompletion(w http.ResponseWriter, r *http.R
 	writeJSON(w, http.StatusOK, result)
 }
 
-func (h *Handlers) handleStreamingCompletion(w http.ResponseWriter, r *http.Request, p provider.Provider, req *model.ChatCompletionRequest, apiKey string) {
+func (h *Handlers) handleStreamingCompletion(w http.ResponseWriter, r *http.Request, first router.Attempt, req *model.ChatCompletionRequest) {
 	startTime := time.Now()
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		h.logFailure(r.Context(), req, p, startTime, fmt.Errorf("streaming not supported"))
+		h.logFailure(r.Context(), req, first.Provider, startTime, fmt.Errorf("streaming not supported"))
 		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
 			Error: model.ErrorDetail{
 				Message: "streaming not supported",
@@ -272,22 +424,29 @@ func (h *Handlers) handleStreamingCompletion(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	httpReq, err := p.TransformRequest(r.Context(), req, apiKey)
-	if err != nil {
-		h.logFailure(r.Context(), req, p, startTime, fmt.Errorf("transform request: %w", err))
-		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "transform request: " + err.Error(),
-				Type:    "internal_error",
-			},
-		})
-		return
-	}
-
 	llmStart := time.Now()
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, exec, err := h.executeChat(r.Context(), first, req)
 	llmLatency := time.Since(llmStart)
+	r = r.WithContext(router.WithExecution(r.Context(), exec))
+	setExecutionHeaders(w, exec)
+	p := exec.Final.Provider
+	req.Model = exec.Final.Model
 	if err != nil {
+		if errors.Is(err, router.ErrAtCapacity) {
+			h.logFailure(r.Context(), req, p, startTime, err)
+			writeAtCapacity(w, err)
+			return
+		}
+		if errors.Is(err, errTransformRequest) {
+			h.logFailure(r.Context(), req, p, startTime, err)
+			writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+				Error: model.ErrorDetail{
+					Message: err.Error(),
+					Type:    "internal_error",
+				},
+			})
+			return
+		}
 		// Phase 3: upstream.responded (error)
 		middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
 			StatusCode: 0,
@@ -330,32 +489,24 @@ func (h *Handlers) handleStreamingCompletion(w http.ResponseWriter, r *http.Requ
 	var accUsage model.Usage
 	var assembledContent strings.Builder
 	var timeToFirstToken time.Duration
-	scanner := bufio.NewScanner(resp.Body)
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if !strings.HasPrefix(line, "data: ") {
-			continue
+	events := provider.DecodeStream(p, resp)
+	var streamErr error
+	for {
+		data, err := events.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				streamErr = err
+				zerolog.Ctx(r.Context()).Warn().Err(err).Msg("upstream stream error")
+			}
+			break
 		}
 
-		data := strings.TrimPrefix(line, "data: ")
-
-		chunk, done, err := p.TransformStreamChunk(r.Context(), []byte(data))
+		chunk, done, err := p.TransformStreamChunk(r.Context(), data)
 		if err != nil {
 			zerolog.Ctx(r.Context()).Warn().Err(err).Msg("stream chunk transform error")
 			continue
 		}
 
-		if done {
-			fmt.Fprintf(w, "data: [DONE]\n\n")
-			flusher.Flush()
-			endTime := time.Now()
-			h.logStreamSuccess(r.Context(), req, lastChunk, accUsage, p, startTime, endTime, llmLatency, timeToFirstToken)
-			// Cache assembled streaming response
-			h.cacheStreamResult(r.Context(), req, lastChunk, assembledContent.String())
-			return
-		}
-
 		if chunk != nil {
 			if timeToFirstToken == 0 {
 				timeToFirstToken = time.Since(startTime)
@@ -379,21 +530,47 @@ func (h *Handlers) handleStreamingCompletion(w http.ResponseWriter, r *http.Requ
 			if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != nil {
 				assembledContent.WriteString(*chunk.Choices[0].Delta.Content)
 			}
-			chunkData, err := json.Marshal(chunk)
-			if err != nil {
+			if chunkData, err := json.Marshal(chunk); err != nil {
 				zerolog.Ctx(r.Context()).Warn().Err(err).Msg("marshal chunk error")
-				continue
+			} else {
+				fmt.Fprintf(w, "data: %s\n\n", chunkData)
+				flusher.Flush()
 			}
-			fmt.Fprintf(w, "data: %s\n\n", chunkData)
+		}
+
+		// A final event may carry a chunk of its own, such as usage.
+		if done {
+			fmt.Fprintf(w, "data: [DONE]\n\n")
 			flusher.Flush()
+			h.recordStreamOutcome(exec.Final.Deployment, nil, llmLatency, timeToFirstToken)
+			endTime := time.Now()
+			h.logStreamSuccess(r.Context(), req, lastChunk, accUsage, p, startTime, endTime, llmLatency, timeToFirstToken)
+			// Cache assembled streaming response
+			h.cacheStreamResult(r.Context(), req, lastChunk, assembledContent.String())
+			return
 		}
 	}
 
 	// Stream ended without [DONE] — still log
+	h.recordStreamOutcome(exec.Final.Deployment, streamErr, llmLatency, timeToFirstToken)
 	endTime := time.Now()
 	h.logStreamSuccess(r.Context(), req, lastChunk, accUsage, p, startTime, endTime, llmLatency, timeToFirstToken)
 }
 
+// recordStreamOutcome reports a finished stream to the Router. The upstream
+// status was already recorded by Execute; a stream that broke off with a read
+// error additionally counts as a failure of the deployment.
+func (h *Handlers) recordStreamOutcome(d *router.Deployment, streamErr error, llmLatency, timeToFirstToken time.Duration) {
+	if h.Router == nil || d == nil {
+		return
+	}
+	if streamErr != nil {
+		h.Router.RecordOutcome(d, 0, streamErr, llmLatency)
+		return
+	}
+	h.Router.RecordTTFT(d, timeToFirstToken)
+}
+
 // cacheStreamResult assembles a non-streaming response from accumulated stream data and caches it.
 func (h *Handlers) cacheStreamResult(ctx context.Context, req *model.ChatCompletionRequest, lastChunk *model.StreamChunk, content string) {
 	if h.Cache == nil || content == "" {
@@ -441,21 +618,51 @@ func (h *Handlers) buildLogData(ctx context.Context, req *model.ChatCompletionRe
 		StartTime: startTime,
 	}
 
-	if userID, ok := ctx.Value(middleware.ContextKeyUserID).(string); ok {
-		data.UserID = userID
-	}
-	if teamID, ok := ctx.Value(middleware.ContextKeyTeamID).(string); ok {
-		data.TeamID = teamID
+	setCaller(ctx, &data)
+	if req.User != nil && *req.User != "" {
+		data.EndUserID = *req.User
 	}
-	if tokenHash, ok := ctx.Value(middleware.ContextKeyTokenHash).(string); ok {
-		data.APIKey = tokenHash
+	if exec := router.ExecutionFromContext(ctx); exec != nil {
+		data.DeploymentID = exec.DeploymentID()
+		data.AttemptedDeployments = exec.Tried
+		if data.ModelGroup == "" {
+			data.ModelGroup = exec.Final.ModelGroup
+		}
+		if exec.Final.Deployment != nil {
+			// Registry name (e.g. "groq"), which provider budgets are keyed by.
+			data.Provider = exec.Final.Deployment.ProviderName
+		}
 	}
 
 	return data
 }
 
+// setCaller fills in who a call is billed to: the virtual key, user, team,
+// organization and end user of the request, and the model it asked for.
+func setCaller(ctx context.Context, data *callback.LogData) {
+	data.ModelGroup = middleware.RequestedModel(ctx)
+	data.APIKey, _ = ctx.Value(middleware.ContextKeyTokenHash).(string)
+	data.UserID, _ = ctx.Value(middleware.ContextKeyUserID).(string)
+	data.TeamID, _ = ctx.Value(middleware.ContextKeyTeamID).(string)
+	data.OrgID, _ = ctx.Value(middleware.ContextKeyOrgID).(string)
+	data.EndUserID, _ = ctx.Value(middleware.ContextKeyEndUserID).(string)
+}
+
+// recordUsage counts the tokens of a successful call against the caller's
+// rate limits and, when ctx carries the call's Execution, the usage window
+// of the deployment that served it.
+func (h *Handlers) recordUsage(ctx context.Context, tokens int) {
+	middleware.RecordUsage(ctx, tokens)
+	if exec := router.ExecutionFromContext(ctx); exec != nil && h.Router != nil {
+		h.Router.RecordTokens(exec.Final.Deployment, int64(tokens))
+	}
+}
+
 // logSuccess fires success callbacks for non-streaming responses.
 func (h *Handlers) logSuccess(ctx context.Context, req *model.ChatCompletionRequest, result *model.ModelResponse, p provider.Provider, startTime, endTime time.Time, llmLatency time.Duration) {
+	if result != nil {
+		h.recordUsage(ctx, result.Usage.TotalTokens)
+	}
 	if h.Callbacks == nil {
 		return
 	}
@@ -481,6 +688,7 @@ func (h *Handlers) logSuccess(ctx context.Context, req *model.ChatCompletionRequ
 	}
 
 	go h.Callbacks.LogSuccess(data)
+	h.logHedges(ctx, data)
 }
 
 // logStreamSuccess fires success callbacks for streaming responses.
@@ -488,10 +696,6 @@ func (h *Handlers) logSuccess(ctx context.Context, req *model.ChatCompletionRequ
 // (different providers emit them in different events). Falls back to
 // lastChunk.Usage when accUsage is empty.
 func (h *Handlers) logStreamSuccess(ctx context.Context, req *model.ChatCompletionRequest, lastChunk *model.StreamChunk, accUsage model.Usage, p provider.Provider, startTime, endTime time.Time, llmLatency, timeToFirstToken time.Duration) {
-	if h.Callbacks == nil {
-		return
-	}
-
 	data := h.buildLogData(ctx, req, p, startTime)
 	data.EndTime = endTime
 	data.Latency = endTime.Sub(startTime)
@@ -522,7 +726,45 @@ func (h *Handlers) logStreamSuccess(ctx context.Context, req *model.ChatCompleti
 		})
 	}
 
+	h.recordUsage(ctx, data.TotalTokens)
+	if h.Callbacks == nil {
+		return
+	}
 	go h.Callbacks.LogSuccess(data)
+	h.logHedges(ctx, data)
+}
+
+// logHedges fires success callbacks for the hedged calls of the request that
+// were cancelled because another deployment answered first. served is the
+// log entry of the call that was used; each hedge is billed for the same
+// prompt on its own model, with no completion.
+func (h *Handlers) logHedges(ctx context.Context, served callback.LogData) {
+	exec := router.ExecutionFromContext(ctx)
+	if exec == nil {
+		return
+	}
+	for _, hc := range exec.Hedges {
+		data := served
+		data.Model = hc.Attempt.Model
+		data.Response = nil
+		data.Error = nil
+		data.StartTime = hc.Start
+		data.EndTime = hc.End
+		data.Latency = hc.End.Sub(hc.Start)
+		data.LLMAPILatency = data.Latency
+		data.TimeToFirstToken = 0
+		data.CompletionTokens = 0
+		data.TotalTokens = served.PromptTokens
+		data.CacheReadInputTokens = 0
+		data.CacheCreationInputTokens = 0
+		data.Cost = pricing.Default().TotalCost(data.Model, pricing.TokenUsage{PromptTokens: served.PromptTokens})
+		data.HedgeCancelled = true
+		if hc.Attempt.Deployment != nil {
+			data.DeploymentID = hc.Attempt.Deployment.ID
+			data.Provider = hc.Attempt.Deployment.ProviderName
+		}
+		go h.Callbacks.LogSuccess(data)
+	}
 }
 
 // logFailure fires failure callbacks.
@@ -540,6 +782,7 @@ func (h *Handlers) logFailure(ctx context.Context, req *model.ChatCompletionRequ
 	data.Error = err
 
 	go h.Callbacks.LogFailure(data)
+	h.logHedges(ctx, data)
 }
 
 // getGuardrailNames extracts guardrail names from the request context.
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/callback"
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLogSuccess_LogsCancelledHedges verifies that a hedged call cancelled
+// in favour of another deployment is logged, billed for the prompt only.
+func TestLogSuccess_LogsCancelledHedges(t *testing.T) {
+	t.Parallel()
+
+	cap := newLogCapture()
+	reg := callback.NewRegistry()
+	reg.Register(cap)
+
+	h := &Handlers{
+		Config:    &config.ProxyConfig{},
+		Callbacks: reg,
+	}
+
+	winner := &router.Deployment{ID: "gpt-4o-1", ProviderName: "azure", ModelName: "gpt-4o"}
+	loser := &router.Deployment{ID: "gpt-4o-0", ProviderName: "openai", ModelName: "gpt-4o"}
+	start := time.Now()
+	exec := &router.Execution{
+		Final: router.Attempt{ModelGroup: "gpt-4o", Deployment: winner, Model: "gpt-4o"},
+		Tried: []string{"gpt-4o-0", "gpt-4o-1"},
+		Hedges: []router.HedgedCall{{
+			Attempt: router.Attempt{ModelGroup: "gpt-4o", Deployment: loser, Model: "gpt-4o"},
+			Start:   start,
+			End:     start.Add(800 * time.Millisecond),
+		}},
+	}
+	ctx := router.WithExecution(context.Background(), exec)
+
+	req := &model.ChatCompletionRequest{Model: "gpt-4o"}
+	result := &model.ModelResponse{Usage: model.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}}
+	h.logSuccess(ctx, req, result, nil, start, start.Add(time.Second), 900*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		cap.mu.Lock()
+		defer cap.mu.Unlock()
+		return len(cap.logs) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cap.mu.Lock()
+	defer cap.mu.Unlock()
+	var served, hedged callback.LogData
+	for _, l := range cap.logs {
+		if l.HedgeCancelled {
+			hedged = l
+		} else {
+			served = l
+		}
+	}
+	assert.Equal(t, "gpt-4o-1", served.DeploymentID)
+	assert.Equal(t, 20, served.CompletionTokens)
+
+	assert.Equal(t, "gpt-4o-0", hedged.DeploymentID)
+	assert.Equal(t, "openai", hedged.Provider)
+	assert.Equal(t, 100, hedged.PromptTokens)
+	assert.Equal(t, 0, hedged.CompletionTokens)
+	assert.Equal(t, 800*time.Millisecond, hedged.Latency)
+	assert.Nil(t, hedged.Response)
+	assert.Less(t, hedged.Cost, served.Cost)
+}
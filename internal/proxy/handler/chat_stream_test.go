@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"sync"
 	"testing"
@@ -13,6 +14,8 @@ import (
 	"github.com/praxisllmlab/tianjiLLM/internal/config"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	anthropicprovider "github.com/praxisllmlab/tianjiLLM/internal/provider/anthropic"
+	bedrockprovider "github.com/praxisllmlab/tianjiLLM/internal/provider/bedrock"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -167,7 +170,7 @@ func TestHandleStreamingCompletion_AnthropicUsageAccumulated(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
-	h.handleStreamingCompletion(w, r, p, req, apiKey)
+	h.handleStreamingCompletion(w, r, router.Attempt{Provider: p, APIKey: apiKey, Model: req.Model}, req)
 
 	data := cap.wait(t, 2*time.Second)
 	assert.Equal(t, 30, data.PromptTokens, "prompt tokens from message_start.message.usage")
@@ -178,4 +181,49 @@ func TestHandleStreamingCompletion_AnthropicUsageAccumulated(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "Hello!")
 }
 
+// TestHandleStreamingCompletion_BedrockEventStream streams a recorded
+// ConverseStream response, in the binary AWS event-stream encoding, through
+// the handler. Usage arrives in the metadata event after messageStop.
+func TestHandleStreamingCompletion_BedrockEventStream(t *testing.T) {
+	t.Parallel()
+
+	frames, err := os.ReadFile("../../provider/bedrock/testdata/converse_stream.bin")
+	require.NoError(t, err)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasSuffix(r.URL.Path, "/converse-stream"))
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(frames)
+	}))
+	defer upstream.Close()
+
+	cap := newLogCapture()
+	reg := callback.NewRegistry()
+	reg.Register(cap)
+	h := &Handlers{Config: &config.ProxyConfig{}, Callbacks: reg}
+
+	p := bedrockprovider.NewWithConfig(bedrockprovider.Config{Region: "us-east-1", Endpoint: upstream.URL})
+	req := &model.ChatCompletionRequest{
+		Model:    "anthropic.claude-3-haiku-20240307-v1:0",
+		Messages: []model.Message{{Role: "user", Content: "weather in Paris?"}},
+		Stream:   boolPtr(true),
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	h.handleStreamingCompletion(w, r, router.Attempt{Provider: p, APIKey: "bedrock-api-key", Model: req.Model}, req)
+
+	data := cap.wait(t, 2*time.Second)
+	assert.Equal(t, 412, data.PromptTokens)
+	assert.Equal(t, 58, data.CompletionTokens)
+
+	out := w.Body.String()
+	assert.Contains(t, out, "Let me check")
+	assert.Contains(t, out, "get_weather")
+	assert.Contains(t, out, `"finish_reason":"tool_calls"`)
+	assert.Contains(t, out, "data: [DONE]")
+}
+
 func boolPtr(b bool) *bool { return &b }
+func intPtr(n int) *int    { return &n }
@@ -7,10 +7,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
 	"github.com/praxisllmlab/tianjiLLM/internal/router"
+	"github.com/praxisllmlab/tianjiLLM/internal/router/strategy"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -58,3 +61,171 @@ func TestChatCompletion_AccessDenied_Returns403(t *testing.T) {
 	require.True(t, ok)
 	assert.Equal(t, "access_denied", errObj["code"])
 }
+
+func TestChatCompletion_KeyModelAccess(t *testing.T) {
+	var upstreamModel string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		upstreamModel, _ = body["model"].(string)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"x","object":"chat.completion","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	apiKey := "sk-test"
+	apiBase := upstream.URL
+	models := []config.ModelConfig{
+		{ModelName: "gpt-4o", TianjiParams: config.TianjiParams{Model: "openai/gpt-4o", APIKey: &apiKey, APIBase: &apiBase}},
+		{ModelName: "gpt-4o-mini", TianjiParams: config.TianjiParams{Model: "openai/gpt-4o-mini", APIKey: &apiKey, APIBase: &apiBase}},
+	}
+	h := &Handlers{Config: &config.ProxyConfig{ModelList: models}, Router: router.New(models, strategy.NewShuffle(), router.RouterSettings{})}
+
+	access := &middleware.ModelAccess{
+		Models:  []string{"gpt-4o", "fast"},
+		Aliases: map[string]string{"fast": "gpt-4o"},
+	}
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyIsMasterKey, false)
+	ctx = context.WithValue(ctx, middleware.ContextKeyAllowedModels, access)
+
+	call := func(modelName string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]any{
+			"model":    modelName,
+			"messages": []map[string]string{{"role": "user", "content": "hi"}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)).WithContext(ctx)
+		w := httptest.NewRecorder()
+		h.ChatCompletion(w, req)
+		return w
+	}
+
+	w := call("fast")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gpt-4o", upstreamModel, "key alias applied before routing")
+
+	w = call("gpt-4o-mini")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "access_denied")
+}
+
+func TestChatCompletion_RecordsUpstreamOutcome(t *testing.T) {
+	status := http.StatusTooManyRequests
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			_, _ = w.Write([]byte(`{"id":"x","object":"chat.completion","choices":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"error":{"message":"slow down","type":"rate_limit"}}`))
+	}))
+	defer upstream.Close()
+
+	apiKey := "sk-test"
+	apiBase := upstream.URL
+	models := []config.ModelConfig{
+		{
+			ModelName:    "gpt-4o",
+			TianjiParams: config.TianjiParams{Model: "openai/gpt-4o", APIKey: &apiKey, APIBase: &apiBase},
+		},
+	}
+	rtr := router.New(models, strategy.NewShuffle(), router.RouterSettings{AllowedFails: 5, CooldownTime: time.Minute})
+	h := &Handlers{Config: &config.ProxyConfig{ModelList: models}, Router: rtr}
+	d := rtr.GetDeployments("gpt-4o")[0]
+
+	body, _ := json.Marshal(map[string]any{
+		"model":    "gpt-4o",
+		"messages": []map[string]string{{"role": "user", "content": "hi"}},
+	})
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyIsMasterKey, true)
+
+	// Healthy deployment answers 200 → latency is recorded
+	status = http.StatusOK
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)).WithContext(ctx)
+	h.ChatCompletion(httptest.NewRecorder(), req)
+	assert.NotZero(t, d.LatencyEMA())
+	assert.True(t, d.IsHealthy())
+
+	// 429 → deployment enters cooldown immediately
+	status = http.StatusTooManyRequests
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)).WithContext(ctx)
+	h.ChatCompletion(httptest.NewRecorder(), req)
+	assert.False(t, d.IsHealthy())
+	assert.Equal(t, router.FailureRateLimit, d.LastFailure())
+}
+
+func TestChatCompletion_ContextWindowFallback(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		if body.Model == "gpt-4o-mini" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"This model's maximum context length is 128000 tokens.","type":"invalid_request_error","code":"context_length_exceeded"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"x","object":"chat.completion","model":"` + body.Model + `","choices":[]}`))
+	}))
+	defer upstream.Close()
+
+	apiKey := "sk-test"
+	apiBase := upstream.URL
+	models := []config.ModelConfig{
+		{ModelName: "small", TianjiParams: config.TianjiParams{Model: "openai/gpt-4o-mini", APIKey: &apiKey, APIBase: &apiBase}},
+		{ModelName: "large", TianjiParams: config.TianjiParams{Model: "openai/gpt-4.1", APIKey: &apiKey, APIBase: &apiBase}},
+	}
+	rtr := router.New(models, strategy.NewShuffle(), router.RouterSettings{
+		ContextWindowFallbacks: map[string][]string{"small": {"large"}},
+	})
+	h := &Handlers{Config: &config.ProxyConfig{ModelList: models}, Router: rtr}
+
+	body, _ := json.Marshal(map[string]any{
+		"model":    "small",
+		"messages": []map[string]string{{"role": "user", "content": "hi"}},
+	})
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyIsMasterKey, true)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	h.ChatCompletion(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "gpt-4.1")
+	assert.Equal(t, "small-0,large-1", w.Header().Get("X-TianjiLLM-Attempted-Deployments"))
+	assert.Equal(t, "1", w.Header().Get("X-TianjiLLM-Attempted-Fallbacks"))
+	assert.True(t, rtr.GetDeployments("small")[0].IsHealthy(), "context window errors must not cool down the deployment")
+}
+
+func TestChatCompletion_ClassifiedUpstreamError(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":{"message":"Your request was rejected as a result of our safety system.","type":"invalid_request_error","code":"content_policy_violation"}}`))
+	}))
+	defer upstream.Close()
+
+	apiKey := "sk-test"
+	apiBase := upstream.URL
+	models := []config.ModelConfig{
+		{ModelName: "gpt-4o", TianjiParams: config.TianjiParams{Model: "openai/gpt-4o", APIKey: &apiKey, APIBase: &apiBase}},
+	}
+	h := &Handlers{
+		Config: &config.ProxyConfig{ModelList: models},
+		Router: router.New(models, strategy.NewShuffle(), router.RouterSettings{}),
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"model":    "gpt-4o",
+		"messages": []map[string]string{{"role": "user", "content": "hi"}},
+	})
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyIsMasterKey, true)
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	h.ChatCompletion(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var errResp model.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &errResp))
+	assert.Equal(t, "content_policy_violation", errResp.Error.Code)
+}
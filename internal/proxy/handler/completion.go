@@ -2,15 +2,21 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/callback"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
+	"github.com/rs/zerolog"
 )
 
 // Completion handles POST /v1/completions (legacy text completion).
@@ -40,9 +46,20 @@ func (h *Handlers) Completion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p, apiKey, _, err := h.resolveProviderFromConfig(req.Model)
+	requested := req.Model
+	var ok bool
+	if req.Model, ok = authorizeModel(w, r, req.Model); !ok {
+		return
+	}
+	body = withModel(body, requested, req.Model)
+
+	d, p, apiKey, modelName, err := h.resolveDeployment(r.Context(), req.Model, nil)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+		status := http.StatusBadRequest
+		if errors.Is(err, router.ErrAtCapacity) {
+			status = http.StatusTooManyRequests
+		}
+		writeJSON(w, status, model.ErrorResponse{
 			Error: model.ErrorDetail{
 				Message: err.Error(),
 				Type:    "invalid_request_error",
@@ -51,32 +68,42 @@ func (h *Handlers) Completion(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Build the upstream URL for /completions
-	url := p.GetRequestURL(req.Model)
-
 	// Phase 2: provider.resolved
-	middleware.LogProviderResolved(r.Context(), h.lookupProviderName(req.Model), url, "completion", req.Model)
-	// Replace /chat/completions with /completions for legacy endpoint
-	url = url[:len(url)-len("/chat/completions")] + "/completions"
+	middleware.LogProviderResolved(r.Context(), h.lookupProviderName(req.Model), p.GetRequestURL(req.Model), "completion", req.Model)
 
-	httpReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, url, bytes.NewReader(body))
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "create upstream request: " + err.Error(),
-				Type:    "internal_error",
-			},
-		})
-		return
+	// Without the Router the body is forwarded verbatim, as before.
+	if d == nil {
+		modelName = req.Model
 	}
-
-	p.SetupHeaders(httpReq, apiKey)
-	httpReq.Header.Set("Content-Type", r.Header.Get("Content-Type"))
-
+	first := router.Attempt{ModelGroup: req.Model, Deployment: d, Provider: p, APIKey: apiKey, Model: modelName}
 	upstreamStart := time.Now()
-	resp, err := http.DefaultClient.Do(httpReq)
+	resp, exec, err := h.executeUpstream(r.Context(), first, nil, func(ctx context.Context, a router.Attempt) (*http.Response, error) {
+		// Replace /chat/completions with /completions for legacy endpoint
+		url := strings.TrimSuffix(a.Provider.GetRequestURL(a.Model), "/chat/completions") + "/completions"
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(withModel(body, req.Model, a.Model)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errTransformRequest, err)
+		}
+		a.Provider.SetupHeaders(httpReq, a.APIKey)
+		httpReq.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+		return upstreamClient(a).Do(httpReq)
+	})
 	upstreamLatency := middleware.UpstreamLatencyMs(upstreamStart)
+	setExecutionHeaders(w, exec)
 	if err != nil {
+		if errors.Is(err, router.ErrAtCapacity) {
+			writeAtCapacity(w, err)
+			return
+		}
+		if errors.Is(err, errTransformRequest) {
+			writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+				Error: model.ErrorDetail{
+					Message: err.Error(),
+					Type:    "internal_error",
+				},
+			})
+			return
+		}
 		middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
 			LatencyMs: upstreamLatency,
 			Error:     err.Error(),
@@ -97,94 +124,113 @@ func (h *Handlers) Completion(w http.ResponseWriter, r *http.Request) {
 		LatencyMs:  upstreamLatency,
 	})
 
-	respBody := mustReadAll(resp.Body)
-
 	for k, vv := range resp.Header {
 		for _, v := range vv {
 			w.Header().Add(k, v)
 		}
 	}
 	w.WriteHeader(resp.StatusCode)
-	_, _ = w.Write(respBody)
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 && h.Callbacks != nil {
+	success := resp.StatusCode >= 200 && resp.StatusCode < 300
+	var usage *model.Usage
+	if success && req.Stream != nil && *req.Stream {
+		usage = relayCompletionStream(r.Context(), w, resp, exec.Final.Provider)
+	} else {
+		respBody := mustReadAll(resp.Body)
+		_, _ = w.Write(respBody)
+		if !success {
+			return
+		}
 		var parsed struct {
-			Usage *struct {
-				PromptTokens     int `json:"prompt_tokens"`
-				CompletionTokens int `json:"completion_tokens"`
-				TotalTokens      int `json:"total_tokens"`
-			} `json:"usage"`
+			Usage *model.Usage `json:"usage"`
 		}
 		_ = json.Unmarshal(respBody, &parsed)
+		usage = parsed.Usage
+	}
 
-		promptTokens, completionTokens, totalTokens := 0, 0, 0
-		if parsed.Usage != nil {
-			promptTokens = parsed.Usage.PromptTokens
-			completionTokens = parsed.Usage.CompletionTokens
-			totalTokens = parsed.Usage.TotalTokens
-		}
+	promptTokens, completionTokens, totalTokens := 0, 0, 0
+	if usage != nil {
+		promptTokens = usage.PromptTokens
+		completionTokens = usage.CompletionTokens
+		totalTokens = usage.TotalTokens
+	}
+	h.recordUsage(router.WithExecution(r.Context(), exec), totalTokens)
+	if h.Callbacks == nil {
+		return
+	}
 
-		endTime := time.Now()
-		go h.Callbacks.LogSuccess(callback.LogData{
-			Model:            req.Model,
-			APIKey:           apiKey,
-			PromptTokens:     promptTokens,
-			CompletionTokens: completionTokens,
-			TotalTokens:      totalTokens,
-			StartTime:        startTime,
-			EndTime:          endTime,
-			Latency:          endTime.Sub(startTime),
-			CallType:         "completion",
-		})
+	endTime := time.Now()
+	data := callback.LogData{
+		Model:            req.Model,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      totalTokens,
+		StartTime:        startTime,
+		EndTime:          endTime,
+		Latency:          endTime.Sub(startTime),
+		CallType:         "completion",
+
+		DeploymentID:         exec.DeploymentID(),
+		AttemptedDeployments: exec.Tried,
 	}
+	setCaller(r.Context(), &data)
+	go h.Callbacks.LogSuccess(data)
+	h.logHedges(router.WithExecution(r.Context(), exec), data)
 }
 
-// proxyUpstream forwards the request body to the upstream URL and pipes the response back.
-func proxyUpstream(w http.ResponseWriter, r *http.Request, url, apiKey string, p provider.Provider) {
-	httpReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, url, r.Body)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "create upstream request: " + err.Error(),
-				Type:    "internal_error",
-			},
-		})
-		return
+// relayCompletionStream forwards a streaming completion to the client as it
+// arrives, decoding its events as the provider's chat streams are to pick
+// up the usage of the final chunk.
+func relayCompletionStream(ctx context.Context, w http.ResponseWriter, resp *http.Response, p provider.Provider) *model.Usage {
+	body := io.TeeReader(resp.Body, flushWriter{w})
+	events := provider.DecodeStream(p, &http.Response{Header: resp.Header, Body: io.NopCloser(body)})
+
+	var usage *model.Usage
+	for {
+		data, err := events.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				zerolog.Ctx(ctx).Warn().Err(err).Msg("upstream stream error")
+			}
+			break
+		}
+		chunk, _, err := p.TransformStreamChunk(ctx, data)
+		if err == nil && chunk != nil && chunk.Usage != nil {
+			usage = chunk.Usage
+		}
 	}
+	// Relay whatever follows the last event.
+	_, _ = io.Copy(io.Discard, body)
+	return usage
+}
 
-	p.SetupHeaders(httpReq, apiKey)
-	httpReq.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+// flushWriter flushes each write through to the client.
+type flushWriter struct {
+	w http.ResponseWriter
+}
 
-	upstreamStart2 := time.Now()
-	resp, err := http.DefaultClient.Do(httpReq)
-	upstreamLatency2 := middleware.UpstreamLatencyMs(upstreamStart2)
-	if err != nil {
-		middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
-			LatencyMs: upstreamLatency2,
-			Error:     err.Error(),
-		})
-		writeJSON(w, http.StatusBadGateway, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "upstream request failed: " + err.Error(),
-				Type:    "internal_error",
-			},
-		})
-		return
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if fl, ok := f.w.(http.Flusher); ok {
+		fl.Flush()
 	}
-	defer resp.Body.Close()
-
-	// Phase 3: upstream.responded
-	middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
-		StatusCode: resp.StatusCode,
-		LatencyMs:  upstreamLatency2,
-	})
+	return n, err
+}
 
-	// Copy upstream response headers and body
-	for k, vv := range resp.Header {
-		for _, v := range vv {
-			w.Header().Add(k, v)
-		}
+// withModel rewrites the "model" field of a JSON request body when the
+// upstream model differs from the one the client asked for.
+func withModel(body []byte, requested, upstream string) []byte {
+	if requested == upstream {
+		return body
 	}
-	w.WriteHeader(resp.StatusCode)
-	_, _ = w.Write(mustReadAll(resp.Body))
+	var m map[string]any
+	if err := json.Unmarshal(body, &m); err != nil {
+		return body
+	}
+	m["model"] = upstream
+	data, err := json.Marshal(m)
+	if err != nil {
+		return body
+	}
+	return data
 }
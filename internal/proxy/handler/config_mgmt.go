@@ -15,8 +15,8 @@ func (h *Handlers) ConfigGet(w http.ResponseWriter, r *http.Request) {
 		"model_list":       h.Config.ModelList,
 	}
 
-	if h.Config.RouterSettings != nil {
-		resp["router_settings"] = h.Config.RouterSettings
+	if rs := h.routerSettings(); rs != nil {
+		resp["router_settings"] = rs
 	}
 
 	writeJSON(w, http.StatusOK, resp)
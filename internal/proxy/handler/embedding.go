@@ -1,15 +1,18 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"io"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/callback"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider"
-	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
 )
 
 // Embedding handles POST /v1/embeddings.
@@ -27,95 +30,104 @@ func (h *Handlers) Embedding(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p, apiKey, modelName, err := h.resolveProviderFromConfig(req.Model)
-	if err != nil {
-		status := http.StatusBadRequest
-		if strings.Contains(err.Error(), "not found") {
-			status = http.StatusNotFound
-		}
-		writeJSON(w, status, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: err.Error(),
-				Type:    "invalid_request_error",
-			},
-		})
+	var ok bool
+	if req.Model, ok = authorizeModel(w, r, req.Model); !ok {
 		return
 	}
 
-	embProvider, ok := p.(provider.EmbeddingProvider)
+	resp, exec, ok := h.dispatchCapabilityCall(w, r, req.Model, capEmbedding, func(ctx context.Context, a router.Attempt) (*http.Response, error) {
+		attemptReq := req
+		attemptReq.Model = a.Model
+		if parts := splitEmbedding(a.Provider, &attemptReq); parts != nil {
+			return sendEmbeddingParts(ctx, a, parts)
+		}
+		httpReq, err := a.Provider.(provider.EmbeddingProvider).TransformEmbeddingRequest(ctx, &attemptReq, a.APIKey)
+		if err != nil {
+			return nil, buildError(err)
+		}
+		return upstreamClient(a).Do(httpReq)
+	})
 	if !ok {
-		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "provider does not support embeddings",
-				Type:    "invalid_request_error",
-			},
-		})
 		return
 	}
+	defer resp.Body.Close()
 
-	// Phase 2: provider.resolved
-	middleware.LogProviderResolved(r.Context(), h.lookupProviderName(req.Model), p.GetRequestURL(modelName), "embedding", modelName)
-
-	req.Model = modelName
-
-	httpReq, err := embProvider.TransformEmbeddingRequest(r.Context(), &req, apiKey)
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "transform request: " + err.Error(),
-				Type:    "internal_error",
-			},
-		})
-		return
+	finalReq := req
+	finalReq.Model = exec.Final.Model
+	var result *model.EmbeddingResponse
+	var err error
+	if splitEmbedding(exec.Final.Provider, &finalReq) != nil && resp.StatusCode == http.StatusOK {
+		// Merged by sendEmbeddingParts.
+		result = &model.EmbeddingResponse{}
+		err = json.NewDecoder(resp.Body).Decode(result)
+	} else {
+		result, err = exec.Final.Provider.(provider.EmbeddingProvider).TransformEmbeddingResponse(r.Context(), resp)
 	}
-
-	upstreamStart := time.Now()
-	resp, err := http.DefaultClient.Do(httpReq)
-	upstreamLatency := middleware.UpstreamLatencyMs(upstreamStart)
 	if err != nil {
-		middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
-			LatencyMs: upstreamLatency,
-			Error:     err.Error(),
-		})
-		writeJSON(w, http.StatusBadGateway, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "upstream request failed: " + err.Error(),
-				Type:    "internal_error",
-			},
-		})
+		writeUpstreamError(w, err)
 		return
 	}
+	if result.Model == "" {
+		result.Model = exec.Final.Model
+	}
 
-	// Phase 3: upstream.responded
-	middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
-		StatusCode: resp.StatusCode,
-		LatencyMs:  upstreamLatency,
-	})
+	h.logCapabilitySuccess(r.Context(), exec, callback.LogData{
+		PromptTokens: result.Usage.PromptTokens,
+		TotalTokens:  result.Usage.TotalTokens,
+		StartTime:    startTime,
+		CallType:     capEmbedding.callType,
+	}, pricing.UnitUsage{})
 
-	result, err := embProvider.TransformEmbeddingResponse(r.Context(), resp)
-	if err != nil {
-		writeJSON(w, http.StatusBadGateway, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "transform response: " + err.Error(),
-				Type:    "internal_error",
-			},
-		})
-		return
+	writeJSON(w, http.StatusOK, result)
+}
+
+// splitEmbedding returns the calls req is split into for p, or nil when it
+// is sent in one call.
+func splitEmbedding(p provider.Provider, req *model.EmbeddingRequest) []*model.EmbeddingRequest {
+	if s, ok := p.(provider.EmbeddingSplitter); ok {
+		return s.SplitEmbeddingRequest(req)
 	}
+	return nil
+}
 
-	endTime := time.Now()
-	if h.Callbacks != nil {
-		go h.Callbacks.LogSuccess(callback.LogData{
-			Model:        modelName,
-			APIKey:       apiKey,
-			PromptTokens: result.Usage.PromptTokens,
-			TotalTokens:  result.Usage.TotalTokens,
-			StartTime:    startTime,
-			EndTime:      endTime,
-			Latency:      endTime.Sub(startTime),
-			CallType:     "embedding",
-		})
+// sendEmbeddingParts sends each part of a split embedding request to the
+// attempt's deployment in turn and merges the results, in OpenAI format,
+// into one response. The first part the upstream rejects is returned as
+// it is, so the attempt fails as a single call would.
+func sendEmbeddingParts(ctx context.Context, a router.Attempt, parts []*model.EmbeddingRequest) (*http.Response, error) {
+	ep := a.Provider.(provider.EmbeddingProvider)
+	merged := model.EmbeddingResponse{Object: "list", Model: a.Model}
+	for _, part := range parts {
+		httpReq, err := ep.TransformEmbeddingRequest(ctx, part, a.APIKey)
+		if err != nil {
+			return nil, buildError(err)
+		}
+		resp, err := upstreamClient(a).Do(httpReq)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return resp, nil
+		}
+		result, err := ep.TransformEmbeddingResponse(ctx, resp)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range result.Data {
+			d.Index = len(merged.Data)
+			merged.Data = append(merged.Data, d)
+		}
+		merged.Usage.PromptTokens += result.Usage.PromptTokens
+		merged.Usage.TotalTokens += result.Usage.TotalTokens
 	}
 
-	writeJSON(w, http.StatusOK, result)
+	body, err := json.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}, nil
 }
@@ -7,6 +7,7 @@ import (
 
 	"time"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/httpclient"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
 )
@@ -42,7 +43,7 @@ func (h *Handlers) forwardToProvider(w http.ResponseWriter, r *http.Request, ups
 	}
 
 	upstreamStart := time.Now()
-	resp, err := http.DefaultClient.Do(upstreamReq)
+	resp, err := httpclient.Default().Do(upstreamReq)
 	upstreamLatency := middleware.UpstreamLatencyMs(upstreamStart)
 	if err != nil {
 		middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
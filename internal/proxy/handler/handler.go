@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/a2a"
 	"github.com/praxisllmlab/tianjiLLM/internal/cache"
@@ -15,10 +16,13 @@ import (
 	"github.com/praxisllmlab/tianjiLLM/internal/config"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/praxisllmlab/tianjiLLM/internal/guardrail"
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/policy"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 	"github.com/praxisllmlab/tianjiLLM/internal/proxy/hook"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
 	"github.com/praxisllmlab/tianjiLLM/internal/router"
+	"github.com/praxisllmlab/tianjiLLM/internal/router/strategy"
 	"github.com/praxisllmlab/tianjiLLM/internal/token"
 	"github.com/praxisllmlab/tianjiLLM/internal/wildcard"
 )
@@ -46,6 +50,12 @@ type Handlers struct {
 	EventDispatcher  *hook.ManagementEventDispatcher
 	DiscordAlerter   *callback.DiscordRateLimitAlerter
 	RateLimitStore   callback.RateLimitStore
+	ProviderSpend    *strategy.ProviderSpend // provider budget tracker for live strategy swaps
+	AuthCache        *middleware.AuthCache   // cached key lookups, dropped on key changes
+	RoleGrants       *middleware.RoleGrants  // cached custom role grants, dropped on role changes
+	IPWhitelist      *middleware.IPWhitelist // global IP allow-list, updated by /ip/add and /ip/delete
+
+	routerSettingsMu sync.RWMutex // guards Config.RouterSettings against PATCH /router/settings
 }
 
 func (h *Handlers) ListModels(w http.ResponseWriter, r *http.Request) {
@@ -60,10 +70,12 @@ func (h *Handlers) ListModels(w http.ResponseWriter, r *http.Request) {
 	}
 
 	models := make([]map[string]any, 0, len(h.Config.ModelList))
+	listed := make(map[string]bool, len(h.Config.ModelList))
 	for _, m := range h.Config.ModelList {
 		if hiddenAliases[m.ModelName] {
 			continue
 		}
+		listed[m.ModelName] = true
 		models = append(models, map[string]any{
 			"id":       m.ModelName,
 			"object":   "model",
@@ -71,6 +83,24 @@ func (h *Handlers) ListModels(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
+	// Models added at runtime via /model/new live only in the router.
+	if h.Router != nil {
+		var extra []string
+		for name := range h.Router.ListModelGroups(r.Context()) {
+			if !listed[name] && !hiddenAliases[name] {
+				extra = append(extra, name)
+			}
+		}
+		sort.Strings(extra)
+		for _, name := range extra {
+			models = append(models, map[string]any{
+				"id":       name,
+				"object":   "model",
+				"owned_by": "tianji",
+			})
+		}
+	}
+
 	writeJSON(w, http.StatusOK, map[string]any{
 		"object": "list",
 		"data":   models,
@@ -83,6 +113,24 @@ func (h *Handlers) KeyGenerate(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// authorizeModel applies the caller's key-level model aliases to modelName
+// and enforces the key's and team's model allow-lists. It writes a 403 and
+// returns false when the model may not be called.
+func authorizeModel(w http.ResponseWriter, r *http.Request, modelName string) (string, bool) {
+	resolved, err := middleware.AuthorizeModel(r.Context(), modelName)
+	if err != nil {
+		writeJSON(w, http.StatusForbidden, model.ErrorResponse{
+			Error: model.ErrorDetail{
+				Message: err.Error(),
+				Type:    "invalid_request_error",
+				Code:    "access_denied",
+			},
+		})
+		return "", false
+	}
+	return resolved, true
+}
+
 // resolveProviderFromConfig looks up a model config and returns the provider,
 // api key, and resolved model name. It handles api_base fallback to
 // OpenAI-compatible providers, matching Python LiteLLM's behavior.
@@ -94,12 +142,7 @@ func (h *Handlers) resolveProviderFromConfig(modelName string) (provider.Provide
 
 	providerName, resolvedModel := provider.ParseModelName(resolvedFullModel)
 
-	apiBase := ""
-	if modelCfg.TianjiParams.APIBase != nil {
-		apiBase = *modelCfg.TianjiParams.APIBase
-	}
-
-	p, err := provider.GetWithBaseURL(providerName, apiBase)
+	p, err := provider.GetConfigured(providerName, router.ProviderParams(&modelCfg.TianjiParams))
 	if err != nil {
 		return nil, "", "", err
 	}
@@ -1,15 +1,22 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
+	"time"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/callback"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
-	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
+	"github.com/praxisllmlab/tianjiLLM/internal/provider"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
 )
 
 // ImageGeneration handles POST /v1/images/generations.
 func (h *Handlers) ImageGeneration(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
 	var req model.ImageGenerationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
@@ -21,22 +28,34 @@ func (h *Handlers) ImageGeneration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	p, apiKey, _, err := h.resolveProviderFromConfig(req.Model)
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: err.Error(),
-				Type:    "invalid_request_error",
-			},
-		})
+	var ok bool
+	if req.Model, ok = authorizeModel(w, r, req.Model); !ok {
 		return
 	}
 
-	url := p.GetRequestURL(req.Model)
-	url = url[:len(url)-len("/chat/completions")] + "/images/generations"
+	resp, exec, ok := h.dispatchCapability(w, r, req.Model, capImageGeneration, func(ctx context.Context, a router.Attempt) (*http.Request, error) {
+		attemptReq := req
+		attemptReq.Model = a.Model
+		return a.Provider.(provider.ImageProvider).TransformImageRequest(ctx, &attemptReq, a.APIKey)
+	})
+	if !ok {
+		return
+	}
+	defer resp.Body.Close()
 
-	// Phase 2: provider.resolved
-	middleware.LogProviderResolved(r.Context(), h.lookupProviderName(req.Model), url, "image_generation", req.Model)
+	result, err := exec.Final.Provider.(provider.ImageProvider).TransformImageResponse(r.Context(), resp)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
+
+	data := callback.LogData{StartTime: startTime, CallType: capImageGeneration.callType}
+	if result.Usage != nil {
+		data.PromptTokens = result.Usage.InputTokens
+		data.CompletionTokens = result.Usage.OutputTokens
+		data.TotalTokens = result.Usage.TotalTokens
+	}
+	h.logCapabilitySuccess(r.Context(), exec, data, pricing.UnitUsage{Images: len(result.Data)})
 
-	proxyUpstream(w, r, url, apiKey, p)
+	writeJSON(w, http.StatusOK, result)
 }
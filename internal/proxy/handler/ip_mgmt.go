@@ -1,13 +1,16 @@
 package handler
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
 )
 
-// IPAdd handles POST /ip/add.
+// IPAdd handles POST /ip/add. The address or CIDR range is allowed on this
+// replica at once and on the others at their next reload.
 func (h *Handlers) IPAdd(w http.ResponseWriter, r *http.Request) {
 	if h.DB == nil {
 		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
@@ -28,6 +31,13 @@ func (h *Handlers) IPAdd(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if _, err := middleware.ParseIPPrefix(req.IPAddress); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: err.Error(), Type: "invalid_request_error"},
+		})
+		return
+	}
+
 	result, err := h.DB.CreateIPWhitelist(r.Context(), db.CreateIPWhitelistParams{
 		IpAddress:   req.IPAddress,
 		Description: &req.Description,
@@ -39,6 +49,9 @@ func (h *Handlers) IPAdd(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if h.IPWhitelist != nil {
+		_ = h.IPWhitelist.Add(req.IPAddress)
+	}
 	writeJSON(w, http.StatusCreated, result)
 }
 
@@ -67,6 +80,9 @@ func (h *Handlers) IPDelete(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if h.IPWhitelist != nil {
+		h.IPWhitelist.Remove(req.IPAddress)
+	}
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
@@ -88,3 +104,12 @@ func (h *Handlers) IPList(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, result)
 }
+
+// allowedIPsJSON validates the allowed_ips of a key or team and encodes
+// them for its metadata.
+func allowedIPsJSON(ips []string) ([]byte, error) {
+	if _, err := middleware.ParseIPPrefixes(ips); err != nil {
+		return nil, err
+	}
+	return json.Marshal(ips)
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIPAdd_UpdatesWhitelist(t *testing.T) {
+	m := newMockStore()
+	m.createIPWhitelistFn = func(_ context.Context, arg db.CreateIPWhitelistParams) (db.IPWhitelistTable, error) {
+		return db.IPWhitelistTable{IpAddress: arg.IpAddress}, nil
+	}
+	h := mockHandlers(m)
+	h.IPWhitelist = middleware.NewIPWhitelist(nil)
+
+	w := httptest.NewRecorder()
+	h.IPAdd(w, httptest.NewRequest("POST", "/ip/add", strings.NewReader(`{"ip_address":"10.0.0.0/8"}`)))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.True(t, h.IPWhitelist.Allows("10.2.3.4"))
+	assert.False(t, h.IPWhitelist.Allows("11.2.3.4"))
+
+	w = httptest.NewRecorder()
+	h.IPAdd(w, httptest.NewRequest("POST", "/ip/add", strings.NewReader(`{"ip_address":"10.0.0"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/rs/zerolog"
 )
 
 type keyGenerateRequest struct {
@@ -24,6 +26,10 @@ type keyGenerateRequest struct {
 	TeamID    *string  `json:"team_id"`
 	TPMLimit  *int64   `json:"tpm_limit"`
 	RPMLimit  *int64   `json:"rpm_limit"`
+	// AllowedIPs restricts the client addresses and CIDR ranges.
+	AllowedIPs []string `json:"allowed_ips"`
+	// ParentKey mints a short-lived child key scoped within this key.
+	ParentKey *string `json:"parent_key"`
 }
 
 // KeyGenerateHandler handles POST /key/generate.
@@ -43,12 +49,49 @@ func (h *Handlers) KeyGenerateHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	parentHash, status, msg := delegationParent(r.Context(), req.ParentKey)
+	if status != 0 {
+		writeJSON(w, status, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: msg, Type: "invalid_request_error"},
+		})
+		return
+	}
+	// The raw parent key must not reach the audit log or event payloads.
+	req.ParentKey = nil
+	var parentToken, orgID *string
+	var expires *time.Time
+	if parentHash != "" {
+		parent, childExpires, status, msg := h.narrowToParent(r.Context(), parentHash, &req, time.Now())
+		if status != 0 {
+			errType := "invalid_request_error"
+			if status == http.StatusInternalServerError {
+				errType = "internal_error"
+			}
+			writeJSON(w, status, model.ErrorResponse{
+				Error: model.ErrorDetail{Message: msg, Type: errType},
+			})
+			return
+		}
+		parentToken, orgID, expires = &parentHash, parent.OrganizationID, &childExpires
+	}
+
+	metadata := []byte("{}")
+	if len(req.AllowedIPs) > 0 {
+		ips, err := allowedIPsJSON(req.AllowedIPs)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+				Error: model.ErrorDetail{Message: err.Error(), Type: "invalid_request_error"},
+			})
+			return
+		}
+		metadata = []byte(`{"allowed_ips":` + string(ips) + `}`)
+	}
+
 	// Generate key: sk-<uuid>
 	rawKey := "sk-" + uuid.New().String()
 	hashedKey := hashKey(rawKey)
 
-	var expires *time.Time
-	if req.Duration != nil {
+	if req.Duration != nil && expires == nil {
 		d, err := time.ParseDuration(*req.Duration)
 		if err == nil {
 			t := time.Now().Add(d)
@@ -62,17 +105,19 @@ func (h *Handlers) KeyGenerateHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	token, err := h.DB.CreateVerificationToken(r.Context(), db.CreateVerificationTokenParams{
-		Token:     hashedKey,
-		KeyName:   req.KeyName,
-		KeyAlias:  req.KeyAlias,
-		MaxBudget: req.MaxBudget,
-		Expires:   expiresTS,
-		Models:    req.Models,
-		UserID:    req.UserID,
-		TeamID:    req.TeamID,
-		TpmLimit:  req.TPMLimit,
-		RpmLimit:  req.RPMLimit,
-		Metadata:  []byte("{}"),
+		Token:          hashedKey,
+		KeyName:        req.KeyName,
+		KeyAlias:       req.KeyAlias,
+		MaxBudget:      req.MaxBudget,
+		Expires:        expiresTS,
+		Models:         req.Models,
+		UserID:         req.UserID,
+		TeamID:         req.TeamID,
+		OrganizationID: orgID,
+		TpmLimit:       req.TPMLimit,
+		RpmLimit:       req.RPMLimit,
+		Metadata:       metadata,
+		ParentToken:    parentToken,
 	})
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
@@ -84,14 +129,15 @@ func (h *Handlers) KeyGenerateHandler(w http.ResponseWriter, r *http.Request) {
 	h.createAuditLog(r.Context(), "created", "VerificationToken", token.Token, "", "", nil, req)
 	h.dispatchEvent(r.Context(), "key_created", token.Token, req)
 	writeJSON(w, http.StatusOK, map[string]any{
-		"key":        rawKey,
-		"token":      token.Token,
-		"key_name":   token.KeyName,
-		"max_budget": token.MaxBudget,
-		"expires":    token.Expires,
-		"models":     token.Models,
-		"user_id":    token.UserID,
-		"team_id":    token.TeamID,
+		"key":          rawKey,
+		"token":        token.Token,
+		"key_name":     token.KeyName,
+		"max_budget":   token.MaxBudget,
+		"expires":      token.Expires,
+		"models":       token.Models,
+		"user_id":      token.UserID,
+		"team_id":      token.TeamID,
+		"parent_token": token.ParentToken,
 	})
 }
 
@@ -216,6 +262,13 @@ func (h *Handlers) KeyDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	hashes := make([]string, len(req.Keys))
+	for i, key := range req.Keys {
+		hashes[i] = hashKey(key)
+	}
+	// Deleting a key deletes its delegated children, so find them first.
+	descendants := h.AuthCache.Descendants(r.Context(), h.DB, hashes...)
+
 	for _, key := range req.Keys {
 		if err := h.DB.DeleteVerificationToken(r.Context(), hashKey(key)); err != nil {
 			writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
@@ -225,6 +278,8 @@ func (h *Handlers) KeyDelete(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	h.invalidateKeys(r.Context(), append(hashes, descendants...)...)
+
 	for _, key := range req.Keys {
 		h.createAuditLog(r.Context(), "deleted", "VerificationToken", hashKey(key), "", "", nil, nil)
 		h.dispatchEvent(r.Context(), "key_deleted", hashKey(key), nil)
@@ -257,6 +312,7 @@ func (h *Handlers) KeyBlock(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	h.invalidateKeys(r.Context(), append([]string{hashKey(req.Key)}, h.AuthCache.Descendants(r.Context(), h.DB, hashKey(req.Key))...)...)
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "blocked"})
 }
@@ -286,6 +342,7 @@ func (h *Handlers) KeyUnblock(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	h.invalidateKeys(r.Context(), append([]string{hashKey(req.Key)}, h.AuthCache.Descendants(r.Context(), h.DB, hashKey(req.Key))...)...)
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "unblocked"})
 }
@@ -307,6 +364,8 @@ func (h *Handlers) KeyUpdate(w http.ResponseWriter, r *http.Request) {
 		Models    []string `json:"models"`
 		TPMLimit  *int64   `json:"tpm_limit"`
 		RPMLimit  *int64   `json:"rpm_limit"`
+		// AllowedIPs replaces the key's IP allow-list when set; [] clears it.
+		AllowedIPs []string `json:"allowed_ips"`
 	}
 	if err := decodeJSON(r, &req); err != nil || req.Key == "" {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
@@ -314,6 +373,16 @@ func (h *Handlers) KeyUpdate(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	var allowedIPs []byte
+	if req.AllowedIPs != nil {
+		var err error
+		if allowedIPs, err = allowedIPsJSON(req.AllowedIPs); err != nil {
+			writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+				Error: model.ErrorDetail{Message: err.Error(), Type: "invalid_request_error"},
+			})
+			return
+		}
+	}
 
 	if _, err := h.DB.UpdateVerificationToken(r.Context(), db.UpdateVerificationTokenParams{
 		Token:     hashKey(req.Key),
@@ -329,12 +398,50 @@ func (h *Handlers) KeyUpdate(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if allowedIPs != nil {
+		if err := h.DB.SetVerificationTokenAllowedIPs(r.Context(), db.SetVerificationTokenAllowedIPsParams{
+			Token:      hashKey(req.Key),
+			AllowedIps: allowedIPs,
+		}); err != nil {
+			writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+				Error: model.ErrorDetail{Message: "update key: " + err.Error(), Type: "internal_error"},
+			})
+			return
+		}
+	}
+	h.invalidateKeys(r.Context(), hashKey(req.Key))
 
 	h.createAuditLog(r.Context(), "updated", "VerificationToken", hashKey(req.Key), "", "", nil, req)
 	h.dispatchEvent(r.Context(), "key_updated", hashKey(req.Key), req)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
 }
 
+// invalidateKeys drops the cached auth state of changed keys on every
+// replica. The change is already stored, so a failure is only logged; the
+// cache TTL bounds how long a stale entry can live.
+func (h *Handlers) invalidateKeys(ctx context.Context, tokenHashes ...string) {
+	if err := h.AuthCache.Invalidate(ctx, tokenHashes...); err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("failed to invalidate cached keys")
+	}
+}
+
+// invalidateTeamKeys drops the cached auth state of every key of a team.
+func (h *Handlers) invalidateTeamKeys(ctx context.Context, teamID string) {
+	if h.AuthCache == nil {
+		return
+	}
+	keys, err := h.DB.ListVerificationTokensByTeam(ctx, &teamID)
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Str("team_id", teamID).Msg("failed to list team keys for invalidation")
+		return
+	}
+	hashes := make([]string, len(keys))
+	for i, k := range keys {
+		hashes[i] = k.Token
+	}
+	h.invalidateKeys(ctx, hashes...)
+}
+
 func hashKey(key string) string {
 	h := sha256.Sum256([]byte(key))
 	return hex.EncodeToString(h[:])
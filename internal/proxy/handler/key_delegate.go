@@ -0,0 +1,239 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+)
+
+// delegationParent returns the hash of the key a /key/generate request
+// mints a child from, or "" for an ordinary key. A virtual key caller below
+// proxy admin only ever mints children of itself, so a key granted
+// POST /key/generate can hand out keys no wider than its own. Callers below
+// proxy admin authenticated otherwise, by JWT or custom auth, have no key to
+// delegate from and are refused.
+func delegationParent(ctx context.Context, parentKey *string) (string, int, string) {
+	callerHash, _ := ctx.Value(middleware.ContextKeyTokenHash).(string)
+	isMaster, _ := ctx.Value(middleware.ContextKeyIsMasterKey).(bool)
+	role, _ := ctx.Value(middleware.ContextKeyRole).(auth.Role)
+
+	var parentHash string
+	if parentKey != nil && *parentKey != "" {
+		parentHash = hashKey(*parentKey)
+	}
+	if isMaster || role == auth.RoleProxyAdmin || (callerHash == "" && role == "") {
+		return parentHash, 0, ""
+	}
+	if callerHash == "" {
+		return "", http.StatusForbidden, "only a virtual key may generate keys below the proxy admin role"
+	}
+	if parentHash != "" && parentHash != callerHash {
+		return "", http.StatusForbidden, "a key may only delegate from itself"
+	}
+	return callerHash, 0, ""
+}
+
+// narrowToParent turns req into a request for a child of the parent key:
+// the child belongs to the parent's user, team and organization, and its
+// models, IP allow-list, RPM/TPM limits, budget and lifetime must fit within
+// the parent's. Unset limits are inherited. It returns the parent and the
+// child's expiry, or the HTTP status and message of the rejection.
+func (h *Handlers) narrowToParent(ctx context.Context, parentHash string, req *keyGenerateRequest, now time.Time) (db.VerificationToken, time.Time, int, string) {
+	parent, err := h.DB.GetVerificationToken(ctx, parentHash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return parent, time.Time{}, http.StatusNotFound, "parent key not found"
+		}
+		return parent, time.Time{}, http.StatusInternalServerError, "get parent key: " + err.Error()
+	}
+	if parent.Blocked != nil && *parent.Blocked {
+		return parent, time.Time{}, http.StatusForbidden, "parent key is blocked"
+	}
+	if parent.Expires.Valid && !now.Before(parent.Expires.Time) {
+		return parent, time.Time{}, http.StatusForbidden, "parent key has expired"
+	}
+
+	// Lifetime
+	if req.Duration == nil {
+		return parent, time.Time{}, http.StatusBadRequest, "duration is required for child keys"
+	}
+	d, err := time.ParseDuration(*req.Duration)
+	if err != nil || d <= 0 {
+		return parent, time.Time{}, http.StatusBadRequest, fmt.Sprintf("invalid duration %q", *req.Duration)
+	}
+	expires := now.Add(d)
+	if parent.Expires.Valid && parent.Expires.Time.Before(expires) {
+		expires = parent.Expires.Time
+	}
+
+	// Ownership
+	for _, o := range []struct {
+		field         string
+		req, parentID *string
+	}{
+		{"user_id", req.UserID, parent.UserID},
+		{"team_id", req.TeamID, parent.TeamID},
+	} {
+		if o.req != nil && (o.parentID == nil || *o.req != *o.parentID) {
+			return parent, time.Time{}, http.StatusBadRequest, "child keys belong to the parent key's " + o.field
+		}
+	}
+	req.UserID, req.TeamID = parent.UserID, parent.TeamID
+
+	// Models
+	parentModels, restricted, err := h.keyModels(ctx, parent)
+	if err != nil {
+		return parent, time.Time{}, http.StatusInternalServerError, "get parent models: " + err.Error()
+	}
+	if restricted {
+		access := &middleware.ModelAccess{Models: parentModels}
+		for _, m := range req.Models {
+			if _, err := access.Resolve(m); err != nil {
+				return parent, time.Time{}, http.StatusBadRequest, fmt.Sprintf("model %q is not allowed by the parent key", m)
+			}
+		}
+		if len(req.Models) == 0 {
+			req.Models = parentModels
+			if len(req.Models) == 0 {
+				// Every access group of the parent is empty: so is the child.
+				req.Models = []string{""}
+			}
+		}
+	}
+
+	// IP allow-list
+	if parentIPs := metadataIPs(parent.Metadata); parentIPs != nil {
+		if len(req.AllowedIPs) == 0 {
+			req.AllowedIPs = parentIPs
+		} else if msg := ipsWithin(req.AllowedIPs, parentIPs); msg != "" {
+			return parent, time.Time{}, http.StatusBadRequest, msg
+		}
+	}
+
+	// Rate limits
+	for _, l := range []struct {
+		field  string
+		req    **int64
+		parent *int64
+	}{
+		{"rpm_limit", &req.RPMLimit, parent.RpmLimit},
+		{"tpm_limit", &req.TPMLimit, parent.TpmLimit},
+	} {
+		switch {
+		case l.parent == nil:
+		case *l.req == nil:
+			*l.req = l.parent
+		case **l.req > *l.parent:
+			return parent, time.Time{}, http.StatusBadRequest, fmt.Sprintf("%s %d exceeds the parent key's %d", l.field, **l.req, *l.parent)
+		}
+	}
+
+	// Budget, carved out of what the parent and its other live children
+	// have not spent or reserved.
+	if parent.MaxBudget != nil {
+		remaining, err := h.remainingBudget(ctx, parent, now)
+		if err != nil {
+			return parent, time.Time{}, http.StatusInternalServerError, "list child keys: " + err.Error()
+		}
+		switch {
+		case remaining <= 0:
+			return parent, time.Time{}, http.StatusBadRequest, "parent key has no budget left to delegate"
+		case req.MaxBudget == nil:
+			req.MaxBudget = &remaining
+		case *req.MaxBudget > remaining:
+			return parent, time.Time{}, http.StatusBadRequest, fmt.Sprintf("max_budget %.4f exceeds the parent key's remaining budget %.4f", *req.MaxBudget, remaining)
+		}
+	}
+	return parent, expires, 0, ""
+}
+
+// keyModels returns the models a key may call: its own plus those of its
+// access groups. restricted is false when the key allows every model.
+func (h *Handlers) keyModels(ctx context.Context, vt db.VerificationToken) ([]string, bool, error) {
+	if len(vt.Models) == 0 && len(vt.AccessGroupIds) == 0 {
+		return nil, false, nil
+	}
+	models := append([]string(nil), vt.Models...)
+	for _, id := range vt.AccessGroupIds {
+		group, err := h.DB.GetAccessGroup(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return nil, false, err
+		}
+		models = append(models, group.Models...)
+	}
+	return models, true, nil
+}
+
+// remainingBudget returns the part of the parent's max budget neither spent
+// nor reserved by a live child. A child's spend already counts in the
+// parent's, so only its unspent budget is reserved.
+func (h *Handlers) remainingBudget(ctx context.Context, parent db.VerificationToken, now time.Time) (float64, error) {
+	children, err := h.DB.ListVerificationTokenChildren(ctx, &parent.Token)
+	if err != nil {
+		return 0, err
+	}
+	remaining := *parent.MaxBudget - parent.Spend
+	for _, c := range children {
+		if c.MaxBudget == nil || (c.Blocked != nil && *c.Blocked) || (c.Expires.Valid && !now.Before(c.Expires.Time)) {
+			continue
+		}
+		if unspent := *c.MaxBudget - c.Spend; unspent > 0 {
+			remaining -= unspent
+		}
+	}
+	return remaining, nil
+}
+
+// metadataIPs returns the "allowed_ips" entries of key metadata, or nil
+// without any.
+func metadataIPs(metadata []byte) []string {
+	var m struct {
+		AllowedIPs []string `json:"allowed_ips"`
+	}
+	if len(metadata) == 0 || json.Unmarshal(metadata, &m) != nil || len(m.AllowedIPs) == 0 {
+		return nil
+	}
+	return m.AllowedIPs
+}
+
+// ipsWithin checks that every entry of ips lies inside one of the parent's
+// entries, returning the rejection message of the first that does not.
+func ipsWithin(ips, parentIPs []string) string {
+	// Malformed parent entries are skipped, as in the auth middleware.
+	var outer []netip.Prefix
+	for _, e := range parentIPs {
+		if q, err := middleware.ParseIPPrefix(e); err == nil {
+			outer = append(outer, q)
+		}
+	}
+	for _, ip := range ips {
+		p, err := middleware.ParseIPPrefix(ip)
+		if err != nil {
+			return err.Error()
+		}
+		inside := false
+		for _, q := range outer {
+			if q.Bits() <= p.Bits() && q.Contains(p.Addr()) {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return fmt.Sprintf("IP %q is not allowed by the parent key", ip)
+		}
+	}
+	return ""
+}
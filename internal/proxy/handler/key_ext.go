@@ -42,6 +42,7 @@ func (h *Handlers) KeyRegenerate(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	h.invalidateKeys(r.Context(), oldHash)
 
 	writeJSON(w, http.StatusOK, map[string]any{
 		"key":      newRaw,
@@ -89,6 +90,7 @@ func (h *Handlers) KeyBulkUpdate(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	h.invalidateKeys(r.Context(), hashed...)
 
 	writeJSON(w, http.StatusOK, map[string]any{"status": "updated", "count": len(req.Keys)})
 }
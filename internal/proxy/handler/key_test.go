@@ -7,8 +7,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+	"github.com/praxisllmlab/tianjiLLM/internal/cache"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
 )
 
 func TestKeyGenerate_Success(t *testing.T) {
@@ -114,6 +120,42 @@ func TestKeyBlock_Success(t *testing.T) {
 	}
 }
 
+// blockableValidator reports keys as blocked once the mock store blocked them.
+type blockableValidator struct{ blocked map[string]bool }
+
+func (v *blockableValidator) ValidateToken(_ context.Context, tokenHash string) (*middleware.TokenInfo, error) {
+	return &middleware.TokenInfo{Blocked: v.blocked[tokenHash]}, nil
+}
+
+func TestKeyBlock_InvalidatesAuthCache(t *testing.T) {
+	v := &blockableValidator{blocked: map[string]bool{}}
+	authCache := middleware.NewAuthCache(v, cache.NewMemoryCache(), nil, time.Hour, 0)
+	ms := newMockStore()
+	ms.blockVerificationTokenFn = func(_ context.Context, token string) error {
+		v.blocked[token] = true
+		return nil
+	}
+	h := &Handlers{DB: ms, AuthCache: authCache}
+
+	info, err := authCache.ValidateToken(context.Background(), hashKey("sk-1"))
+	if err != nil || info.Blocked {
+		t.Fatalf("expected unblocked key, got %+v, %v", info, err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"key": "sk-1"})
+	req := httptest.NewRequest(http.MethodPost, "/key/block", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	h.KeyBlock(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	info, err = authCache.ValidateToken(context.Background(), hashKey("sk-1"))
+	if err != nil || !info.Blocked {
+		t.Fatalf("expected blocked key after invalidation, got %+v, %v", info, err)
+	}
+}
+
 func TestKeyUnblock_Success(t *testing.T) {
 	ms := newMockStore()
 	ms.unblockVerificationTokenFn = func(_ context.Context, _ string) error { return nil }
@@ -145,3 +187,207 @@ func TestKeyUpdate_Success(t *testing.T) {
 		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
 	}
 }
+
+func TestKeyGenerate_AllowedIPs(t *testing.T) {
+	ms := newMockStore()
+	var metadata []byte
+	ms.createVerificationTokenFn = func(_ context.Context, arg db.CreateVerificationTokenParams) (db.VerificationToken, error) {
+		metadata = arg.Metadata
+		return db.VerificationToken{Token: arg.Token}, nil
+	}
+	h := &Handlers{DB: ms}
+
+	body := `{"allowed_ips":["10.0.0.0/8","1.2.3.4"]}`
+	w := httptest.NewRecorder()
+	h.KeyGenerateHandler(w, httptest.NewRequest(http.MethodPost, "/key/generate", bytes.NewReader([]byte(body))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if string(metadata) != `{"allowed_ips":["10.0.0.0/8","1.2.3.4"]}` {
+		t.Fatalf("unexpected metadata: %s", metadata)
+	}
+
+	w = httptest.NewRecorder()
+	h.KeyGenerateHandler(w, httptest.NewRequest(http.MethodPost, "/key/generate", bytes.NewReader([]byte(`{"allowed_ips":["10.0.0.0/99"]}`))))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestKeyUpdate_AllowedIPs(t *testing.T) {
+	ms := newMockStore()
+	ms.updateVerificationTokenFn = func(_ context.Context, arg db.UpdateVerificationTokenParams) (db.VerificationToken, error) {
+		return db.VerificationToken{Token: arg.Token}, nil
+	}
+	var set []byte
+	ms.setVerificationTokenAllowedIPsFn = func(_ context.Context, arg db.SetVerificationTokenAllowedIPsParams) error {
+		set = arg.AllowedIps
+		return nil
+	}
+	h := &Handlers{DB: ms}
+
+	w := httptest.NewRecorder()
+	h.KeyUpdate(w, httptest.NewRequest(http.MethodPost, "/key/update", bytes.NewReader([]byte(`{"key":"sk-1","allowed_ips":[]}`))))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if string(set) != `[]` {
+		t.Fatalf("expected the allow-list to be cleared, got %s", set)
+	}
+}
+
+func TestKeyGenerate_ChildKey(t *testing.T) {
+	parentHash := hashKey("sk-parent")
+	budget, spend, rpm := 10.0, 4.0, int64(100)
+	parentExpires := time.Now().Add(30 * time.Minute)
+	user, team := "user-1", "team-1"
+	ms := newMockStore()
+	ms.getVerificationTokenFn = func(_ context.Context, token string) (db.VerificationToken, error) {
+		if token != parentHash {
+			t.Fatalf("unexpected parent lookup %q", token)
+		}
+		return db.VerificationToken{
+			Token:     parentHash,
+			MaxBudget: &budget,
+			Spend:     spend,
+			Models:    []string{"gpt-4o", "gpt-4o-mini"},
+			RpmLimit:  &rpm,
+			UserID:    &user,
+			TeamID:    &team,
+			Expires:   pgtype.Timestamptz{Time: parentExpires, Valid: true},
+			Metadata:  []byte(`{"allowed_ips":["10.0.0.0/8"]}`),
+		}, nil
+	}
+	childBudget := 2.0
+	ms.listVerificationTokenChildrenFn = func(_ context.Context, parent *string) ([]db.VerificationToken, error) {
+		// A live child has 1.5 of its budget unspent.
+		return []db.VerificationToken{{Token: "child", MaxBudget: &childBudget, Spend: 0.5}}, nil
+	}
+	var created db.CreateVerificationTokenParams
+	ms.createVerificationTokenFn = func(_ context.Context, arg db.CreateVerificationTokenParams) (db.VerificationToken, error) {
+		created = arg
+		return db.VerificationToken{Token: arg.Token, ParentToken: arg.ParentToken}, nil
+	}
+	h := &Handlers{DB: ms}
+
+	generate := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.KeyGenerateHandler(w, httptest.NewRequest(http.MethodPost, "/key/generate", bytes.NewReader([]byte(body))))
+		return w
+	}
+
+	w := generate(`{"parent_key":"sk-parent","duration":"1h","models":["gpt-4o-mini"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if created.ParentToken == nil || *created.ParentToken != parentHash {
+		t.Fatalf("expected parent token %q, got %v", parentHash, created.ParentToken)
+	}
+	if created.MaxBudget == nil || *created.MaxBudget != 4.5 {
+		t.Fatalf("expected the remaining budget 4.5, got %v", created.MaxBudget)
+	}
+	if created.RpmLimit == nil || *created.RpmLimit != rpm {
+		t.Fatalf("expected the parent's rpm limit, got %v", created.RpmLimit)
+	}
+	if !created.Expires.Time.Equal(parentExpires) {
+		t.Fatalf("expected the child to expire with its parent, got %v", created.Expires.Time)
+	}
+	if *created.UserID != user || *created.TeamID != team {
+		t.Fatalf("expected the parent's user and team, got %v %v", created.UserID, created.TeamID)
+	}
+	if string(created.Metadata) != `{"allowed_ips":["10.0.0.0/8"]}` {
+		t.Fatalf("expected the parent's allow-list, got %s", created.Metadata)
+	}
+
+	for name, body := range map[string]string{
+		"no duration":  `{"parent_key":"sk-parent"}`,
+		"wider model":  `{"parent_key":"sk-parent","duration":"1h","models":["claude-3"]}`,
+		"over budget":  `{"parent_key":"sk-parent","duration":"1h","max_budget":5}`,
+		"higher rpm":   `{"parent_key":"sk-parent","duration":"1h","rpm_limit":101}`,
+		"wider ip":     `{"parent_key":"sk-parent","duration":"1h","allowed_ips":["0.0.0.0/0"]}`,
+		"other team":   `{"parent_key":"sk-parent","duration":"1h","team_id":"team-2"}`,
+		"invalid ttl":  `{"parent_key":"sk-parent","duration":"soon"}`,
+		"negative ttl": `{"parent_key":"sk-parent","duration":"-1h"}`,
+	} {
+		if w := generate(body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestKeyGenerate_ChildKeyOfCaller(t *testing.T) {
+	callerHash := hashKey("sk-agent")
+	ms := newMockStore()
+	ms.getVerificationTokenFn = func(_ context.Context, token string) (db.VerificationToken, error) {
+		return db.VerificationToken{Token: token}, nil
+	}
+	var created db.CreateVerificationTokenParams
+	ms.createVerificationTokenFn = func(_ context.Context, arg db.CreateVerificationTokenParams) (db.VerificationToken, error) {
+		created = arg
+		return db.VerificationToken{Token: arg.Token}, nil
+	}
+	h := &Handlers{DB: ms}
+
+	generate := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/key/generate", bytes.NewReader([]byte(body)))
+		ctx := context.WithValue(req.Context(), middleware.ContextKeyTokenHash, callerHash)
+		ctx = context.WithValue(ctx, middleware.ContextKeyRole, auth.RoleInternalUser)
+		w := httptest.NewRecorder()
+		h.KeyGenerateHandler(w, req.WithContext(ctx))
+		return w
+	}
+
+	// A virtual key below proxy admin always delegates from itself.
+	if w := generate(`{"duration":"10m"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if created.ParentToken == nil || *created.ParentToken != callerHash {
+		t.Fatalf("expected a child of the calling key, got %v", created.ParentToken)
+	}
+	if w := generate(`{"parent_key":"sk-other","duration":"10m"}`); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A JWT or custom auth caller has no key to delegate from.
+	req := httptest.NewRequest(http.MethodPost, "/key/generate", bytes.NewReader([]byte(`{"duration":"10m"}`)))
+	ctx := context.WithValue(req.Context(), middleware.ContextKeyRole, auth.RoleInternalUser)
+	w := httptest.NewRecorder()
+	h.KeyGenerateHandler(w, req.WithContext(ctx))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestKeyBlock_InvalidatesChildKeys(t *testing.T) {
+	v := &blockableValidator{blocked: map[string]bool{}}
+	authCache := middleware.NewAuthCache(v, cache.NewMemoryCache(), nil, time.Hour, 0)
+	parentHash := hashKey("sk-parent")
+	ms := newMockStore()
+	ms.listVerificationTokenChildrenFn = func(_ context.Context, parent *string) ([]db.VerificationToken, error) {
+		if *parent == parentHash {
+			return []db.VerificationToken{{Token: "child"}}, nil
+		}
+		return nil, nil
+	}
+	ms.blockVerificationTokenFn = func(_ context.Context, token string) error {
+		// The validator reports children of a blocked parent as blocked.
+		v.blocked[token], v.blocked["child"] = true, true
+		return nil
+	}
+	h := &Handlers{DB: ms, AuthCache: authCache}
+
+	if info, err := authCache.ValidateToken(context.Background(), "child"); err != nil || info.Blocked {
+		t.Fatalf("expected unblocked child, got %+v, %v", info, err)
+	}
+
+	body, _ := json.Marshal(map[string]string{"key": "sk-parent"})
+	w := httptest.NewRecorder()
+	h.KeyBlock(w, httptest.NewRequest(http.MethodPost, "/key/block", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if info, err := authCache.ValidateToken(context.Background(), "child"); err != nil || !info.Blocked {
+		t.Fatalf("expected the child to be dropped from the cache, got %+v, %v", info, err)
+	}
+}
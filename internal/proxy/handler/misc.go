@@ -25,7 +25,12 @@ func (h *Handlers) TransformRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	prov, _, apiKey, err := h.resolveProvider(r.Context(), &req)
+	var ok bool
+	if req.Model, ok = authorizeModel(w, r, req.Model); !ok {
+		return
+	}
+
+	_, prov, apiKey, _, err := h.resolveProvider(r.Context(), &req)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
 			Error: model.ErrorDetail{Message: "resolve provider: " + err.Error(), Type: "invalid_request_error"},
@@ -63,7 +68,7 @@ func (h *Handlers) ConfigV2Get(w http.ResponseWriter, r *http.Request) {
 		"model_list":       h.Config.ModelList,
 		"general_settings": h.Config.GeneralSettings,
 		"tianji_settings":  h.Config.TianjiSettings,
-		"router_settings":  h.Config.RouterSettings,
+		"router_settings":  h.routerSettings(),
 	})
 }
 
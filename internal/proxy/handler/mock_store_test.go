@@ -21,6 +21,8 @@ type mockStore struct {
 	blockVerificationTokenFn          func(ctx context.Context, token string) error
 	unblockVerificationTokenFn        func(ctx context.Context, token string) error
 	updateVerificationTokenFn         func(ctx context.Context, arg db.UpdateVerificationTokenParams) (db.VerificationToken, error)
+	setVerificationTokenAllowedIPsFn  func(ctx context.Context, arg db.SetVerificationTokenAllowedIPsParams) error
+	listVerificationTokenChildrenFn   func(ctx context.Context, parentToken *string) ([]db.VerificationToken, error)
 
 	// Teams
 	createTeamFn       func(ctx context.Context, arg db.CreateTeamParams) (db.TeamTable, error)
@@ -83,6 +85,9 @@ type mockStore struct {
 	updateMCPServerFn func(ctx context.Context, arg db.UpdateMCPServerParams) (db.MCPServerTable, error)
 	deleteMCPServerFn func(ctx context.Context, id string) error
 
+	// IP whitelist
+	createIPWhitelistFn func(ctx context.Context, arg db.CreateIPWhitelistParams) (db.IPWhitelistTable, error)
+
 	// Model
 	createProxyModelFn func(ctx context.Context, arg db.CreateProxyModelParams) (db.ProxyModelTable, error)
 	getProxyModelFn    func(ctx context.Context, modelID string) (db.ProxyModelTable, error)
@@ -90,6 +95,20 @@ type mockStore struct {
 	updateProxyModelFn func(ctx context.Context, arg db.UpdateProxyModelParams) (db.ProxyModelTable, error)
 	deleteProxyModelFn func(ctx context.Context, modelID string) error
 
+	// Proxy config
+	getProxyConfigFn    func(ctx context.Context, paramName string) (db.ProxyConfigTable, error)
+	upsertProxyConfigFn func(ctx context.Context, arg db.UpsertProxyConfigParams) (db.ProxyConfigTable, error)
+
+	// Roles
+	createRoleFn           func(ctx context.Context, arg db.CreateRoleParams) (db.RoleTable, error)
+	createRoleAssignmentFn func(ctx context.Context, arg db.CreateRoleAssignmentParams) (db.RoleAssignmentTable, error)
+	deleteRoleFn           func(ctx context.Context, roleName string) error
+	deleteRoleAssignmentFn func(ctx context.Context, id string) error
+	getRoleFn              func(ctx context.Context, roleName string) (db.RoleTable, error)
+	listRoleAssignmentsFn  func(ctx context.Context, arg db.ListRoleAssignmentsParams) ([]db.RoleAssignmentTable, error)
+	listRolesFn            func(ctx context.Context) ([]db.RoleTable, error)
+	updateRoleFn           func(ctx context.Context, arg db.UpdateRoleParams) (db.RoleTable, error)
+
 	// Policy
 	createPolicyFn           func(ctx context.Context, arg db.CreatePolicyParams) (db.PolicyTable, error)
 	getPolicyFn              func(ctx context.Context, id string) (db.PolicyTable, error)
@@ -152,6 +171,21 @@ func (m *mockStore) ListVerificationTokensFiltered(ctx context.Context, arg db.L
 	}
 	return nil, fmt.Errorf("not mocked")
 }
+func (m *mockStore) ListVerificationTokenChildren(ctx context.Context, parentToken *string) ([]db.VerificationToken, error) {
+	if m.listVerificationTokenChildrenFn != nil {
+		return m.listVerificationTokenChildrenFn(ctx, parentToken)
+	}
+	return nil, nil
+}
+func (m *mockStore) ListVerificationTokensByTeam(ctx context.Context, teamID *string) ([]db.VerificationToken, error) {
+	return nil, nil
+}
+func (m *mockStore) SetVerificationTokenAllowedIPs(ctx context.Context, arg db.SetVerificationTokenAllowedIPsParams) error {
+	if m.setVerificationTokenAllowedIPsFn != nil {
+		return m.setVerificationTokenAllowedIPsFn(ctx, arg)
+	}
+	return fmt.Errorf("not mocked")
+}
 func (m *mockStore) CountVerificationTokensFiltered(ctx context.Context, arg db.CountVerificationTokensFilteredParams) (int64, error) {
 	if m.countVerificationTokensFilteredFn != nil {
 		return m.countVerificationTokensFilteredFn(ctx, arg)
@@ -368,6 +402,9 @@ func (m *mockStore) CreateGuardrailConfig(ctx context.Context, arg db.CreateGuar
 	return db.GuardrailConfigTable{}, fmt.Errorf("not mocked")
 }
 func (m *mockStore) CreateIPWhitelist(ctx context.Context, arg db.CreateIPWhitelistParams) (db.IPWhitelistTable, error) {
+	if m.createIPWhitelistFn != nil {
+		return m.createIPWhitelistFn(ctx, arg)
+	}
 	m.ni()
 	return db.IPWhitelistTable{}, nil
 }
@@ -621,12 +658,72 @@ func (m *mockStore) GetPromptVersions(ctx context.Context, name string) ([]db.Pr
 	m.ni()
 	return nil, nil
 }
+func (m *mockStore) GetProxyConfig(ctx context.Context, paramName string) (db.ProxyConfigTable, error) {
+	if m.getProxyConfigFn != nil {
+		return m.getProxyConfigFn(ctx, paramName)
+	}
+	return db.ProxyConfigTable{}, fmt.Errorf("not mocked")
+}
+func (m *mockStore) UpsertProxyConfig(ctx context.Context, arg db.UpsertProxyConfigParams) (db.ProxyConfigTable, error) {
+	if m.upsertProxyConfigFn != nil {
+		return m.upsertProxyConfigFn(ctx, arg)
+	}
+	return db.ProxyConfigTable{}, fmt.Errorf("not mocked")
+}
 func (m *mockStore) GetProxyModel(ctx context.Context, modelID string) (db.ProxyModelTable, error) {
 	if m.getProxyModelFn != nil {
 		return m.getProxyModelFn(ctx, modelID)
 	}
 	return db.ProxyModelTable{}, fmt.Errorf("not mocked")
 }
+func (m *mockStore) CreateRole(ctx context.Context, arg db.CreateRoleParams) (db.RoleTable, error) {
+	if m.createRoleFn != nil {
+		return m.createRoleFn(ctx, arg)
+	}
+	return db.RoleTable{}, fmt.Errorf("not mocked")
+}
+func (m *mockStore) CreateRoleAssignment(ctx context.Context, arg db.CreateRoleAssignmentParams) (db.RoleAssignmentTable, error) {
+	if m.createRoleAssignmentFn != nil {
+		return m.createRoleAssignmentFn(ctx, arg)
+	}
+	return db.RoleAssignmentTable{}, fmt.Errorf("not mocked")
+}
+func (m *mockStore) DeleteRole(ctx context.Context, roleName string) error {
+	if m.deleteRoleFn != nil {
+		return m.deleteRoleFn(ctx, roleName)
+	}
+	return fmt.Errorf("not mocked")
+}
+func (m *mockStore) DeleteRoleAssignment(ctx context.Context, id string) error {
+	if m.deleteRoleAssignmentFn != nil {
+		return m.deleteRoleAssignmentFn(ctx, id)
+	}
+	return fmt.Errorf("not mocked")
+}
+func (m *mockStore) GetRole(ctx context.Context, roleName string) (db.RoleTable, error) {
+	if m.getRoleFn != nil {
+		return m.getRoleFn(ctx, roleName)
+	}
+	return db.RoleTable{}, fmt.Errorf("not mocked")
+}
+func (m *mockStore) ListRoleAssignments(ctx context.Context, arg db.ListRoleAssignmentsParams) ([]db.RoleAssignmentTable, error) {
+	if m.listRoleAssignmentsFn != nil {
+		return m.listRoleAssignmentsFn(ctx, arg)
+	}
+	return nil, nil
+}
+func (m *mockStore) ListRoles(ctx context.Context) ([]db.RoleTable, error) {
+	if m.listRolesFn != nil {
+		return m.listRolesFn(ctx)
+	}
+	return nil, nil
+}
+func (m *mockStore) UpdateRole(ctx context.Context, arg db.UpdateRoleParams) (db.RoleTable, error) {
+	if m.updateRoleFn != nil {
+		return m.updateRoleFn(ctx, arg)
+	}
+	return db.RoleTable{}, fmt.Errorf("not mocked")
+}
 func (m *mockStore) GetSkill(ctx context.Context, skillID string) (db.SkillsTable, error) {
 	m.ni()
 	return db.SkillsTable{}, nil
@@ -818,6 +915,9 @@ func (m *mockStore) ResetVerificationTokenSpend(ctx context.Context, token strin
 	m.ni()
 	return nil
 }
+func (m *mockStore) SetTeamAllowedIPs(ctx context.Context, arg db.SetTeamAllowedIPsParams) error {
+	return nil
+}
 func (m *mockStore) SetTeamCallback(ctx context.Context, arg db.SetTeamCallbackParams) error {
 	m.ni()
 	return nil
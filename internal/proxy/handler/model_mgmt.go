@@ -2,11 +2,13 @@ package handler
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 )
@@ -38,6 +40,25 @@ func toProxyModelResponse(m db.ProxyModelTable) proxyModelResponse {
 	}
 }
 
+// syncRouterModel applies a created or updated DB model to the live router
+// so it is routable immediately on this replica. Other replicas pick it up
+// on their next model reload. A model whose params cannot be routed is
+// removed from the router rather than left stale.
+func (h *Handlers) syncRouterModel(m db.ProxyModelTable) {
+	if h.Router == nil {
+		return
+	}
+	cfg, err := config.ModelConfigFromJSON(m.ModelName, m.TianjiParams, m.ModelInfo)
+	if err != nil {
+		log.Printf("model %s not routable: %v", m.ModelID, err)
+		h.Router.RemoveModel(m.ModelID)
+		return
+	}
+	if err := h.Router.UpsertModel(m.ModelID, cfg); err != nil {
+		log.Printf("model %s not routable: %v", m.ModelID, err)
+	}
+}
+
 // ModelNew handles POST /model/new.
 func (h *Handlers) ModelNew(w http.ResponseWriter, r *http.Request) {
 	if h.DB == nil {
@@ -75,6 +96,7 @@ func (h *Handlers) ModelNew(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.syncRouterModel(result)
 	writeJSON(w, http.StatusCreated, toProxyModelResponse(result))
 }
 
@@ -151,6 +173,7 @@ func (h *Handlers) ModelUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.syncRouterModel(result)
 	writeJSON(w, http.StatusOK, toProxyModelResponse(result))
 }
 
@@ -180,5 +203,8 @@ func (h *Handlers) ModelDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if h.Router != nil {
+		h.Router.RemoveModel(modelID)
+	}
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
@@ -8,7 +8,10 @@ import (
 	"testing"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
+	"github.com/praxisllmlab/tianjiLLM/internal/router/strategy"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestModelNew_Success(t *testing.T) {
@@ -71,3 +74,34 @@ func TestModelDelete_Success(t *testing.T) {
 	h.ModelDelete(w, r)
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestModelNew_RoutableImmediately(t *testing.T) {
+	m := newMockStore()
+	m.createProxyModelFn = func(_ context.Context, arg db.CreateProxyModelParams) (db.ProxyModelTable, error) {
+		return db.ProxyModelTable{ModelID: arg.ModelID, ModelName: arg.ModelName, TianjiParams: arg.TianjiParams, ModelInfo: arg.ModelInfo}, nil
+	}
+	m.deleteProxyModelFn = func(context.Context, string) error { return nil }
+	h := mockHandlers(m)
+	h.Router = router.New(nil, strategy.NewShuffle(), router.RouterSettings{})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/model/new", strings.NewReader(`{"model_id":"m1","model_name":"db-gpt","tianji_params":{"model":"openai/gpt-4o","api_key":"sk-db"},"model_info":{}}`))
+	h.ModelNew(w, r)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	d, _, err := h.Router.Route(context.Background(), "db-gpt", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "m1", d.ID)
+	assert.Equal(t, "sk-db", d.APIKey())
+
+	w = httptest.NewRecorder()
+	h.ListModels(w, httptest.NewRequest("GET", "/v1/models", nil))
+	assert.Contains(t, w.Body.String(), `"id":"db-gpt"`)
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "/model/delete", strings.NewReader(`{"model_id":"m1"}`))
+	h.ModelDelete(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+	_, _, err = h.Router.Route(context.Background(), "db-gpt", nil)
+	assert.ErrorIs(t, err, router.ErrNoDeployments)
+}
@@ -1,14 +1,21 @@
 package handler
 
 import (
+	"context"
 	"net/http"
+	"time"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/callback"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
-	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
+	"github.com/praxisllmlab/tianjiLLM/internal/provider"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
 )
 
 // Moderation handles POST /v1/moderations.
 func (h *Handlers) Moderation(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+
 	var req model.ModerationRequest
 	if err := decodeJSON(r, &req); err != nil {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
@@ -20,27 +27,35 @@ func (h *Handlers) Moderation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	modelName := req.Model
-	if modelName == "" {
-		modelName = "text-moderation-latest"
+	if req.Model == "" {
+		req.Model = "text-moderation-latest"
 	}
 
-	p, apiKey, _, err := h.resolveProviderFromConfig(modelName)
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: err.Error(),
-				Type:    "invalid_request_error",
-			},
-		})
+	var ok bool
+	if req.Model, ok = authorizeModel(w, r, req.Model); !ok {
 		return
 	}
 
-	url := p.GetRequestURL(modelName)
-	url = url[:len(url)-len("/chat/completions")] + "/moderations"
+	resp, exec, ok := h.dispatchCapability(w, r, req.Model, capModeration, func(ctx context.Context, a router.Attempt) (*http.Request, error) {
+		attemptReq := req
+		attemptReq.Model = a.Model
+		return a.Provider.(provider.ModerationProvider).TransformModerationRequest(ctx, &attemptReq, a.APIKey)
+	})
+	if !ok {
+		return
+	}
+	defer resp.Body.Close()
+
+	result, err := exec.Final.Provider.(provider.ModerationProvider).TransformModerationResponse(r.Context(), resp)
+	if err != nil {
+		writeUpstreamError(w, err)
+		return
+	}
 
-	// Phase 2: provider.resolved
-	middleware.LogProviderResolved(r.Context(), h.lookupProviderName(modelName), url, "moderation", modelName)
+	h.logCapabilitySuccess(r.Context(), exec, callback.LogData{
+		StartTime: startTime,
+		CallType:  capModeration.callType,
+	}, pricing.UnitUsage{})
 
-	proxyUpstream(w, r, url, apiKey, p)
+	writeJSON(w, http.StatusOK, result)
 }
@@ -15,17 +15,31 @@ import (
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/praxisllmlab/tianjiLLM/internal/callback"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
+	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider/anthropic"
 	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
 )
 
 // nativeProxy creates a reverse proxy to a specific provider's base URL.
 func (h *Handlers) nativeProxy(w http.ResponseWriter, r *http.Request, providerName string) {
+	// Read request body to extract model name as fallback for spend logging.
+	requestModel := extractRequestModel(r)
+
+	// Gemini routes carry the model in the path rather than the body.
+	calledModel := chi.URLParam(r, "model")
+	if calledModel == "" {
+		calledModel = requestModel
+	}
+	if _, ok := authorizeModel(w, r, calledModel); !ok {
+		return
+	}
+
 	upstreams := h.resolveAllNativeUpstreams(providerName)
 	upstream, throttleErr := h.selectUpstreamWithThrottle(providerName, upstreams)
 	if throttleErr != nil {
@@ -65,9 +79,6 @@ func (h *Handlers) nativeProxy(w http.ResponseWriter, r *http.Request, providerN
 		return
 	}
 
-	// Read request body to extract model name as fallback for spend logging.
-	requestModel := extractRequestModel(r)
-
 	startTime := time.Now()
 	ctx := r.Context()
 
@@ -193,6 +204,7 @@ func (h *Handlers) nativeProxy(w http.ResponseWriter, r *http.Request, providerN
 				// bypassing our Read() method and leaving buf empty.
 				ssr := &sseSpendReader{
 					src:          resp.Body,
+					header:       resp.Header,
 					providerName: providerName,
 					startTime:    startTime,
 					ctx:          ctx,
@@ -214,10 +226,12 @@ func (h *Handlers) nativeProxy(w http.ResponseWriter, r *http.Request, providerN
 			if modelName == "" {
 				modelName = requestModel
 			}
-			go h.Callbacks.LogSuccess(buildNativeLogData(
+			data := buildNativeLogData(
 				ctx, providerName, modelName, startTime,
 				prompt, completion, cacheRead, cacheCreation,
-			))
+			)
+			middleware.RecordUsage(ctx, data.TotalTokens)
+			go h.Callbacks.LogSuccess(data)
 			return nil
 		},
 		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
@@ -334,15 +348,7 @@ func buildNativeLogData(ctx context.Context, providerName, modelName string, sta
 		CacheCreationInputTokens: cacheCreation,
 		Cost:                     promptCost + completionCost,
 	}
-	if tokenHash, ok := ctx.Value(middleware.ContextKeyTokenHash).(string); ok {
-		data.APIKey = tokenHash
-	}
-	if userID, ok := ctx.Value(middleware.ContextKeyUserID).(string); ok {
-		data.UserID = userID
-	}
-	if teamID, ok := ctx.Value(middleware.ContextKeyTeamID).(string); ok {
-		data.TeamID = teamID
-	}
+	setCaller(ctx, &data)
 	return data
 }
 
@@ -351,6 +357,7 @@ func buildNativeLogData(ctx context.Context, providerName, modelName string, sta
 // collected SSE events to extract usage and fires the spend callback.
 type sseSpendReader struct {
 	src          io.ReadCloser
+	header       http.Header
 	buf          bytes.Buffer
 	providerName string
 	requestModel string
@@ -388,27 +395,35 @@ type readCloserOnly struct{ io.ReadCloser }
 func (r *sseSpendReader) Close() error {
 	err := r.src.Close()
 
-	prompt, completion, cacheRead, cacheCreation, modelName := parseSSEUsage(r.providerName, r.buf.Bytes())
+	// Decode the stream the way the provider's own streaming calls are.
+	p, _ := provider.Get(r.providerName)
+	events := provider.DecodeStream(p, &http.Response{Header: r.header, Body: io.NopCloser(&r.buf)})
+	prompt, completion, cacheRead, cacheCreation, modelName := parseStreamUsage(r.providerName, events)
 	if modelName == "" {
 		modelName = r.requestModel
 	}
-	go r.callbacks.LogSuccess(buildNativeLogData(
+	data := buildNativeLogData(
 		r.ctx, r.providerName, modelName, r.startTime,
 		prompt, completion, cacheRead, cacheCreation,
-	))
+	)
+	middleware.RecordUsage(r.ctx, data.TotalTokens)
+	go r.callbacks.LogSuccess(data)
 	return err
 }
 
 // parseSSEUsage scans SSE events for usage data.
 // Anthropic: model in message_start, usage in message_delta.
 func parseSSEUsage(providerName string, raw []byte) (prompt, completion, cacheRead, cacheCreation int, modelName string) {
-	// Split into lines and process "data: " prefixed lines.
-	for _, line := range bytes.Split(raw, []byte("\n")) {
-		line = bytes.TrimSpace(line)
-		if !bytes.HasPrefix(line, []byte("data: ")) {
-			continue
+	return parseStreamUsage(providerName, provider.NewSSEReader(bytes.NewReader(raw)))
+}
+
+// parseStreamUsage scans stream events for usage data.
+func parseStreamUsage(providerName string, events provider.StreamReader) (prompt, completion, cacheRead, cacheCreation int, modelName string) {
+	for {
+		data, err := events.Next()
+		if err != nil {
+			break
 		}
-		data := line[6:] // strip "data: "
 
 		switch providerName {
 		case "anthropic":
@@ -7,9 +7,11 @@ import (
 	"net/http/httptest"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-chi/chi/v5"
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -17,6 +19,7 @@ import (
 	"github.com/praxisllmlab/tianjiLLM/internal/config"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -477,3 +480,51 @@ func TestParseUsage_InvalidJSON(t *testing.T) {
 	assert.Equal(t, 0, completion)
 	assert.Equal(t, "", model)
 }
+
+func TestNativeProxy_EnforcesModelAllowList(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"model":"claude-3","usage":{"input_tokens":1,"output_tokens":1}}`))
+	}))
+	defer upstream.Close()
+
+	access := &middleware.ModelAccess{Models: []string{"claude-3"}}
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyAllowedModels, access)
+
+	h := nativeTestHandlers(upstream.URL, "anthropic", nil)
+	call := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(body)).WithContext(ctx)
+		w := httptest.NewRecorder()
+		h.AnthropicMessages(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusForbidden, call(`{"model":"claude-opus"}`))
+	assert.Equal(t, int32(0), calls.Load(), "denied request must not reach upstream")
+	assert.Equal(t, http.StatusOK, call(`{"model":"claude-3"}`))
+
+	// Gemini routes take the model from the path.
+	gh := nativeTestHandlers(upstream.URL, "gemini", nil)
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("model", "gemini-pro")
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/gemini-pro:generateContent", strings.NewReader(`{}`))
+	req = req.WithContext(context.WithValue(ctx, chi.RouteCtxKey, rctx))
+	w := httptest.NewRecorder()
+	gh.GeminiGenerateContent(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestCreateResponse_EnforcesModelAllowList(t *testing.T) {
+	t.Parallel()
+
+	access := &middleware.ModelAccess{Models: []string{"gpt-4o"}}
+	ctx := context.WithValue(context.Background(), middleware.ContextKeyAllowedModels, access)
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(`{"model":"o3"}`)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	newTestHandlers().CreateResponse(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "access_denied")
+}
@@ -11,6 +11,12 @@ import (
 // then forwards the full request to the upstream endpoint at the given path suffix.
 func (h *Handlers) proxyPassthrough(w http.ResponseWriter, r *http.Request, pathSuffix string) {
 	modelName := r.URL.Query().Get("model")
+	if modelName != "" {
+		var ok bool
+		if modelName, ok = authorizeModel(w, r, modelName); !ok {
+			return
+		}
+	}
 	baseURL, apiKey, err := h.resolveProviderBaseURL(modelName)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
@@ -304,7 +304,7 @@ func TestModeration_NoProvider(t *testing.T) {
 	r.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	h.Moderation(w, r)
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
 // TestResolvePromptTemplate_NoPromptName tests that resolvePromptTemplate returns nil when PromptName is empty.
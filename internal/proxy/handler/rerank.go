@@ -1,34 +1,23 @@
 package handler
 
 import (
-	"bytes"
-	"encoding/json"
-	"io"
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/callback"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
-	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
+	"github.com/praxisllmlab/tianjiLLM/internal/provider"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
 )
 
 // Rerank handles POST /v1/rerank — rerank documents.
 func (h *Handlers) Rerank(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "read request body: " + err.Error(),
-				Type:    "invalid_request_error",
-			},
-		})
-		return
-	}
-
 	var req model.RerankRequest
-	if err = json.Unmarshal(body, &req); err != nil {
+	if err := decodeJSON(r, &req); err != nil {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
 			Error: model.ErrorDetail{
 				Message: "invalid request body: " + err.Error(),
@@ -38,88 +27,50 @@ func (h *Handlers) Rerank(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	baseURL, apiKey, err := h.resolveProviderBaseURL(req.Model)
-	if err != nil {
-		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
-			Error: model.ErrorDetail{Message: err.Error(), Type: "invalid_request_error"},
-		})
+	var ok bool
+	if req.Model, ok = authorizeModel(w, r, req.Model); !ok {
 		return
 	}
 
-	// Phase 2: provider.resolved
-	middleware.LogProviderResolved(r.Context(), h.lookupProviderName(req.Model), baseURL+"/rerank", "rerank", req.Model)
-
-	upstreamReq, err := http.NewRequestWithContext(r.Context(), http.MethodPost, baseURL+"/rerank", bytes.NewReader(body))
-	if err != nil {
-		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "create upstream request: " + err.Error(),
-				Type:    "internal_error",
-			},
-		})
+	resp, exec, ok := h.dispatchCapability(w, r, req.Model, capRerank, func(ctx context.Context, a router.Attempt) (*http.Request, error) {
+		attemptReq := req
+		attemptReq.Model = a.Model
+		return a.Provider.(provider.RerankProvider).TransformRerankRequest(ctx, &attemptReq, a.APIKey)
+	})
+	if !ok {
 		return
 	}
-	upstreamReq.Header.Set("Content-Type", "application/json")
-	upstreamReq.Header.Set("Authorization", "Bearer "+apiKey)
+	defer resp.Body.Close()
 
-	upstreamStart := time.Now()
-	resp, err := http.DefaultClient.Do(upstreamReq)
-	upstreamLatency := middleware.UpstreamLatencyMs(upstreamStart)
+	result, err := exec.Final.Provider.(provider.RerankProvider).TransformRerankResponse(r.Context(), resp)
 	if err != nil {
-		middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
-			LatencyMs: upstreamLatency,
-			Error:     err.Error(),
-		})
-		writeJSON(w, http.StatusBadGateway, model.ErrorResponse{
-			Error: model.ErrorDetail{
-				Message: "upstream request failed: " + err.Error(),
-				Type:    "internal_error",
-			},
-		})
+		writeUpstreamError(w, err)
 		return
 	}
-	defer resp.Body.Close()
-
-	// Phase 3: upstream.responded
-	middleware.LogUpstreamResponded(r.Context(), middleware.UpstreamResult{
-		StatusCode: resp.StatusCode,
-		LatencyMs:  upstreamLatency,
-	})
-
-	respBody, _ := io.ReadAll(resp.Body)
 
-	for k, vv := range resp.Header {
-		for _, v := range vv {
-			w.Header().Add(k, v)
+	totalTokens := 0
+	queries := 1
+	if result.Usage != nil {
+		totalTokens = result.Usage.TotalTokens
+	}
+	if result.Meta != nil {
+		// Cohere-style response: meta.tokens and meta.billed_units
+		if result.Usage == nil && result.Meta.Tokens != nil {
+			totalTokens = result.Meta.Tokens.InputTokens + result.Meta.Tokens.OutputTokens
+		}
+		if result.Meta.BilledUnits != nil && result.Meta.BilledUnits.SearchUnits > 0 {
+			queries = result.Meta.BilledUnits.SearchUnits
 		}
 	}
-	w.WriteHeader(resp.StatusCode)
-	_, _ = w.Write(respBody)
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 && h.Callbacks != nil {
-		var parsed model.RerankResponse
-		_ = json.Unmarshal(respBody, &parsed)
+	// For rerank, there is no prompt/completion token split.
+	// Set PromptTokens = TotalTokens so the UI can display token usage.
+	h.logCapabilitySuccess(r.Context(), exec, callback.LogData{
+		PromptTokens: totalTokens,
+		TotalTokens:  totalTokens,
+		StartTime:    startTime,
+		CallType:     capRerank.callType,
+	}, pricing.UnitUsage{Queries: queries})
 
-		totalTokens := 0
-		if parsed.Usage != nil {
-			totalTokens = parsed.Usage.TotalTokens
-		} else if parsed.Meta != nil && parsed.Meta.Tokens != nil {
-			// Cohere-style response: meta.tokens.input_tokens
-			totalTokens = parsed.Meta.Tokens.InputTokens + parsed.Meta.Tokens.OutputTokens
-		}
-
-		endTime := time.Now()
-		// For rerank, there is no prompt/completion token split.
-		// Set PromptTokens = TotalTokens so the UI can display token usage.
-		go h.Callbacks.LogSuccess(callback.LogData{
-			Model:        req.Model,
-			APIKey:       apiKey,
-			PromptTokens: totalTokens,
-			TotalTokens:  totalTokens,
-			StartTime:    startTime,
-			EndTime:      endTime,
-			Latency:      endTime.Sub(startTime),
-			CallType:     "rerank",
-		})
-	}
+	writeJSON(w, http.StatusOK, result)
 }
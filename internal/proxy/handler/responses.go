@@ -7,5 +7,8 @@ import (
 // CreateResponse handles POST /v1/responses.
 // Proxies to upstream OpenAI Responses API via the same mechanism as Assistants API.
 func (h *Handlers) CreateResponse(w http.ResponseWriter, r *http.Request) {
+	if _, ok := authorizeModel(w, r, extractRequestModel(r)); !ok {
+		return
+	}
 	h.assistantsProxy(w, r)
 }
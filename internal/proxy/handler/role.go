@@ -0,0 +1,302 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+)
+
+// roleRequest is the body of POST /role/new and /role/update.
+type roleRequest struct {
+	RoleName    string   `json:"role_name"`
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// roleInfo is a custom role with its assignments.
+type roleInfo struct {
+	db.RoleTable
+	Assignments []db.RoleAssignmentTable `json:"assignments"`
+}
+
+// RoleNew handles POST /role/new.
+func (h *Handlers) RoleNew(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "database not configured", Type: "internal_error"},
+		})
+		return
+	}
+
+	var req roleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "invalid request: " + err.Error(), Type: "invalid_request_error"},
+		})
+		return
+	}
+	if err := auth.ValidateCustomRole(req.RoleName, req.Permissions); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: err.Error(), Type: "invalid_request_error"},
+		})
+		return
+	}
+
+	changedBy, _ := r.Context().Value(middleware.ContextKeyUserID).(string)
+	params := db.CreateRoleParams{
+		RoleName:    req.RoleName,
+		Permissions: req.Permissions,
+		CreatedBy:   changedBy,
+	}
+	if req.Description != nil {
+		params.Description = *req.Description
+	}
+	if params.Permissions == nil {
+		params.Permissions = []string{}
+	}
+	role, err := h.DB.CreateRole(r.Context(), params)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "create role: " + err.Error(), Type: "internal_error"},
+		})
+		return
+	}
+
+	h.createAuditLog(r.Context(), "created", "RoleTable", role.RoleName, changedBy, "", nil, req)
+	writeJSON(w, http.StatusCreated, role)
+}
+
+// RoleList handles GET /role/list.
+func (h *Handlers) RoleList(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "database not configured", Type: "internal_error"},
+		})
+		return
+	}
+
+	roles, err := h.DB.ListRoles(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "list roles: " + err.Error(), Type: "internal_error"},
+		})
+		return
+	}
+	if roles == nil {
+		roles = []db.RoleTable{}
+	}
+	writeJSON(w, http.StatusOK, roles)
+}
+
+// RoleInfo handles GET /role/info/{role_name}.
+func (h *Handlers) RoleInfo(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "database not configured", Type: "internal_error"},
+		})
+		return
+	}
+
+	name := chi.URLParam(r, "role_name")
+	role, err := h.DB.GetRole(r.Context(), name)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "role not found", Type: "not_found"},
+		})
+		return
+	}
+	assignments, err := h.DB.ListRoleAssignments(r.Context(), db.ListRoleAssignmentsParams{RoleName: name})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "list role assignments: " + err.Error(), Type: "internal_error"},
+		})
+		return
+	}
+	if assignments == nil {
+		assignments = []db.RoleAssignmentTable{}
+	}
+	writeJSON(w, http.StatusOK, roleInfo{RoleTable: role, Assignments: assignments})
+}
+
+// RoleUpdate handles POST /role/update.
+func (h *Handlers) RoleUpdate(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "database not configured", Type: "internal_error"},
+		})
+		return
+	}
+
+	var req roleRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "invalid request: " + err.Error(), Type: "invalid_request_error"},
+		})
+		return
+	}
+	if err := auth.ValidateCustomRole(req.RoleName, req.Permissions); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: err.Error(), Type: "invalid_request_error"},
+		})
+		return
+	}
+
+	changedBy, _ := r.Context().Value(middleware.ContextKeyUserID).(string)
+	role, err := h.DB.UpdateRole(r.Context(), db.UpdateRoleParams{
+		RoleName:    req.RoleName,
+		Description: req.Description,
+		Permissions: req.Permissions,
+		UpdatedBy:   changedBy,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			writeJSON(w, http.StatusNotFound, model.ErrorResponse{
+				Error: model.ErrorDetail{Message: "role not found", Type: "not_found"},
+			})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "update role: " + err.Error(), Type: "internal_error"},
+		})
+		return
+	}
+
+	h.RoleGrants.Invalidate()
+	h.createAuditLog(r.Context(), "updated", "RoleTable", role.RoleName, changedBy, "", nil, req)
+	writeJSON(w, http.StatusOK, role)
+}
+
+// RoleDelete handles POST /role/delete. Its assignments are deleted with it.
+func (h *Handlers) RoleDelete(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "database not configured", Type: "internal_error"},
+		})
+		return
+	}
+
+	var req struct {
+		RoleName string `json:"role_name"`
+	}
+	if err := decodeJSON(r, &req); err != nil || req.RoleName == "" {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "role_name is required", Type: "invalid_request_error"},
+		})
+		return
+	}
+
+	if err := h.DB.DeleteRole(r.Context(), req.RoleName); err != nil {
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "delete role: " + err.Error(), Type: "internal_error"},
+		})
+		return
+	}
+
+	h.RoleGrants.Invalidate()
+	changedBy, _ := r.Context().Value(middleware.ContextKeyUserID).(string)
+	h.createAuditLog(r.Context(), "deleted", "RoleTable", req.RoleName, changedBy, "", nil, nil)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// RoleAssign handles POST /role/assign. An organization_id or team_id
+// limits the grant to requests made in that organization or team.
+func (h *Handlers) RoleAssign(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "database not configured", Type: "internal_error"},
+		})
+		return
+	}
+
+	var req struct {
+		RoleName       string  `json:"role_name"`
+		UserID         string  `json:"user_id"`
+		OrganizationID *string `json:"organization_id"`
+		TeamID         *string `json:"team_id"`
+	}
+	if err := decodeJSON(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "invalid request: " + err.Error(), Type: "invalid_request_error"},
+		})
+		return
+	}
+	if req.RoleName == "" || req.UserID == "" {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "role_name and user_id are required", Type: "invalid_request_error"},
+		})
+		return
+	}
+	if _, err := h.DB.GetRole(r.Context(), req.RoleName); err != nil {
+		writeJSON(w, http.StatusNotFound, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "role not found", Type: "not_found"},
+		})
+		return
+	}
+
+	changedBy, _ := r.Context().Value(middleware.ContextKeyUserID).(string)
+	assignment, err := h.DB.CreateRoleAssignment(r.Context(), db.CreateRoleAssignmentParams{
+		RoleName:       req.RoleName,
+		UserID:         req.UserID,
+		OrganizationID: nonEmpty(req.OrganizationID),
+		TeamID:         nonEmpty(req.TeamID),
+		CreatedBy:      changedBy,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "assign role: " + err.Error(), Type: "internal_error"},
+		})
+		return
+	}
+
+	h.RoleGrants.Invalidate()
+	h.createAuditLog(r.Context(), "created", "RoleAssignmentTable", assignment.ID, changedBy, "", nil, req)
+	writeJSON(w, http.StatusCreated, assignment)
+}
+
+// RoleUnassign handles POST /role/unassign.
+func (h *Handlers) RoleUnassign(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		writeJSON(w, http.StatusServiceUnavailable, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "database not configured", Type: "internal_error"},
+		})
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := decodeJSON(r, &req); err != nil || req.ID == "" {
+		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "id is required", Type: "invalid_request_error"},
+		})
+		return
+	}
+
+	if err := h.DB.DeleteRoleAssignment(r.Context(), req.ID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+			Error: model.ErrorDetail{Message: "unassign role: " + err.Error(), Type: "internal_error"},
+		})
+		return
+	}
+
+	h.RoleGrants.Invalidate()
+	changedBy, _ := r.Context().Value(middleware.ContextKeyUserID).(string)
+	h.createAuditLog(r.Context(), "deleted", "RoleAssignmentTable", req.ID, changedBy, "", nil, nil)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// nonEmpty returns nil for a nil or blank string.
+func nonEmpty(s *string) *string {
+	if s == nil || strings.TrimSpace(*s) == "" {
+		return nil
+	}
+	return s
+}
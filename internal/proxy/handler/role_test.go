@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleNew_Success(t *testing.T) {
+	m := newMockStore()
+	m.createRoleFn = func(_ context.Context, arg db.CreateRoleParams) (db.RoleTable, error) {
+		return db.RoleTable{RoleName: arg.RoleName, Permissions: arg.Permissions}, nil
+	}
+	h := mockHandlers(m)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/role/new", strings.NewReader(`{"role_name":"spend-reader","permissions":["GET /spend/*"]}`))
+	h.RoleNew(w, r)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestRoleNew_Invalid(t *testing.T) {
+	for name, body := range map[string]string{
+		"built-in role":  `{"role_name":"proxy_admin","permissions":["*"]}`,
+		"missing name":   `{"permissions":["*"]}`,
+		"bad method":     `{"role_name":"r","permissions":["FETCH /spend/logs"]}`,
+		"relative route": `{"role_name":"r","permissions":["spend/logs"]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			h := mockHandlers(newMockStore())
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest("POST", "/role/new", strings.NewReader(body))
+			h.RoleNew(w, r)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestRoleAssign_UnknownRole(t *testing.T) {
+	h := mockHandlers(newMockStore())
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/role/assign", strings.NewReader(`{"role_name":"nope","user_id":"u1"}`))
+	h.RoleAssign(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
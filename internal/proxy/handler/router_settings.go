@@ -1,52 +1,148 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
+	"github.com/praxisllmlab/tianjiLLM/internal/router/strategy"
 )
 
+// RouterSettingsParam is the ProxyConfigTable param_name under which
+// runtime router_settings overrides are persisted.
+const RouterSettingsParam = "router_settings"
+
 // RouterSettingsGet handles GET /router/settings — returns current router settings.
 func (h *Handlers) RouterSettingsGet(w http.ResponseWriter, r *http.Request) {
-	if h.Config.RouterSettings == nil {
+	rs := h.routerSettings()
+	if rs == nil {
 		writeJSON(w, http.StatusOK, map[string]any{})
 		return
 	}
-	writeJSON(w, http.StatusOK, h.Config.RouterSettings)
+	writeJSON(w, http.StatusOK, rs)
+}
+
+// routerSettings returns the current router_settings. Patches replace the
+// value rather than modify it, so the result is safe to read unlocked.
+func (h *Handlers) routerSettings() *config.RouterSettings {
+	h.routerSettingsMu.RLock()
+	defer h.routerSettingsMu.RUnlock()
+	return h.Config.RouterSettings
 }
 
 // RouterSettingsPatch handles PATCH /router/settings — updates router settings.
+//
+// The body uses proxy_config.yaml keys; each top-level key replaces the
+// current value and null resets it. The new settings are validated, the
+// patched keys are merged into the overrides stored in ProxyConfigTable
+// (so keys never patched keep following proxy_config.yaml), and applied to the running Router (strategy, retries,
+// cooldowns, fallbacks, aliases and retry policies) and audit logged.
+// Patches are serialized so concurrent requests never lose each other's keys.
 func (h *Handlers) RouterSettingsPatch(w http.ResponseWriter, r *http.Request) {
-	if h.Config.RouterSettings == nil {
+	var patch map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "router_settings not configured",
+			"error": "invalid request body: " + err.Error(),
 		})
 		return
 	}
 
-	var patch map[string]any
-	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+	h.routerSettingsMu.Lock()
+	defer h.routerSettingsMu.Unlock()
+
+	before := h.Config.RouterSettings
+	patched, err := config.PatchRouterSettings(before, patch)
+	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "invalid request body: " + err.Error(),
+			"error": err.Error(),
 		})
 		return
 	}
 
-	// Apply known fields
-	if v, ok := patch["routing_strategy"].(string); ok {
-		h.Config.RouterSettings.RoutingStrategy = v
+	var routeStrategy router.Strategy
+	if h.Router != nil {
+		routeStrategy, err = strategy.NewFromSettings(patched, h.ProviderSpend)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"error": "routing_strategy: " + err.Error(),
+			})
+			return
+		}
 	}
-	if v, ok := patch["num_retries"].(float64); ok {
-		n := int(v)
-		h.Config.RouterSettings.NumRetries = &n
+
+	changedBy, _ := r.Context().Value(middleware.ContextKeyUserID).(string)
+	changedByKey, _ := r.Context().Value(middleware.ContextKeyTokenHash).(string)
+
+	runtime, err := config.RuntimeRouterSettings(patched)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{
+			"error": err.Error(),
+		})
+		return
 	}
-	if v, ok := patch["allowed_fails"].(float64); ok {
-		n := int(v)
-		h.Config.RouterSettings.AllowedFails = &n
+	if h.DB != nil {
+		overrides, err := h.storedRouterSettings(r.Context())
+		var value []byte
+		if err == nil {
+			for k, v := range patch {
+				overrides[k] = v
+			}
+			value, err = json.Marshal(overrides)
+		}
+		if err == nil {
+			_, err = h.DB.UpsertProxyConfig(r.Context(), db.UpsertProxyConfigParams{
+				ParamName:  RouterSettingsParam,
+				ParamValue: value,
+				UpdatedBy:  changedBy,
+			})
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{
+				"error": "persist router settings: " + err.Error(),
+			})
+			return
+		}
 	}
-	if v, ok := patch["cooldown_time"].(float64); ok {
-		n := int(v)
-		h.Config.RouterSettings.CooldownTime = &n
+
+	if h.Router != nil {
+		cfg := *h.Config
+		cfg.RouterSettings = patched
+		h.Router.Reconfigure(routeStrategy, router.SettingsFromConfig(&cfg))
+	}
+	h.Config.RouterSettings = patched
+
+	var beforeValue any
+	if before != nil {
+		beforeValue, _ = config.RuntimeRouterSettings(before)
 	}
+	h.createAuditLog(r.Context(), "updated", "ProxyConfigTable", RouterSettingsParam, changedBy, changedByKey, beforeValue, runtime)
 
-	writeJSON(w, http.StatusOK, h.Config.RouterSettings)
+	writeJSON(w, http.StatusOK, patched)
+}
+
+// storedRouterSettings returns the router_settings overrides persisted by
+// earlier patches, or an empty map when none are stored.
+func (h *Handlers) storedRouterSettings(ctx context.Context) (map[string]any, error) {
+	overrides := map[string]any{}
+	row, err := h.DB.GetProxyConfig(ctx, RouterSettingsParam)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return overrides, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(row.ParamValue, &overrides); err != nil {
+		return nil, err
+	}
+	if overrides == nil {
+		overrides = map[string]any{}
+	}
+	return overrides, nil
 }
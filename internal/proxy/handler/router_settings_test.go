@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
+	"github.com/praxisllmlab/tianjiLLM/internal/router/strategy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterSettingsPatch_AppliesLive(t *testing.T) {
+	m := newMockStore()
+	m.getProxyConfigFn = func(context.Context, string) (db.ProxyConfigTable, error) {
+		return db.ProxyConfigTable{}, pgx.ErrNoRows
+	}
+	var persisted db.UpsertProxyConfigParams
+	m.upsertProxyConfigFn = func(_ context.Context, arg db.UpsertProxyConfigParams) (db.ProxyConfigTable, error) {
+		persisted = arg
+		return db.ProxyConfigTable{ParamName: arg.ParamName, ParamValue: arg.ParamValue}, nil
+	}
+	var audit db.InsertAuditLogParams
+	m.insertAuditLogFn = func(_ context.Context, arg db.InsertAuditLogParams) (db.AuditLog, error) {
+		audit = arg
+		return db.AuditLog{}, nil
+	}
+
+	h := mockHandlers(m)
+	h.Config.GeneralSettings.StoreAuditLogs = true
+	h.Config.RouterSettings = &config.RouterSettings{RoutingStrategy: "simple-shuffle"}
+	h.Router = router.New(nil, strategy.NewShuffle(), router.RouterSettings{})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PATCH", "/router/settings", strings.NewReader(
+		`{"routing_strategy":"least-busy","num_retries":4,"max_fallbacks":0,"fallbacks":[{"gpt-4o":["claude-3"]}]}`))
+	h.RouterSettingsPatch(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	settings := h.Router.Settings()
+	assert.Equal(t, 4, *settings.NumRetries)
+	assert.Equal(t, 0, *settings.MaxFallbacks, "an explicit 0 is not replaced by the default")
+	assert.Equal(t, []string{"claude-3"}, settings.Fallbacks["gpt-4o"])
+	assert.Equal(t, "least-busy", h.Config.RouterSettings.RoutingStrategy)
+
+	assert.Equal(t, RouterSettingsParam, persisted.ParamName)
+	var stored map[string]any
+	require.NoError(t, json.Unmarshal(persisted.ParamValue, &stored))
+	assert.Equal(t, "least-busy", stored["routing_strategy"])
+	assert.Len(t, stored, 4, "only patched keys are persisted")
+
+	assert.Equal(t, "updated", audit.Action)
+	assert.Equal(t, RouterSettingsParam, audit.ObjectID)
+}
+
+func TestRouterSettingsPatch_MergesStoredOverrides(t *testing.T) {
+	m := newMockStore()
+	m.getProxyConfigFn = func(_ context.Context, name string) (db.ProxyConfigTable, error) {
+		return db.ProxyConfigTable{ParamName: name, ParamValue: []byte(`{"num_retries":4,"timeout":30}`)}, nil
+	}
+	var persisted db.UpsertProxyConfigParams
+	m.upsertProxyConfigFn = func(_ context.Context, arg db.UpsertProxyConfigParams) (db.ProxyConfigTable, error) {
+		persisted = arg
+		return db.ProxyConfigTable{ParamName: arg.ParamName, ParamValue: arg.ParamValue}, nil
+	}
+
+	h := mockHandlers(m)
+	h.Config.RouterSettings = &config.RouterSettings{RoutingStrategy: "simple-shuffle"}
+	h.Router = router.New(nil, strategy.NewShuffle(), router.RouterSettings{})
+
+	w := httptest.NewRecorder()
+	h.RouterSettingsPatch(w, httptest.NewRequest("PATCH", "/router/settings", strings.NewReader(`{"num_retries":2,"timeout":null}`)))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var stored map[string]any
+	require.NoError(t, json.Unmarshal(persisted.ParamValue, &stored))
+	assert.Equal(t, map[string]any{"num_retries": float64(2), "timeout": nil}, stored)
+}
+
+func TestRouterSettingsPatch_RejectsInvalid(t *testing.T) {
+	h := newTestHandlers()
+	h.Config.RouterSettings = &config.RouterSettings{RoutingStrategy: "simple-shuffle"}
+	h.Router = router.New(nil, strategy.NewShuffle(), router.RouterSettings{NumRetries: intPtr(3)})
+
+	for _, body := range []string{
+		`{"routing_strategy":"no-such-strategy"}`,
+		`{"redis_host":"other"}`,
+		`{"num_retries":"many"}`,
+	} {
+		w := httptest.NewRecorder()
+		h.RouterSettingsPatch(w, httptest.NewRequest("PATCH", "/router/settings", strings.NewReader(body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	assert.Equal(t, 3, *h.Router.Settings().NumRetries, "rejected patches must not be applied")
+	assert.Equal(t, "simple-shuffle", h.Config.RouterSettings.RoutingStrategy)
+}
+
+func TestRouterSettingsPatch_ConcurrentPatchesKeepAllKeys(t *testing.T) {
+	var mu sync.Mutex
+	stored := []byte(`{}`)
+	m := newMockStore()
+	m.getProxyConfigFn = func(_ context.Context, name string) (db.ProxyConfigTable, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return db.ProxyConfigTable{ParamName: name, ParamValue: stored}, nil
+	}
+	m.upsertProxyConfigFn = func(_ context.Context, arg db.UpsertProxyConfigParams) (db.ProxyConfigTable, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		stored = arg.ParamValue
+		return db.ProxyConfigTable{ParamName: arg.ParamName, ParamValue: arg.ParamValue}, nil
+	}
+
+	h := mockHandlers(m)
+	h.Config.RouterSettings = &config.RouterSettings{RoutingStrategy: "simple-shuffle"}
+	h.Router = router.New(nil, strategy.NewShuffle(), router.RouterSettings{})
+
+	keys := []string{"num_retries", "allowed_fails", "cooldown_time", "retry_after", "max_fallbacks", "timeout"}
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			h.RouterSettingsPatch(w, httptest.NewRequest("PATCH", "/router/settings", strings.NewReader(`{"`+key+`":1}`)))
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			h.RouterSettingsGet(httptest.NewRecorder(), httptest.NewRequest("GET", "/router/settings", nil))
+		}()
+	}
+	wg.Wait()
+
+	var overrides map[string]any
+	require.NoError(t, json.Unmarshal(stored, &overrides))
+	assert.Len(t, overrides, len(keys), "no patch may overwrite another's keys")
+
+	rs := h.Config.RouterSettings
+	for _, v := range []*int{rs.NumRetries, rs.AllowedFails, rs.CooldownTime, rs.RetryAfter, rs.MaxFallbacks, rs.Timeout} {
+		require.NotNil(t, v)
+		assert.Equal(t, 1, *v)
+	}
+}
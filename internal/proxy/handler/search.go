@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/praxisllmlab/tianjiLLM/internal/httpclient"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/search"
 )
@@ -82,7 +83,7 @@ func (h *Handlers) SearchHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	resp, err := http.DefaultClient.Do(upstreamReq)
+	resp, err := httpclient.Default().Do(upstreamReq)
 	if err != nil {
 		writeJSON(w, http.StatusBadGateway, model.ErrorResponse{
 			Error: model.ErrorDetail{Message: "upstream request failed: " + err.Error(), Type: "upstream_error"},
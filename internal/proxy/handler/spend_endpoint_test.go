@@ -237,11 +237,50 @@ func TestRerank_ResponseBodyForwarded(t *testing.T) {
 	w := httptest.NewRecorder()
 	h.Rerank(w, req)
 
-	if w.Body.String() != expectedBody {
+	if strings.TrimSpace(w.Body.String()) != expectedBody {
 		t.Errorf("response body mismatch\nwant: %s\ngot:  %s", expectedBody, w.Body.String())
 	}
 }
 
+func TestRerank_ForwardsDocumentObjectsAndExtraFields(t *testing.T) {
+	t.Parallel()
+
+	var got map[string]any
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"results":[{"index":0,"relevance_score":0.9}]}`))
+	}))
+	defer upstream.Close()
+
+	h := rerankTestHandlers(upstream.URL)
+
+	body := `{"model":"jina-reranker-v2-base-multilingual","query":"test","documents":[{"text":"doc1","title":"t"}],"rank_fields":["text"],"max_chunks_per_doc":2,"return_documents":true}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/rerank", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.Rerank(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	want := map[string]any{
+		"model":              "jina-reranker-v2-base-multilingual",
+		"query":              "test",
+		"documents":          []any{map[string]any{"text": "doc1", "title": "t"}},
+		"rank_fields":        []any{"text"},
+		"max_chunks_per_doc": float64(2),
+		"return_documents":   true,
+	}
+	for k, v := range want {
+		gotJSON, _ := json.Marshal(got[k])
+		wantJSON, _ := json.Marshal(v)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("%s: want %s, got %s", k, wantJSON, gotJSON)
+		}
+	}
+}
+
 func TestRerank_SpendLog_NotCalledOnError(t *testing.T) {
 	t.Parallel()
 
@@ -341,7 +380,7 @@ func TestLegacyCompletion_ResponseBodyForwarded(t *testing.T) {
 	w := httptest.NewRecorder()
 	h.Completion(w, req)
 
-	if w.Body.String() != expectedBody {
+	if strings.TrimSpace(w.Body.String()) != expectedBody {
 		t.Errorf("response body mismatch\nwant: %s\ngot:  %s", expectedBody, w.Body.String())
 	}
 }
@@ -369,3 +408,38 @@ func TestLegacyCompletion_SpendLog_NotCalledOnError(t *testing.T) {
 		t.Errorf("expected 0 LogSuccess calls on error, got %d", spy.logCount())
 	}
 }
+
+func TestLegacyCompletion_Streaming(t *testing.T) {
+	t.Parallel()
+
+	stream := "data: {\"id\":\"cmpl-abc\",\"object\":\"text_completion\",\"choices\":[{\"text\":\"Hello\",\"index\":0}]}\n\n" +
+		"data: {\"id\":\"cmpl-abc\",\"object\":\"text_completion\",\"choices\":[{\"text\":\"!\",\"index\":0,\"finish_reason\":\"stop\"}]}\n\n" +
+		"data: {\"id\":\"cmpl-abc\",\"object\":\"text_completion\",\"choices\":[],\"usage\":{\"prompt_tokens\":5,\"completion_tokens\":2,\"total_tokens\":7}}\n\n" +
+		"data: [DONE]\n\n"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(stream))
+	}))
+	defer upstream.Close()
+
+	spy := newSpyLogger()
+	h := completionLegacyTestHandlers(upstream.URL)
+	h.Callbacks.Register(spy)
+
+	body := `{"model":"gpt-3.5-turbo-instruct","prompt":"Hello","stream":true,"stream_options":{"include_usage":true}}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.Completion(w, req)
+
+	if w.Body.String() != stream {
+		t.Errorf("stream not forwarded verbatim\nwant: %q\ngot:  %q", stream, w.Body.String())
+	}
+
+	spy.waitCalled(t, 2*time.Second)
+	data := spy.lastCall(t)
+	if data.PromptTokens != 5 || data.CompletionTokens != 2 || data.TotalTokens != 7 {
+		t.Errorf("usage = %d/%d/%d, want 5/2/7", data.PromptTokens, data.CompletionTokens, data.TotalTokens)
+	}
+}
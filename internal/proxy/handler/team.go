@@ -14,6 +14,9 @@ type teamCreateRequest struct {
 	Models    []string `json:"models"`
 	TPMLimit  *int64   `json:"tpm_limit"`
 	RPMLimit  *int64   `json:"rpm_limit"`
+	// AllowedIPs restricts the client addresses and CIDR ranges of the
+	// team's keys.
+	AllowedIPs []string `json:"allowed_ips"`
 }
 
 // TeamNew handles POST /team/new
@@ -32,6 +35,16 @@ func (h *Handlers) TeamNew(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	var allowedIPs []byte
+	if len(req.AllowedIPs) > 0 {
+		var err error
+		if allowedIPs, err = allowedIPsJSON(req.AllowedIPs); err != nil {
+			writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+				Error: model.ErrorDetail{Message: err.Error(), Type: "invalid_request_error"},
+			})
+			return
+		}
+	}
 
 	team, err := h.DB.CreateTeam(r.Context(), db.CreateTeamParams{
 		TeamID:    uuid.New().String(),
@@ -47,6 +60,14 @@ func (h *Handlers) TeamNew(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if allowedIPs != nil {
+		if err := h.DB.SetTeamAllowedIPs(r.Context(), db.SetTeamAllowedIPsParams{TeamID: team.TeamID, AllowedIps: allowedIPs}); err != nil {
+			writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+				Error: model.ErrorDetail{Message: "create team: " + err.Error(), Type: "internal_error"},
+			})
+			return
+		}
+	}
 
 	h.createAuditLog(r.Context(), "created", "TeamTable", team.TeamID, "", "", nil, req)
 	h.dispatchEvent(r.Context(), "team_created", team.TeamID, req)
@@ -124,6 +145,8 @@ func (h *Handlers) TeamUpdate(w http.ResponseWriter, r *http.Request) {
 		Models    []string `json:"models"`
 		TPMLimit  *int64   `json:"tpm_limit"`
 		RPMLimit  *int64   `json:"rpm_limit"`
+		// AllowedIPs replaces the team's IP allow-list when set; [] clears it.
+		AllowedIPs []string `json:"allowed_ips"`
 	}
 	if err := decodeJSON(r, &req); err != nil || req.TeamID == "" {
 		writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
@@ -131,6 +154,16 @@ func (h *Handlers) TeamUpdate(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	var allowedIPs []byte
+	if req.AllowedIPs != nil {
+		var err error
+		if allowedIPs, err = allowedIPsJSON(req.AllowedIPs); err != nil {
+			writeJSON(w, http.StatusBadRequest, model.ErrorResponse{
+				Error: model.ErrorDetail{Message: err.Error(), Type: "invalid_request_error"},
+			})
+			return
+		}
+	}
 
 	if _, err := h.DB.UpdateTeam(r.Context(), db.UpdateTeamParams{
 		TeamID:    req.TeamID,
@@ -145,6 +178,15 @@ func (h *Handlers) TeamUpdate(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	if allowedIPs != nil {
+		if err := h.DB.SetTeamAllowedIPs(r.Context(), db.SetTeamAllowedIPsParams{TeamID: req.TeamID, AllowedIps: allowedIPs}); err != nil {
+			writeJSON(w, http.StatusInternalServerError, model.ErrorResponse{
+				Error: model.ErrorDetail{Message: "update team: " + err.Error(), Type: "internal_error"},
+			})
+			return
+		}
+		h.invalidateTeamKeys(r.Context(), req.TeamID)
+	}
 
 	h.createAuditLog(r.Context(), "updated", "TeamTable", req.TeamID, "", "", nil, req)
 	h.dispatchEvent(r.Context(), "team_updated", req.TeamID, req)
@@ -7,12 +7,15 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/netip"
 	"strings"
+	"time"
 
 	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
 )
 
 type contextKey string
@@ -26,14 +29,35 @@ const (
 	ContextKeyRole          contextKey = "role"
 	ContextKeyAllowedModels contextKey = "allowed_models"
 	ContextKeyGuardrails    contextKey = "guardrails"
+	ContextKeyEndUserID     contextKey = "end_user_id"
 )
 
 // TokenInfo holds the result of a virtual key lookup.
 type TokenInfo struct {
 	UserID     *string
 	TeamID     *string
+	OrgID      *string // the key's organization, or its team's
 	Blocked    bool
 	Guardrails []string
+
+	// Expires is when the key stops working; nil never expires.
+	Expires *time.Time
+	// TeamBlocked is set when the key's team is blocked.
+	TeamBlocked bool
+	// AllowedRoutes restricts the routes the key may call; empty allows all.
+	AllowedRoutes []string
+	// ModelAccess restricts the models the key may call; nil allows all.
+	ModelAccess *ModelAccess
+	// Role is the RBAC role of the key's user; internal_user without one.
+	Role auth.Role
+	// AllowedIPs and TeamAllowedIPs restrict the client addresses of the
+	// key and of its team, from "allowed_ips" in their metadata. Nil
+	// allows every address.
+	AllowedIPs     []netip.Prefix
+	TeamAllowedIPs []netip.Prefix
+	// ParentBlocked is set when a delegated key's parent, or a key above
+	// it, is blocked or deleted.
+	ParentBlocked bool
 }
 
 // TokenValidator looks up a virtual key by its hash.
@@ -49,20 +73,26 @@ type AuthErrorLogger interface {
 
 // AuthConfig holds configuration for the auth middleware.
 type AuthConfig struct {
-	MasterKey     string
-	Validator     TokenValidator
-	JWTValidator  *auth.JWTValidator
-	RBACEngine    *auth.RBACEngine
-	EnableJWTAuth bool
-	ErrorLogger   AuthErrorLogger // optional: records auth failures to ErrorLogs
+	MasterKey      string
+	Validator      TokenValidator
+	JWTValidator   *auth.JWTValidator
+	RBACEngine     *auth.RBACEngine // optional: default-deny route access by role
+	Grants         GrantSource      // optional: custom role grants checked by RBACEngine
+	EnableJWTAuth  bool
+	JWTProvisioner *JWTProvisioner // optional: creates user/team rows for JWT callers
+	CustomAuth     *CustomAuth     // optional: external authorization service
+	// HeaderName is read for the API key before the standard headers.
+	HeaderName  string
+	ErrorLogger AuthErrorLogger // optional: records auth failures to ErrorLogs
 }
 
 // NewAuthMiddleware creates an auth middleware that validates
 // the master key, JWT tokens, or virtual keys from the database.
 // Follows Python LiteLLM's decision tree:
 //  1. Master key check
-//  2. JWT auth (if enabled and token looks like JWT)
-//  3. Virtual key from DB
+//  2. Custom auth service (if configured)
+//  3. JWT auth (if enabled and token looks like JWT)
+//  4. Virtual key from DB
 func NewAuthMiddleware(cfg AuthConfig) func(http.Handler) http.Handler {
 	masterKeyHash := hashToken(cfg.MasterKey)
 
@@ -75,7 +105,23 @@ func NewAuthMiddleware(cfg AuthConfig) func(http.Handler) http.Handler {
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			token := extractToken(r)
+			// authorize applies RBAC to an authenticated caller described
+			// by ctx, writing the rejection when it is denied.
+			authorize := func(ctx context.Context, tokenHash string) bool {
+				status, msg := authorizeRoute(ctx, cfg, r)
+				if status == 0 {
+					return true
+				}
+				if status == http.StatusForbidden {
+					logFailure(ctx, tokenHash, status, msg)
+				} else {
+					zerolog.Ctx(ctx).Error().Msg("auth error: " + msg)
+				}
+				authError(w, msg, status)
+				return false
+			}
+
+			token := extractToken(r, cfg.HeaderName)
 			if token == "" {
 				logFailure(r.Context(), "", http.StatusUnauthorized, "missing API key")
 				authError(w, "missing API key", http.StatusUnauthorized)
@@ -93,7 +139,41 @@ func NewAuthMiddleware(cfg AuthConfig) func(http.Handler) http.Handler {
 				return
 			}
 
-			// 2. JWT auth (if enabled and token looks like a JWT — 3 dot-separated segments)
+			// 2. Custom auth service
+			if cfg.CustomAuth != nil {
+				d, err := cfg.CustomAuth.Authorize(r, token)
+				switch {
+				case err != nil && cfg.CustomAuth.FailOpen:
+					zerolog.Ctx(r.Context()).Warn().Err(err).Msg("custom auth failed, admitting request (fail open)")
+					ctx := withDecision(r.Context(), &CustomAuthDecision{}, auth.RoleInternalUser)
+					if !authorize(ctx, tokenHash) {
+						return
+					}
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				case err != nil:
+					zerolog.Ctx(r.Context()).Error().Err(err).Msg("auth error: custom auth failed")
+					authError(w, "service temporarily unavailable", http.StatusServiceUnavailable)
+					return
+				case d.Allow:
+					ctx := withDecision(r.Context(), d, decisionRole(d))
+					if !authorize(ctx, tokenHash) {
+						return
+					}
+					next.ServeHTTP(w, r.WithContext(ctx))
+					return
+				case !cfg.CustomAuth.Fallback:
+					msg := d.Message
+					if msg == "" {
+						msg = "access denied by custom auth"
+					}
+					logFailure(r.Context(), tokenHash, http.StatusForbidden, msg)
+					authError(w, msg, http.StatusForbidden)
+					return
+				}
+			}
+
+			// 3. JWT auth (if enabled and token looks like a JWT — 3 dot-separated segments)
 			if cfg.EnableJWTAuth && cfg.JWTValidator != nil && isJWT(token) {
 				claims, err := cfg.JWTValidator.ValidateToken(r.Context(), token)
 				if err != nil {
@@ -103,14 +183,12 @@ func NewAuthMiddleware(cfg AuthConfig) func(http.Handler) http.Handler {
 					return
 				}
 
-				role := resolveRole(claims)
+				role := cfg.JWTValidator.ResolveRole(claims)
 
-				// RBAC route check
-				if cfg.RBACEngine != nil {
-					if err := cfg.RBACEngine.CheckRouteAccess(role, r.URL.Path); err != nil {
-						msg := fmt.Sprintf("access denied: %s", err)
-						logFailure(r.Context(), tokenHash, http.StatusForbidden, msg)
-						authError(w, msg, http.StatusForbidden)
+				if cfg.JWTProvisioner != nil {
+					if err := cfg.JWTProvisioner.Provision(r.Context(), claims, role); err != nil {
+						zerolog.Ctx(r.Context()).Error().Err(err).Msg("auth error: JWT provisioning failed")
+						authError(w, "service temporarily unavailable", http.StatusServiceUnavailable)
 						return
 					}
 				}
@@ -118,6 +196,11 @@ func NewAuthMiddleware(cfg AuthConfig) func(http.Handler) http.Handler {
 				ctx := r.Context()
 				ctx = context.WithValue(ctx, ContextKeyIsMasterKey, false)
 				ctx = context.WithValue(ctx, ContextKeyRole, role)
+				if cfg.RBACEngine != nil {
+					if models := cfg.RBACEngine.RoleModels(role); len(models) > 0 {
+						ctx = context.WithValue(ctx, ContextKeyAllowedModels, &ModelAccess{Models: models})
+					}
+				}
 				if claims.UserID != "" {
 					ctx = context.WithValue(ctx, ContextKeyUserID, claims.UserID)
 				}
@@ -127,17 +210,24 @@ func NewAuthMiddleware(cfg AuthConfig) func(http.Handler) http.Handler {
 				if claims.OrgID != "" {
 					ctx = context.WithValue(ctx, ContextKeyOrgID, claims.OrgID)
 				}
+				if !authorize(ctx, tokenHash) {
+					return
+				}
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
 
-			// 3. Virtual key from DB
+			// 4. Virtual key from DB
 			if cfg.Validator != nil {
 				info, err := cfg.Validator.ValidateToken(r.Context(), tokenHash)
 				if err != nil {
 					if errors.Is(err, ErrDBUnavailable) {
 						zerolog.Ctx(r.Context()).Error().Err(err).Msg("auth error: database unavailable")
 						authError(w, "service temporarily unavailable", http.StatusServiceUnavailable)
+					} else if errors.Is(err, ErrNotOrgMember) {
+						zerolog.Ctx(r.Context()).Warn().Str("token_hash_prefix", tokenHash[:8]).Msg("auth failed: organization mismatch")
+						logFailure(r.Context(), tokenHash, http.StatusForbidden, err.Error())
+						authError(w, err.Error(), http.StatusForbidden)
 					} else {
 						zerolog.Ctx(r.Context()).Warn().Str("token_hash_prefix", tokenHash[:8]).Msg("auth failed: key not found")
 						logFailure(r.Context(), tokenHash, http.StatusUnauthorized, "invalid API key")
@@ -145,10 +235,10 @@ func NewAuthMiddleware(cfg AuthConfig) func(http.Handler) http.Handler {
 					}
 					return
 				}
-				if info.Blocked {
-					zerolog.Ctx(r.Context()).Warn().Str("token_hash_prefix", tokenHash[:8]).Msg("auth failed: key is blocked")
-					logFailure(r.Context(), tokenHash, http.StatusForbidden, "API key is blocked")
-					authError(w, "API key is blocked", http.StatusForbidden)
+				if status, msg := checkTokenInfo(info, r, time.Now()); status != 0 {
+					zerolog.Ctx(r.Context()).Warn().Str("token_hash_prefix", tokenHash[:8]).Msg("auth failed: " + msg)
+					logFailure(r.Context(), tokenHash, status, msg)
+					authError(w, msg, status)
 					return
 				}
 				zerolog.Ctx(r.Context()).Info().Interface("user_id", info.UserID).Interface("team_id", info.TeamID).Msg("virtual key auth success")
@@ -162,9 +252,23 @@ func NewAuthMiddleware(cfg AuthConfig) func(http.Handler) http.Handler {
 				if info.TeamID != nil {
 					ctx = context.WithValue(ctx, ContextKeyTeamID, *info.TeamID)
 				}
+				if info.OrgID != nil {
+					ctx = context.WithValue(ctx, ContextKeyOrgID, *info.OrgID)
+				}
+				if info.ModelAccess != nil {
+					ctx = context.WithValue(ctx, ContextKeyAllowedModels, info.ModelAccess)
+				}
 				if len(info.Guardrails) > 0 {
 					ctx = context.WithValue(ctx, ContextKeyGuardrails, info.Guardrails)
 				}
+				role := info.Role
+				if role == "" {
+					role = auth.RoleInternalUser
+				}
+				ctx = context.WithValue(ctx, ContextKeyRole, role)
+				if !authorize(ctx, tokenHash) {
+					return
+				}
 
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
@@ -176,48 +280,132 @@ func NewAuthMiddleware(cfg AuthConfig) func(http.Handler) http.Handler {
 	}
 }
 
-// isJWT checks if a token looks like a JWT (3 dot-separated segments).
-// Matches Python LiteLLM's JWTHandler.is_jwt().
-func isJWT(token string) bool {
-	return strings.Count(token, ".") == 2
-}
+// authorizeRoute applies the RBAC engine to an authenticated, non-master
+// request: the caller's role must allow the route, or one of the user's
+// custom role grants must. A grant scoped to a team or organization only
+// allows the routes of scopedGrantRoutes, for the team or organization the
+// request names. It returns the HTTP status and message of the rejection,
+// or 0 to allow it. Without an engine every route is allowed.
+func authorizeRoute(ctx context.Context, cfg AuthConfig, r *http.Request) (int, string) {
+	if cfg.RBACEngine == nil {
+		return 0, ""
+	}
+	role, _ := ctx.Value(ContextKeyRole).(auth.Role)
+	if cfg.RBACEngine.CheckRouteAccess(role, r.URL.Path) == nil {
+		return 0, ""
+	}
 
-// resolveRole determines the RBAC role from JWT claims.
-// Follows Python's JWTHandler.get_rbac_role() priority:
-//  1. Admin scope → PROXY_ADMIN
-//  2. Explicit role claim
-//  3. TeamID present → TEAM
-//  4. UserID present → INTERNAL_USER
-//  5. Default → INTERNAL_USER
-func resolveRole(claims *auth.JWTClaims) auth.Role {
-	// Check scopes for admin
-	for _, scope := range claims.Scopes {
-		if scope == "tianji_proxy_admin" {
-			return auth.RoleProxyAdmin
+	var grants []auth.Grant
+	if userID, _ := ctx.Value(ContextKeyUserID).(string); userID != "" && cfg.Grants != nil {
+		var err error
+		if grants, err = cfg.Grants.Grants(ctx, userID); err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("auth error: loading role grants failed")
+			return http.StatusServiceUnavailable, "service temporarily unavailable"
 		}
 	}
+	orgID, teamID := requestTarget(r)
+	if err := cfg.RBACEngine.CheckAccess(role, grants, r.Method, r.URL.Path, orgID, teamID); err != nil {
+		return http.StatusForbidden, fmt.Sprintf("access denied: role %s cannot call %s %s", role, r.Method, r.URL.Path)
+	}
+	return 0, ""
+}
 
-	// Check explicit role claim
-	if claims.Role != "" {
-		if role, err := auth.ParseRole(claims.Role); err == nil {
-			return role
-		}
+// scopedGrantRoutes are the routes whose handlers act on the one team or
+// organization named by a request field, mapped to that field: the query
+// parameter of GET requests, the JSON body field of POST requests. Scoped
+// grants allow no other route, as the others are not limited to a team.
+var scopedGrantRoutes = map[string]struct {
+	team  bool // whether the field names a team, not an organization
+	field string
+}{
+	"GET /team/info":                {true, "team_id"},
+	"GET /team/daily_activity":      {true, "team_id"},
+	"GET /team/permissions":         {true, "team_id"},
+	"GET /team/callback":            {true, "team_id"},
+	"POST /team/update":             {true, "team_id"},
+	"POST /team/block":              {true, "team_id"},
+	"POST /team/unblock":            {true, "team_id"},
+	"POST /team/member/add":         {true, "team_id"},
+	"POST /team/member_add":         {true, "team_id"},
+	"POST /team/member/delete":      {true, "team_id"},
+	"POST /team/member_delete":      {true, "team_id"},
+	"POST /team/member_update":      {true, "team_id"},
+	"POST /team/model/add":          {true, "team_id"},
+	"POST /team/model/remove":       {true, "team_id"},
+	"POST /team/permissions":        {true, "team_id"},
+	"POST /team/callback":           {true, "team_id"},
+	"GET /spend/logs":               {true, "team"},
+	"GET /organization/info":        {false, "organization_id"},
+	"POST /organization/update":     {false, "organization_id"},
+	"POST /organization/member_add": {false, "organization_id"},
+	"GET /credentials/list":         {false, "organization_id"},
+}
+
+// requestTarget returns the organization or team a request acts on, for
+// checking scoped grants, or "" for routes outside scopedGrantRoutes.
+func requestTarget(r *http.Request) (orgID, teamID string) {
+	route, ok := scopedGrantRoutes[r.Method+" "+r.URL.Path]
+	if !ok {
+		return "", ""
+	}
+	var id string
+	if r.Method == http.MethodGet {
+		id = r.URL.Query().Get(route.field)
+	} else {
+		_, f := peekRequest(r)
+		id = f.Target[route.field]
 	}
+	if route.team {
+		return "", id
+	}
+	return id, ""
+}
 
-	// Infer from presence of team/user IDs
-	if claims.TeamID != "" {
-		return auth.RoleTeam
+// checkTokenInfo enforces the key's state on request r at now. It returns
+// the HTTP status and message of the rejection, or 0 to allow it.
+func checkTokenInfo(info *TokenInfo, r *http.Request, now time.Time) (int, string) {
+	path := r.URL.Path
+	switch {
+	case info.Blocked:
+		return http.StatusForbidden, "API key is blocked"
+	case info.ParentBlocked:
+		return http.StatusForbidden, "parent API key is blocked"
+	case info.Expires != nil && now.After(*info.Expires):
+		return http.StatusUnauthorized, "API key has expired"
+	case info.TeamBlocked:
+		return http.StatusForbidden, "team is blocked"
+	case !routeAllowed(info.AllowedRoutes, path):
+		return http.StatusForbidden, "API key is not allowed to call route " + path
+	case !ipAllowed(info.AllowedIPs, r):
+		return http.StatusForbidden, "API key is not allowed from IP " + extractIP(r)
+	case !ipAllowed(info.TeamAllowedIPs, r):
+		return http.StatusForbidden, "team is not allowed from IP " + extractIP(r)
 	}
+	return 0, ""
+}
 
-	return auth.RoleInternalUser
+// isJWT checks if a token looks like a JWT (3 dot-separated segments).
+// Matches Python LiteLLM's JWTHandler.is_jwt().
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
 }
 
 // extractToken extracts the API token from the request.
 // Supports multiple header formats matching Python LiteLLM:
+//   - <headerName>: <token> or Bearer <token>, when configured
 //   - Authorization: Bearer <token>
 //   - api-key: <token> (Azure)
 //   - x-api-key: <token> (Anthropic)
-func extractToken(r *http.Request) string {
+func extractToken(r *http.Request, headerName string) string {
+	if headerName != "" {
+		if v := strings.TrimSpace(r.Header.Get(headerName)); v != "" {
+			if token, ok := strings.CutPrefix(v, "Bearer "); ok {
+				return strings.TrimSpace(token)
+			}
+			return v
+		}
+	}
+
 	// Standard Bearer token (highest priority after custom headers)
 	if auth := r.Header.Get("Authorization"); auth != "" {
 		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
@@ -242,8 +430,14 @@ func extractToken(r *http.Request) string {
 	return ""
 }
 
+// authError writes an authentication error. msg may carry the request path
+// or a custom auth message, so it is JSON encoded rather than interpolated.
 func authError(w http.ResponseWriter, msg string, status int) {
-	http.Error(w, fmt.Sprintf(`{"error":{"message":"%s","type":"authentication_error"}}`, msg), status)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	writeJSONResponse(w, model.ErrorResponse{
+		Error: model.ErrorDetail{Message: msg, Type: "authentication_error"},
+	})
 }
 
 func hashToken(token string) string {
@@ -0,0 +1,337 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/cache"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+)
+
+// Defaults for AuthCache when no TTL or grace period is configured.
+const (
+	DefaultAuthCacheTTL   = 60 * time.Second
+	DefaultAuthCacheGrace = 5 * time.Minute
+)
+
+// authInvalidateChannel carries the hashes of keys whose cached auth state
+// is stale, so every replica drops them.
+const authInvalidateChannel = "tianji:auth:invalidate"
+
+// authInvalidateRowChannel carries the cache keys of budget rows whose
+// spend changed, so every replica drops them.
+const authInvalidateRowChannel = "tianji:auth:invalidate:row"
+
+// authCacheEntry is a cached key lookup.
+type authCacheEntry struct {
+	Info        *TokenInfo `json:"info"`
+	ValidatedAt time.Time  `json:"validated_at"`
+}
+
+// AuthCache is a TokenValidator that caches the keys, with their team and
+// organization context, resolved by another validator. Lookups are served
+// from the cache for TTL. After that the key is validated again; if the
+// database is unavailable the cached lookup keeps being served for up to
+// Grace more, so a brief outage does not lock out recently seen keys.
+//
+// Key changes must call Invalidate. With a Redis client the invalidation is
+// published to every replica, which drop the key from their memory layer.
+type AuthCache struct {
+	Validator TokenValidator
+	TTL       time.Duration
+	Grace     time.Duration
+
+	cache cache.Cache
+	rdb   redis.UniversalClient
+	now   func() time.Time
+}
+
+// NewAuthCache creates an AuthCache in front of v, storing lookups in c,
+// normally a cache.DualCache. rdb is optional and enables cross-replica
+// invalidation. A zero ttl or grace selects the default.
+func NewAuthCache(v TokenValidator, c cache.Cache, rdb redis.UniversalClient, ttl, grace time.Duration) *AuthCache {
+	if ttl <= 0 {
+		ttl = DefaultAuthCacheTTL
+	}
+	if grace <= 0 {
+		grace = DefaultAuthCacheGrace
+	}
+	return &AuthCache{Validator: v, TTL: ttl, Grace: grace, cache: c, rdb: rdb, now: time.Now}
+}
+
+func authCacheKey(tokenHash string) string {
+	return "tianji:auth:key:" + tokenHash
+}
+
+// ValidateToken implements TokenValidator.
+func (a *AuthCache) ValidateToken(ctx context.Context, tokenHash string) (*TokenInfo, error) {
+	entry, cached := a.get(ctx, tokenHash)
+	age := a.now().Sub(entry.ValidatedAt)
+	if cached && age < a.TTL {
+		return entry.Info, nil
+	}
+
+	info, err := a.Validator.ValidateToken(ctx, tokenHash)
+	switch {
+	case err == nil:
+		a.set(ctx, tokenHash, info)
+		return info, nil
+	case errors.Is(err, ErrDBUnavailable) && cached && age < a.TTL+a.Grace:
+		zerolog.Ctx(ctx).Warn().Err(err).Str("token_hash_prefix", tokenHash[:min(8, len(tokenHash))]).
+			Dur("age", age).Msg("serving cached key while database is unavailable")
+		return entry.Info, nil
+	case cached && !errors.Is(err, ErrDBUnavailable):
+		_ = a.cache.Delete(ctx, authCacheKey(tokenHash))
+	}
+	return nil, err
+}
+
+func (a *AuthCache) get(ctx context.Context, tokenHash string) (authCacheEntry, bool) {
+	var entry authCacheEntry
+	raw, err := a.cache.Get(ctx, authCacheKey(tokenHash))
+	if err != nil || raw == nil {
+		return entry, false
+	}
+	if err := json.Unmarshal(raw, &entry); err != nil || entry.Info == nil {
+		return authCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (a *AuthCache) set(ctx context.Context, tokenHash string, info *TokenInfo) {
+	raw, err := json.Marshal(authCacheEntry{Info: info, ValidatedAt: a.now()})
+	if err != nil {
+		return
+	}
+	if err := a.cache.Set(ctx, authCacheKey(tokenHash), raw, a.TTL+a.Grace); err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("failed to cache key lookup")
+	}
+}
+
+// Invalidate drops the cached lookups of the given key hashes on this and,
+// with Redis, every other replica. A nil AuthCache does nothing.
+func (a *AuthCache) Invalidate(ctx context.Context, tokenHashes ...string) error {
+	if a == nil {
+		return nil
+	}
+	var errs []error
+	for _, hash := range tokenHashes {
+		if err := a.drop(ctx, hash); err != nil {
+			errs = append(errs, err)
+		}
+		if a.rdb != nil {
+			if err := a.rdb.Publish(ctx, authInvalidateChannel, hash).Err(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// KeyChildLister lists the delegated keys minted from a parent key.
+type KeyChildLister interface {
+	ListVerificationTokenChildren(ctx context.Context, parentToken *string) ([]db.VerificationToken, error)
+}
+
+// Descendants returns the delegated keys minted, directly or through other
+// children, from the given keys. Their cached auth state depends on their
+// parents', so they must be invalidated with them. A nil AuthCache has
+// nothing to drop and returns nil.
+func (a *AuthCache) Descendants(ctx context.Context, store KeyChildLister, tokenHashes ...string) []string {
+	if a == nil {
+		return nil
+	}
+	var out []string
+	seen := make(map[string]bool)
+	queue := append([]string(nil), tokenHashes...)
+	for len(queue) > 0 {
+		hash := queue[0]
+		queue = queue[1:]
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		children, err := store.ListVerificationTokenChildren(ctx, &hash)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msg("failed to list child keys for invalidation")
+			continue
+		}
+		for _, c := range children {
+			out = append(out, c.Token)
+			queue = append(queue, c.Token)
+		}
+	}
+	return out
+}
+
+// Subscribe drops keys invalidated by other replicas until ctx is done.
+// It returns immediately without a Redis client.
+func (a *AuthCache) Subscribe(ctx context.Context) {
+	if a.rdb == nil {
+		return
+	}
+	sub := a.rdb.Subscribe(ctx, authInvalidateChannel, authInvalidateRowChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			if msg.Channel == authInvalidateRowChannel {
+				if err := a.cache.Delete(ctx, msg.Payload); err != nil {
+					log.Warn().Err(err).Msg("auth cache: failed to drop invalidated row")
+				}
+				continue
+			}
+			if err := a.drop(ctx, msg.Payload); err != nil {
+				log.Warn().Err(err).Msg("auth cache: failed to drop invalidated key")
+			}
+		}
+	}
+}
+
+// drop deletes the cached lookup and key row of tokenHash from this replica.
+func (a *AuthCache) drop(ctx context.Context, tokenHash string) error {
+	return errors.Join(
+		a.cache.Delete(ctx, authCacheKey(tokenHash)),
+		a.cache.Delete(ctx, rowCacheKey("key", tokenHash)),
+	)
+}
+
+var _ TokenValidator = (*AuthCache)(nil)
+
+// Store returns store with its key, user, team, organization, end-user and
+// budget reads cached like key lookups: rows are served from the cache for
+// TTL and, while the database is unavailable, for up to Grace more. Budget
+// and rate limit checks read through it. Key rows are dropped by Invalidate
+// and rows whose spend changed by InvalidateSpend, so the spend they see
+// stays current; budget rows may lag the database by up to TTL.
+func (a *AuthCache) Store(store BudgetStore) BudgetStore {
+	return &cachedStore{BudgetStore: store, a: a}
+}
+
+// InvalidateSpend drops the cached key, user, team, organization and
+// end-user rows whose spend changed on this and, with Redis, every other
+// replica. Empty IDs are skipped. A nil AuthCache does nothing.
+func (a *AuthCache) InvalidateSpend(ctx context.Context, keyHash, userID, teamID, orgID, endUserID string) error {
+	if a == nil {
+		return nil
+	}
+	var errs []error
+	for _, row := range [][2]string{
+		{"key", keyHash}, {"user", userID}, {"team", teamID}, {"org", orgID}, {"end_user", endUserID},
+	} {
+		if row[1] == "" {
+			continue
+		}
+		key := rowCacheKey(row[0], row[1])
+		if err := a.cache.Delete(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+		if a.rdb != nil {
+			if err := a.rdb.Publish(ctx, authInvalidateRowChannel, key).Err(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func rowCacheKey(kind, id string) string {
+	return "tianji:auth:row:" + kind + ":" + id
+}
+
+// rowCacheEntry is a cached database row.
+type rowCacheEntry[T any] struct {
+	Row         *T        `json:"row"`
+	ValidatedAt time.Time `json:"validated_at"`
+}
+
+// cachedRow returns the row cached under key, loading it when the entry is
+// missing or older than TTL. Rows that no longer exist are dropped.
+func cachedRow[T any](ctx context.Context, a *AuthCache, key string, load func() (T, error)) (T, error) {
+	var entry rowCacheEntry[T]
+	raw, err := a.cache.Get(ctx, key)
+	cached := err == nil && raw != nil && json.Unmarshal(raw, &entry) == nil && entry.Row != nil
+	age := a.now().Sub(entry.ValidatedAt)
+	if cached && age < a.TTL {
+		return *entry.Row, nil
+	}
+
+	row, err := load()
+	switch {
+	case err == nil:
+		if raw, err := json.Marshal(rowCacheEntry[T]{Row: &row, ValidatedAt: a.now()}); err == nil {
+			if err := a.cache.Set(ctx, key, raw, a.TTL+a.Grace); err != nil {
+				zerolog.Ctx(ctx).Warn().Err(err).Msg("failed to cache row lookup")
+			}
+		}
+		return row, nil
+	case lookupErr(err) != nil && cached && age < a.TTL+a.Grace:
+		return *entry.Row, nil
+	case cached && lookupErr(err) == nil:
+		_ = a.cache.Delete(ctx, key)
+	}
+	return row, err
+}
+
+// cachedStore is the BudgetStore returned by AuthCache.Store.
+type cachedStore struct {
+	BudgetStore
+	a *AuthCache
+}
+
+func (s *cachedStore) GetVerificationToken(ctx context.Context, token string) (db.VerificationToken, error) {
+	return cachedRow(ctx, s.a, rowCacheKey("key", token), func() (db.VerificationToken, error) {
+		return s.BudgetStore.GetVerificationToken(ctx, token)
+	})
+}
+
+func (s *cachedStore) GetUser(ctx context.Context, userID string) (db.UserTable, error) {
+	return cachedRow(ctx, s.a, rowCacheKey("user", userID), func() (db.UserTable, error) {
+		return s.BudgetStore.GetUser(ctx, userID)
+	})
+}
+
+func (s *cachedStore) GetTeam(ctx context.Context, teamID string) (db.TeamTable, error) {
+	return cachedRow(ctx, s.a, rowCacheKey("team", teamID), func() (db.TeamTable, error) {
+		return s.BudgetStore.GetTeam(ctx, teamID)
+	})
+}
+
+func (s *cachedStore) GetOrganization(ctx context.Context, organizationID string) (db.OrganizationTable, error) {
+	return cachedRow(ctx, s.a, rowCacheKey("org", organizationID), func() (db.OrganizationTable, error) {
+		return s.BudgetStore.GetOrganization(ctx, organizationID)
+	})
+}
+
+func (s *cachedStore) GetEndUserSpend(ctx context.Context, userID string) (db.EndUserTable, error) {
+	return cachedRow(ctx, s.a, rowCacheKey("end_user", userID), func() (db.EndUserTable, error) {
+		return s.BudgetStore.GetEndUserSpend(ctx, userID)
+	})
+}
+
+func (s *cachedStore) GetBudget(ctx context.Context, budgetID string) (db.BudgetTable, error) {
+	return cachedRow(ctx, s.a, rowCacheKey("budget", budgetID), func() (db.BudgetTable, error) {
+		return s.BudgetStore.GetBudget(ctx, budgetID)
+	})
+}
+
+// SetVerificationTokenSoftBudgetCooldown also drops the cached key row, so
+// the soft budget alert is not sent again from a stale row.
+func (s *cachedStore) SetVerificationTokenSoftBudgetCooldown(ctx context.Context, token string) error {
+	err := s.BudgetStore.SetVerificationTokenSoftBudgetCooldown(ctx, token)
+	_ = s.a.cache.Delete(ctx, rowCacheKey("key", token))
+	return err
+}
@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/cache"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+)
+
+// countingValidator resolves every key to a team and counts lookups.
+type countingValidator struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (v *countingValidator) ValidateToken(_ context.Context, _ string) (*TokenInfo, error) {
+	v.calls.Add(1)
+	if v.err != nil {
+		return nil, v.err
+	}
+	return &TokenInfo{TeamID: ptr("team-1"), ModelAccess: &ModelAccess{Models: []string{"gpt-4o"}}}, nil
+}
+
+func TestAuthCache_TTLAndGrace(t *testing.T) {
+	v := &countingValidator{}
+	a := NewAuthCache(v, cache.NewMemoryCache(), nil, time.Minute, 5*time.Minute)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	info, err := a.ValidateToken(ctx, "hash")
+	require.NoError(t, err)
+	info, err = a.ValidateToken(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), v.calls.Load(), "second lookup served from cache")
+	assert.Equal(t, "team-1", *info.TeamID)
+	assert.Equal(t, []string{"gpt-4o"}, info.ModelAccess.Models)
+
+	now = now.Add(2 * time.Minute)
+	v.err = ErrDBUnavailable
+	info, err = a.ValidateToken(ctx, "hash")
+	require.NoError(t, err, "stale entry served during the grace period")
+	assert.Equal(t, "team-1", *info.TeamID)
+	assert.Equal(t, int32(2), v.calls.Load(), "expired entry is revalidated")
+
+	now = now.Add(5 * time.Minute)
+	_, err = a.ValidateToken(ctx, "hash")
+	assert.ErrorIs(t, err, ErrDBUnavailable, "grace period has ended")
+
+	// A key that no longer exists is dropped rather than served.
+	v.err = nil
+	_, _ = a.ValidateToken(ctx, "gone")
+	now = now.Add(2 * time.Minute)
+	v.err = ErrKeyNotFound
+	_, err = a.ValidateToken(ctx, "gone")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+	v.err = ErrDBUnavailable
+	_, err = a.ValidateToken(ctx, "gone")
+	assert.ErrorIs(t, err, ErrDBUnavailable)
+}
+
+func TestAuthCache_InvalidateAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	replica := func(v TokenValidator) *AuthCache {
+		c := cache.NewDualCache(cache.NewMemoryCache(), cache.NewRedisCache(rdb))
+		return NewAuthCache(v, c, rdb, time.Hour, 0)
+	}
+	va, vb := &countingValidator{}, &countingValidator{}
+	a, b := replica(va), replica(vb)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go b.Subscribe(ctx)
+	require.Eventually(t, func() bool {
+		return mr.PubSubNumSub(authInvalidateChannel)[authInvalidateChannel] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	_, err := a.ValidateToken(ctx, "hash")
+	require.NoError(t, err)
+	_, err = b.ValidateToken(ctx, "hash")
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), vb.calls.Load(), "replica b reads a's lookup from Redis")
+
+	require.NoError(t, a.Invalidate(ctx, "hash"))
+	require.Eventually(t, func() bool {
+		_, err := b.ValidateToken(ctx, "hash")
+		return err == nil && vb.calls.Load() == 1
+	}, time.Second, 10*time.Millisecond, "replica b revalidates after the broadcast")
+
+	var nilCache *AuthCache
+	assert.NoError(t, nilCache.Invalidate(ctx, "hash"))
+}
+
+func TestAuthCache_InvalidateSpendAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := &countingBudgetStore{fakeBudgetStore: &fakeBudgetStore{
+		keys: map[string]db.VerificationToken{"hash": {Spend: 5}},
+	}}
+	replica := func() *AuthCache {
+		c := cache.NewDualCache(cache.NewMemoryCache(), cache.NewRedisCache(rdb))
+		return NewAuthCache(&countingValidator{}, c, rdb, time.Hour, 0)
+	}
+	a, b := replica(), replica()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go b.Subscribe(ctx)
+	require.Eventually(t, func() bool {
+		return mr.PubSubNumSub(authInvalidateRowChannel)[authInvalidateRowChannel] == 1
+	}, time.Second, 10*time.Millisecond)
+
+	cachedB := b.Store(store)
+	vt, err := cachedB.GetVerificationToken(ctx, "hash")
+	require.NoError(t, err)
+	assert.InDelta(t, 5.0, vt.Spend, 0.001)
+
+	// Replica a records spend; b must not keep serving the old row.
+	store.keys["hash"] = db.VerificationToken{Spend: 9}
+	require.NoError(t, a.InvalidateSpend(ctx, "hash", "", "", "", ""))
+	require.Eventually(t, func() bool {
+		vt, err := cachedB.GetVerificationToken(ctx, "hash")
+		return err == nil && vt.Spend == 9
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), store.keyReads.Load())
+
+	var nilCache *AuthCache
+	assert.NoError(t, nilCache.InvalidateSpend(ctx, "hash", "", "", "", ""))
+}
+
+// countingBudgetStore counts key row reads.
+type countingBudgetStore struct {
+	*fakeBudgetStore
+	keyReads atomic.Int32
+}
+
+func (s *countingBudgetStore) GetVerificationToken(ctx context.Context, token string) (db.VerificationToken, error) {
+	s.keyReads.Add(1)
+	return s.fakeBudgetStore.GetVerificationToken(ctx, token)
+}
+
+// childLister serves a fixed key tree.
+type childLister map[string][]string
+
+func (l childLister) ListVerificationTokenChildren(_ context.Context, parent *string) ([]db.VerificationToken, error) {
+	var out []db.VerificationToken
+	for _, c := range l[*parent] {
+		out = append(out, db.VerificationToken{Token: c})
+	}
+	return out, nil
+}
+
+func TestAuthCache_Descendants(t *testing.T) {
+	tree := childLister{"root": {"a", "b"}, "a": {"c"}}
+	a := NewAuthCache(&countingValidator{}, cache.NewMemoryCache(), nil, 0, 0)
+
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, a.Descendants(context.Background(), tree, "root"))
+	assert.Empty(t, a.Descendants(context.Background(), tree, "b"))
+
+	var none *AuthCache
+	assert.Nil(t, none.Descendants(context.Background(), tree, "root"), "nothing to drop without a cache")
+}
+
+func TestAuthCache_Store(t *testing.T) {
+	store := &countingBudgetStore{fakeBudgetStore: &fakeBudgetStore{
+		keys: map[string]db.VerificationToken{"hash": {Spend: 5, MaxBudget: ptr(10.0)}},
+	}}
+	a := NewAuthCache(&countingValidator{}, cache.NewMemoryCache(), nil, time.Minute, 5*time.Minute)
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	ctx := context.Background()
+	cached := a.Store(store)
+
+	for range 3 {
+		vt, err := cached.GetVerificationToken(ctx, "hash")
+		require.NoError(t, err)
+		assert.InDelta(t, 5.0, vt.Spend, 0.001)
+	}
+	assert.Equal(t, int32(1), store.keyReads.Load(), "key row served from cache")
+
+	// Invalidating the key drops its row too.
+	store.keys["hash"] = db.VerificationToken{Spend: 7}
+	require.NoError(t, a.Invalidate(ctx, "hash"))
+	vt, err := cached.GetVerificationToken(ctx, "hash")
+	require.NoError(t, err)
+	assert.InDelta(t, 7.0, vt.Spend, 0.001)
+
+	// Stale rows are served while the database is down, within the grace period.
+	now = now.Add(2 * time.Minute)
+	store.err = errors.New("connection refused")
+	vt, err = cached.GetVerificationToken(ctx, "hash")
+	require.NoError(t, err)
+	assert.InDelta(t, 7.0, vt.Spend, 0.001)
+	now = now.Add(5 * time.Minute)
+	_, err = cached.GetVerificationToken(ctx, "hash")
+	assert.Error(t, err)
+
+	// Missing rows are not cached.
+	store.err = nil
+	_, err = cached.GetUser(ctx, "nobody")
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
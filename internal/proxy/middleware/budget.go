@@ -2,67 +2,350 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rs/zerolog"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 )
 
-// BudgetChecker checks if a key's spend exceeds its budget.
-type BudgetChecker interface {
+// BudgetLevel names a level of the budget hierarchy.
+type BudgetLevel string
+
+const (
+	BudgetLevelKey      BudgetLevel = "key"
+	BudgetLevelKeyModel BudgetLevel = "key_model"
+	BudgetLevelUser     BudgetLevel = "user"
+	BudgetLevelTeam     BudgetLevel = "team"
+	BudgetLevelOrg      BudgetLevel = "organization"
+	BudgetLevelEndUser  BudgetLevel = "end_user"
+)
+
+// BudgetExceededError reports the level of the budget hierarchy whose spend
+// reached its max budget. It matches model.ErrBudgetExceeded.
+type BudgetExceededError struct {
+	Level     BudgetLevel
+	ID        string
+	Model     string // set for per-model key budgets
+	Spend     float64
+	MaxBudget float64
+}
+
+func (e *BudgetExceededError) Error() string {
+	if e.Model != "" {
+		return fmt.Sprintf("budget exceeded for model %q on key %q: spend %.4f >= max budget %.4f", e.Model, e.ID, e.Spend, e.MaxBudget)
+	}
+	return fmt.Sprintf("budget exceeded for %s %q: spend %.4f >= max budget %.4f", e.Level, e.ID, e.Spend, e.MaxBudget)
+}
+
+func (e *BudgetExceededError) Unwrap() error {
+	return model.ErrBudgetExceeded
+}
+
+// BudgetStore reads spend and budgets for every level of the hierarchy.
+// Satisfied by *db.Queries.
+type BudgetStore interface {
 	GetVerificationToken(ctx context.Context, token string) (db.VerificationToken, error)
+	GetUser(ctx context.Context, userID string) (db.UserTable, error)
+	GetTeam(ctx context.Context, teamID string) (db.TeamTable, error)
+	GetOrganization(ctx context.Context, organizationID string) (db.OrganizationTable, error)
+	GetEndUserSpend(ctx context.Context, userID string) (db.EndUserTable, error)
+	GetBudget(ctx context.Context, budgetID string) (db.BudgetTable, error)
+	SetVerificationTokenSoftBudgetCooldown(ctx context.Context, token string) error
+}
+
+var _ BudgetStore = (*db.Queries)(nil)
+
+// BudgetScope identifies the entities a request is billed to.
+type BudgetScope struct {
+	TokenHash string
+	UserID    string
+	TeamID    string
+	OrgID     string
+	EndUserID string // the request's "user" field
+	Model     string // the model as requested
+}
+
+// BudgetScopeFromContext builds the scope of an authenticated request.
+func BudgetScopeFromContext(ctx context.Context) BudgetScope {
+	var s BudgetScope
+	s.TokenHash, _ = ctx.Value(ContextKeyTokenHash).(string)
+	s.UserID, _ = ctx.Value(ContextKeyUserID).(string)
+	s.TeamID, _ = ctx.Value(ContextKeyTeamID).(string)
+	s.OrgID, _ = ctx.Value(ContextKeyOrgID).(string)
+	s.EndUserID, _ = ctx.Value(ContextKeyEndUserID).(string)
+	s.Model = RequestedModel(ctx)
+	return s
+}
+
+// RequestedModel returns the model named in the request body, as seen by
+// the budget middleware before any alias is applied.
+func RequestedModel(ctx context.Context) string {
+	m, _ := ctx.Value(modelGroupKey).(string)
+	return m
+}
+
+// BudgetEnforcer checks spend against every level of the budget hierarchy:
+// key (overall and per model), user, team, organization and end user.
+// A level's max budget is its own max_budget, or that of its linked
+// BudgetTable row. Spend from a budget window that has ended counts as zero
+// until the reset job clears it. Spend itself is recorded by spend.Tracker.
+type BudgetEnforcer struct {
+	DB  BudgetStore
+	now func() time.Time
+}
+
+// NewBudgetEnforcer creates a BudgetEnforcer reading from store.
+func NewBudgetEnforcer(store BudgetStore) *BudgetEnforcer {
+	return &BudgetEnforcer{DB: store, now: time.Now}
+}
+
+// Check returns a *BudgetExceededError for the first level of s whose spend
+// has reached its max budget, or ErrDBUnavailable when a level cannot be
+// read. Levels that do not exist are skipped.
+func (e *BudgetEnforcer) Check(ctx context.Context, s BudgetScope) error {
+	for _, check := range []func(context.Context, BudgetScope) error{
+		e.checkKey, e.checkUser, e.checkTeam, e.checkOrg, e.checkEndUser,
+	} {
+		if err := check(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkKey checks the key's budgets and, for a delegated key, those of
+// every parent key above it, whose spend includes the child's.
+func (e *BudgetEnforcer) checkKey(ctx context.Context, s BudgetScope) error {
+	seen := make(map[string]bool)
+	for hash := s.TokenHash; hash != "" && !seen[hash]; {
+		seen[hash] = true
+		vt, err := e.DB.GetVerificationToken(ctx, hash)
+		if err != nil {
+			return lookupErr(err)
+		}
+		if err := e.checkKeyBudget(ctx, hash, vt, s.Model); err != nil {
+			return err
+		}
+		hash = ""
+		if vt.ParentToken != nil {
+			hash = *vt.ParentToken
+		}
+	}
+	return nil
+}
+
+func (e *BudgetEnforcer) checkKeyBudget(ctx context.Context, tokenHash string, vt db.VerificationToken, model string) error {
+	linked, err := linkedBudget(ctx, e.DB, vt.BudgetID)
+	if err != nil {
+		return err
+	}
+
+	keyID := keyName(tokenHash, vt.KeyAlias)
+
+	spend := e.windowSpend(vt.Spend, vt.BudgetResetAt)
+	if limit := maxBudget(vt.MaxBudget, linked); limit != nil && spend >= *limit {
+		return &BudgetExceededError{Level: BudgetLevelKey, ID: keyID, Spend: spend, MaxBudget: *limit}
+	}
+	if linked.SoftBudget != nil && spend >= *linked.SoftBudget && !vt.SoftBudgetCooldown {
+		e.softBudgetCrossed(ctx, tokenHash, keyID, spend, *linked.SoftBudget)
+	}
+
+	if model == "" {
+		return nil
+	}
+	limits := jsonFloats(vt.ModelMaxBudget)
+	if len(limits) == 0 {
+		limits = jsonFloats(linked.ModelMaxBudget)
+	}
+	limit, ok := limits[model]
+	if !ok || limit <= 0 {
+		return nil
+	}
+	modelSpend := e.windowSpend(jsonFloats(vt.ModelSpend)[model], vt.BudgetResetAt)
+	if modelSpend >= limit {
+		return &BudgetExceededError{Level: BudgetLevelKeyModel, ID: keyID, Model: model, Spend: modelSpend, MaxBudget: limit}
+	}
+	return nil
+}
+
+func (e *BudgetEnforcer) checkUser(ctx context.Context, s BudgetScope) error {
+	if s.UserID == "" {
+		return nil
+	}
+	u, err := e.DB.GetUser(ctx, s.UserID)
+	if err != nil {
+		return lookupErr(err)
+	}
+	return e.checkLevel(ctx, BudgetLevelUser, s.UserID, e.windowSpend(u.Spend, u.BudgetResetAt), u.MaxBudget, u.BudgetID)
+}
+
+func (e *BudgetEnforcer) checkTeam(ctx context.Context, s BudgetScope) error {
+	if s.TeamID == "" {
+		return nil
+	}
+	t, err := e.DB.GetTeam(ctx, s.TeamID)
+	if err != nil {
+		return lookupErr(err)
+	}
+	return e.checkLevel(ctx, BudgetLevelTeam, s.TeamID, e.windowSpend(t.Spend, t.BudgetResetAt), t.MaxBudget, t.BudgetID)
 }
 
-// NewBudgetMiddleware returns middleware that checks budget limits.
-func NewBudgetMiddleware(checker BudgetChecker) func(http.Handler) http.Handler {
-	if checker == nil {
+func (e *BudgetEnforcer) checkOrg(ctx context.Context, s BudgetScope) error {
+	if s.OrgID == "" {
+		return nil
+	}
+	o, err := e.DB.GetOrganization(ctx, s.OrgID)
+	if err != nil {
+		return lookupErr(err)
+	}
+	return e.checkLevel(ctx, BudgetLevelOrg, s.OrgID, e.windowSpend(o.Spend, o.BudgetResetAt), o.MaxBudget, o.BudgetID)
+}
+
+func (e *BudgetEnforcer) checkEndUser(ctx context.Context, s BudgetScope) error {
+	if s.EndUserID == "" {
+		return nil
+	}
+	eu, err := e.DB.GetEndUserSpend(ctx, s.EndUserID)
+	if err != nil {
+		return lookupErr(err)
+	}
+	return e.checkLevel(ctx, BudgetLevelEndUser, s.EndUserID, eu.Spend, nil, eu.TianjiBudgetTable)
+}
+
+// checkLevel compares spend with own, or else the linked budget's max budget.
+func (e *BudgetEnforcer) checkLevel(ctx context.Context, level BudgetLevel, id string, spend float64, own *float64, budgetID *string) error {
+	linked, err := linkedBudget(ctx, e.DB, budgetID)
+	if err != nil {
+		return err
+	}
+	if limit := maxBudget(own, linked); limit != nil && spend >= *limit {
+		return &BudgetExceededError{Level: level, ID: id, Spend: spend, MaxBudget: *limit}
+	}
+	return nil
+}
+
+// budgetReader loads the BudgetTable rows that keys, users and teams link to.
+type budgetReader interface {
+	GetBudget(ctx context.Context, budgetID string) (db.BudgetTable, error)
+}
+
+// linkedBudget loads a BudgetTable row; a missing row is an empty budget.
+func linkedBudget(ctx context.Context, store budgetReader, budgetID *string) (db.BudgetTable, error) {
+	if budgetID == nil || *budgetID == "" {
+		return db.BudgetTable{}, nil
+	}
+	b, err := store.GetBudget(ctx, *budgetID)
+	if err != nil {
+		return db.BudgetTable{}, lookupErr(err)
+	}
+	return b, nil
+}
+
+// keyName names a key in errors and logs by its alias or hash prefix; the
+// full hash is a credential.
+func keyName(tokenHash string, alias *string) string {
+	if alias != nil && *alias != "" {
+		return *alias
+	}
+	return tokenHash[:min(8, len(tokenHash))]
+}
+
+// windowSpend returns spend, or zero once its budget window has ended.
+func (e *BudgetEnforcer) windowSpend(spend float64, resetAt pgtype.Timestamptz) float64 {
+	if resetAt.Valid && !e.now().Before(resetAt.Time) {
+		return 0
+	}
+	return spend
+}
+
+// softBudgetCrossed warns once per budget window when a key passes its
+// soft budget; soft_budget_cooldown is cleared by the budget reset job.
+func (e *BudgetEnforcer) softBudgetCrossed(ctx context.Context, tokenHash, keyID string, spend, softBudget float64) {
+	zerolog.Ctx(ctx).Warn().
+		Str("key", keyID).
+		Float64("spend", spend).
+		Float64("soft_budget", softBudget).
+		Msg("key crossed its soft budget")
+	if err := e.DB.SetVerificationTokenSoftBudgetCooldown(ctx, tokenHash); err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("failed to set soft budget cooldown")
+	}
+}
+
+func maxBudget(own *float64, linked db.BudgetTable) *float64 {
+	if own != nil {
+		return own
+	}
+	return linked.MaxBudget
+}
+
+func jsonFloats(raw []byte) map[string]float64 {
+	var m map[string]float64
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &m)
+	}
+	return m
+}
+
+// lookupErr treats a missing row as no budget and anything else as the
+// database being unavailable.
+func lookupErr(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	return ErrDBUnavailable
+}
+
+// NewBudgetMiddleware returns middleware that rejects requests whose key,
+// user, team, organization or end user has exhausted its budget. The model
+// and "user" fields of JSON bodies select the per-model key budget and the
+// end-user budget. Only POST requests, which start paid calls, are checked;
+// master key requests are not budgeted.
+func NewBudgetMiddleware(enforcer *BudgetEnforcer) func(http.Handler) http.Handler {
+	if enforcer == nil {
 		return func(next http.Handler) http.Handler { return next }
 	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			tokenHash, ok := r.Context().Value(tokenHashKey).(string)
-			if !ok || tokenHash == "" {
+			if isMaster, _ := r.Context().Value(ContextKeyIsMasterKey).(bool); isMaster || r.Method != http.MethodPost {
 				next.ServeHTTP(w, r)
 				return
 			}
 
-			token, err := checker.GetVerificationToken(r.Context(), tokenHash)
-			if err != nil {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusServiceUnavailable)
-				writeJSONResponse(w, model.ErrorResponse{
-					Error: model.ErrorDetail{
-						Message: "unable to verify budget",
-						Type:    "internal_error",
-					},
-				})
-				return
-			}
+			r = withRequestScope(r)
+			ctx := r.Context()
 
-			// Check budget
-			if token.MaxBudget != nil && token.Spend >= *token.MaxBudget {
+			err := enforcer.Check(ctx, BudgetScopeFromContext(ctx))
+			var exceeded *BudgetExceededError
+			switch {
+			case errors.As(err, &exceeded):
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusTooManyRequests)
 				writeJSONResponse(w, model.ErrorResponse{
 					Error: model.ErrorDetail{
-						Message: "budget exceeded",
+						Message: exceeded.Error(),
 						Type:    "budget_exceeded",
 						Code:    "budget_exceeded",
 					},
 				})
 				return
-			}
-
-			// Check if blocked
-			if token.Blocked != nil && *token.Blocked {
+			case err != nil:
+				zerolog.Ctx(ctx).Error().Err(err).Msg("budget check failed")
 				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusForbidden)
+				w.WriteHeader(http.StatusServiceUnavailable)
 				writeJSONResponse(w, model.ErrorResponse{
 					Error: model.ErrorDetail{
-						Message: "key is blocked",
-						Type:    "permission_denied",
+						Message: "unable to verify budget",
+						Type:    "internal_error",
 					},
 				})
 				return
@@ -1,10 +1,22 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
-	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
 )
 
 func TestModelBudgetLimiter_WithinBudget(t *testing.T) {
@@ -60,3 +72,219 @@ func TestModelBudgetLimiter_Reset(t *testing.T) {
 	assert.NoError(t, m.Check("gpt-4"))
 	assert.Equal(t, 0.0, m.GetSpend("gpt-4"))
 }
+
+// fakeBudgetStore is an in-memory BudgetStore.
+type fakeBudgetStore struct {
+	keys      map[string]db.VerificationToken
+	users     map[string]db.UserTable
+	teams     map[string]db.TeamTable
+	orgs      map[string]db.OrganizationTable
+	endUsers  map[string]db.EndUserTable
+	budgets   map[string]db.BudgetTable
+	err       error
+	cooldowns []string
+}
+
+func lookup[T any](m map[string]T, id string, err error) (T, error) {
+	var zero T
+	if err != nil {
+		return zero, err
+	}
+	if v, ok := m[id]; ok {
+		return v, nil
+	}
+	return zero, pgx.ErrNoRows
+}
+
+func (f *fakeBudgetStore) GetVerificationToken(_ context.Context, token string) (db.VerificationToken, error) {
+	return lookup(f.keys, token, f.err)
+}
+
+func (f *fakeBudgetStore) GetUser(_ context.Context, id string) (db.UserTable, error) {
+	return lookup(f.users, id, f.err)
+}
+
+func (f *fakeBudgetStore) GetTeam(_ context.Context, id string) (db.TeamTable, error) {
+	return lookup(f.teams, id, f.err)
+}
+
+func (f *fakeBudgetStore) GetOrganization(_ context.Context, id string) (db.OrganizationTable, error) {
+	return lookup(f.orgs, id, f.err)
+}
+
+func (f *fakeBudgetStore) GetEndUserSpend(_ context.Context, id string) (db.EndUserTable, error) {
+	return lookup(f.endUsers, id, f.err)
+}
+
+func (f *fakeBudgetStore) GetBudget(_ context.Context, id string) (db.BudgetTable, error) {
+	return lookup(f.budgets, id, f.err)
+}
+
+func (f *fakeBudgetStore) SetVerificationTokenSoftBudgetCooldown(_ context.Context, token string) error {
+	f.cooldowns = append(f.cooldowns, token)
+	return nil
+}
+
+func TestBudgetEnforcer_NamesLevel(t *testing.T) {
+	store := &fakeBudgetStore{
+		keys:     map[string]db.VerificationToken{"hash": {Spend: 1, MaxBudget: ptr(10.0), KeyAlias: ptr("ci-key")}},
+		users:    map[string]db.UserTable{"u1": {Spend: 5, MaxBudget: ptr(10.0)}},
+		teams:    map[string]db.TeamTable{"t1": {Spend: 3}},
+		orgs:     map[string]db.OrganizationTable{"o1": {Spend: 50, MaxBudget: ptr(50.0)}},
+		endUsers: map[string]db.EndUserTable{"cust-1": {Spend: 2, TianjiBudgetTable: ptr("b-cust")}},
+		budgets:  map[string]db.BudgetTable{"b-team": {MaxBudget: ptr(3.0)}, "b-cust": {MaxBudget: ptr(2.0)}},
+	}
+	e := NewBudgetEnforcer(store)
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		scope BudgetScope
+		setup func()
+		level BudgetLevel
+	}{
+		{name: "within every budget", scope: BudgetScope{TokenHash: "hash", UserID: "u1", TeamID: "t1"}},
+		{name: "organization", scope: BudgetScope{TokenHash: "hash", UserID: "u1", OrgID: "o1"}, level: BudgetLevelOrg},
+		{name: "end user via linked budget", scope: BudgetScope{EndUserID: "cust-1"}, level: BudgetLevelEndUser},
+		{
+			name:  "team via linked budget",
+			scope: BudgetScope{TokenHash: "hash", TeamID: "t1"},
+			setup: func() { store.teams["t1"] = db.TeamTable{Spend: 3, BudgetID: ptr("b-team")} },
+			level: BudgetLevelTeam,
+		},
+		{
+			name:  "key checked before user",
+			scope: BudgetScope{TokenHash: "hash", UserID: "u1"},
+			setup: func() {
+				store.keys["hash"] = db.VerificationToken{Spend: 10, MaxBudget: ptr(10.0), KeyAlias: ptr("ci-key")}
+				store.users["u1"] = db.UserTable{Spend: 10, MaxBudget: ptr(10.0)}
+			},
+			level: BudgetLevelKey,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+			err := e.Check(ctx, tt.scope)
+			if tt.level == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var exceeded *BudgetExceededError
+			require.ErrorAs(t, err, &exceeded)
+			assert.Equal(t, tt.level, exceeded.Level)
+			assert.ErrorIs(t, err, model.ErrBudgetExceeded)
+		})
+	}
+
+	err := e.Check(ctx, BudgetScope{TokenHash: "hash"})
+	assert.Contains(t, err.Error(), `"ci-key"`, "key named by alias, not hash")
+}
+
+func TestBudgetEnforcer_ModelBudget(t *testing.T) {
+	store := &fakeBudgetStore{keys: map[string]db.VerificationToken{"hash": {
+		ModelMaxBudget: []byte(`{"gpt-4o": 5}`),
+		ModelSpend:     []byte(`{"gpt-4o": 5, "gpt-4o-mini": 100}`),
+	}}}
+	e := NewBudgetEnforcer(store)
+
+	err := e.Check(context.Background(), BudgetScope{TokenHash: "hash", Model: "gpt-4o"})
+	var exceeded *BudgetExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, BudgetLevelKeyModel, exceeded.Level)
+	assert.Equal(t, "gpt-4o", exceeded.Model)
+
+	assert.NoError(t, e.Check(context.Background(), BudgetScope{TokenHash: "hash", Model: "gpt-4o-mini"}))
+}
+
+func TestBudgetEnforcer_ParentKeyBudget(t *testing.T) {
+	store := &fakeBudgetStore{keys: map[string]db.VerificationToken{
+		"child":  {Spend: 1, MaxBudget: ptr(5.0), ParentToken: ptr("parent")},
+		"parent": {Spend: 10, MaxBudget: ptr(10.0), KeyAlias: ptr("agent"), ParentToken: ptr("child")},
+	}}
+	e := NewBudgetEnforcer(store)
+
+	err := e.Check(context.Background(), BudgetScope{TokenHash: "child"})
+	var exceeded *BudgetExceededError
+	require.ErrorAs(t, err, &exceeded, "a child key is held to its parent's budget")
+	assert.Equal(t, "agent", exceeded.ID)
+
+	store.keys["parent"] = db.VerificationToken{Spend: 1, MaxBudget: ptr(10.0), ParentToken: ptr("child")}
+	assert.NoError(t, e.Check(context.Background(), BudgetScope{TokenHash: "child"}), "a parent cycle ends the walk")
+}
+
+func TestBudgetEnforcer_ElapsedWindow(t *testing.T) {
+	now := time.Now()
+	store := &fakeBudgetStore{teams: map[string]db.TeamTable{"t1": {
+		Spend:         10,
+		MaxBudget:     ptr(10.0),
+		BudgetResetAt: pgtype.Timestamptz{Time: now.Add(-time.Minute), Valid: true},
+	}}}
+	e := NewBudgetEnforcer(store)
+
+	assert.NoError(t, e.Check(context.Background(), BudgetScope{TeamID: "t1"}), "spend of an ended window is not counted")
+
+	e.now = func() time.Time { return now.Add(-time.Hour) }
+	assert.ErrorIs(t, e.Check(context.Background(), BudgetScope{TeamID: "t1"}), model.ErrBudgetExceeded)
+}
+
+func TestBudgetEnforcer_SoftBudget(t *testing.T) {
+	store := &fakeBudgetStore{
+		keys:    map[string]db.VerificationToken{"hash": {Spend: 8, BudgetID: ptr("b1")}},
+		budgets: map[string]db.BudgetTable{"b1": {SoftBudget: ptr(5.0), MaxBudget: ptr(10.0)}},
+	}
+	e := NewBudgetEnforcer(store)
+
+	assert.NoError(t, e.Check(context.Background(), BudgetScope{TokenHash: "hash"}), "soft budget does not block")
+	assert.Equal(t, []string{"hash"}, store.cooldowns)
+
+	store.keys["hash"] = db.VerificationToken{Spend: 9, BudgetID: ptr("b1"), SoftBudgetCooldown: true}
+	assert.NoError(t, e.Check(context.Background(), BudgetScope{TokenHash: "hash"}))
+	assert.Len(t, store.cooldowns, 1, "no repeat warning within the window")
+}
+
+func TestBudgetMiddleware(t *testing.T) {
+	store := &fakeBudgetStore{
+		keys:     map[string]db.VerificationToken{"hash": {Spend: 1, MaxBudget: ptr(10.0)}},
+		endUsers: map[string]db.EndUserTable{"cust-1": {Spend: 2, TianjiBudgetTable: ptr("b1")}},
+		budgets:  map[string]db.BudgetTable{"b1": {MaxBudget: ptr(2.0)}},
+	}
+	var gotBody []byte
+	var gotModel string
+	handler := NewBudgetMiddleware(NewBudgetEnforcer(store))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotModel = RequestedModel(r.Context())
+		gotBody = make([]byte, r.ContentLength)
+		_, _ = r.Body.Read(gotBody)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	call := func(body string, ctx context.Context) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)).WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	keyCtx := context.WithValue(context.Background(), ContextKeyTokenHash, "hash")
+
+	body := `{"model":"gpt-4o","user":"cust-2"}`
+	w := call(body, keyCtx)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, string(gotBody), "body restored for the handler")
+	assert.Equal(t, "gpt-4o", gotModel)
+
+	w = call(`{"model":"gpt-4o","user":"cust-1"}`, keyCtx)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	var resp model.ErrorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "budget_exceeded", resp.Error.Code)
+	assert.Contains(t, resp.Error.Message, "end_user")
+
+	masterCtx := context.WithValue(keyCtx, ContextKeyIsMasterKey, true)
+	assert.Equal(t, http.StatusOK, call(`{"model":"gpt-4o","user":"cust-1"}`, masterCtx).Code)
+
+	store.err = errors.New("connection refused")
+	assert.Equal(t, http.StatusServiceUnavailable, call(`{"model":"gpt-4o"}`, keyCtx).Code)
+}
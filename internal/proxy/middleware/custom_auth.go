@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+	"github.com/praxisllmlab/tianjiLLM/internal/cache"
+)
+
+// CustomAuthRequest is the body posted to the custom authorization service.
+type CustomAuthRequest struct {
+	APIKey   string            `json:"api_key"`
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Headers  map[string]string `json:"headers"`
+	ClientIP string            `json:"client_ip"`
+}
+
+// CustomAuthDecision is the service's answer. A 401 or 403 response denies
+// the request like Allow false; Message is returned to the caller.
+type CustomAuthDecision struct {
+	Allow   bool     `json:"allow"`
+	Message string   `json:"message,omitempty"`
+	UserID  string   `json:"user_id,omitempty"`
+	TeamID  string   `json:"team_id,omitempty"`
+	OrgID   string   `json:"org_id,omitempty"`
+	Role    string   `json:"role,omitempty"`
+	Models  []string `json:"models,omitempty"`
+}
+
+// CustomAuth delegates authentication to an external HTTP service, in the
+// style of Envoy's ext_authz: the credentials and request metadata are
+// posted to URL and the returned decision sets the caller's identity.
+type CustomAuth struct {
+	URL     string
+	Client  *http.Client
+	Headers map[string]string // added to every call
+	// TTL caches decisions per key, method and path; 0 disables caching.
+	TTL time.Duration
+	// FailOpen admits requests without identity when the service fails.
+	FailOpen bool
+	// Fallback hands denied requests to the built-in key and JWT checks.
+	Fallback bool
+
+	cache cache.Cache
+}
+
+// NewCustomAuth creates a CustomAuth calling url with the given timeout,
+// caching decisions in memory for ttl.
+func NewCustomAuth(url string, timeout, ttl time.Duration) *CustomAuth {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	a := &CustomAuth{URL: url, Client: &http.Client{Timeout: timeout}, TTL: ttl}
+	if ttl > 0 {
+		a.cache = cache.NewMemoryCache()
+	}
+	return a
+}
+
+// errCustomAuthUnavailable wraps failures to reach or understand the service.
+var errCustomAuthUnavailable = errors.New("custom auth service unavailable")
+
+// Authorize returns the service's decision for the request, from the cache
+// when possible.
+func (a *CustomAuth) Authorize(r *http.Request, token string) (*CustomAuthDecision, error) {
+	ctx := r.Context()
+	key := "tianji:custom_auth:" + hashToken(token) + ":" + r.Method + ":" + r.URL.Path
+	if a.cache != nil {
+		if raw, err := a.cache.Get(ctx, key); err == nil && raw != nil {
+			var d CustomAuthDecision
+			if json.Unmarshal(raw, &d) == nil {
+				return &d, nil
+			}
+		}
+	}
+
+	d, err := a.call(ctx, r, token)
+	if err != nil {
+		return nil, err
+	}
+	if a.cache != nil {
+		if raw, err := json.Marshal(d); err == nil {
+			_ = a.cache.Set(ctx, key, raw, a.TTL)
+		}
+	}
+	return d, nil
+}
+
+func (a *CustomAuth) call(ctx context.Context, r *http.Request, token string) (*CustomAuthDecision, error) {
+	headers := make(map[string]string, len(r.Header))
+	for name := range r.Header {
+		headers[name] = r.Header.Get(name)
+	}
+	body, err := json.Marshal(CustomAuthRequest{
+		APIKey:   token,
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Headers:  headers,
+		ClientIP: r.RemoteAddr,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCustomAuthUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range a.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := a.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errCustomAuthUnavailable, err)
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	var d CustomAuthDecision
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if err := json.Unmarshal(raw, &d); err != nil {
+			return nil, fmt.Errorf("%w: decode decision: %v", errCustomAuthUnavailable, err)
+		}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		_ = json.Unmarshal(raw, &d)
+		d.Allow = false
+	default:
+		return nil, fmt.Errorf("%w: status %d", errCustomAuthUnavailable, resp.StatusCode)
+	}
+	return &d, nil
+}
+
+// withDecision adds the identity from an allowing decision to ctx.
+func withDecision(ctx context.Context, d *CustomAuthDecision, role auth.Role) context.Context {
+	ctx = context.WithValue(ctx, ContextKeyIsMasterKey, false)
+	ctx = context.WithValue(ctx, ContextKeyRole, role)
+	if d.UserID != "" {
+		ctx = context.WithValue(ctx, ContextKeyUserID, d.UserID)
+	}
+	if d.TeamID != "" {
+		ctx = context.WithValue(ctx, ContextKeyTeamID, d.TeamID)
+	}
+	if d.OrgID != "" {
+		ctx = context.WithValue(ctx, ContextKeyOrgID, d.OrgID)
+	}
+	if len(d.Models) > 0 {
+		ctx = context.WithValue(ctx, ContextKeyAllowedModels, &ModelAccess{Models: d.Models})
+	}
+	return ctx
+}
+
+// decisionRole is the role named by the decision; unknown or missing roles
+// are internal users.
+func decisionRole(d *CustomAuthDecision) auth.Role {
+	if role, err := auth.ParseRole(d.Role); err == nil {
+		return role
+	}
+	return auth.RoleInternalUser
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+)
+
+// authzServer answers custom auth calls: "good" is allowed as alice,
+// "down" fails with 500 and anything else is denied.
+func authzServer(t *testing.T, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		assert.Equal(t, "s3cret", r.Header.Get("X-Authz-Token"))
+		var req CustomAuthRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		switch req.APIKey {
+		case "good":
+			_ = json.NewEncoder(w).Encode(CustomAuthDecision{
+				Allow: true, UserID: "alice", TeamID: "team-a", Role: "team", Models: []string{"gpt-4o"},
+			})
+		case "down":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(CustomAuthDecision{Message: "unknown caller"})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func serveAuth(cfg AuthConfig, header, token string) (*httptest.ResponseRecorder, context.Context) {
+	var got context.Context
+	h := NewAuthMiddleware(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Context()
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set(header, token)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec, got
+}
+
+func TestCustomAuth_AllowCachedAndDeny(t *testing.T) {
+	var calls atomic.Int32
+	ca := NewCustomAuth(authzServer(t, &calls).URL, time.Second, time.Minute)
+	ca.Headers = map[string]string{"X-Authz-Token": "s3cret"}
+	cfg := AuthConfig{CustomAuth: ca, HeaderName: "X-Tianji-Key"}
+
+	for range 2 {
+		rec, ctx := serveAuth(cfg, "X-Tianji-Key", "good")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "alice", ctx.Value(ContextKeyUserID))
+		assert.Equal(t, "team-a", ctx.Value(ContextKeyTeamID))
+		assert.Equal(t, auth.RoleTeam, ctx.Value(ContextKeyRole))
+		access, _ := ctx.Value(ContextKeyAllowedModels).(*ModelAccess)
+		require.NotNil(t, access)
+		assert.Equal(t, []string{"gpt-4o"}, access.Models)
+	}
+	assert.Equal(t, int32(1), calls.Load(), "decision is cached")
+
+	rec, _ := serveAuth(cfg, "Authorization", "Bearer bad")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "unknown caller")
+}
+
+func TestCustomAuth_FailurePolicy(t *testing.T) {
+	var calls atomic.Int32
+	url := authzServer(t, &calls).URL
+
+	closed := NewCustomAuth(url, time.Second, 0)
+	closed.Headers = map[string]string{"X-Authz-Token": "s3cret"}
+	rec, _ := serveAuth(AuthConfig{CustomAuth: closed}, "Authorization", "Bearer down")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	open := NewCustomAuth(url, time.Second, 0)
+	open.Headers = closed.Headers
+	open.FailOpen = true
+	rec, ctx := serveAuth(AuthConfig{CustomAuth: open}, "Authorization", "Bearer down")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, auth.RoleInternalUser, ctx.Value(ContextKeyRole))
+	assert.Nil(t, ctx.Value(ContextKeyUserID))
+}
+
+func TestCustomAuth_FallbackToVirtualKeys(t *testing.T) {
+	var calls atomic.Int32
+	ca := NewCustomAuth(authzServer(t, &calls).URL, time.Second, 0)
+	ca.Headers = map[string]string{"X-Authz-Token": "s3cret"}
+	ca.Fallback = true
+	uid := "bob"
+	cfg := AuthConfig{CustomAuth: ca, Validator: &mockValidator{info: &TokenInfo{UserID: &uid}}}
+
+	rec, ctx := serveAuth(cfg, "Authorization", "Bearer sk-virtual")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "bob", ctx.Value(ContextKeyUserID))
+}
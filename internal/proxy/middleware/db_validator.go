@@ -2,10 +2,14 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/netip"
+	"slices"
 
 	"github.com/jackc/pgx/v5"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 )
 
@@ -13,12 +17,17 @@ import (
 var (
 	ErrKeyNotFound   = errors.New("api key not found")
 	ErrDBUnavailable = errors.New("database unavailable")
+	ErrNotOrgMember  = errors.New("key does not belong to its organization")
 )
 
 // verificationTokenQuerier is a narrow interface satisfied by *db.Queries.
 // It exists to make DBValidator unit-testable without a real DB connection.
 type verificationTokenQuerier interface {
 	GetVerificationToken(ctx context.Context, token string) (db.VerificationToken, error)
+	GetTeam(ctx context.Context, teamID string) (db.TeamTable, error)
+	GetAccessGroup(ctx context.Context, groupID string) (db.ModelAccessGroup, error)
+	GetOrgMember(ctx context.Context, arg db.GetOrgMemberParams) (db.OrganizationMembership, error)
+	GetUser(ctx context.Context, userID string) (db.UserTable, error)
 }
 
 // DBValidator bridges *db.Queries to TokenValidator.
@@ -26,8 +35,9 @@ type DBValidator struct {
 	DB verificationTokenQuerier
 }
 
-// ValidateToken looks up a virtual key by its SHA256 hash in a single DB call.
-// Returns all key metadata needed for auth and guardrail enforcement.
+// ValidateToken looks up a virtual key by its SHA256 hash, together with its
+// team, access groups, organization membership and user role. Returns all key metadata
+// needed for auth, model and route enforcement, and guardrails.
 func (d *DBValidator) ValidateToken(ctx context.Context, tokenHash string) (*TokenInfo, error) {
 	vt, err := d.DB.GetVerificationToken(ctx, tokenHash)
 	if err != nil {
@@ -36,12 +46,144 @@ func (d *DBValidator) ValidateToken(ctx context.Context, tokenHash string) (*Tok
 		}
 		return nil, ErrDBUnavailable
 	}
-	return &TokenInfo{
-		UserID:     vt.UserID,
-		TeamID:     vt.TeamID,
-		Blocked:    vt.Blocked != nil && *vt.Blocked,
-		Guardrails: vt.Policies,
-	}, nil
+
+	info := &TokenInfo{
+		UserID:        vt.UserID,
+		TeamID:        vt.TeamID,
+		OrgID:         vt.OrganizationID,
+		Blocked:       vt.Blocked != nil && *vt.Blocked,
+		Guardrails:    vt.Policies,
+		AllowedRoutes: vt.AllowedRoutes,
+		AllowedIPs:    metadataAllowedIPs(vt.Metadata),
+	}
+	if vt.Expires.Valid {
+		expires := vt.Expires.Time
+		info.Expires = &expires
+	}
+	if err := d.applyParents(ctx, tokenHash, vt.ParentToken, info); err != nil {
+		return nil, err
+	}
+
+	access := &ModelAccess{Models: vt.Models}
+	if len(vt.Aliases) > 0 {
+		// Malformed aliases are ignored rather than locking the key out.
+		_ = json.Unmarshal(vt.Aliases, &access.Aliases)
+	}
+	for _, id := range vt.AccessGroupIds {
+		group, err := d.DB.GetAccessGroup(ctx, id)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return nil, ErrDBUnavailable
+		}
+		access.Models = append(access.Models, group.Models...)
+	}
+	if len(vt.Models) == 0 && len(vt.AccessGroupIds) > 0 && len(access.Models) == 0 {
+		// Every access group was deleted or empty: the key keeps its
+		// restriction instead of silently gaining access to every model.
+		access.Models = []string{""}
+	}
+
+	if vt.TeamID != nil {
+		team, err := d.DB.GetTeam(ctx, *vt.TeamID)
+		switch {
+		case err == nil:
+			info.TeamBlocked = team.Blocked
+			info.TeamAllowedIPs = metadataAllowedIPs(team.Metadata)
+			access.TeamModels = team.Models
+			if info.OrgID == nil {
+				info.OrgID = team.OrganizationID
+			} else if team.OrganizationID != nil && *team.OrganizationID != *info.OrgID {
+				return nil, ErrNotOrgMember
+			}
+		case !errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrDBUnavailable
+		}
+	}
+
+	info.Role = auth.RoleInternalUser
+	if vt.UserID != nil {
+		user, err := d.DB.GetUser(ctx, *vt.UserID)
+		switch {
+		case err == nil:
+			if role, err := auth.ParseRole(user.UserRole); err == nil {
+				info.Role = role
+			}
+		case !errors.Is(err, pgx.ErrNoRows):
+			return nil, ErrDBUnavailable
+		}
+	}
+
+	if vt.OrganizationID != nil && vt.UserID != nil {
+		_, err := d.DB.GetOrgMember(ctx, db.GetOrgMemberParams{UserID: *vt.UserID, OrganizationID: *vt.OrganizationID})
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return nil, ErrNotOrgMember
+			}
+			return nil, ErrDBUnavailable
+		}
+	}
+
+	if len(access.Models) > 0 || len(access.TeamModels) > 0 || len(access.Aliases) > 0 {
+		info.ModelAccess = access
+	}
+	return info, nil
+}
+
+// applyParents holds a delegated key to the keys above it: the key is
+// blocked when any parent is blocked or deleted, expires with the earliest
+// parent, runs every parent's guardrails and, without routes of its own,
+// is limited to the nearest parent's allowed routes.
+func (d *DBValidator) applyParents(ctx context.Context, tokenHash string, parent *string, info *TokenInfo) error {
+	seen := map[string]bool{tokenHash: true}
+	for parent != nil && !seen[*parent] {
+		seen[*parent] = true
+		pt, err := d.DB.GetVerificationToken(ctx, *parent)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				info.ParentBlocked = true
+				return nil
+			}
+			return ErrDBUnavailable
+		}
+		if pt.Blocked != nil && *pt.Blocked {
+			info.ParentBlocked = true
+		}
+		if pt.Expires.Valid && (info.Expires == nil || pt.Expires.Time.Before(*info.Expires)) {
+			expires := pt.Expires.Time
+			info.Expires = &expires
+		}
+		if len(info.AllowedRoutes) == 0 {
+			info.AllowedRoutes = pt.AllowedRoutes
+		}
+		for _, g := range pt.Policies {
+			if !slices.Contains(info.Guardrails, g) {
+				info.Guardrails = append(info.Guardrails, g)
+			}
+		}
+		parent = pt.ParentToken
+	}
+	return nil
+}
+
+// metadataAllowedIPs reads the "allowed_ips" list of key or team metadata.
+// Without one every address is allowed. Malformed entries are skipped, but
+// a list with no valid entry allows no address rather than every one.
+func metadataAllowedIPs(metadata []byte) []netip.Prefix {
+	var m struct {
+		AllowedIPs []string `json:"allowed_ips"`
+	}
+	if len(metadata) == 0 || json.Unmarshal(metadata, &m) != nil || len(m.AllowedIPs) == 0 {
+		return nil
+	}
+	prefixes := make([]netip.Prefix, 0, len(m.AllowedIPs))
+	for _, e := range m.AllowedIPs {
+		if p, err := ParseIPPrefix(e); err == nil {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
 }
 
 // errorLogInserter is a narrow interface satisfied by *db.Queries.
@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 )
 
@@ -16,12 +19,53 @@ import (
 type fakeQuerier struct {
 	result db.VerificationToken
 	err    error
+	// parents are the keys above the validated key "somehash"; when set,
+	// any other token is not found.
+	parents map[string]db.VerificationToken
+	teams   map[string]db.TeamTable
+	groups  map[string]db.ModelAccessGroup
+	members map[db.GetOrgMemberParams]bool
+	users   map[string]db.UserTable
 }
 
-func (f *fakeQuerier) GetVerificationToken(_ context.Context, _ string) (db.VerificationToken, error) {
+func (f *fakeQuerier) GetVerificationToken(_ context.Context, token string) (db.VerificationToken, error) {
+	if f.parents != nil && token != "somehash" {
+		if parent, ok := f.parents[token]; ok {
+			return parent, nil
+		}
+		return db.VerificationToken{}, pgx.ErrNoRows
+	}
 	return f.result, f.err
 }
 
+func (f *fakeQuerier) GetTeam(_ context.Context, teamID string) (db.TeamTable, error) {
+	if team, ok := f.teams[teamID]; ok {
+		return team, nil
+	}
+	return db.TeamTable{}, pgx.ErrNoRows
+}
+
+func (f *fakeQuerier) GetAccessGroup(_ context.Context, groupID string) (db.ModelAccessGroup, error) {
+	if group, ok := f.groups[groupID]; ok {
+		return group, nil
+	}
+	return db.ModelAccessGroup{}, pgx.ErrNoRows
+}
+
+func (f *fakeQuerier) GetOrgMember(_ context.Context, arg db.GetOrgMemberParams) (db.OrganizationMembership, error) {
+	if f.members[arg] {
+		return db.OrganizationMembership{UserID: arg.UserID, OrganizationID: arg.OrganizationID}, nil
+	}
+	return db.OrganizationMembership{}, pgx.ErrNoRows
+}
+
+func (f *fakeQuerier) GetUser(_ context.Context, userID string) (db.UserTable, error) {
+	if user, ok := f.users[userID]; ok {
+		return user, nil
+	}
+	return db.UserTable{}, pgx.ErrNoRows
+}
+
 func ptr[T any](v T) *T { return &v }
 
 // BenchmarkValidateToken measures the latency of a single ValidateToken call
@@ -123,3 +167,124 @@ func TestDBValidator_ValidateToken(t *testing.T) {
 		})
 	}
 }
+
+func TestDBValidator_ValidateToken_AccessPolicy(t *testing.T) {
+	uid, tid, org := "user-1", "team-1", "org-1"
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	q := &fakeQuerier{
+		result: db.VerificationToken{
+			UserID:         &uid,
+			TeamID:         &tid,
+			Expires:        pgtype.Timestamptz{Time: expires, Valid: true},
+			Models:         []string{"gpt-4o"},
+			Aliases:        []byte(`{"fast": "gpt-4o-mini"}`),
+			AccessGroupIds: []string{"group-1", "deleted"},
+			AllowedRoutes:  []string{"llm_api_routes"},
+		},
+		teams: map[string]db.TeamTable{
+			tid: {TeamID: tid, Blocked: true, Models: []string{"gpt-4o", "gpt-4o-mini"}, OrganizationID: &org},
+		},
+		groups: map[string]db.ModelAccessGroup{
+			"group-1": {GroupID: "group-1", Models: []string{"gpt-4o-mini"}},
+		},
+	}
+
+	info, err := (&DBValidator{DB: q}).ValidateToken(context.Background(), "somehash")
+	require.NoError(t, err)
+	assert.Equal(t, &expires, info.Expires)
+	assert.True(t, info.TeamBlocked)
+	assert.Equal(t, &org, info.OrgID, "org is inherited from the team")
+	assert.Equal(t, []string{"llm_api_routes"}, info.AllowedRoutes)
+	require.NotNil(t, info.ModelAccess)
+	assert.Equal(t, []string{"gpt-4o", "gpt-4o-mini"}, info.ModelAccess.Models)
+	assert.Equal(t, []string{"gpt-4o", "gpt-4o-mini"}, info.ModelAccess.TeamModels)
+	assert.Equal(t, map[string]string{"fast": "gpt-4o-mini"}, info.ModelAccess.Aliases)
+}
+
+func TestDBValidator_ValidateToken_OrgMembership(t *testing.T) {
+	uid, org := "user-1", "org-1"
+	q := &fakeQuerier{result: db.VerificationToken{UserID: &uid, OrganizationID: &org}}
+	v := &DBValidator{DB: q}
+
+	_, err := v.ValidateToken(context.Background(), "somehash")
+	assert.ErrorIs(t, err, ErrNotOrgMember)
+
+	q.members = map[db.GetOrgMemberParams]bool{{UserID: uid, OrganizationID: org}: true}
+	info, err := v.ValidateToken(context.Background(), "somehash")
+	require.NoError(t, err)
+	assert.Equal(t, &org, info.OrgID)
+	assert.Nil(t, info.ModelAccess)
+}
+
+func TestDBValidator_ValidateToken_TeamInOtherOrg(t *testing.T) {
+	tid, org, other := "team-1", "org-1", "org-2"
+	q := &fakeQuerier{
+		result: db.VerificationToken{TeamID: &tid, OrganizationID: &org},
+		teams:  map[string]db.TeamTable{tid: {TeamID: tid, OrganizationID: &other}},
+	}
+	_, err := (&DBValidator{DB: q}).ValidateToken(context.Background(), "somehash")
+	assert.ErrorIs(t, err, ErrNotOrgMember)
+}
+
+func TestDBValidator_ValidateToken_UserRole(t *testing.T) {
+	admin, plain, missing := "admin-1", "user-1", "ghost"
+	q := &fakeQuerier{users: map[string]db.UserTable{
+		admin: {UserID: admin, UserRole: "proxy_admin"},
+		plain: {UserID: plain, UserRole: "custom-role"},
+	}}
+	v := &DBValidator{DB: q}
+
+	for uid, want := range map[*string]auth.Role{
+		&admin:   auth.RoleProxyAdmin,
+		&plain:   auth.RoleInternalUser,
+		&missing: auth.RoleInternalUser,
+		nil:      auth.RoleInternalUser,
+	} {
+		q.result = db.VerificationToken{UserID: uid}
+		info, err := v.ValidateToken(context.Background(), "somehash")
+		require.NoError(t, err)
+		assert.Equal(t, want, info.Role)
+	}
+}
+
+func TestDBValidator_ValidateToken_Parents(t *testing.T) {
+	soon := time.Now().Add(time.Minute)
+	q := &fakeQuerier{
+		result: db.VerificationToken{ParentToken: ptr("parent"), Policies: []string{"pii"}},
+		parents: map[string]db.VerificationToken{
+			"parent":      {ParentToken: ptr("grandparent"), Policies: []string{"pii", "toxicity"}},
+			"grandparent": {Expires: pgtype.Timestamptz{Time: soon, Valid: true}, AllowedRoutes: []string{"llm_api_routes"}},
+		},
+	}
+	v := &DBValidator{DB: q}
+
+	info, err := v.ValidateToken(context.Background(), "somehash")
+	require.NoError(t, err)
+	assert.False(t, info.ParentBlocked)
+	require.NotNil(t, info.Expires)
+	assert.True(t, info.Expires.Equal(soon), "a child expires with its earliest parent")
+	assert.Equal(t, []string{"pii", "toxicity"}, info.Guardrails)
+	assert.Equal(t, []string{"llm_api_routes"}, info.AllowedRoutes)
+
+	q.parents["grandparent"] = db.VerificationToken{Blocked: ptr(true)}
+	info, err = v.ValidateToken(context.Background(), "somehash")
+	require.NoError(t, err)
+	assert.True(t, info.ParentBlocked, "blocking any key above revokes the child")
+
+	delete(q.parents, "grandparent")
+	info, err = v.ValidateToken(context.Background(), "somehash")
+	require.NoError(t, err)
+	assert.True(t, info.ParentBlocked, "a deleted parent revokes the child")
+}
+
+func TestMetadataAllowedIPs(t *testing.T) {
+	assert.Nil(t, metadataAllowedIPs(nil))
+	assert.Nil(t, metadataAllowedIPs([]byte(`{}`)))
+	assert.Nil(t, metadataAllowedIPs([]byte(`{"allowed_ips":[]}`)))
+	assert.Len(t, metadataAllowedIPs([]byte(`{"allowed_ips":["10.0.0.0/8","1.2.3.4","junk"]}`)), 2)
+
+	none := metadataAllowedIPs([]byte(`{"allowed_ips":["junk"]}`))
+	assert.NotNil(t, none, "a list without valid entries must not lift the restriction")
+	assert.Empty(t, none)
+}
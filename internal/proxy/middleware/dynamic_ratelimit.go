@@ -96,8 +96,10 @@ type CheckResult struct {
 	TPMRemaining int64
 	TPMLimit     int64
 	ResetSeconds int // seconds until counters reset
-	EffectiveRPM int64
-	EffectiveTPM int64
+	// TPMResetSeconds, when set, is the seconds until the TPM counter resets.
+	TPMResetSeconds int
+	EffectiveRPM    int64
+	EffectiveTPM    int64
 }
 
 // Check determines if a request should be allowed based on priority, saturation, and limits.
@@ -205,6 +207,9 @@ func setRateLimitHeaders(w http.ResponseWriter, r CheckResult) {
 		w.Header().Set("X-RateLimit-Remaining-Tokens", strconv.FormatInt(r.TPMRemaining, 10))
 	}
 	w.Header().Set("X-RateLimit-Reset-Requests", strconv.Itoa(r.ResetSeconds)+"s")
+	if r.TPMLimit > 0 && r.TPMResetSeconds > 0 {
+		w.Header().Set("X-RateLimit-Reset-Tokens", strconv.Itoa(r.TPMResetSeconds)+"s")
+	}
 }
 
 // NewDynamicRateLimitMiddleware creates middleware for dynamic rate limiting.
@@ -1,7 +1,11 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"io"
+	"mime"
 	"net/http"
 )
 
@@ -11,9 +15,80 @@ var (
 	rpmLimitKey   contextKey = "rpm_limit"
 	tpmLimitKey   contextKey = "tpm_limit"
 	modelGroupKey contextKey = "model_group"
+
+	requestFieldsKey contextKey = "request_fields"
 )
 
 // writeJSONResponse writes a JSON response to the writer.
 func writeJSONResponse(w http.ResponseWriter, v any) {
 	_ = json.NewEncoder(w).Encode(v)
 }
+
+// requestFields are the fields of a JSON request body read by middleware
+// before the handler parses it.
+type requestFields struct {
+	Model     string
+	User      string
+	MaxTokens int64
+	Size      int64 // body length in bytes
+	// Target holds the "team_id" and "organization_id" fields, naming the
+	// team or organization a management request acts on.
+	Target map[string]string
+}
+
+// peekRequest reads the fields of a JSON POST body that middleware needs
+// and restores the body for the handler. The result is cached in the
+// returned request's context so later middleware do not read the body again.
+// Other requests yield zero fields.
+func peekRequest(r *http.Request) (*http.Request, requestFields) {
+	if f, ok := r.Context().Value(requestFieldsKey).(requestFields); ok {
+		return r, f
+	}
+	var f requestFields
+	if r.Body == nil || r.Method != http.MethodPost {
+		return r, f
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, _ := mime.ParseMediaType(ct); mt != "application/json" {
+			return r, f
+		}
+	}
+	body, err := io.ReadAll(r.Body)
+	_ = r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return r, f
+	}
+	var fields struct {
+		Model               string `json:"model"`
+		User                string `json:"user"`
+		MaxTokens           int64  `json:"max_tokens"`
+		MaxCompletionTokens int64  `json:"max_completion_tokens"`
+		MaxOutputTokens     int64  `json:"max_output_tokens"`
+		TeamID              string `json:"team_id"`
+		OrganizationID      string `json:"organization_id"`
+	}
+	_ = json.Unmarshal(body, &fields)
+	f = requestFields{
+		Model:     fields.Model,
+		User:      fields.User,
+		MaxTokens: max(fields.MaxTokens, fields.MaxCompletionTokens, fields.MaxOutputTokens),
+		Size:      int64(len(body)),
+		Target:    map[string]string{"team_id": fields.TeamID, "organization_id": fields.OrganizationID},
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestFieldsKey, f)), f
+}
+
+// withRequestScope records the requested model and end user of a JSON
+// request in its context, for budgets, rate limits and spend logs.
+func withRequestScope(r *http.Request) *http.Request {
+	r, f := peekRequest(r)
+	ctx := r.Context()
+	if f.Model != "" && RequestedModel(ctx) == "" {
+		ctx = context.WithValue(ctx, modelGroupKey, f.Model)
+	}
+	if f.User != "" {
+		ctx = context.WithValue(ctx, ContextKeyEndUserID, f.User)
+	}
+	return r.WithContext(ctx)
+}
@@ -1,47 +1,126 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
+	"sort"
 	"sync"
 )
 
+// ipWhitelistExempt are the probe endpoints reachable from any address, so
+// an allow-list does not take a replica out of its load balancer.
+var ipWhitelistExempt = map[string]bool{
+	"/health/liveness":  true,
+	"/health/readiness": true,
+}
+
 // IPWhitelist middleware rejects requests from non-whitelisted IPs.
+// Entries are single addresses or CIDR ranges. Entries given to
+// NewIPWhitelist come from the config and are kept when Replace loads the
+// entries stored in the database.
 type IPWhitelist struct {
 	mu      sync.RWMutex
-	allowed map[string]bool
+	static  map[string]netip.Prefix
+	allowed map[string]netip.Prefix
 	enabled bool
 }
 
 // NewIPWhitelist creates an IP whitelist. Pass nil for no initial IPs (disabled).
+// Malformed entries are ignored.
 func NewIPWhitelist(ips []string) *IPWhitelist {
 	w := &IPWhitelist{
-		allowed: make(map[string]bool, len(ips)),
-		enabled: len(ips) > 0,
+		static:  make(map[string]netip.Prefix, len(ips)),
+		allowed: make(map[string]netip.Prefix, len(ips)),
 	}
 	for _, ip := range ips {
-		w.allowed[ip] = true
+		if p, err := ParseIPPrefix(ip); err == nil {
+			w.static[ip] = p
+			w.allowed[ip] = p
+		}
 	}
+	w.enabled = len(w.allowed) > 0
 	return w
 }
 
-// Add adds an IP to the whitelist.
-func (w *IPWhitelist) Add(ip string) {
+// ParseIPPrefix parses an allow-list entry: an address, which matches only
+// itself, or a CIDR range.
+func ParseIPPrefix(s string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(s); err == nil {
+		return p.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid IP or CIDR %q", s)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// ParseIPPrefixes parses allow-list entries, returning the first error.
+func ParseIPPrefixes(entries []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(entries))
+	for _, e := range entries {
+		p, err := ParseIPPrefix(e)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// Add adds an IP or CIDR range to the whitelist.
+func (w *IPWhitelist) Add(ip string) error {
+	p, err := ParseIPPrefix(ip)
+	if err != nil {
+		return err
+	}
 	w.mu.Lock()
-	w.allowed[ip] = true
+	w.allowed[ip] = p
 	w.enabled = true
 	w.mu.Unlock()
+	return nil
 }
 
-// Remove removes an IP from the whitelist.
+// Remove removes an IP or CIDR range from the whitelist. Config entries
+// stay.
 func (w *IPWhitelist) Remove(ip string) {
 	w.mu.Lock()
-	delete(w.allowed, ip)
+	if _, ok := w.static[ip]; !ok {
+		delete(w.allowed, ip)
+	}
 	w.enabled = len(w.allowed) > 0
 	w.mu.Unlock()
 }
 
-// List returns all whitelisted IPs.
+// Replace swaps the database entries for entries, keeping the config
+// entries. Malformed entries are skipped and reported in the error.
+func (w *IPWhitelist) Replace(entries []string) error {
+	allowed := make(map[string]netip.Prefix, len(entries))
+	var errs []error
+	for _, e := range entries {
+		p, err := ParseIPPrefix(e)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		allowed[e] = p
+	}
+
+	w.mu.Lock()
+	for e, p := range w.static {
+		allowed[e] = p
+	}
+	w.allowed = allowed
+	w.enabled = len(allowed) > 0
+	w.mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// List returns all whitelisted IPs and CIDR ranges.
 func (w *IPWhitelist) List() []string {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
@@ -49,38 +128,63 @@ func (w *IPWhitelist) List() []string {
 	for ip := range w.allowed {
 		ips = append(ips, ip)
 	}
+	sort.Strings(ips)
 	return ips
 }
 
+// Allows reports whether ip may call the proxy.
+func (w *IPWhitelist) Allows(ip string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if !w.enabled {
+		return true
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range w.allowed {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
 // Middleware returns the HTTP middleware handler.
 func (w *IPWhitelist) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
-		w.mu.RLock()
-		enabled := w.enabled
-		w.mu.RUnlock()
-
-		if !enabled {
+		if ipWhitelistExempt[r.URL.Path] || w.Allows(extractIP(r)) {
 			next.ServeHTTP(rw, r)
 			return
 		}
+		http.Error(rw, `{"error":"IP not whitelisted"}`, http.StatusForbidden)
+	})
+}
 
-		clientIP := extractIP(r)
-		w.mu.RLock()
-		allowed := w.allowed[clientIP]
-		w.mu.RUnlock()
-
-		if !allowed {
-			http.Error(rw, `{"error":"IP not whitelisted"}`, http.StatusForbidden)
-			return
+// ipAllowed reports whether the client of r falls in prefixes. A nil list
+// allows every address; an empty non-nil list allows none.
+func ipAllowed(prefixes []netip.Prefix, r *http.Request) bool {
+	if prefixes == nil {
+		return true
+	}
+	addr, err := netip.ParseAddr(extractIP(r))
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
 		}
-
-		next.ServeHTTP(rw, r)
-	})
+	}
+	return false
 }
 
 func extractIP(r *http.Request) string {
-	// Use RemoteAddr directly. chi's RealIP middleware (if configured)
-	// already sets RemoteAddr from trusted proxy headers. Parsing
+	// Use RemoteAddr directly. RealIP (if configured) already sets
+	// RemoteAddr from the headers of trusted proxies. Parsing
 	// X-Forwarded-For here would let clients bypass the whitelist by
 	// spoofing the header.
 	host, _, err := net.SplitHostPort(r.RemoteAddr)
@@ -81,3 +81,59 @@ func TestExtractIPNoPort(t *testing.T) {
 		t.Fatalf("got %q", ip)
 	}
 }
+
+func TestIPWhitelistCIDR(t *testing.T) {
+	wl := NewIPWhitelist([]string{"10.0.0.0/8", "2001:db8::/32"})
+	for ip, want := range map[string]bool{
+		"10.1.2.3":           true,
+		"::ffff:10.1.2.3":    true,
+		"11.0.0.1":           false,
+		"2001:db8::1":        true,
+		"2001:db9::1":        false,
+		"not-an-ip":          false,
+		"192.168.0.1:1234:x": false,
+	} {
+		if got := wl.Allows(ip); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", ip, got, want)
+		}
+	}
+	if err := wl.Add("bad/99"); err == nil {
+		t.Error("expected error for malformed entry")
+	}
+}
+
+func TestIPWhitelistReplaceKeepsConfigEntries(t *testing.T) {
+	wl := NewIPWhitelist([]string{"10.0.0.1"})
+	if err := wl.Replace([]string{"192.168.0.0/16", "garbage"}); err == nil {
+		t.Error("expected error for malformed entry")
+	}
+	if !wl.Allows("10.0.0.1") || !wl.Allows("192.168.3.4") {
+		t.Fatalf("list: %v", wl.List())
+	}
+
+	wl.Replace(nil)
+	if !wl.Allows("10.0.0.1") || wl.Allows("192.168.3.4") {
+		t.Fatalf("list: %v", wl.List())
+	}
+	wl.Remove("10.0.0.1")
+	if !wl.enabled {
+		t.Fatal("config entries cannot be removed")
+	}
+}
+
+func TestIPWhitelistHealthProbesExempt(t *testing.T) {
+	wl := NewIPWhitelist([]string{"10.0.0.1"})
+	handler := wl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(200)
+	}))
+
+	for path, want := range map[string]int{"/health/liveness": 200, "/health": 403} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "192.168.1.1:5678"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("%s: code %d, want %d", path, rr.Code, want)
+		}
+	}
+}
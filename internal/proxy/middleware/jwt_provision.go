@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+)
+
+// ProvisionStore is the subset of db.Queries used by JWTProvisioner.
+type ProvisionStore interface {
+	GetUser(ctx context.Context, userID string) (db.UserTable, error)
+	CreateUser(ctx context.Context, arg db.CreateUserParams) (db.UserTable, error)
+	GetTeam(ctx context.Context, teamID string) (db.TeamTable, error)
+	CreateTeam(ctx context.Context, arg db.CreateTeamParams) (db.TeamTable, error)
+}
+
+var _ ProvisionStore = (*db.Queries)(nil)
+
+// JWTProvisioner creates user and team rows for JWT callers the database
+// has not seen, so their budgets, rate limits and spend are tracked like
+// those of virtual-key callers. IDs already known are remembered and not
+// looked up again.
+type JWTProvisioner struct {
+	Store ProvisionStore
+	Users bool // create unknown users
+	Teams bool // create unknown teams
+
+	seen sync.Map // "user:<id>" / "team:<id>" → struct{}
+}
+
+// NewJWTProvisioner creates a provisioner that creates unknown users and/or
+// teams in store.
+func NewJWTProvisioner(store ProvisionStore, users, teams bool) *JWTProvisioner {
+	return &JWTProvisioner{Store: store, Users: users, Teams: teams}
+}
+
+// Provision ensures the caller's user and team rows exist.
+func (p *JWTProvisioner) Provision(ctx context.Context, claims *auth.JWTClaims, role auth.Role) error {
+	if p.Teams && claims.TeamID != "" {
+		if err := p.ensure("team:"+claims.TeamID, func() error {
+			_, err := p.Store.GetTeam(ctx, claims.TeamID)
+			return err
+		}, func() error {
+			var members []string
+			if claims.UserID != "" {
+				members = []string{claims.UserID}
+			}
+			var org *string
+			if claims.OrgID != "" {
+				org = &claims.OrgID
+			}
+			_, err := p.Store.CreateTeam(ctx, db.CreateTeamParams{
+				TeamID:         claims.TeamID,
+				TeamAlias:      &claims.TeamID,
+				OrganizationID: org,
+				Members:        members,
+				CreatedBy:      "jwt",
+			})
+			return err
+		}); err != nil {
+			return fmt.Errorf("provision team %s: %w", claims.TeamID, err)
+		}
+	}
+
+	if p.Users && claims.UserID != "" {
+		if err := p.ensure("user:"+claims.UserID, func() error {
+			_, err := p.Store.GetUser(ctx, claims.UserID)
+			return err
+		}, func() error {
+			var email *string
+			if claims.Email != "" {
+				email = &claims.Email
+			}
+			var teams []string
+			if claims.TeamID != "" {
+				teams = []string{claims.TeamID}
+			}
+			_, err := p.Store.CreateUser(ctx, db.CreateUserParams{
+				UserID:    claims.UserID,
+				UserEmail: email,
+				UserRole:  string(role),
+				Teams:     teams,
+				CreatedBy: "jwt",
+			})
+			return err
+		}); err != nil {
+			return fmt.Errorf("provision user %s: %w", claims.UserID, err)
+		}
+	}
+	return nil
+}
+
+// ensure runs create when get reports no row, unless key was already seen.
+// A failed create is retried with get, as another replica may have won.
+func (p *JWTProvisioner) ensure(key string, get, create func() error) error {
+	if _, ok := p.seen.Load(key); ok {
+		return nil
+	}
+	err := get()
+	if errors.Is(err, pgx.ErrNoRows) {
+		if err = create(); err != nil && get() == nil {
+			err = nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	p.seen.Store(key, struct{}{})
+	return nil
+}
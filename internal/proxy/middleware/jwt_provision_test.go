@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+)
+
+// provisionStore is an in-memory ProvisionStore.
+type provisionStore struct {
+	mu     sync.Mutex
+	users  map[string]db.CreateUserParams
+	teams  map[string]db.CreateTeamParams
+	lookup int
+}
+
+func newProvisionStore() *provisionStore {
+	return &provisionStore{users: map[string]db.CreateUserParams{}, teams: map[string]db.CreateTeamParams{}}
+}
+
+func (s *provisionStore) GetUser(_ context.Context, id string) (db.UserTable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookup++
+	if _, ok := s.users[id]; !ok {
+		return db.UserTable{}, pgx.ErrNoRows
+	}
+	return db.UserTable{UserID: id}, nil
+}
+
+func (s *provisionStore) CreateUser(_ context.Context, arg db.CreateUserParams) (db.UserTable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[arg.UserID] = arg
+	return db.UserTable{UserID: arg.UserID}, nil
+}
+
+func (s *provisionStore) GetTeam(_ context.Context, id string) (db.TeamTable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lookup++
+	if _, ok := s.teams[id]; !ok {
+		return db.TeamTable{}, pgx.ErrNoRows
+	}
+	return db.TeamTable{TeamID: id}, nil
+}
+
+func (s *provisionStore) CreateTeam(_ context.Context, arg db.CreateTeamParams) (db.TeamTable, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.teams[arg.TeamID] = arg
+	return db.TeamTable{TeamID: arg.TeamID}, nil
+}
+
+func TestJWTAuth_ProvisionsUserAndTeam(t *testing.T) {
+	secret := []byte("super-secret-key-at-least-32-bytes!")
+	v := auth.NewJWTValidator(auth.JWTConfig{Claims: auth.ClaimFields{TeamID: "groups"}})
+	v.SetKeys(map[string]any{"k1": secret})
+	rbac := auth.NewRBACEngine()
+	rbac.SetRolePermissions(map[auth.Role]auth.RolePermission{auth.RoleTeam: {Models: []string{"gpt-4o"}}})
+	store := newProvisionStore()
+
+	var gotCtx context.Context
+	mw := NewAuthMiddleware(AuthConfig{
+		EnableJWTAuth:  true,
+		JWTValidator:   v,
+		RBACEngine:     rbac,
+		JWTProvisioner: NewJWTProvisioner(store, true, true),
+	})
+	handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotCtx = r.Context()
+	}))
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":    "alice",
+		"email":  "alice@example.com",
+		"groups": []string{"team-a"},
+		"exp":    time.Now().Add(time.Hour).Unix(),
+	})
+	tok.Header["kid"] = "k1"
+	signed, err := tok.SignedString(secret)
+	require.NoError(t, err)
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req.Header.Set("Authorization", "Bearer "+signed)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	require.Contains(t, store.users, "alice")
+	assert.Equal(t, string(auth.RoleTeam), store.users["alice"].UserRole)
+	assert.Equal(t, []string{"team-a"}, store.users["alice"].Teams)
+	require.Contains(t, store.teams, "team-a")
+	assert.Equal(t, []string{"alice"}, store.teams["team-a"].Members)
+	assert.Equal(t, 2, store.lookup, "known IDs are not looked up again")
+
+	assert.Equal(t, "alice", gotCtx.Value(ContextKeyUserID))
+	assert.Equal(t, "team-a", gotCtx.Value(ContextKeyTeamID))
+	access, _ := gotCtx.Value(ContextKeyAllowedModels).(*ModelAccess)
+	require.NotNil(t, access)
+	_, err = access.Resolve("gpt-4o-mini")
+	assert.ErrorIs(t, err, ErrModelNotAllowed)
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+)
+
+// ErrModelNotAllowed is returned by AuthorizeModel when the authenticated
+// key, or its team, may not call the requested model.
+var ErrModelNotAllowed = errors.New("model not allowed")
+
+// allProxyModels in a model list lifts the restriction, as in LiteLLM.
+const allProxyModels = "all-proxy-models"
+
+// ModelAccess is the model policy of an authenticated virtual key.
+type ModelAccess struct {
+	// Models are the key's models plus those of its access groups.
+	// Empty allows every model.
+	Models []string
+	// TeamModels are the models of the key's team. Empty allows every model.
+	TeamModels []string
+	// Aliases maps model names the key may request to the model used.
+	Aliases map[string]string
+}
+
+// Resolve applies the key's aliases to model and checks the result against
+// the key's and team's model lists. A model is allowed when either the
+// requested name or its alias target is listed.
+func (a *ModelAccess) Resolve(model string) (string, error) {
+	if a == nil {
+		return model, nil
+	}
+	resolved := model
+	if target, ok := a.Aliases[model]; ok && target != "" {
+		resolved = target
+	}
+	if !modelListAllows(a.Models, model, resolved) {
+		return "", fmt.Errorf("%w: key cannot call model %q", ErrModelNotAllowed, model)
+	}
+	if !modelListAllows(a.TeamModels, model, resolved) {
+		return "", fmt.Errorf("%w: team cannot call model %q", ErrModelNotAllowed, model)
+	}
+	return resolved, nil
+}
+
+func modelListAllows(allowed []string, names ...string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, m := range allowed {
+		if m == allProxyModels || m == "*" {
+			return true
+		}
+	}
+	for _, name := range names {
+		if auth.CheckModelAccess(allowed, name) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizeModel applies the authenticated key's model aliases to model and
+// enforces its model allow-lists. Requests without a model policy (master
+// key, JWT roles without configured models) are unrestricted.
+func AuthorizeModel(ctx context.Context, model string) (string, error) {
+	access, _ := ctx.Value(ContextKeyAllowedModels).(*ModelAccess)
+	return access.Resolve(model)
+}
+
+// llmAPIRoutes are the routes matched by the "llm_api_routes" group in a
+// key's allowed_routes.
+var llmAPIRoutes = []string{
+	"/v1/",
+	"/chat/completions",
+	"/completions",
+	"/embeddings",
+	"/models",
+	"/moderations",
+	"/rerank",
+	"/images/",
+	"/audio/",
+	"/responses",
+}
+
+// routeAllowed reports whether path matches a key's allowed_routes. Entries
+// are exact paths, prefixes ending in "*", or the "llm_api_routes" group.
+// An empty list allows every route.
+func routeAllowed(allowed []string, path string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, route := range allowed {
+		switch {
+		case route == "llm_api_routes":
+			for _, prefix := range llmAPIRoutes {
+				if path == prefix || strings.HasPrefix(path, prefix) {
+					return true
+				}
+			}
+		case strings.HasSuffix(route, "*"):
+			if strings.HasPrefix(path, strings.TrimSuffix(route, "*")) {
+				return true
+			}
+		case route == path:
+			return true
+		}
+	}
+	return false
+}
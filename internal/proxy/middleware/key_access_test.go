@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelAccess_Resolve(t *testing.T) {
+	access := &ModelAccess{
+		Models:     []string{"gpt-4o", "fast", "anthropic/*"},
+		TeamModels: []string{"gpt-4o", "gpt-4o-mini", "anthropic/claude-3"},
+		Aliases:    map[string]string{"fast": "gpt-4o-mini"},
+	}
+
+	model, err := access.Resolve("fast")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o-mini", model)
+
+	model, err = access.Resolve("anthropic/claude-3")
+	require.NoError(t, err)
+	assert.Equal(t, "anthropic/claude-3", model)
+
+	_, err = access.Resolve("gpt-4o-mini")
+	assert.ErrorIs(t, err, ErrModelNotAllowed, "not in the key's models")
+
+	_, err = access.Resolve("anthropic/claude-opus")
+	assert.ErrorIs(t, err, ErrModelNotAllowed, "not in the team's models")
+}
+
+func TestModelAccess_AllProxyModels(t *testing.T) {
+	access := &ModelAccess{Models: []string{"all-proxy-models"}, TeamModels: []string{"gpt-4o"}}
+	_, err := access.Resolve("gpt-4o")
+	assert.NoError(t, err)
+	_, err = access.Resolve("claude-3")
+	assert.ErrorIs(t, err, ErrModelNotAllowed)
+}
+
+func TestAuthorizeModel_UnrestrictedWithoutPolicy(t *testing.T) {
+	model, err := AuthorizeModel(context.Background(), "anything")
+	require.NoError(t, err)
+	assert.Equal(t, "anything", model)
+}
@@ -0,0 +1,403 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+// RateLimitStore reads the RPM and TPM limits of keys, users and teams.
+// Satisfied by *db.Queries.
+type RateLimitStore interface {
+	GetVerificationToken(ctx context.Context, token string) (db.VerificationToken, error)
+	GetUser(ctx context.Context, userID string) (db.UserTable, error)
+	GetTeam(ctx context.Context, teamID string) (db.TeamTable, error)
+	GetBudget(ctx context.Context, budgetID string) (db.BudgetTable, error)
+}
+
+var _ RateLimitStore = (*db.Queries)(nil)
+
+// RateLimitExceededError reports the level whose requests or tokens per
+// minute limit a request would exceed. It matches model.ErrRateLimit.
+type RateLimitExceededError struct {
+	Level  BudgetLevel
+	ID     string
+	Model  string // set for per-model key limits
+	Tokens bool   // the TPM rather than the RPM limit
+	Limit  int64
+	Reset  time.Duration
+}
+
+func (e *RateLimitExceededError) Error() string {
+	unit := "requests"
+	if e.Tokens {
+		unit = "tokens"
+	}
+	if e.Model != "" {
+		return fmt.Sprintf("rate limit exceeded for model %q on key %q: %d %s per minute", e.Model, e.ID, e.Limit, unit)
+	}
+	return fmt.Sprintf("rate limit exceeded for %s %q: %d %s per minute", e.Level, e.ID, e.Limit, unit)
+}
+
+func (e *RateLimitExceededError) Unwrap() error {
+	return model.ErrRateLimit
+}
+
+// rateLimit is the RPM and TPM limit of one level; 0 means unlimited.
+type rateLimit struct {
+	level   BudgetLevel
+	id      string // named in errors
+	model   string
+	counter string // suffix of the Redis keys counting the level
+	rpm     int64
+	tpm     int64
+}
+
+// RateLimitEnforcer enforces the requests and tokens per minute limits of a
+// request's key (overall and per model), user and team. A delegated key's
+// requests also count against the limits of every parent key above it, so
+// children share their parent's. A level's limits are
+// its own rpm_limit and tpm_limit, or those of its linked BudgetTable row.
+// Per-model key limits are the "model_rpm_limit" and "model_tpm_limit" maps
+// of the key's metadata, keyed by requested model.
+type RateLimitEnforcer struct {
+	Limiter *RateLimiter
+	DB      RateLimitStore
+}
+
+// NewRateLimitEnforcer creates a RateLimitEnforcer counting in limiter and
+// reading limits from store.
+func NewRateLimitEnforcer(limiter *RateLimiter, store RateLimitStore) *RateLimitEnforcer {
+	return &RateLimitEnforcer{Limiter: limiter, DB: store}
+}
+
+// Reserve counts a request against every limit of s and reserves tokens,
+// an estimate of its usage, against every TPM limit. It returns a
+// *RateLimitExceededError, after undoing what it counted, when a limit has
+// no room, and ErrDBUnavailable when limits cannot be read. The returned
+// reservation is nil when no limit applies.
+func (e *RateLimitEnforcer) Reserve(ctx context.Context, s BudgetScope, tokens int64) (*RateLimitReservation, error) {
+	limits, err := e.limits(ctx, s)
+	if err != nil || len(limits) == 0 {
+		return nil, err
+	}
+
+	res := &RateLimitReservation{
+		limiter: e.Limiter,
+		id:      uuid.NewString(),
+		tokens:  tokens,
+	}
+	for _, l := range limits {
+		if err := res.add(ctx, l); err != nil {
+			res.undo(ctx)
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (e *RateLimitEnforcer) limits(ctx context.Context, s BudgetScope) ([]rateLimit, error) {
+	var limits []rateLimit
+	add := func(l rateLimit) {
+		if l.rpm > 0 || l.tpm > 0 {
+			limits = append(limits, l)
+		}
+	}
+
+	// A delegated key also counts against every parent key above it.
+	seen := make(map[string]bool)
+	for hash := s.TokenHash; hash != "" && !seen[hash]; {
+		seen[hash] = true
+		vt, err := e.DB.GetVerificationToken(ctx, hash)
+		if err != nil && lookupErr(err) != nil {
+			return nil, ErrDBUnavailable
+		}
+		if err != nil {
+			break
+		}
+		linked, err := linkedBudget(ctx, e.DB, vt.BudgetID)
+		if err != nil {
+			return nil, err
+		}
+		keyID := keyName(hash, vt.KeyAlias)
+		add(rateLimit{
+			level:   BudgetLevelKey,
+			id:      keyID,
+			counter: "key:" + hash,
+			rpm:     limitOf(vt.RpmLimit, linked.RpmLimit),
+			tpm:     limitOf(vt.TpmLimit, linked.TpmLimit),
+		})
+		if s.Model != "" {
+			rpm, tpm := modelRateLimits(vt.Metadata, s.Model)
+			add(rateLimit{
+				level:   BudgetLevelKeyModel,
+				id:      keyID,
+				model:   s.Model,
+				counter: "key_model:" + hash + ":" + s.Model,
+				rpm:     rpm,
+				tpm:     tpm,
+			})
+		}
+		hash = ""
+		if vt.ParentToken != nil {
+			hash = *vt.ParentToken
+		}
+	}
+
+	if s.UserID != "" {
+		u, err := e.DB.GetUser(ctx, s.UserID)
+		if err != nil && lookupErr(err) != nil {
+			return nil, ErrDBUnavailable
+		}
+		if err == nil {
+			linked, err := linkedBudget(ctx, e.DB, u.BudgetID)
+			if err != nil {
+				return nil, err
+			}
+			add(rateLimit{
+				level:   BudgetLevelUser,
+				id:      s.UserID,
+				counter: "user:" + s.UserID,
+				rpm:     limitOf(u.RpmLimit, linked.RpmLimit),
+				tpm:     limitOf(u.TpmLimit, linked.TpmLimit),
+			})
+		}
+	}
+
+	if s.TeamID != "" {
+		t, err := e.DB.GetTeam(ctx, s.TeamID)
+		if err != nil && lookupErr(err) != nil {
+			return nil, ErrDBUnavailable
+		}
+		if err == nil {
+			linked, err := linkedBudget(ctx, e.DB, t.BudgetID)
+			if err != nil {
+				return nil, err
+			}
+			add(rateLimit{
+				level:   BudgetLevelTeam,
+				id:      s.TeamID,
+				counter: "team:" + s.TeamID,
+				rpm:     limitOf(t.RpmLimit, linked.RpmLimit),
+				tpm:     limitOf(t.TpmLimit, linked.TpmLimit),
+			})
+		}
+	}
+	return limits, nil
+}
+
+func limitOf(own, linked *int64) int64 {
+	if own != nil {
+		return *own
+	}
+	if linked != nil {
+		return *linked
+	}
+	return 0
+}
+
+// modelRateLimits reads a key's per-model limits from its metadata.
+func modelRateLimits(metadata []byte, modelName string) (rpm, tpm int64) {
+	var m struct {
+		RPM map[string]int64 `json:"model_rpm_limit"`
+		TPM map[string]int64 `json:"model_tpm_limit"`
+	}
+	if len(metadata) > 0 {
+		_ = json.Unmarshal(metadata, &m)
+	}
+	return m.RPM[modelName], m.TPM[modelName]
+}
+
+// RateLimitReservation is what a request counted against its rate limits.
+type RateLimitReservation struct {
+	limiter *RateLimiter
+	id      string
+	tokens  int64 // reserved per TPM counter
+
+	requestKeys []string
+	tokenKeys   []string
+	result      CheckResult
+}
+
+func (r *RateLimitReservation) add(ctx context.Context, l rateLimit) error {
+	if l.rpm > 0 {
+		key := "tianji:ratelimit:rpm:" + l.counter
+		usage, err := r.limiter.AcquireRequest(ctx, key, r.id, l.rpm)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msg("rate limit check failed, allowing request")
+		} else if !usage.Allowed {
+			return &RateLimitExceededError{Level: l.level, ID: l.id, Model: l.model, Limit: l.rpm, Reset: usage.Reset}
+		} else {
+			r.requestKeys = append(r.requestKeys, key)
+		}
+		remaining := max(l.rpm-usage.Used, 0)
+		if r.result.RPMLimit == 0 || remaining < r.result.RPMRemaining {
+			r.result.RPMLimit, r.result.EffectiveRPM, r.result.RPMRemaining = l.rpm, l.rpm, remaining
+			r.result.ResetSeconds = int(usage.Reset.Seconds())
+		}
+	}
+	if l.tpm > 0 {
+		key := "tianji:ratelimit:tpm:" + l.counter
+		usage, err := r.limiter.ReserveTokens(ctx, key, r.tokens, l.tpm)
+		if err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msg("rate limit check failed, allowing request")
+		} else if !usage.Allowed {
+			return &RateLimitExceededError{Level: l.level, ID: l.id, Model: l.model, Tokens: true, Limit: l.tpm, Reset: usage.Reset}
+		} else {
+			r.tokenKeys = append(r.tokenKeys, key)
+		}
+		remaining := max(l.tpm-usage.Used, 0)
+		if r.result.TPMLimit == 0 || remaining < r.result.TPMRemaining {
+			r.result.TPMLimit, r.result.EffectiveTPM, r.result.TPMRemaining = l.tpm, l.tpm, remaining
+			r.result.TPMResetSeconds = int(usage.Reset.Seconds())
+		}
+	}
+	return nil
+}
+
+// undo removes the request and its tokens from every counter.
+func (r *RateLimitReservation) undo(ctx context.Context) {
+	for _, key := range r.requestKeys {
+		if err := r.limiter.ReleaseRequest(ctx, key, r.id); err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msg("failed to release rate limit")
+		}
+	}
+	r.Release(ctx)
+}
+
+// Result returns the most constraining RPM and TPM limit, for headers.
+func (r *RateLimitReservation) Result() CheckResult {
+	res := r.result
+	res.Allowed = true
+	if res.ResetSeconds == 0 {
+		res.ResetSeconds = int(rateWindow.Seconds())
+	}
+	return res
+}
+
+// Settle replaces the estimated tokens with the tokens the call used.
+func (r *RateLimitReservation) Settle(ctx context.Context, used int64) {
+	r.adjust(ctx, used-r.tokens)
+}
+
+// Release returns the reserved tokens, for calls that failed. The request
+// itself still counts towards RPM limits.
+func (r *RateLimitReservation) Release(ctx context.Context) {
+	r.adjust(ctx, -r.tokens)
+}
+
+func (r *RateLimitReservation) adjust(ctx context.Context, delta int64) {
+	for _, key := range r.tokenKeys {
+		if err := r.limiter.AdjustTokens(ctx, key, delta); err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Msg("failed to settle token rate limit")
+		}
+	}
+}
+
+// usageKey holds the *usageRecorder of a rate limited request.
+var usageKey contextKey = "token_usage"
+
+type usageRecorder struct {
+	tokens   atomic.Int64
+	recorded atomic.Bool
+}
+
+// RecordUsage reports the total tokens a call used, so the rate limit
+// middleware can settle the request's TPM reservation. Handlers call it
+// once usage is known; it is a no-op outside rate limited requests.
+func RecordUsage(ctx context.Context, totalTokens int) {
+	u, _ := ctx.Value(usageKey).(*usageRecorder)
+	if u == nil {
+		return
+	}
+	u.tokens.Add(int64(totalTokens))
+	u.recorded.Store(true)
+}
+
+// estimateTokens estimates the tokens a request will use: about four bytes
+// of body per prompt token, plus the completion tokens it allows.
+func estimateTokens(f requestFields) int64 {
+	return f.Size/4 + f.MaxTokens
+}
+
+// NewRateLimitMiddleware returns middleware that enforces the RPM and TPM
+// limits of the request's key, user and team, and of the key for the
+// requested model. Tokens are reserved from an estimate and settled with
+// the usage reported through RecordUsage once the handler returns; failed
+// calls that report no usage give their reservation back. Responses carry
+// x-ratelimit-* headers for the most constraining limit. Only POST requests
+// are limited; master key requests are not.
+func NewRateLimitMiddleware(enforcer *RateLimitEnforcer) func(http.Handler) http.Handler {
+	if enforcer == nil {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isMaster, _ := r.Context().Value(ContextKeyIsMasterKey).(bool); isMaster || r.Method != http.MethodPost {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r = withRequestScope(r)
+			r, fields := peekRequest(r)
+			ctx := r.Context()
+
+			res, err := enforcer.Reserve(ctx, BudgetScopeFromContext(ctx), estimateTokens(fields))
+			var exceeded *RateLimitExceededError
+			switch {
+			case errors.As(err, &exceeded):
+				w.Header().Set("Retry-After", strconv.Itoa(max(int(exceeded.Reset.Seconds()), 1)))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusTooManyRequests)
+				writeJSONResponse(w, model.ErrorResponse{
+					Error: model.ErrorDetail{
+						Message: exceeded.Error(),
+						Type:    "rate_limit_exceeded",
+						Code:    "rate_limit_exceeded",
+					},
+				})
+				return
+			case err != nil:
+				zerolog.Ctx(ctx).Error().Err(err).Msg("rate limit check failed")
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				writeJSONResponse(w, model.ErrorResponse{
+					Error: model.ErrorDetail{
+						Message: "unable to verify rate limits",
+						Type:    "internal_error",
+					},
+				})
+				return
+			case res == nil:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			setRateLimitHeaders(w, res.Result())
+			usage := &usageRecorder{}
+			ww := chiMiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(ctx, usageKey, usage)))
+
+			settleCtx := context.WithoutCancel(ctx)
+			switch {
+			case usage.recorded.Load():
+				res.Settle(settleCtx, usage.tokens.Load())
+			case ww.Status() >= http.StatusBadRequest:
+				res.Release(settleCtx)
+			}
+		})
+	}
+}
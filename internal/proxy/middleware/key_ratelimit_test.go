@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+func TestRateLimitEnforcer_Levels(t *testing.T) {
+	store := &fakeBudgetStore{
+		keys: map[string]db.VerificationToken{"hash": {
+			RpmLimit: ptr(int64(5)),
+			KeyAlias: ptr("ci-key"),
+			Metadata: []byte(`{"model_rpm_limit":{"gpt-4o":1}}`),
+		}},
+		users:   map[string]db.UserTable{"u1": {BudgetID: ptr("b1")}},
+		teams:   map[string]db.TeamTable{"t1": {RpmLimit: ptr(int64(2))}},
+		budgets: map[string]db.BudgetTable{"b1": {TpmLimit: ptr(int64(100))}},
+	}
+	limiter := NewRateLimiter(nil)
+	e := NewRateLimitEnforcer(limiter, store)
+	ctx := context.Background()
+
+	scope := BudgetScope{TokenHash: "hash", UserID: "u1", TeamID: "t1", Model: "gpt-4o"}
+	res, err := e.Reserve(ctx, scope, 10)
+	require.NoError(t, err)
+	require.NotNil(t, res)
+	result := res.Result()
+	assert.Equal(t, int64(1), result.RPMLimit, "most constraining RPM limit")
+	assert.Equal(t, int64(0), result.RPMRemaining)
+	assert.Equal(t, int64(100), result.TPMLimit, "user limit from linked budget")
+	assert.Equal(t, int64(90), result.TPMRemaining)
+
+	_, err = e.Reserve(ctx, scope, 10)
+	var exceeded *RateLimitExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, BudgetLevelKeyModel, exceeded.Level)
+	assert.Equal(t, "ci-key", exceeded.ID)
+	assert.ErrorIs(t, err, model.ErrRateLimit)
+
+	// Another model is not held back by the per-model limit, but the team's.
+	scope.Model = "gpt-4o-mini"
+	_, err = e.Reserve(ctx, scope, 10)
+	require.NoError(t, err)
+	_, err = e.Reserve(ctx, scope, 10)
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, BudgetLevelTeam, exceeded.Level)
+
+	// The rejected request was taken off the levels checked before the team.
+	u, _ := limiter.AcquireRequest(ctx, "tianji:ratelimit:rpm:key:hash", "probe", 5)
+	assert.Equal(t, int64(3), u.Used)
+	u, _ = limiter.ReserveTokens(ctx, "tianji:ratelimit:tpm:user:u1", 0, 100)
+	assert.Equal(t, int64(20), u.Used)
+
+	res, err = e.Reserve(ctx, BudgetScope{TokenHash: "unknown"}, 10)
+	require.NoError(t, err)
+	assert.Nil(t, res, "no limits apply")
+
+	store.err = errors.New("connection refused")
+	_, err = e.Reserve(ctx, scope, 10)
+	assert.ErrorIs(t, err, ErrDBUnavailable)
+}
+
+func TestRateLimitEnforcer_ParentKeys(t *testing.T) {
+	store := &fakeBudgetStore{keys: map[string]db.VerificationToken{
+		"child-a": {RpmLimit: ptr(int64(5)), ParentToken: ptr("parent")},
+		"child-b": {ParentToken: ptr("parent")},
+		"parent":  {RpmLimit: ptr(int64(2)), KeyAlias: ptr("agent"), ParentToken: ptr("child-a")},
+	}}
+	e := NewRateLimitEnforcer(NewRateLimiter(nil), store)
+	ctx := context.Background()
+
+	_, err := e.Reserve(ctx, BudgetScope{TokenHash: "child-a"}, 0)
+	require.NoError(t, err)
+	_, err = e.Reserve(ctx, BudgetScope{TokenHash: "child-b"}, 0)
+	require.NoError(t, err)
+
+	// Both children counted against the parent, whose limit is reached; the
+	// parent cycle back to child-a is walked once.
+	_, err = e.Reserve(ctx, BudgetScope{TokenHash: "child-a"}, 0)
+	var exceeded *RateLimitExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, BudgetLevelKey, exceeded.Level)
+	assert.Equal(t, "agent", exceeded.ID)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	store := &fakeBudgetStore{
+		keys: map[string]db.VerificationToken{"hash": {TpmLimit: ptr(int64(100)), RpmLimit: ptr(int64(10))}},
+	}
+	limiter := NewRateLimiter(nil)
+	status, usage := http.StatusOK, 0
+	handler := NewRateLimitMiddleware(NewRateLimitEnforcer(limiter, store))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if usage > 0 {
+			RecordUsage(r.Context(), usage)
+		}
+		w.WriteHeader(status)
+	}))
+
+	call := func(ctx context.Context) *httptest.ResponseRecorder {
+		// 40 bytes of body and max_tokens 10 are estimated as 20 tokens.
+		body := `{"model":"gpt-4o","max_tokens":10,"x":1}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewBufferString(body)).WithContext(ctx)
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+	tokensUsed := func() int64 {
+		u, _ := limiter.ReserveTokens(context.Background(), "tianji:ratelimit:tpm:key:hash", 0, 1000)
+		return u.Used
+	}
+	keyCtx := context.WithValue(context.Background(), ContextKeyTokenHash, "hash")
+
+	usage = 55
+	w := call(keyCtx)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "10", w.Header().Get("X-RateLimit-Limit-Requests"))
+	assert.Equal(t, "9", w.Header().Get("X-RateLimit-Remaining-Requests"))
+	assert.Equal(t, "100", w.Header().Get("X-RateLimit-Limit-Tokens"))
+	assert.Equal(t, "80", w.Header().Get("X-RateLimit-Remaining-Tokens"))
+	assert.Equal(t, int64(55), tokensUsed(), "settled with actual usage")
+
+	usage, status = 0, http.StatusBadGateway
+	call(keyCtx)
+	assert.Equal(t, int64(55), tokensUsed(), "failed call gives its reservation back")
+
+	usage, status = 30, http.StatusOK
+	call(keyCtx)
+	assert.Equal(t, int64(85), tokensUsed())
+
+	// The estimate no longer fits, but the window is not used up yet.
+	assert.Equal(t, http.StatusOK, call(keyCtx).Code)
+	assert.Equal(t, int64(115), tokensUsed())
+
+	w = call(keyCtx)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "100 tokens per minute")
+
+	masterCtx := context.WithValue(keyCtx, ContextKeyIsMasterKey, true)
+	assert.Equal(t, http.StatusOK, call(masterCtx).Code)
+}
@@ -2,8 +2,8 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/http"
 	"time"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
@@ -34,18 +34,160 @@ redis.call('EXPIRE', key, window)
 return 1
 `
 
-// RateLimiter checks RPM/TPM limits using Redis sliding window.
+// acquireRequestScript admits a request into a millisecond sliding window
+// of at most limit requests. It returns {admitted, requests in window}; the
+// admitted request is stored under ARGV[4] so it can be released.
+const acquireRequestScript = `
+local key = KEYS[1]
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+if count >= limit then
+    return {0, count}
+end
+redis.call('ZADD', key, now, ARGV[4])
+redis.call('PEXPIRE', key, window)
+return {1, count + 1}
+`
+
+// reserveTokensScript adds tokens to a fixed-window counter unless the
+// window is used up. It returns {reserved, tokens in window, ms to reset}.
+const reserveTokensScript = `
+local key = KEYS[1]
+local tokens = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local window = tonumber(ARGV[3])
+
+local current = tonumber(redis.call('GET', key) or '0')
+local ttl = redis.call('PTTL', key)
+if ttl < 0 then
+    ttl = window
+end
+if current >= limit then
+    return {0, current, ttl}
+end
+current = redis.call('INCRBY', key, tokens)
+if redis.call('PTTL', key) < 0 then
+    redis.call('PEXPIRE', key, window)
+end
+return {1, current, ttl}
+`
+
+// adjustTokensScript corrects a token reservation by delta while its window
+// is still open, never going below zero.
+const adjustTokensScript = `
+local key = KEYS[1]
+local delta = tonumber(ARGV[1])
+if redis.call('EXISTS', key) == 0 then
+    return 0
+end
+local current = redis.call('INCRBY', key, delta)
+if current < 0 then
+    redis.call('SET', key, 0, 'KEEPTTL')
+    return 0
+end
+return current
+`
+
+// rateWindow is the window of RPM and TPM limits.
+const rateWindow = time.Minute
+
+// RateLimiter checks RPM/TPM limits using Redis sliding window. Without a
+// Redis client the counters are kept in process memory, which limits each
+// replica separately.
 type RateLimiter struct {
 	rdb    redis.UniversalClient
 	script *redis.Script
+
+	acquire *redis.Script
+	reserve *redis.Script
+	adjust  *redis.Script
+	mem     *memoryCounters
+	now     func() time.Time
 }
 
-// NewRateLimiter creates a rate limiter backed by Redis.
+// NewRateLimiter creates a rate limiter backed by Redis, or by process
+// memory when rdb is nil.
 func NewRateLimiter(rdb redis.UniversalClient) *RateLimiter {
 	return &RateLimiter{
-		rdb:    rdb,
-		script: redis.NewScript(slidingWindowScript),
+		rdb:     rdb,
+		script:  redis.NewScript(slidingWindowScript),
+		acquire: redis.NewScript(acquireRequestScript),
+		reserve: redis.NewScript(reserveTokensScript),
+		adjust:  redis.NewScript(adjustTokensScript),
+		mem:     newMemoryCounters(),
+		now:     time.Now,
+	}
+}
+
+// WindowUsage is the state of a rate limit counter after a request.
+type WindowUsage struct {
+	Allowed bool
+	Used    int64         // requests or tokens counted in the window
+	Reset   time.Duration // until the window frees capacity
+}
+
+// AcquireRequest counts a request against a sliding one-minute window of at
+// most limit requests. An admitted request is identified by id, which
+// ReleaseRequest takes to remove it again.
+func (rl *RateLimiter) AcquireRequest(ctx context.Context, key, id string, limit int64) (WindowUsage, error) {
+	if rl.rdb == nil {
+		return rl.mem.acquire(key, id, limit, rl.now()), nil
+	}
+	now := rl.now().UnixMilli()
+	res, err := rl.acquire.Run(ctx, rl.rdb, []string{key}, limit, rateWindow.Milliseconds(), now, id).Int64Slice()
+	if err != nil || len(res) != 2 {
+		return WindowUsage{Allowed: true, Reset: rateWindow}, fmt.Errorf("acquire request: %w", scriptErr(err))
 	}
+	return WindowUsage{Allowed: res[0] == 1, Used: res[1], Reset: rateWindow}, nil
+}
+
+// ReleaseRequest removes a request admitted by AcquireRequest.
+func (rl *RateLimiter) ReleaseRequest(ctx context.Context, key, id string) error {
+	if rl.rdb == nil {
+		rl.mem.release(key, id)
+		return nil
+	}
+	return rl.rdb.ZRem(ctx, key, id).Err()
+}
+
+// ReserveTokens adds tokens to a one-minute window of at most limit tokens,
+// unless the window is used up. A reservation may take the window past
+// limit, so requests estimated above the limit are not refused outright;
+// the window then stays closed until it resets or the reservation is
+// settled.
+func (rl *RateLimiter) ReserveTokens(ctx context.Context, key string, tokens, limit int64) (WindowUsage, error) {
+	if rl.rdb == nil {
+		return rl.mem.reserve(key, tokens, limit, rl.now()), nil
+	}
+	res, err := rl.reserve.Run(ctx, rl.rdb, []string{key}, tokens, limit, rateWindow.Milliseconds()).Int64Slice()
+	if err != nil || len(res) != 3 {
+		return WindowUsage{Allowed: true, Reset: rateWindow}, fmt.Errorf("reserve tokens: %w", scriptErr(err))
+	}
+	return WindowUsage{Allowed: res[0] == 1, Used: res[1], Reset: time.Duration(res[2]) * time.Millisecond}, nil
+}
+
+// AdjustTokens corrects a reservation made by ReserveTokens by delta tokens.
+// Windows that have already ended are left alone.
+func (rl *RateLimiter) AdjustTokens(ctx context.Context, key string, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+	if rl.rdb == nil {
+		rl.mem.adjust(key, delta, rl.now())
+		return nil
+	}
+	return rl.adjust.Run(ctx, rl.rdb, []string{key}, delta).Err()
+}
+
+func scriptErr(err error) error {
+	if err == nil {
+		return errors.New("unexpected script result")
+	}
+	return err
 }
 
 // CheckRPM checks if the request rate is within the RPM limit.
@@ -110,35 +252,3 @@ func (rl *RateLimiter) check(ctx context.Context, key string, limit int64, windo
 	}
 	return result == 1, nil
 }
-
-// NewRateLimitMiddleware creates middleware that enforces RPM limits.
-func NewRateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
-	if limiter == nil {
-		return func(next http.Handler) http.Handler { return next }
-	}
-
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			tokenHash, _ := r.Context().Value(tokenHashKey).(string)
-			rpmLimit, _ := r.Context().Value(rpmLimitKey).(int64)
-
-			if tokenHash != "" && rpmLimit > 0 {
-				allowed, err := limiter.CheckRPM(r.Context(), tokenHash, rpmLimit)
-				if err == nil && !allowed {
-					w.Header().Set("Content-Type", "application/json")
-					w.WriteHeader(http.StatusTooManyRequests)
-					writeJSONResponse(w, model.ErrorResponse{
-						Error: model.ErrorDetail{
-							Message: "rate limit exceeded",
-							Type:    "rate_limit_exceeded",
-							Code:    "rate_limit_exceeded",
-						},
-					})
-					return
-				}
-			}
-
-			next.ServeHTTP(w, r)
-		})
-	}
-}
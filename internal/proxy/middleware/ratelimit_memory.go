@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+)
+
+// memoryCounters holds the RateLimiter windows when no Redis client is
+// configured. Request windows slide; token windows are fixed, as in Redis.
+type memoryCounters struct {
+	mu       sync.Mutex
+	requests map[string][]admitted
+	tokens   map[string]*tokenWindow
+	sweptAt  time.Time
+}
+
+type admitted struct {
+	id string
+	at time.Time
+}
+
+type tokenWindow struct {
+	used    int64
+	resetAt time.Time
+}
+
+func newMemoryCounters() *memoryCounters {
+	return &memoryCounters{
+		requests: make(map[string][]admitted),
+		tokens:   make(map[string]*tokenWindow),
+	}
+}
+
+func (m *memoryCounters) acquire(key, id string, limit int64, now time.Time) WindowUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+
+	window := m.requests[key]
+	cutoff := now.Add(-rateWindow)
+	live := window[:0]
+	for _, a := range window {
+		if a.at.After(cutoff) {
+			live = append(live, a)
+		}
+	}
+	if int64(len(live)) >= limit {
+		m.requests[key] = live
+		return WindowUsage{Used: int64(len(live)), Reset: rateWindow}
+	}
+	m.requests[key] = append(live, admitted{id: id, at: now})
+	return WindowUsage{Allowed: true, Used: int64(len(live)) + 1, Reset: rateWindow}
+}
+
+func (m *memoryCounters) release(key, id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	window := m.requests[key]
+	for i, a := range window {
+		if a.id == id {
+			m.requests[key] = append(window[:i], window[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *memoryCounters) reserve(key string, tokens, limit int64, now time.Time) WindowUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+
+	tw := m.tokens[key]
+	if tw == nil || !now.Before(tw.resetAt) {
+		tw = &tokenWindow{resetAt: now.Add(rateWindow)}
+		m.tokens[key] = tw
+	}
+	reset := tw.resetAt.Sub(now)
+	if tw.used >= limit {
+		return WindowUsage{Used: tw.used, Reset: reset}
+	}
+	tw.used += tokens
+	return WindowUsage{Allowed: true, Used: tw.used, Reset: reset}
+}
+
+func (m *memoryCounters) adjust(key string, delta int64, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tw := m.tokens[key]
+	if tw == nil || !now.Before(tw.resetAt) {
+		return
+	}
+	tw.used = max(tw.used+delta, 0)
+}
+
+// sweep drops windows that have ended, at most once per window, so keys
+// that stop sending requests do not accumulate. Caller must hold m.mu.
+func (m *memoryCounters) sweep(now time.Time) {
+	if now.Sub(m.sweptAt) < rateWindow {
+		return
+	}
+	m.sweptAt = now
+	cutoff := now.Add(-rateWindow)
+	for key, window := range m.requests {
+		if len(window) == 0 || !window[len(window)-1].at.After(cutoff) {
+			delete(m.requests, key)
+		}
+	}
+	for key, tw := range m.tokens {
+		if !now.Before(tw.resetAt) {
+			delete(m.tokens, key)
+		}
+	}
+}
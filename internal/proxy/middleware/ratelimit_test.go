@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
@@ -93,3 +94,92 @@ func TestTPMUtilization_NoData(t *testing.T) {
 	util := rl.TPMUtilization(ctx, "nonexistent", "gpt-4", 1000)
 	assert.Equal(t, 0.0, util)
 }
+
+// rateLimiterBackends runs a test against Redis and the in-memory fallback.
+func rateLimiterBackends(t *testing.T, test func(t *testing.T, rl *RateLimiter)) {
+	t.Run("redis", func(t *testing.T) {
+		rl, _ := setupRateLimiter(t)
+		test(t, rl)
+	})
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewRateLimiter(nil))
+	})
+}
+
+func TestRateLimiter_AcquireRequest(t *testing.T) {
+	rateLimiterBackends(t, func(t *testing.T, rl *RateLimiter) {
+		ctx := context.Background()
+
+		for i, id := range []string{"a", "b"} {
+			u, err := rl.AcquireRequest(ctx, "rpm:k", id, 2)
+			require.NoError(t, err)
+			assert.True(t, u.Allowed)
+			assert.Equal(t, int64(i+1), u.Used)
+		}
+		u, err := rl.AcquireRequest(ctx, "rpm:k", "c", 2)
+		require.NoError(t, err)
+		assert.False(t, u.Allowed)
+
+		require.NoError(t, rl.ReleaseRequest(ctx, "rpm:k", "b"))
+		u, err = rl.AcquireRequest(ctx, "rpm:k", "c", 2)
+		require.NoError(t, err)
+		assert.True(t, u.Allowed)
+	})
+}
+
+func TestRateLimiter_ReserveTokens(t *testing.T) {
+	rateLimiterBackends(t, func(t *testing.T, rl *RateLimiter) {
+		ctx := context.Background()
+
+		u, err := rl.ReserveTokens(ctx, "tpm:k", 60, 100)
+		require.NoError(t, err)
+		assert.True(t, u.Allowed)
+		assert.Equal(t, int64(60), u.Used)
+		assert.Positive(t, u.Reset)
+
+		u, err = rl.ReserveTokens(ctx, "tpm:k", 60, 100)
+		require.NoError(t, err)
+		assert.True(t, u.Allowed, "window not used up yet")
+		assert.Equal(t, int64(120), u.Used)
+
+		u, err = rl.ReserveTokens(ctx, "tpm:k", 1, 100)
+		require.NoError(t, err)
+		assert.False(t, u.Allowed, "window used up")
+		assert.Equal(t, int64(120), u.Used)
+
+		// Settling with the actual usage frees the unused part.
+		require.NoError(t, rl.AdjustTokens(ctx, "tpm:k", -80))
+		u, err = rl.ReserveTokens(ctx, "tpm:k", 60, 100)
+		require.NoError(t, err)
+		assert.True(t, u.Allowed)
+		assert.Equal(t, int64(100), u.Used)
+
+		// An estimate above the limit is admitted on an empty window.
+		u, err = rl.ReserveTokens(ctx, "tpm:big", 500, 100)
+		require.NoError(t, err)
+		assert.True(t, u.Allowed)
+
+		require.NoError(t, rl.AdjustTokens(ctx, "tpm:k", -500))
+		u, err = rl.ReserveTokens(ctx, "tpm:k", 0, 100)
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), u.Used, "adjustments never go below zero")
+	})
+}
+
+func TestRateLimiter_MemoryWindowExpires(t *testing.T) {
+	rl := NewRateLimiter(nil)
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, _ = rl.AcquireRequest(ctx, "rpm:k", "a", 1)
+	_, _ = rl.ReserveTokens(ctx, "tpm:k", 100, 100)
+
+	now = now.Add(rateWindow + time.Second)
+	u, err := rl.AcquireRequest(ctx, "rpm:k", "b", 1)
+	require.NoError(t, err)
+	assert.True(t, u.Allowed)
+	u, err = rl.ReserveTokens(ctx, "tpm:k", 100, 100)
+	require.NoError(t, err)
+	assert.True(t, u.Allowed)
+}
@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP sets r.RemoteAddr to the client address reported by a trusted
+// reverse proxy. Unlike chi's RealIP, the X-Forwarded-For and X-Real-IP
+// headers are only read when the connection comes from one of trusted, and
+// X-Forwarded-For is walked from the right, skipping trusted hops, so a
+// client cannot spoof its address by sending the header itself. With no
+// trusted proxies the headers are ignored.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(trusted) > 0 {
+				if peer, err := netip.ParseAddr(extractIP(r)); err == nil && isTrusted(peer.Unmap()) {
+					if client := forwardedClient(r, isTrusted); client != "" {
+						r.RemoteAddr = client
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClient returns the rightmost untrusted address in
+// X-Forwarded-For, the leftmost when every hop is trusted, or X-Real-IP
+// without X-Forwarded-For.
+func forwardedClient(r *http.Request, isTrusted func(netip.Addr) bool) string {
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(h, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if len(hops) == 0 {
+		if ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+			return ip.Unmap().String()
+		}
+		return ""
+	}
+
+	var client string
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			// Garbage in the chain was not added by a trusted proxy.
+			break
+		}
+		client = ip.Unmap().String()
+		if !isTrusted(ip.Unmap()) {
+			break
+		}
+	}
+	return client
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	tests := []struct {
+		name    string
+		trusted []netip.Prefix
+		remote  string
+		xff     string
+		xRealIP string
+		want    string
+	}{
+		{"no trusted proxies", nil, "10.0.0.1:1234", "1.2.3.4", "", "10.0.0.1"},
+		{"untrusted peer", trusted, "5.6.7.8:1234", "1.2.3.4", "", "5.6.7.8"},
+		{"trusted peer", trusted, "10.0.0.1:1234", "1.2.3.4", "", "1.2.3.4"},
+		{"spoofed chain", trusted, "10.0.0.1:1234", "9.9.9.9, 1.2.3.4, 10.0.0.2", "", "1.2.3.4"},
+		{"all hops trusted", trusted, "10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "", "10.0.0.3"},
+		{"garbage hop", trusted, "10.0.0.1:1234", "1.2.3.4, junk", "", "10.0.0.1"},
+		{"x-real-ip", trusted, "10.0.0.1:1234", "", "1.2.3.4", "1.2.3.4"},
+		{"no headers", trusted, "10.0.0.1:1234", "", "", "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := RealIP(tt.trusted)(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				got = extractIP(r)
+			}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			if tt.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
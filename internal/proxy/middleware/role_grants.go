@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+)
+
+// DefaultRoleGrantsTTL is how long a user's custom role grants are cached.
+const DefaultRoleGrantsTTL = 30 * time.Second
+
+// GrantSource returns the custom role grants of a user.
+type GrantSource interface {
+	Grants(ctx context.Context, userID string) ([]auth.Grant, error)
+}
+
+// roleGrantQuerier is the subset of db.Queries used by RoleGrants.
+type roleGrantQuerier interface {
+	ListUserRoleGrants(ctx context.Context, userID string) ([]db.ListUserRoleGrantsRow, error)
+}
+
+var _ roleGrantQuerier = (*db.Queries)(nil)
+
+type grantEntry struct {
+	grants  []auth.Grant
+	expires time.Time
+}
+
+// RoleGrants is a GrantSource backed by the role assignments in the
+// database. Each user's grants are cached for TTL; role changes made on
+// this replica call Invalidate, other replicas pick them up within TTL.
+type RoleGrants struct {
+	DB  roleGrantQuerier
+	TTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]grantEntry
+	now     func() time.Time
+}
+
+// NewRoleGrants creates a RoleGrants reading from store. A zero ttl selects
+// DefaultRoleGrantsTTL.
+func NewRoleGrants(store roleGrantQuerier, ttl time.Duration) *RoleGrants {
+	if ttl <= 0 {
+		ttl = DefaultRoleGrantsTTL
+	}
+	return &RoleGrants{DB: store, TTL: ttl, entries: make(map[string]grantEntry), now: time.Now}
+}
+
+// Grants implements GrantSource.
+func (g *RoleGrants) Grants(ctx context.Context, userID string) ([]auth.Grant, error) {
+	now := g.now()
+	g.mu.Lock()
+	entry, ok := g.entries[userID]
+	g.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.grants, nil
+	}
+
+	rows, err := g.DB.ListUserRoleGrants(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	grants := make([]auth.Grant, 0, len(rows))
+	for _, row := range rows {
+		grant := auth.Grant{Role: row.RoleName, Permissions: row.Permissions}
+		if row.OrganizationID != nil {
+			grant.OrgID = *row.OrganizationID
+		}
+		if row.TeamID != nil {
+			grant.TeamID = *row.TeamID
+		}
+		grants = append(grants, grant)
+	}
+
+	g.mu.Lock()
+	g.entries[userID] = grantEntry{grants: grants, expires: now.Add(g.TTL)}
+	g.mu.Unlock()
+	return grants, nil
+}
+
+// Invalidate drops every cached grant. A nil RoleGrants does nothing.
+func (g *RoleGrants) Invalidate() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	clear(g.entries)
+	g.mu.Unlock()
+}
+
+var _ GrantSource = (*RoleGrants)(nil)
@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"net/http"
+	"net/netip"
 	"os"
 
 	"github.com/go-chi/chi/v5"
@@ -9,6 +10,7 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
 	"github.com/praxisllmlab/tianjiLLM/internal/callback"
 	"github.com/praxisllmlab/tianjiLLM/internal/proxy/handler"
 	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
@@ -30,6 +32,8 @@ type Server struct {
 	parallelMW     func(http.Handler) http.Handler
 	dynamicRateMW  func(http.Handler) http.Handler
 	cacheControlMW func(http.Handler) http.Handler
+	budgetMW       func(http.Handler) http.Handler
+	rateLimitMW    func(http.Handler) http.Handler
 }
 
 // UIRouter registers UI routes onto a chi subrouter.
@@ -42,13 +46,20 @@ type ServerConfig struct {
 	Handlers           *handler.Handlers
 	MasterKey          string
 	DBQueries          middleware.TokenValidator
-	AuthErrorLogger    middleware.AuthErrorLogger // optional: records auth failures to ErrorLogs
-	RedisClient        redis.UniversalClient      // optional, enables rate limiting middleware
+	AuthErrorLogger    middleware.AuthErrorLogger    // optional: records auth failures to ErrorLogs
+	RedisClient        redis.UniversalClient         // optional, enables rate limiting middleware
+	BudgetEnforcer     *middleware.BudgetEnforcer    // optional, enforces key/user/team/org/end-user budgets
+	RateLimitEnforcer  *middleware.RateLimitEnforcer // optional, enforces key/user/team RPM and TPM limits
+	SCIMHandler        http.Handler                  // optional SCIM 2.0 server, authenticating its own requests
 	PassthroughHandler http.Handler
 	MCPSSEHandler      http.Handler
 	MCPStreamHandler   http.Handler
 	MCPRESTHandler     http.Handler
 	UIHandler          UIRouter // optional admin dashboard UI
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For and
+	// X-Real-IP headers name the client; the headers of others are ignored.
+	TrustedProxies []netip.Prefix
+	IPWhitelist    *middleware.IPWhitelist // optional, global client allow-list
 }
 
 // NewServer creates a chi router with all routes configured.
@@ -66,11 +77,19 @@ func NewServerWithAuth(cfg ServerConfig, authCfg middleware.AuthConfig) *Server
 	r := chi.NewRouter()
 
 	r.Use(chiMiddleware.RequestID)
-	r.Use(chiMiddleware.RealIP)
+	r.Use(middleware.RealIP(cfg.TrustedProxies))
+	if cfg.IPWhitelist != nil {
+		r.Use(cfg.IPWhitelist.Middleware)
+	}
 	r.Use(chiMiddleware.Recoverer)
 	// Structured logging via zerolog (HO-150)
 	r.Use(middleware.StructuredLogging(zerolog.New(os.Stdout).With().Timestamp().Logger()))
 
+	// Every authenticated route is checked against the role table; routes
+	// it does not list are reserved for proxy admins.
+	if authCfg.RBACEngine == nil {
+		authCfg.RBACEngine = auth.NewRBACEngine()
+	}
 	authMW := middleware.NewAuthMiddleware(authCfg)
 
 	// Build rate limiting middleware (nil-safe: pass-through when no Redis)
@@ -82,6 +101,7 @@ func NewServerWithAuth(cfg ServerConfig, authCfg middleware.AuthConfig) *Server
 		Handlers:           cfg.Handlers,
 		AuthMiddleware:     authMW,
 		PassthroughHandler: cfg.PassthroughHandler,
+		SCIMHandler:        cfg.SCIMHandler,
 		MCPSSEHandler:      cfg.MCPSSEHandler,
 		MCPStreamHandler:   cfg.MCPStreamHandler,
 		MCPRESTHandler:     cfg.MCPRESTHandler,
@@ -89,6 +109,8 @@ func NewServerWithAuth(cfg ServerConfig, authCfg middleware.AuthConfig) *Server
 		parallelMW:         middleware.NewParallelRequestMiddleware(parallelLimiter),
 		dynamicRateMW:      middleware.NewDynamicRateLimitMiddleware(dynamicLimiter),
 		cacheControlMW:     middleware.NewCacheControlMiddleware(),
+		budgetMW:           middleware.NewBudgetMiddleware(cfg.BudgetEnforcer),
+		rateLimitMW:        middleware.NewRateLimitMiddleware(cfg.RateLimitEnforcer),
 	}
 
 	s.setupRoutes()
@@ -113,6 +135,8 @@ func (s *Server) setupRoutes() {
 	// Python LiteLLM registers both; most SDKs use bare paths (no /v1 prefix).
 	llmMiddleware := func(r chi.Router) {
 		r.Use(s.AuthMiddleware)
+		r.Use(s.budgetMW)
+		r.Use(s.rateLimitMW)
 		r.Use(s.parallelMW)
 		r.Use(s.dynamicRateMW)
 		r.Use(s.cacheControlMW)
@@ -458,6 +482,18 @@ func (s *Server) setupRoutes() {
 		r.Post("/delete", s.Handlers.TagDelete)
 	})
 
+	// Custom roles and their assignments
+	r.Route("/role", func(r chi.Router) {
+		r.Use(s.AuthMiddleware)
+		r.Post("/new", s.Handlers.RoleNew)
+		r.Get("/info/{role_name}", s.Handlers.RoleInfo)
+		r.Get("/list", s.Handlers.RoleList)
+		r.Post("/update", s.Handlers.RoleUpdate)
+		r.Post("/delete", s.Handlers.RoleDelete)
+		r.Post("/assign", s.Handlers.RoleAssign)
+		r.Post("/unassign", s.Handlers.RoleUnassign)
+	})
+
 	// End user (customer) management
 	r.Route("/end_user", func(r chi.Router) {
 		r.Use(s.AuthMiddleware)
@@ -560,24 +596,26 @@ func (s *Server) setupRoutes() {
 
 	// Gemini native format (auth required)
 	r.Route("/v1beta/models", func(r chi.Router) {
-		r.Use(s.AuthMiddleware)
+		llmMiddleware(r)
 		r.Post("/{model}:generateContent", s.Handlers.GeminiGenerateContent)
 		r.Post("/{model}:streamGenerateContent", s.Handlers.GeminiStreamGenerateContent)
 		r.Post("/{model}:countTokens", s.Handlers.GeminiCountTokens)
 	})
 
-	// SCIM 2.0 (auth required — IDP provisioning)
+	// SCIM 2.0 (IDP provisioning). The handler checks its own bearer token
+	// and serves paths below /scim.
 	if s.SCIMHandler != nil {
-		r.Route("/scim/v2", func(r chi.Router) {
-			r.Use(s.AuthMiddleware)
-			r.Mount("/", s.SCIMHandler)
-		})
+		r.Mount("/scim/v2", http.StripPrefix("/scim", s.SCIMHandler))
 	}
 
-	// A2A Protocol (agent-to-agent)
+	// A2A Protocol (agent-to-agent). The agent card is public discovery;
+	// messages call the agent's model and require auth.
 	r.Route("/a2a/{id}", func(r chi.Router) {
 		r.Get("/.well-known/agent-card.json", s.Handlers.A2AAgentCard)
-		r.Post("/", s.Handlers.A2AMessage)
+		r.Group(func(r chi.Router) {
+			llmMiddleware(r)
+			r.Post("/", s.Handlers.A2AMessage)
+		})
 	})
 
 	// Discovery (auth required)
@@ -620,7 +658,7 @@ func (s *Server) setupRoutes() {
 
 	// Anthropic batches pass-through
 	r.Route("/anthropic/v1/messages/batches", func(r chi.Router) {
-		r.Use(s.AuthMiddleware)
+		llmMiddleware(r)
 		r.Post("/", s.Handlers.AnthropicBatchesCreate)
 		r.Get("/{id}", s.Handlers.AnthropicBatchesGet)
 		r.Get("/{id}/results", s.Handlers.AnthropicBatchesResults)
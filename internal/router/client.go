@@ -0,0 +1,57 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/praxisllmlab/tianjiLLM/internal/httpclient"
+)
+
+// httpClient returns the upstream client for model config m. Deployments
+// with the same client options share a client and its connection pool.
+// Invalid options (e.g. an unreadable CA file) yield a client that fails
+// every call, so the deployment is cooled down rather than silently
+// called without its TLS or proxy settings. Caller must hold r.mu once r
+// is shared.
+func (r *Router) httpClient(m *config.ModelConfig) *http.Client {
+	opts := r.clientOptions(m)
+	client, err := r.clients.Client(opts)
+	if err != nil {
+		return httpclient.Failing(fmt.Errorf("http client for model %q: %w", m.ModelName, err))
+	}
+	return client
+}
+
+// clientOptions resolves the HTTP client options of model config m. The
+// request timeout comes from tianji_params.timeout, then the model group's
+// retry policy, then RouterSettings.Timeout.
+func (r *Router) clientOptions(m *config.ModelConfig) httpclient.Options {
+	p := m.TianjiParams
+	opts := httpclient.Options{
+		Timeout:           r.settings.Timeout,
+		StreamIdleTimeout: r.settings.StreamTimeout,
+		DisableHTTP2:      p.DisableHTTP2,
+		CACertFile:        p.SSLCACert,
+		ClientCertFile:    p.SSLClientCert,
+		ClientKeyFile:     p.SSLClientKey,
+		ProxyURL:          p.ProxyURL,
+	}
+	if policy, ok := r.settings.ModelGroupRetryPolicy[m.ModelName]; ok && policy.TimeoutSeconds > 0 {
+		opts.Timeout = time.Duration(policy.TimeoutSeconds) * time.Second
+	}
+	if p.Timeout != nil {
+		opts.Timeout = time.Duration(*p.Timeout) * time.Second
+	}
+	if p.StreamTimeout != nil {
+		opts.StreamIdleTimeout = time.Duration(*p.StreamTimeout) * time.Second
+	}
+	if p.MaxIdleConnections != nil {
+		opts.MaxIdleConnsPerHost = *p.MaxIdleConnections
+	}
+	if p.SSLVerify != nil {
+		opts.InsecureSkipVerify = !*p.SSLVerify
+	}
+	return opts
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientOptions_TimeoutPrecedence(t *testing.T) {
+	models := executeModels("gpt-4o", "claude-3", "gemini")
+	deploymentTimeout, streamTimeout, verify := 5, 7, false
+	models[0].TianjiParams.Timeout = &deploymentTimeout
+	models[0].TianjiParams.StreamTimeout = &streamTimeout
+	models[0].TianjiParams.SSLVerify = &verify
+	models[0].TianjiParams.ProxyURL = "http://egress:3128"
+
+	r := New(models, &roundRobinStrategy{}, RouterSettings{
+		Timeout:               30 * time.Second,
+		StreamTimeout:         20 * time.Second,
+		ModelGroupRetryPolicy: map[string]RetryPolicy{"claude-3": {TimeoutSeconds: 10}},
+	})
+
+	opts := r.clientOptions(&models[0])
+	assert.Equal(t, 5*time.Second, opts.Timeout)
+	assert.Equal(t, 7*time.Second, opts.StreamIdleTimeout)
+	assert.True(t, opts.InsecureSkipVerify)
+	assert.Equal(t, "http://egress:3128", opts.ProxyURL)
+
+	assert.Equal(t, 10*time.Second, r.clientOptions(&models[1]).Timeout)
+
+	opts = r.clientOptions(&models[2])
+	assert.Equal(t, 30*time.Second, opts.Timeout)
+	assert.Equal(t, 20*time.Second, opts.StreamIdleTimeout)
+}
+
+func TestDeployment_HTTPClientFollowsReconfigure(t *testing.T) {
+	r := New(executeModels("gpt-4o", "claude-3"), &roundRobinStrategy{}, RouterSettings{})
+	gpt, claude := r.GetDeployments("gpt-4o")[0], r.GetDeployments("claude-3")[0]
+	assert.Same(t, gpt.HTTPClient(), claude.HTTPClient(), "same options share a client")
+
+	before := gpt.HTTPClient()
+	r.Reconfigure(&roundRobinStrategy{}, RouterSettings{Timeout: time.Minute})
+	assert.NotSame(t, before, gpt.HTTPClient())
+}
@@ -1,10 +1,13 @@
 package router
 
 import (
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/praxisllmlab/tianjiLLM/internal/httpclient"
+	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 )
 
 // Deployment represents a single provider deployment with health tracking.
@@ -15,63 +18,275 @@ type Deployment struct {
 	Region       string // geographic region for region-based routing
 	Config       *config.ModelConfig
 
+	// base is set on wildcard clones so health is tracked on the
+	// long-lived deployment instead of the per-request copy.
+	base *Deployment
+
+	// state, when set, mirrors failures and cooldowns to other replicas.
+	state StateStore
+
 	mu            sync.Mutex
 	failures      int
 	successes     int
 	allowedFails  int
 	cooldownTime  time.Duration
 	cooldownUntil time.Time
+	lastFailure   FailureClass
+
+	// client performs upstream calls with the deployment's timeouts,
+	// connection pool, TLS and proxy settings.
+	client *http.Client
+	// provider is built from the deployment's tianji_params when the
+	// deployment is created; providerErr is why it could not be.
+	provider    provider.Provider
+	providerErr error
+
+	// In-flight tracking for max_parallel_requests (0 = unlimited)
+	inflight    int
+	maxParallel int
 
 	// Latency tracking for lowest-latency strategy
 	latencyEMA   time.Duration // exponential moving average
+	ttftEMA      time.Duration // time-to-first-token EMA for streaming calls
 	latencyAlpha float64       // EMA smoothing factor (0.3 by default)
 }
 
+// health returns the deployment that owns the health state for d.
+func (d *Deployment) health() *Deployment {
+	if d.base != nil {
+		return d.base
+	}
+	return d
+}
+
 // IsHealthy returns true if the deployment is not in cooldown.
 func (d *Deployment) IsHealthy() bool {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	return time.Now().After(d.cooldownUntil)
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return time.Now().After(h.cooldownUntil)
 }
 
 // RecordSuccess records a successful call, resetting failure count.
 func (d *Deployment) RecordSuccess(latency time.Duration) {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	h := d.health()
+	h.mu.Lock()
+	h.failures = 0
+	h.successes++
+	h.latencyEMA = h.ema(h.latencyEMA, latency)
+	h.mu.Unlock()
 
-	d.failures = 0
-	d.successes++
+	if h.state != nil {
+		ctx, cancel := StateContext()
+		defer cancel()
+		_ = h.state.ResetFailures(ctx, h.ID)
+	}
+}
 
-	// Update latency EMA
-	alpha := d.latencyAlpha
-	if alpha == 0 {
-		alpha = 0.3
+// recordLatency folds a latency sample into the EMA without counting the
+// call as a success, e.g. for a hedged call cancelled before it answered.
+func (d *Deployment) recordLatency(latency time.Duration) {
+	if d == nil || latency <= 0 {
+		return
 	}
-	if d.latencyEMA == 0 {
-		d.latencyEMA = latency
-	} else {
-		d.latencyEMA = time.Duration(float64(d.latencyEMA)*(1-alpha) + float64(latency)*alpha)
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.latencyEMA = h.ema(h.latencyEMA, latency)
+}
+
+// RecordTTFT records the time to first token of a streaming call.
+func (d *Deployment) RecordTTFT(ttft time.Duration) {
+	if ttft <= 0 {
+		return
 	}
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ttftEMA = h.ema(h.ttftEMA, ttft)
 }
 
 // RecordFailure records a failed call. After exceeding allowed failures,
 // the deployment enters cooldown.
 func (d *Deployment) RecordFailure() {
-	d.mu.Lock()
-	defer d.mu.Unlock()
+	d.RecordFailureClass(FailureServer)
+}
+
+// RecordFailureClass records a classified failed call. Rate limit and auth
+// failures put the deployment into cooldown immediately; timeouts and server
+// errors count toward AllowedFails. Client errors do not affect health.
+func (d *Deployment) RecordFailureClass(class FailureClass) {
+	if class == FailureNone || class == FailureClient {
+		return
+	}
+
+	h := d.health()
+	h.mu.Lock()
+	h.lastFailure = class
+	coolDown := class == FailureRateLimit || class == FailureAuth
+	if !coolDown {
+		h.failures++
+		coolDown = h.failures >= h.allowedFails
+	}
+	if coolDown {
+		h.cooldownUntil = time.Now().Add(h.cooldownTime)
+		h.failures = 0
+	}
+	allowedFails, cooldownTime := h.allowedFails, h.cooldownTime
+	h.mu.Unlock()
+
+	if h.state != nil {
+		h.shareFailure(coolDown, allowedFails, cooldownTime)
+	}
+}
+
+// shareFailure records a failure in the shared state store. Failures seen by
+// all replicas count toward AllowedFails together.
+func (d *Deployment) shareFailure(coolDown bool, allowedFails int, cooldownTime time.Duration) {
+	ctx, cancel := StateContext()
+	defer cancel()
+
+	if !coolDown {
+		n, err := d.state.IncrFailures(ctx, d.ID, cooldownTime)
+		if err != nil || n < int64(allowedFails) {
+			return
+		}
+		d.coolDownUntil(time.Now().Add(cooldownTime))
+	}
+	_ = d.state.SetCooldown(ctx, d.ID, cooldownTime)
+}
+
+// coolDownUntil extends the deployment's cooldown to at least until.
+func (d *Deployment) coolDownUntil(until time.Time) {
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if until.After(h.cooldownUntil) {
+		h.cooldownUntil = until
+		h.failures = 0
+	}
+}
 
-	d.failures++
-	if d.failures >= d.allowedFails {
-		d.cooldownUntil = time.Now().Add(d.cooldownTime)
-		d.failures = 0
+// tryAcquire reserves an in-flight slot, failing when the deployment is at
+// its max_parallel_requests cap.
+func (d *Deployment) tryAcquire() bool {
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.maxParallel > 0 && h.inflight >= h.maxParallel {
+		return false
 	}
+	h.inflight++
+	return true
+}
+
+// release frees a slot reserved by tryAcquire.
+func (d *Deployment) release() {
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.inflight > 0 {
+		h.inflight--
+	}
+}
+
+// Inflight returns the number of requests currently in flight on this replica.
+func (d *Deployment) Inflight() int {
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.inflight
+}
+
+// AtCapacity reports whether the deployment has reached max_parallel_requests.
+func (d *Deployment) AtCapacity() bool {
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.maxParallel > 0 && h.inflight >= h.maxParallel
+}
+
+// LastFailure returns the class of the most recent recorded failure.
+func (d *Deployment) LastFailure() FailureClass {
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastFailure
 }
 
 // LatencyEMA returns the current exponential moving average latency.
 func (d *Deployment) LatencyEMA() time.Duration {
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	return d.latencyEMA
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.latencyEMA
+}
+
+// TTFTEMA returns the exponential moving average time to first token.
+func (d *Deployment) TTFTEMA() time.Duration {
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.ttftEMA
+}
+
+// ema folds sample into current using the deployment's smoothing factor.
+// Caller must hold d.mu.
+func (d *Deployment) ema(current, sample time.Duration) time.Duration {
+	alpha := d.latencyAlpha
+	if alpha == 0 {
+		alpha = 0.3
+	}
+	if current == 0 {
+		return sample
+	}
+	return time.Duration(float64(current)*(1-alpha) + float64(sample)*alpha)
+}
+
+// HTTPClient returns the client for upstream calls to the deployment.
+func (d *Deployment) HTTPClient() *http.Client {
+	h := d.health()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.client == nil {
+		return httpclient.Default()
+	}
+	return h.client
+}
+
+// Provider returns the provider configured for the deployment, such as
+// one holding its region and credentials, or the error building it. It is
+// built when the deployment is created and kept for its lifetime.
+func (d *Deployment) Provider() (provider.Provider, error) {
+	h := d.health()
+	if h.provider == nil && h.providerErr == nil {
+		// Not created by a Router.
+		return provider.GetConfigured(h.ProviderName, ProviderParams(&h.Config.TianjiParams))
+	}
+	return h.provider, h.providerErr
+}
+
+// ProviderParams returns the provider settings of a deployment's
+// tianji_params.
+func ProviderParams(tp *config.TianjiParams) provider.Params {
+	p := provider.Params{
+		AWSRegionName:      tp.AWSRegionName,
+		AWSAccessKeyID:     tp.AWSAccessKeyID,
+		AWSSecretAccessKey: tp.AWSSecretAccessKey,
+		AWSSessionToken:    tp.AWSSessionToken,
+		AWSRoleName:        tp.AWSRoleName,
+		AWSSessionName:     tp.AWSSessionName,
+		VertexProject:      tp.VertexProject,
+		VertexLocation:     tp.VertexLocation,
+		VertexCredentials:  tp.VertexCredentials,
+	}
+	if tp.APIBase != nil {
+		p.APIBase = *tp.APIBase
+	}
+	if tp.APIVersion != nil {
+		p.APIVersion = *tp.APIVersion
+	}
+	return p
 }
 
 // APIKey returns the API key from the deployment config.
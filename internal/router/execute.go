@@ -0,0 +1,296 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+	"github.com/praxisllmlab/tianjiLLM/internal/provider"
+)
+
+// ErrUnsupported marks a call that could not be attempted on a deployment,
+// e.g. because its provider lacks the requested capability. Execute moves on
+// to the next deployment without counting it as a deployment failure.
+var ErrUnsupported = errors.New("unsupported by deployment")
+
+// Attempt is the target of a single upstream call.
+type Attempt struct {
+	ModelGroup string      // model group the attempt was routed for
+	Deployment *Deployment // nil when resolved without the Router
+	Provider   provider.Provider
+	APIKey     string
+	Model      string // upstream model name
+}
+
+// NewAttempt builds an Attempt for deployment d of modelGroup.
+func NewAttempt(modelGroup string, d *Deployment, p provider.Provider) Attempt {
+	return Attempt{
+		ModelGroup: modelGroup,
+		Deployment: d,
+		Provider:   p,
+		APIKey:     d.APIKey(),
+		Model:      d.ModelName,
+	}
+}
+
+// CallFunc performs one upstream call for an attempt. It returns the raw
+// upstream response; Execute closes the body of any response it retries past.
+type CallFunc func(ctx context.Context, a Attempt) (*http.Response, error)
+
+// Execution records how a request was served across retries and fallbacks.
+type Execution struct {
+	Final     Attempt  // attempt that produced the returned response or error
+	Tried     []string // deployment IDs in the order they were called
+	Retries   int      // extra attempts within a model group
+	Fallbacks int      // fallback model groups entered
+
+	// Hedges are calls that lost a hedging race to Final and were cancelled.
+	Hedges []HedgedCall
+}
+
+// DeploymentID returns the ID of the deployment that served the request,
+// or "" when no routed deployment was called.
+func (e *Execution) DeploymentID() string {
+	if e == nil || len(e.Tried) == 0 {
+		return ""
+	}
+	if e.Final.Deployment != nil {
+		return e.Final.Deployment.ID
+	}
+	return e.Tried[len(e.Tried)-1]
+}
+
+// Execute calls first, then on retryable failures (timeouts, 429, 5xx, auth)
+// retries on other deployments of the same model group up to the group's
+// NumRetries, and then walks Fallbacks/DefaultFallbacks up to MaxFallbacks.
+// Model groups with a HedgePolicy hedge each attempt on a second deployment
+// when the first is slow to answer.
+//
+// The last upstream response is returned unclosed even when it is a failure,
+// so callers can relay the upstream error body. err is non-nil only when no
+// response was obtained at all.
+func (r *Router) Execute(ctx context.Context, first Attempt, req *model.ChatCompletionRequest, call CallFunc) (*http.Response, *Execution, error) {
+	exec := &Execution{Final: first}
+
+	var lastResp *http.Response
+	var lastErr error
+	atCapacity := false
+	for gi, group := range r.fallbackChain(first.ModelGroup) {
+		policy := r.retryPolicy(group)
+		tried := make(map[string]bool)
+
+		for attempt := 0; attempt <= policy.retries(); attempt++ {
+			var a Attempt
+			if gi == 0 && attempt == 0 && first.Deployment != nil {
+				a = first
+			} else {
+				d, p, err := r.routeExcluding(ctx, group, req, tried)
+				if err != nil {
+					if lastErr == nil && lastResp == nil {
+						lastErr = err
+					}
+					break
+				}
+				a = NewAttempt(group, d, p)
+			}
+
+			if len(exec.Tried) > 0 {
+				drainAndClose(lastResp)
+				lastResp = nil
+				if err := sleepCtx(ctx, policy.retryAfter(r.Settings().RetryAfter)); err != nil {
+					return nil, exec, err
+				}
+			}
+
+			release, ok := r.Acquire(a.Deployment)
+			if !ok {
+				// Filled up since routing; try another deployment.
+				tried[a.Deployment.ID] = true
+				atCapacity = true
+				continue
+			}
+
+			if len(exec.Tried) > 0 {
+				if attempt == 0 {
+					exec.Fallbacks++
+				} else {
+					exec.Retries++
+				}
+			}
+			exec.Tried = append(exec.Tried, a.Deployment.ID)
+			exec.Final = a
+			tried[a.Deployment.ID] = true
+
+			start := time.Now()
+			var resp *http.Response
+			var err error
+			if hedge, ok := r.hedgePolicy(group); ok {
+				a, resp, start, err = r.hedgedCall(ctx, hedge, group, a, release, req, tried, exec, call)
+				exec.Final = a
+			} else {
+				resp, err = call(ctx, a)
+				resp = holdSlot(resp, release)
+			}
+			lastResp, lastErr = resp, err
+			if errors.Is(err, ErrUnsupported) {
+				continue
+			}
+
+			status := 0
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			r.RecordOutcome(a.Deployment, status, err, time.Since(start))
+			if !isRetryable(ClassifyFailure(status, err)) {
+				return resp, exec, err
+			}
+		}
+	}
+
+	if lastResp == nil && lastErr == nil {
+		if atCapacity {
+			lastErr = fmt.Errorf("%w for model %q", ErrAtCapacity, first.ModelGroup)
+		} else {
+			lastErr = fmt.Errorf("all deployments failed for model %q", first.ModelGroup)
+		}
+	}
+	return lastResp, exec, lastErr
+}
+
+// routeExcluding routes modelGroup while skipping deployments already tried.
+// When every deployment has been tried it starts over, so retries can reuse
+// a deployment once the group is exhausted.
+func (r *Router) routeExcluding(ctx context.Context, modelGroup string, req *model.ChatCompletionRequest, tried map[string]bool) (*Deployment, provider.Provider, error) {
+	if len(tried) == 0 {
+		return r.Route(ctx, modelGroup, req)
+	}
+	name := r.resolveAlias(modelGroup)
+	if d, p, err := r.route(ctx, name, req, tried); err == nil {
+		return d, p, nil
+	}
+	return r.route(ctx, name, req, nil)
+}
+
+// resolveAlias returns the model group an alias points to, or modelGroup.
+func (r *Router) resolveAlias(modelGroup string) string {
+	if alias, ok := r.Settings().ModelGroupAlias[modelGroup]; ok {
+		return alias.Model
+	}
+	return modelGroup
+}
+
+// fallbackChain returns modelGroup followed by its fallback groups — model
+// specific first, then defaults — deduplicated and capped at MaxFallbacks.
+func (r *Router) fallbackChain(modelGroup string) []string {
+	settings := r.Settings()
+	chain := []string{modelGroup}
+	seen := map[string]bool{modelGroup: true}
+
+	candidates := append([]string{}, settings.Fallbacks[modelGroup]...)
+	candidates = append(candidates, settings.DefaultFallbacks...)
+	for _, fb := range candidates {
+		if settings.MaxFallbacks != nil && len(chain)-1 >= *settings.MaxFallbacks {
+			break
+		}
+		if seen[fb] {
+			continue
+		}
+		seen[fb] = true
+		chain = append(chain, fb)
+	}
+	return chain
+}
+
+// retryPolicy returns the effective retry policy for a model group.
+func (r *Router) retryPolicy(modelGroup string) RetryPolicy {
+	settings := r.Settings()
+	policy := settings.ModelGroupRetryPolicy[modelGroup]
+	if policy.NumRetries == nil {
+		policy.NumRetries = settings.NumRetries
+	}
+	return policy
+}
+
+// retries returns how many retries the policy allows.
+func (p RetryPolicy) retries() int {
+	if p.NumRetries == nil {
+		return 0
+	}
+	return *p.NumRetries
+}
+
+// retryAfter returns the wait before a retry, preferring the group override.
+func (p RetryPolicy) retryAfter(fallback time.Duration) time.Duration {
+	if p.RetryAfterSeconds > 0 {
+		return time.Duration(p.RetryAfterSeconds) * time.Second
+	}
+	return fallback
+}
+
+// isRetryable reports whether a failure class warrants another attempt.
+func isRetryable(class FailureClass) bool {
+	switch class {
+	case FailureTimeout, FailureRateLimit, FailureServer, FailureAuth:
+		return true
+	}
+	return false
+}
+
+// releaseBody frees the deployment's in-flight slot once the response body
+// is fully read or closed, so streams hold their slot until they finish.
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.release()
+	}
+	return n, err
+}
+
+func (b *releaseBody) Close() error {
+	b.release()
+	return b.ReadCloser.Close()
+}
+
+func drainAndClose(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+type executionKey struct{}
+
+// WithExecution attaches exec to ctx so logging can report the attempts made.
+func WithExecution(ctx context.Context, exec *Execution) context.Context {
+	return context.WithValue(ctx, executionKey{}, exec)
+}
+
+// ExecutionFromContext returns the Execution attached by WithExecution, or nil.
+func ExecutionFromContext(ctx context.Context) *Execution {
+	exec, _ := ctx.Value(executionKey{}).(*Execution)
+	return exec
+}
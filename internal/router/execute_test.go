@@ -0,0 +1,270 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeResponse(status int) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("{}"))}
+}
+
+func executeModels(groups ...string) []config.ModelConfig {
+	apiKey := "sk-test"
+	var models []config.ModelConfig
+	for _, g := range groups {
+		models = append(models, config.ModelConfig{
+			ModelName:    g,
+			TianjiParams: config.TianjiParams{Model: "openai/" + g, APIKey: &apiKey},
+		})
+	}
+	return models
+}
+
+func TestExecute_RetriesOnOtherDeployment(t *testing.T) {
+	r := New(executeModels("gpt-4o", "gpt-4o"), &roundRobinStrategy{}, RouterSettings{NumRetries: intPtr(2)})
+
+	var calls []string
+	resp, exec, err := r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(_ context.Context, a Attempt) (*http.Response, error) {
+		calls = append(calls, a.Deployment.ID)
+		if len(calls) == 1 {
+			return fakeResponse(http.StatusServiceUnavailable), nil
+		}
+		return fakeResponse(http.StatusOK), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []string{"gpt-4o-0", "gpt-4o-1"}, exec.Tried)
+	assert.Equal(t, 1, exec.Retries)
+	assert.Equal(t, 0, exec.Fallbacks)
+}
+
+func TestExecute_WalksFallbacks(t *testing.T) {
+	r := New(executeModels("gpt-4o", "claude-3"), &roundRobinStrategy{}, RouterSettings{
+		NumRetries: intPtr(1),
+		Fallbacks:  map[string][]string{"gpt-4o": {"claude-3"}},
+	})
+
+	resp, exec, err := r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(_ context.Context, a Attempt) (*http.Response, error) {
+		if a.ModelGroup == "gpt-4o" {
+			return fakeResponse(http.StatusTooManyRequests), nil
+		}
+		return fakeResponse(http.StatusOK), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "claude-3", exec.Final.ModelGroup)
+	assert.Equal(t, "claude-3", exec.Final.Model)
+	assert.Equal(t, 1, exec.Fallbacks)
+	assert.Equal(t, []string{"gpt-4o-0", "gpt-4o-0", "claude-3-1"}, exec.Tried)
+}
+
+func TestExecute_ClientErrorIsNotRetried(t *testing.T) {
+	r := New(executeModels("gpt-4o", "gpt-4o"), &roundRobinStrategy{}, RouterSettings{NumRetries: intPtr(3)})
+
+	calls := 0
+	resp, exec, err := r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(context.Context, Attempt) (*http.Response, error) {
+		calls++
+		return fakeResponse(http.StatusBadRequest), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, 1, calls)
+	assert.Len(t, exec.Tried, 1)
+}
+
+func TestExecute_ExhaustedReturnsLastResponse(t *testing.T) {
+	r := New(executeModels("gpt-4o"), &roundRobinStrategy{}, RouterSettings{NumRetries: intPtr(1)})
+
+	resp, exec, err := r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(context.Context, Attempt) (*http.Response, error) {
+		return fakeResponse(http.StatusBadGateway), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Len(t, exec.Tried, 2)
+}
+
+func TestExecute_TransportErrorReturned(t *testing.T) {
+	r := New(executeModels("gpt-4o"), &roundRobinStrategy{}, RouterSettings{NumRetries: intPtr(1)})
+
+	resp, _, err := r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(context.Context, Attempt) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	assert.Nil(t, resp)
+	assert.EqualError(t, err, "connection refused")
+}
+
+func TestExecute_UsesFirstAttempt(t *testing.T) {
+	r := New(executeModels("gpt-4o", "gpt-4o"), &roundRobinStrategy{}, RouterSettings{})
+	d := r.GetDeployments("gpt-4o")[1]
+	first := NewAttempt("gpt-4o", d, nil)
+
+	_, exec, err := r.Execute(context.Background(), first, nil, func(context.Context, Attempt) (*http.Response, error) {
+		return fakeResponse(http.StatusOK), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"gpt-4o-1"}, exec.Tried)
+	assert.NotZero(t, d.LatencyEMA(), "outcome should be recorded on the deployment")
+}
+
+func TestExecute_RetryPolicyOverridesRetries(t *testing.T) {
+	r := New(executeModels("gpt-4o"), &roundRobinStrategy{}, RouterSettings{
+		NumRetries: intPtr(5),
+		ModelGroupRetryPolicy: map[string]RetryPolicy{
+			"gpt-4o": {NumRetries: intPtr(1)},
+		},
+	})
+
+	calls := 0
+	_, _, _ = r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(context.Context, Attempt) (*http.Response, error) {
+		calls++
+		return fakeResponse(http.StatusInternalServerError), nil
+	})
+	assert.Equal(t, 2, calls)
+}
+
+func TestExecute_ZeroRetriesHonored(t *testing.T) {
+	r := New(executeModels("gpt-4o", "gpt-4o", "claude-3"), &roundRobinStrategy{}, RouterSettings{
+		NumRetries:   intPtr(5),
+		MaxFallbacks: intPtr(0),
+		Fallbacks:    map[string][]string{"gpt-4o": {"claude-3"}},
+		ModelGroupRetryPolicy: map[string]RetryPolicy{
+			"gpt-4o": {NumRetries: intPtr(0)},
+		},
+	})
+
+	calls := 0
+	_, exec, _ := r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(context.Context, Attempt) (*http.Response, error) {
+		calls++
+		return fakeResponse(http.StatusInternalServerError), nil
+	})
+	assert.Equal(t, 1, calls, "group num_retries 0 and max_fallbacks 0 allow a single attempt")
+	assert.Zero(t, exec.Fallbacks)
+}
+
+func TestExecute_RetryAfterHonorsContext(t *testing.T) {
+	r := New(executeModels("gpt-4o"), &roundRobinStrategy{}, RouterSettings{NumRetries: intPtr(1), RetryAfter: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, _, err := r.Execute(ctx, Attempt{ModelGroup: "gpt-4o"}, nil, func(context.Context, Attempt) (*http.Response, error) {
+		return fakeResponse(http.StatusInternalServerError), nil
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestFallbackChain_CapsAtMaxFallbacks(t *testing.T) {
+	r := New(nil, &roundRobinStrategy{}, RouterSettings{
+		Fallbacks:        map[string][]string{"a": {"b", "c"}},
+		DefaultFallbacks: []string{"c", "d", "a"},
+		MaxFallbacks:     intPtr(2),
+	})
+	assert.Equal(t, []string{"a", "b", "c"}, r.fallbackChain("a"))
+}
+
+// trackingStrategy records InflightTracker calls.
+type trackingStrategy struct {
+	roundRobinStrategy
+	inflight map[string]int
+}
+
+func (s *trackingStrategy) Acquire(id string) { s.inflight[id]++ }
+func (s *trackingStrategy) Release(id string) { s.inflight[id]-- }
+
+func TestExecute_HoldsSlotUntilBodyClosed(t *testing.T) {
+	tracker := &trackingStrategy{inflight: map[string]int{}}
+	r := New(executeModels("gpt-4o"), tracker, RouterSettings{})
+	d := r.GetDeployments("gpt-4o")[0]
+
+	resp, _, err := r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(context.Context, Attempt) (*http.Response, error) {
+		return fakeResponse(http.StatusOK), nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, d.Inflight())
+	assert.Equal(t, 1, tracker.inflight["gpt-4o-0"])
+
+	_, _ = io.ReadAll(resp.Body)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, 0, d.Inflight())
+	assert.Equal(t, 0, tracker.inflight["gpt-4o-0"], "release must happen exactly once")
+}
+
+func TestExecute_ReleasesSlotOnTransportError(t *testing.T) {
+	r := New(executeModels("gpt-4o"), &roundRobinStrategy{}, RouterSettings{NumRetries: intPtr(1)})
+
+	_, _, _ = r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(context.Context, Attempt) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	assert.Equal(t, 0, r.GetDeployments("gpt-4o")[0].Inflight())
+}
+
+func TestRoute_SkipsDeploymentsAtCapacity(t *testing.T) {
+	one := 1
+	models := executeModels("gpt-4o", "gpt-4o")
+	models[0].TianjiParams.MaxParallelRequests = &one
+	r := New(models, &roundRobinStrategy{}, RouterSettings{DefaultMaxParallelRequests: 1})
+	deployments := r.GetDeployments("gpt-4o")
+
+	release0, ok := r.Acquire(deployments[0])
+	require.True(t, ok)
+	_, ok = r.Acquire(deployments[0])
+	assert.False(t, ok, "second acquire must exceed max_parallel_requests")
+
+	for i := 0; i < 3; i++ {
+		d, _, err := r.Route(context.Background(), "gpt-4o", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "gpt-4o-1", d.ID)
+	}
+
+	release1, ok := r.Acquire(deployments[1])
+	require.True(t, ok)
+	_, _, err := r.Route(context.Background(), "gpt-4o", nil)
+	assert.ErrorIs(t, err, ErrAtCapacity)
+
+	release0()
+	release0()
+	release1()
+	assert.Equal(t, 0, deployments[0].Inflight())
+	d, _, err := r.Route(context.Background(), "gpt-4o", nil)
+	require.NoError(t, err)
+	assert.NotNil(t, d)
+}
+
+// fillingStrategy takes the picked deployment's only slot before returning
+// it, as a concurrent request would between routing and Acquire.
+type fillingStrategy struct {
+	r *Router
+}
+
+func (s *fillingStrategy) Pick(deployments []*Deployment) *Deployment {
+	if len(deployments) == 0 {
+		return nil
+	}
+	s.r.Acquire(deployments[0])
+	return deployments[0]
+}
+
+func TestExecute_AtCapacityWhenNoSlotAcquired(t *testing.T) {
+	s := &fillingStrategy{}
+	r := New(executeModels("gpt-4o", "gpt-4o"), s, RouterSettings{NumRetries: intPtr(1), DefaultMaxParallelRequests: 1})
+	s.r = r
+
+	called := false
+	resp, exec, err := r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(context.Context, Attempt) (*http.Response, error) {
+		called = true
+		return fakeResponse(http.StatusOK), nil
+	})
+	assert.Nil(t, resp)
+	assert.False(t, called)
+	assert.Empty(t, exec.Tried)
+	assert.ErrorIs(t, err, ErrAtCapacity)
+}
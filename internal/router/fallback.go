@@ -2,16 +2,20 @@ package router
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 )
 
 // GeneralFallback returns the first available fallback deployment for the given model.
 // Checks model-specific fallbacks first, then default fallbacks.
 func (r *Router) GeneralFallback(modelName string) (*Deployment, provider.Provider, error) {
+	settings := r.Settings()
+
 	// Try model-specific fallbacks first
-	if fallbacks, ok := r.settings.Fallbacks[modelName]; ok {
+	if fallbacks, ok := settings.Fallbacks[modelName]; ok {
 		for _, fb := range fallbacks {
 			d, p, err := r.Route(context.Background(), fb, nil)
 			if err == nil {
@@ -21,7 +25,7 @@ func (r *Router) GeneralFallback(modelName string) (*Deployment, provider.Provid
 	}
 
 	// Try default fallbacks
-	for _, fb := range r.settings.DefaultFallbacks {
+	for _, fb := range settings.DefaultFallbacks {
 		if fb == modelName {
 			continue // skip self
 		}
@@ -36,22 +40,34 @@ func (r *Router) GeneralFallback(modelName string) (*Deployment, provider.Provid
 
 // ContentPolicyFallback returns the first available fallback for content policy errors (HTTP 400).
 func (r *Router) ContentPolicyFallback(modelName string) (*Deployment, provider.Provider, error) {
-	fallbacks := r.settings.ContentPolicyFallbacks
-	if len(fallbacks) == 0 {
-		return nil, nil, fmt.Errorf("no content policy fallbacks configured for %q", modelName)
-	}
+	return r.routeFallback("content policy", modelName, r.ErrorFallbacks(modelName, model.ErrContentPolicyViolation))
+}
 
-	models, ok := fallbacks[modelName]
-	if !ok || len(models) == 0 {
-		return nil, nil, fmt.Errorf("no content policy fallbacks configured for %q", modelName)
+// ErrorFallbacks returns the fallback model groups configured for a
+// classified provider error: ContextWindowFallbacks for
+// model.ErrContextWindowExceeded and ContentPolicyFallbacks for
+// model.ErrContentPolicyViolation. Other errors have no such fallbacks.
+func (r *Router) ErrorFallbacks(modelName string, err error) []string {
+	switch {
+	case errors.Is(err, model.ErrContextWindowExceeded):
+		return r.Settings().ContextWindowFallbacks[modelName]
+	case errors.Is(err, model.ErrContentPolicyViolation):
+		return r.Settings().ContentPolicyFallbacks[modelName]
 	}
+	return nil
+}
 
-	for _, fb := range models {
+// routeFallback returns a deployment of the first of the kind's fallback
+// groups that can be routed.
+func (r *Router) routeFallback(kind, modelName string, groups []string) (*Deployment, provider.Provider, error) {
+	if len(groups) == 0 {
+		return nil, nil, fmt.Errorf("no %s fallbacks configured for %q", kind, modelName)
+	}
+	for _, fb := range groups {
 		d, p, err := r.Route(context.Background(), fb, nil)
 		if err == nil {
 			return d, p, nil
 		}
 	}
-
-	return nil, nil, fmt.Errorf("all content policy fallbacks exhausted for %q", modelName)
+	return nil, nil, fmt.Errorf("all %s fallbacks exhausted for %q", kind, modelName)
 }
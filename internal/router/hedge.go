@@ -0,0 +1,208 @@
+package router
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/model"
+)
+
+// ErrHedgeCancelled is the cancellation cause seen by the call of a hedged
+// pair that did not answer first.
+var ErrHedgeCancelled = errors.New("hedged request cancelled")
+
+// HedgePolicy enables hedged requests for a model group: when the first
+// deployment has not started answering within Delay, the same request is
+// also sent to a second deployment and whichever answers first is used.
+type HedgePolicy struct {
+	Delay time.Duration
+}
+
+// HedgedCall is an upstream call that lost a hedging race and was cancelled.
+type HedgedCall struct {
+	Attempt Attempt
+	Start   time.Time
+	End     time.Time
+}
+
+// hedgePolicy returns the hedge policy of a model group, if it has one.
+func (r *Router) hedgePolicy(modelGroup string) (HedgePolicy, bool) {
+	p, ok := r.Settings().ModelGroupHedgePolicy[modelGroup]
+	return p, ok && p.Delay > 0
+}
+
+// hedgeCall is one in-flight call of a hedged pair.
+type hedgeCall struct {
+	attempt Attempt
+	start   time.Time
+	cancel  context.CancelCauseFunc
+}
+
+// hedgeResult is the outcome of a hedgeCall.
+type hedgeResult struct {
+	call *hedgeCall
+	resp *http.Response
+	err  error
+}
+
+// hedgedCall calls a, which already holds the in-flight slot freed by
+// release. If a has not answered — response headers and first body bytes,
+// so the first token of a stream — within policy.Delay, a second healthy
+// deployment of group that is not in tried is called as well. The first
+// call to answer without a retryable failure wins; the other is cancelled
+// with ErrHedgeCancelled and recorded in exec.Hedges. The winner's start
+// time is returned so latency is measured from its own call.
+func (r *Router) hedgedCall(ctx context.Context, policy HedgePolicy, group string, a Attempt, release func(), req *model.ChatCompletionRequest, tried map[string]bool, exec *Execution, call CallFunc) (Attempt, *http.Response, time.Time, error) {
+	results := make(chan hedgeResult, 2)
+	var calls []*hedgeCall
+	launch := func(a Attempt, release func()) {
+		callCtx, cancel := context.WithCancelCause(ctx)
+		c := &hedgeCall{attempt: a, start: time.Now(), cancel: cancel}
+		calls = append(calls, c)
+		go func() {
+			resp, err := call(callCtx, a)
+			if err == nil {
+				resp, err = awaitFirstByte(resp)
+			}
+			// The call's context must outlive it until its body is closed.
+			resp = holdSlot(resp, func() {
+				release()
+				cancel(nil)
+			})
+			results <- hedgeResult{call: c, resp: resp, err: err}
+		}()
+	}
+
+	launch(a, release)
+	pending := 1
+	timer := time.NewTimer(policy.Delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			h, hRelease, ok := r.hedgeAttempt(ctx, group, req, tried)
+			if !ok {
+				continue
+			}
+			tried[h.Deployment.ID] = true
+			exec.Tried = append(exec.Tried, h.Deployment.ID)
+			launch(h, hRelease)
+			pending++
+
+		case res := <-results:
+			pending--
+			if pending > 0 && failedCall(res) {
+				// The other call may still answer.
+				if !errors.Is(res.err, ErrUnsupported) {
+					r.RecordOutcome(res.call.attempt.Deployment, statusOf(res.resp), res.err, time.Since(res.call.start))
+				}
+				drainAndClose(res.resp)
+				continue
+			}
+			if pending > 0 {
+				exec.Hedges = append(exec.Hedges, abandon(results, calls, res.call)...)
+			}
+			return res.call.attempt, res.resp, res.call.start, res.err
+
+		case <-ctx.Done():
+			for _, c := range calls {
+				c.cancel(context.Cause(ctx))
+			}
+			go drainResults(results, pending)
+			return a, nil, calls[0].start, ctx.Err()
+		}
+	}
+}
+
+// hedgeAttempt routes and reserves a second deployment for a hedged call.
+func (r *Router) hedgeAttempt(ctx context.Context, group string, req *model.ChatCompletionRequest, tried map[string]bool) (Attempt, func(), bool) {
+	d, p, err := r.route(ctx, r.resolveAlias(group), req, tried)
+	if err != nil {
+		return Attempt{}, nil, false
+	}
+	release, ok := r.Acquire(d)
+	if !ok {
+		return Attempt{}, nil, false
+	}
+	return NewAttempt(group, d, p), release, true
+}
+
+// abandon cancels every call but winner and returns them as HedgedCalls.
+// Their responses are closed in the background as they come in.
+func abandon(results chan hedgeResult, calls []*hedgeCall, winner *hedgeCall) []HedgedCall {
+	end := time.Now()
+	var hedges []HedgedCall
+	for _, c := range calls {
+		if c == winner {
+			continue
+		}
+		c.cancel(ErrHedgeCancelled)
+		hedges = append(hedges, HedgedCall{Attempt: c.attempt, Start: c.start, End: end})
+		// Cancelled before answering: at least this slow, which is worth
+		// knowing for latency-based routing.
+		c.attempt.Deployment.recordLatency(end.Sub(c.start))
+	}
+	if len(hedges) > 0 {
+		go drainResults(results, len(hedges))
+	}
+	return hedges
+}
+
+// drainResults closes the responses of n hedged calls as they return.
+func drainResults(results <-chan hedgeResult, n int) {
+	for range n {
+		res := <-results
+		drainAndClose(res.resp)
+	}
+}
+
+// failedCall reports whether a hedged call failed in a way another
+// deployment might not.
+func failedCall(res hedgeResult) bool {
+	return errors.Is(res.err, ErrUnsupported) || isRetryable(ClassifyFailure(statusOf(res.resp), res.err))
+}
+
+func statusOf(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// awaitFirstByte blocks until a successful response's body yields its first
+// byte, so a stream only counts as answered once tokens start flowing. A
+// body that fails before its first byte is reported like a transport error.
+func awaitFirstByte(resp *http.Response) (*http.Response, error) {
+	if resp == nil || resp.Body == nil || resp.StatusCode >= http.StatusBadRequest {
+		return resp, nil
+	}
+	br := bufio.NewReader(resp.Body)
+	if _, err := br.Peek(1); err != nil && err != io.EOF {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	resp.Body = &bufferedBody{Reader: br, Closer: resp.Body}
+	return resp, nil
+}
+
+// bufferedBody is a response body read through the buffer used to peek it.
+type bufferedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// holdSlot ties release to the response body, or calls it right away when
+// there is no body to hold the slot.
+func holdSlot(resp *http.Response, release func()) *http.Response {
+	if resp != nil && resp.Body != nil {
+		resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+	} else {
+		release()
+	}
+	return resp
+}
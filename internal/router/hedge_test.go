@@ -0,0 +1,97 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hedgedRouter() *Router {
+	return New(executeModels("gpt-4o", "gpt-4o"), &roundRobinStrategy{}, RouterSettings{
+		NumRetries:            intPtr(1),
+		ModelGroupHedgePolicy: map[string]HedgePolicy{"gpt-4o": {Delay: 20 * time.Millisecond}},
+	})
+}
+
+func TestExecute_HedgeWinsWhenPrimaryIsSlow(t *testing.T) {
+	r := hedgedRouter()
+
+	cause := make(chan error, 1)
+	resp, exec, err := r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(ctx context.Context, a Attempt) (*http.Response, error) {
+		if a.Deployment.ID == "gpt-4o-0" {
+			<-ctx.Done()
+			cause <- context.Cause(ctx)
+			return nil, ctx.Err()
+		}
+		return fakeResponse(http.StatusOK), nil
+	})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gpt-4o-1", exec.DeploymentID())
+	assert.Equal(t, []string{"gpt-4o-0", "gpt-4o-1"}, exec.Tried)
+	require.Len(t, exec.Hedges, 1)
+	assert.Equal(t, "gpt-4o-0", exec.Hedges[0].Attempt.Deployment.ID)
+	assert.False(t, exec.Hedges[0].End.Before(exec.Hedges[0].Start))
+
+	select {
+	case err := <-cause:
+		assert.ErrorIs(t, err, ErrHedgeCancelled)
+	case <-time.After(time.Second):
+		t.Fatal("slow call was not cancelled")
+	}
+	// The cancelled call is not a deployment failure.
+	assert.True(t, r.GetDeployments("gpt-4o")[0].IsHealthy())
+}
+
+func TestExecute_NoHedgeWhenPrimaryIsFast(t *testing.T) {
+	r := hedgedRouter()
+
+	var calls atomic.Int32
+	resp, exec, err := r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(context.Context, Attempt) (*http.Response, error) {
+		calls.Add(1)
+		return fakeResponse(http.StatusOK), nil
+	})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	time.Sleep(40 * time.Millisecond)
+	assert.EqualValues(t, 1, calls.Load())
+	assert.Len(t, exec.Tried, 1)
+	assert.Empty(t, exec.Hedges)
+}
+
+func TestExecute_HedgeAnswersAfterPrimaryFails(t *testing.T) {
+	r := hedgedRouter()
+
+	resp, exec, err := r.Execute(context.Background(), Attempt{ModelGroup: "gpt-4o"}, nil, func(_ context.Context, a Attempt) (*http.Response, error) {
+		if a.Deployment.ID == "gpt-4o-0" {
+			time.Sleep(40 * time.Millisecond)
+			return fakeResponse(http.StatusServiceUnavailable), nil
+		}
+		time.Sleep(60 * time.Millisecond)
+		return fakeResponse(http.StatusOK), nil
+	})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gpt-4o-1", exec.DeploymentID())
+	assert.Empty(t, exec.Hedges, "a failed call is not a cancelled hedge")
+	assert.Equal(t, 0, exec.Retries)
+}
+
+func TestSettingsFromConfig_HedgePolicy(t *testing.T) {
+	policies := parseHedgePolicies(map[string]any{
+		"gpt-4o": map[string]any{"delay_ms": 500},
+		"claude": map[string]any{"delay_ms": float64(250)},
+		"off":    map[string]any{"delay_ms": 0},
+	})
+	assert.Equal(t, map[string]HedgePolicy{
+		"gpt-4o": {Delay: 500 * time.Millisecond},
+		"claude": {Delay: 250 * time.Millisecond},
+	}, policies)
+}
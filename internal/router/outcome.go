@@ -0,0 +1,85 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// FailureClass categorizes an upstream failure for deployment health tracking.
+type FailureClass string
+
+const (
+	// FailureNone means the call did not fail, or failed for a reason that
+	// says nothing about the deployment (e.g. the client went away).
+	FailureNone FailureClass = ""
+	// FailureTimeout is a request timeout (transport deadline, 408 or 504).
+	FailureTimeout FailureClass = "timeout"
+	// FailureRateLimit is an upstream 429.
+	FailureRateLimit FailureClass = "rate_limit"
+	// FailureAuth is an upstream 401 or 403.
+	FailureAuth FailureClass = "auth"
+	// FailureServer is an upstream 5xx or a connection-level error.
+	FailureServer FailureClass = "server_error"
+	// FailureClient is any other 4xx — the request, not the deployment, is at fault.
+	FailureClient FailureClass = "client_error"
+)
+
+// ClassifyFailure maps the result of an upstream call to a FailureClass.
+// statusCode is 0 when the transport returned err before a response.
+func ClassifyFailure(statusCode int, err error) FailureClass {
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return FailureNone
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return FailureTimeout
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return FailureTimeout
+		}
+		if statusCode == 0 {
+			return FailureServer
+		}
+	}
+
+	switch {
+	case statusCode == 0 || statusCode < http.StatusBadRequest:
+		return FailureNone
+	case statusCode == http.StatusTooManyRequests:
+		return FailureRateLimit
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return FailureAuth
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		return FailureTimeout
+	case statusCode >= http.StatusInternalServerError:
+		return FailureServer
+	default:
+		return FailureClient
+	}
+}
+
+// RecordOutcome feeds the result of an upstream call back into d's health.
+// Successful calls reset the failure count and update the latency EMA;
+// failures are classified and may put d into cooldown. Calls that got a
+// response count toward a UsageRecorder strategy's request windows.
+func (r *Router) RecordOutcome(d *Deployment, statusCode int, err error, latency time.Duration) {
+	if d == nil {
+		return
+	}
+	if err == nil {
+		if u, ok := r.currentStrategy().(UsageRecorder); ok {
+			u.RecordRequest(d.health().ID)
+		}
+	}
+	if class := ClassifyFailure(statusCode, err); class != FailureNone {
+		d.RecordFailureClass(class)
+		return
+	}
+	if err == nil {
+		d.RecordSuccess(latency)
+	}
+}
@@ -0,0 +1,113 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestClassifyFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   FailureClass
+	}{
+		{"success", http.StatusOK, nil, FailureNone},
+		{"rate limit", http.StatusTooManyRequests, nil, FailureRateLimit},
+		{"unauthorized", http.StatusUnauthorized, nil, FailureAuth},
+		{"forbidden", http.StatusForbidden, nil, FailureAuth},
+		{"server error", http.StatusBadGateway, nil, FailureServer},
+		{"gateway timeout", http.StatusGatewayTimeout, nil, FailureTimeout},
+		{"bad request", http.StatusBadRequest, nil, FailureClient},
+		{"deadline", 0, fmt.Errorf("do: %w", context.DeadlineExceeded), FailureTimeout},
+		{"net timeout", 0, timeoutErr{}, FailureTimeout},
+		{"connection refused", 0, errors.New("connection refused"), FailureServer},
+		{"client canceled", 0, context.Canceled, FailureNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ClassifyFailure(tt.status, tt.err))
+		})
+	}
+}
+
+func newOutcomeRouter(t *testing.T, settings RouterSettings) (*Router, *Deployment) {
+	t.Helper()
+	apiKey := "sk-test"
+	models := []config.ModelConfig{
+		{
+			ModelName:    "gpt-4o",
+			TianjiParams: config.TianjiParams{Model: "openai/gpt-4o", APIKey: &apiKey},
+		},
+	}
+	r := New(models, &roundRobinStrategy{}, settings)
+	deployments := r.GetDeployments("gpt-4o")
+	require.Len(t, deployments, 1)
+	return r, deployments[0]
+}
+
+func TestRecordOutcome_RateLimitCoolsDownImmediately(t *testing.T) {
+	r, d := newOutcomeRouter(t, RouterSettings{AllowedFails: 5, CooldownTime: time.Minute})
+
+	r.RecordOutcome(d, http.StatusTooManyRequests, nil, 10*time.Millisecond)
+	assert.False(t, d.IsHealthy())
+	assert.Equal(t, FailureRateLimit, d.LastFailure())
+}
+
+func TestRecordOutcome_ServerErrorsCountTowardAllowedFails(t *testing.T) {
+	r, d := newOutcomeRouter(t, RouterSettings{AllowedFails: 2, CooldownTime: time.Minute})
+
+	r.RecordOutcome(d, http.StatusInternalServerError, nil, 0)
+	assert.True(t, d.IsHealthy())
+	r.RecordOutcome(d, http.StatusInternalServerError, nil, 0)
+	assert.False(t, d.IsHealthy())
+}
+
+func TestRecordOutcome_ClientErrorKeepsHealthy(t *testing.T) {
+	r, d := newOutcomeRouter(t, RouterSettings{AllowedFails: 1, CooldownTime: time.Minute})
+
+	r.RecordOutcome(d, http.StatusBadRequest, nil, 0)
+	assert.True(t, d.IsHealthy())
+	assert.Zero(t, d.LatencyEMA(), "client errors should not feed latency")
+}
+
+func TestRecordOutcome_SuccessUpdatesLatency(t *testing.T) {
+	r, d := newOutcomeRouter(t, RouterSettings{})
+
+	r.RecordOutcome(d, http.StatusOK, nil, 200*time.Millisecond)
+	assert.Equal(t, 200*time.Millisecond, d.LatencyEMA())
+
+	r.RecordTTFT(d, 50*time.Millisecond)
+	assert.Equal(t, 50*time.Millisecond, d.TTFTEMA())
+}
+
+func TestRecordOutcome_WildcardCloneSharesHealth(t *testing.T) {
+	apiKey := "sk-test"
+	models := []config.ModelConfig{
+		{
+			ModelName:    "claude-*",
+			TianjiParams: config.TianjiParams{Model: "openai/claude-*", APIKey: &apiKey},
+		},
+	}
+	r := New(models, &roundRobinStrategy{}, RouterSettings{CooldownTime: time.Minute})
+
+	d, _, err := r.Route(context.Background(), "claude-opus", nil)
+	require.NoError(t, err)
+
+	r.RecordOutcome(d, http.StatusTooManyRequests, nil, 0)
+	assert.False(t, r.GetDeployments("claude-*")[0].IsHealthy(), "cooldown should land on the configured deployment")
+}
@@ -7,6 +7,7 @@ import (
 )
 
 func strPtr(s string) *string { return &s }
+func intPtr(n int) *int       { return &n }
 
 func TestNewPolicyEngine(t *testing.T) {
 	pe := NewPolicyEngine(nil)
@@ -0,0 +1,182 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisStatePrefix = "tianji:router:"
+
+// inflightTTL expires in-flight counters of replicas that died mid-request.
+const inflightTTL = 10 * time.Minute
+
+// incrExpireScript increments a counter and sets its expiry when the counter
+// is created.
+const incrExpireScript = `
+local current = redis.call('INCR', KEYS[1])
+if current == 1 then
+    redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return current
+`
+
+// inflightScript adjusts an in-flight counter, never letting it go negative.
+const inflightScript = `
+local current = redis.call('INCRBY', KEYS[1], ARGV[1])
+if current < 0 then
+    redis.call('SET', KEYS[1], 0)
+    current = 0
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return current
+`
+
+// usageScript adds to a usage window hash and starts its expiry on creation.
+const usageScript = `
+local created = redis.call('EXISTS', KEYS[1]) == 0
+redis.call('HINCRBY', KEYS[1], 'requests', ARGV[1])
+redis.call('HINCRBY', KEYS[1], 'tokens', ARGV[2])
+if created then
+    redis.call('PEXPIRE', KEYS[1], ARGV[3])
+end
+return 1
+`
+
+// RedisStateStore is a StateStore backed by Redis. Multi-key reads are
+// pipelined per key so it also works against Redis Cluster.
+type RedisStateStore struct {
+	rdb      redis.UniversalClient
+	incr     *redis.Script
+	inflight *redis.Script
+	usage    *redis.Script
+}
+
+// NewRedisStateStore creates a StateStore backed by Redis.
+func NewRedisStateStore(rdb redis.UniversalClient) *RedisStateStore {
+	return &RedisStateStore{
+		rdb:      rdb,
+		incr:     redis.NewScript(incrExpireScript),
+		inflight: redis.NewScript(inflightScript),
+		usage:    redis.NewScript(usageScript),
+	}
+}
+
+func cooldownKey(id string) string { return redisStatePrefix + "cooldown:" + id }
+func failuresKey(id string) string { return redisStatePrefix + "failures:" + id }
+func inflightKey(id string) string { return redisStatePrefix + "inflight:" + id }
+func usageKey(id string) string    { return redisStatePrefix + "usage:" + id }
+
+// SetCooldown implements StateStore.
+func (s *RedisStateStore) SetCooldown(ctx context.Context, deploymentID string, d time.Duration) error {
+	until := time.Now().Add(d).UnixMilli()
+	pipe := s.rdb.Pipeline()
+	pipe.Set(ctx, cooldownKey(deploymentID), until, d)
+	pipe.Del(ctx, failuresKey(deploymentID))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Cooldowns implements StateStore.
+func (s *RedisStateStore) Cooldowns(ctx context.Context, deploymentIDs []string) (map[string]time.Time, error) {
+	cmds, err := s.getAll(ctx, deploymentIDs, cooldownKey)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := make(map[string]time.Time)
+	for i, cmd := range cmds {
+		ms, err := cmd.Int64()
+		if err != nil {
+			continue
+		}
+		if until := time.UnixMilli(ms); until.After(now) {
+			result[deploymentIDs[i]] = until
+		}
+	}
+	return result, nil
+}
+
+// IncrFailures implements StateStore.
+func (s *RedisStateStore) IncrFailures(ctx context.Context, deploymentID string, window time.Duration) (int64, error) {
+	return s.incr.Run(ctx, s.rdb, []string{failuresKey(deploymentID)}, window.Milliseconds()).Int64()
+}
+
+// ResetFailures implements StateStore.
+func (s *RedisStateStore) ResetFailures(ctx context.Context, deploymentID string) error {
+	return s.rdb.Del(ctx, failuresKey(deploymentID)).Err()
+}
+
+// AddInflight implements StateStore.
+func (s *RedisStateStore) AddInflight(ctx context.Context, deploymentID string, delta int64) error {
+	return s.inflight.Run(ctx, s.rdb, []string{inflightKey(deploymentID)}, delta, inflightTTL.Milliseconds()).Err()
+}
+
+// Inflight implements StateStore.
+func (s *RedisStateStore) Inflight(ctx context.Context, deploymentIDs []string) (map[string]int64, error) {
+	cmds, err := s.getAll(ctx, deploymentIDs, inflightKey)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]int64, len(deploymentIDs))
+	for i, cmd := range cmds {
+		n, _ := cmd.Int64()
+		result[deploymentIDs[i]] = n
+	}
+	return result, nil
+}
+
+// AddUsage implements StateStore.
+func (s *RedisStateStore) AddUsage(ctx context.Context, deploymentID string, requests, tokens int64, window time.Duration) error {
+	return s.usage.Run(ctx, s.rdb, []string{usageKey(deploymentID)}, requests, tokens, window.Milliseconds()).Err()
+}
+
+// Usage implements StateStore.
+func (s *RedisStateStore) Usage(ctx context.Context, deploymentIDs []string) (map[string]DeploymentUsage, error) {
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(deploymentIDs))
+	for i, id := range deploymentIDs {
+		cmds[i] = pipe.HMGet(ctx, usageKey(id), "requests", "tokens")
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	result := make(map[string]DeploymentUsage, len(deploymentIDs))
+	for i, cmd := range cmds {
+		vals := cmd.Val()
+		if len(vals) != 2 {
+			continue
+		}
+		result[deploymentIDs[i]] = DeploymentUsage{
+			Requests: parseRedisInt(vals[0]),
+			Tokens:   parseRedisInt(vals[1]),
+		}
+	}
+	return result, nil
+}
+
+// getAll pipelines a GET for each deployment's key.
+func (s *RedisStateStore) getAll(ctx context.Context, deploymentIDs []string, key func(string) string) ([]*redis.StringCmd, error) {
+	pipe := s.rdb.Pipeline()
+	cmds := make([]*redis.StringCmd, len(deploymentIDs))
+	for i, id := range deploymentIDs {
+		cmds[i] = pipe.Get(ctx, key(id))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	return cmds, nil
+}
+
+func parseRedisInt(v any) int64 {
+	s, ok := v.(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
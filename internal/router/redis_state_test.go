@@ -0,0 +1,108 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupStateStore(t *testing.T) (*RedisStateStore, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	return NewRedisStateStore(rdb), mr
+}
+
+func TestRedisStateStore_Cooldowns(t *testing.T) {
+	s, mr := setupStateStore(t)
+	ctx := context.Background()
+
+	n, err := s.IncrFailures(ctx, "a", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	require.NoError(t, s.SetCooldown(ctx, "a", time.Minute))
+	assert.False(t, mr.Exists(failuresKey("a")), "cooldown clears failures")
+
+	cooldowns, err := s.Cooldowns(ctx, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Contains(t, cooldowns, "a")
+	assert.NotContains(t, cooldowns, "b")
+
+	mr.FastForward(2 * time.Minute)
+	cooldowns, err = s.Cooldowns(ctx, []string{"a"})
+	require.NoError(t, err)
+	assert.Empty(t, cooldowns)
+}
+
+func TestRedisStateStore_InflightNeverNegative(t *testing.T) {
+	s, _ := setupStateStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.AddInflight(ctx, "a", 2))
+	require.NoError(t, s.AddInflight(ctx, "a", -3))
+	require.NoError(t, s.AddInflight(ctx, "b", 1))
+
+	counts, err := s.Inflight(ctx, []string{"a", "b", "c"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 0, "b": 1, "c": 0}, counts)
+}
+
+func TestRedisStateStore_UsageWindow(t *testing.T) {
+	s, mr := setupStateStore(t)
+	ctx := context.Background()
+
+	require.NoError(t, s.AddUsage(ctx, "a", 1, 100, time.Minute))
+	require.NoError(t, s.AddUsage(ctx, "a", 1, 50, time.Minute))
+
+	usage, err := s.Usage(ctx, []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, DeploymentUsage{Requests: 2, Tokens: 150}, usage["a"])
+	assert.Equal(t, DeploymentUsage{}, usage["b"])
+
+	mr.FastForward(time.Minute + time.Second)
+	usage, err = s.Usage(ctx, []string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, DeploymentUsage{}, usage["a"])
+}
+
+func TestSharedState_CooldownSeenByOtherReplica(t *testing.T) {
+	s, _ := setupStateStore(t)
+	settings := RouterSettings{AllowedFails: 2, CooldownTime: time.Minute, State: s}
+	models := executeModels("gpt-4o", "gpt-4o")
+
+	replicaA := New(models, &roundRobinStrategy{}, settings)
+	replicaB := New(models, &roundRobinStrategy{}, settings)
+
+	// One failure on each replica reaches AllowedFails cluster-wide.
+	replicaA.RecordOutcome(replicaA.GetDeployments("gpt-4o")[0], http.StatusInternalServerError, nil, 0)
+	replicaB.RecordOutcome(replicaB.GetDeployments("gpt-4o")[0], http.StatusInternalServerError, nil, 0)
+
+	for i := 0; i < 4; i++ {
+		d, _, err := replicaA.Route(context.Background(), "gpt-4o", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "gpt-4o-1", d.ID, "replica A should skip the deployment cooled down by replica B")
+	}
+}
+
+func TestSharedState_RateLimitSharedImmediately(t *testing.T) {
+	s, _ := setupStateStore(t)
+	settings := RouterSettings{CooldownTime: time.Minute, State: s}
+	models := executeModels("gpt-4o", "gpt-4o")
+
+	replicaA := New(models, &roundRobinStrategy{}, settings)
+	replicaB := New(models, &roundRobinStrategy{}, settings)
+
+	replicaB.RecordOutcome(replicaB.GetDeployments("gpt-4o")[1], http.StatusTooManyRequests, nil, 0)
+
+	d, _, err := replicaA.Route(context.Background(), "gpt-4o", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o-0", d.ID)
+	assert.False(t, replicaA.GetDeployments("gpt-4o")[1].IsHealthy())
+}
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+)
+
+// ReplaceDynamicModels swaps the DB-managed deployments for models, keyed by
+// model_id. Deployments from the config file are left untouched. A model
+// whose config is unchanged keeps its *Deployment, so cooldowns, latency
+// and in-flight counts survive the reload; in-flight requests on removed
+// deployments finish normally. Models whose provider cannot be built are
+// left out and reported in the returned error.
+func (r *Router) ReplaceDynamicModels(models map[string]config.ModelConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var errs []error
+	dynamic := make(map[string]*Deployment, len(models))
+	for id, m := range models {
+		d := r.reuseOrNew(id, m)
+		if d.providerErr != nil {
+			errs = append(errs, fmt.Errorf("model %s: %w", id, d.providerErr))
+			continue
+		}
+		dynamic[id] = d
+	}
+	r.dynamic = dynamic
+	r.rebuild()
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+// UpsertModel adds or replaces the DB-managed deployment with model_id id.
+// When its provider cannot be built the model is removed instead and the
+// error returned.
+func (r *Router) UpsertModel(id string, m config.ModelConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d := r.reuseOrNew(id, m)
+	if d.providerErr != nil {
+		delete(r.dynamic, id)
+		r.rebuild()
+		return d.providerErr
+	}
+	if r.dynamic == nil {
+		r.dynamic = make(map[string]*Deployment)
+	}
+	r.dynamic[id] = d
+	r.rebuild()
+	return nil
+}
+
+// RemoveModel removes the DB-managed deployment with model_id id.
+func (r *Router) RemoveModel(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.dynamic[id]; !ok {
+		return
+	}
+	delete(r.dynamic, id)
+	r.rebuild()
+}
+
+// reuseOrNew returns the current deployment for id when its config is
+// unchanged, or a fresh one. Caller must hold r.mu.
+func (r *Router) reuseOrNew(id string, m config.ModelConfig) *Deployment {
+	if d, ok := r.dynamic[id]; ok && reflect.DeepEqual(*d.Config, m) {
+		return d
+	}
+	return r.newDeployment(id, &m)
+}
+
+// rebuild recomputes the model group index from the config file deployments
+// followed by the DB-managed ones. Caller must hold r.mu.
+func (r *Router) rebuild() {
+	deployments := make(map[string][]*Deployment, len(r.static)+len(r.dynamic))
+	for name, deps := range r.static {
+		deployments[name] = append([]*Deployment(nil), deps...)
+	}
+
+	ids := make([]string, 0, len(r.dynamic))
+	for id := range r.dynamic {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		d := r.dynamic[id]
+		deployments[d.Config.ModelName] = append(deployments[d.Config.ModelName], d)
+	}
+	r.deployments = deployments
+}
@@ -0,0 +1,83 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dbModel(name, upstream string) config.ModelConfig {
+	apiKey := "sk-db"
+	return config.ModelConfig{
+		ModelName:    name,
+		TianjiParams: config.TianjiParams{Model: upstream, APIKey: &apiKey},
+	}
+}
+
+func TestUpsertModel_RoutesWithoutRestart(t *testing.T) {
+	r := New(executeModels("gpt-4o"), &roundRobinStrategy{}, RouterSettings{})
+
+	_, _, err := r.Route(context.Background(), "claude-3", nil)
+	require.ErrorIs(t, err, ErrNoDeployments)
+
+	require.NoError(t, r.UpsertModel("model-uuid-1", dbModel("claude-3", "openai/claude-3-opus")))
+	d, _, err := r.Route(context.Background(), "claude-3", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "model-uuid-1", d.ID)
+	assert.Equal(t, "claude-3-opus", d.ModelName)
+
+	require.NoError(t, r.UpsertModel("model-uuid-2", dbModel("gpt-4o", "openai/gpt-4o-mini")))
+	assert.Len(t, r.GetDeployments("gpt-4o"), 2, "DB models join config model groups")
+
+	r.RemoveModel("model-uuid-1")
+	_, _, err = r.Route(context.Background(), "claude-3", nil)
+	assert.ErrorIs(t, err, ErrNoDeployments)
+	assert.Len(t, r.GetDeployments("gpt-4o"), 2)
+}
+
+func TestReplaceDynamicModels_PreservesUnchangedDeployments(t *testing.T) {
+	r := New(executeModels("gpt-4o"), &roundRobinStrategy{}, RouterSettings{AllowedFails: 1})
+	require.NoError(t, r.ReplaceDynamicModels(map[string]config.ModelConfig{
+		"a": dbModel("claude-3", "openai/claude-3-opus"),
+		"b": dbModel("claude-3", "openai/claude-3-sonnet"),
+	}))
+	before := r.GetDeployments("claude-3")
+	require.Len(t, before, 2)
+	before[0].RecordFailure()
+	require.False(t, before[0].IsHealthy())
+
+	require.NoError(t, r.ReplaceDynamicModels(map[string]config.ModelConfig{
+		"a": dbModel("claude-3", "openai/claude-3-opus"),
+		"b": dbModel("claude-3", "openai/claude-3-haiku"),
+	}))
+	after := r.GetDeployments("claude-3")
+	require.Len(t, after, 2)
+	assert.Same(t, before[0], after[0], "unchanged model keeps its health state")
+	assert.False(t, after[0].IsHealthy())
+	assert.NotSame(t, before[1], after[1], "edited model gets a fresh deployment")
+	assert.Equal(t, "claude-3-haiku", after[1].ModelName)
+
+	require.NoError(t, r.ReplaceDynamicModels(nil))
+	assert.Empty(t, r.GetDeployments("claude-3"))
+	assert.Len(t, r.GetDeployments("gpt-4o"), 1, "config models are never removed")
+}
+
+func TestUpsertModel_RejectsUnbuildableProvider(t *testing.T) {
+	r := New(nil, &roundRobinStrategy{}, RouterSettings{})
+	require.NoError(t, r.UpsertModel("m1", dbModel("claude-3", "openai/claude-3-opus")))
+
+	// An edit that breaks the provider removes the model.
+	assert.Error(t, r.UpsertModel("m1", dbModel("claude-3", "unknown_provider/claude-3-opus")))
+	assert.Empty(t, r.GetDeployments("claude-3"))
+
+	err := r.ReplaceDynamicModels(map[string]config.ModelConfig{
+		"m1": dbModel("claude-3", "openai/claude-3-opus"),
+		"m2": dbModel("claude-3", "unknown_provider/claude-3-opus"),
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "model m2")
+	assert.Len(t, r.GetDeployments("claude-3"), 1)
+}
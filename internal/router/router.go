@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/praxisllmlab/tianjiLLM/internal/httpclient"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider"
 	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
@@ -22,11 +23,32 @@ var ErrNoDeployments = errors.New("no deployments")
 // ErrAccessDenied is returned when deployments exist but none are accessible to the caller.
 var ErrAccessDenied = errors.New("access denied")
 
+// ErrAtCapacity is returned when every deployment for a model is at its
+// max_parallel_requests cap.
+var ErrAtCapacity = errors.New("all deployments at max_parallel_requests")
+
 // Strategy selects a deployment from a list of healthy deployments.
 type Strategy interface {
 	Pick(deployments []*Deployment) *Deployment
 }
 
+// InflightTracker is implemented by strategies that count in-flight
+// requests. The Router calls Acquire before each upstream call and Release
+// when its response or stream finishes.
+type InflightTracker interface {
+	Acquire(deploymentID string)
+	Release(deploymentID string)
+}
+
+// UsageRecorder is implemented by strategies that route on the requests and
+// tokens each deployment serves. The Router records a request for each
+// upstream call that gets a response, and handlers add the tokens of
+// successful calls through Router.RecordTokens once they are known.
+type UsageRecorder interface {
+	RecordRequest(deploymentID string)
+	RecordTokens(deploymentID string, tokens int64)
+}
+
 // TagPicker extends Strategy with tag-based filtering.
 type TagPicker interface {
 	Strategy
@@ -40,16 +62,40 @@ type AutoRouterFunc func(ctx context.Context, lastUserMessage string) (string, e
 type Router struct {
 	mu          sync.RWMutex
 	deployments map[string][]*Deployment // modelName → deployments
+	static      map[string][]*Deployment // deployments from the config file
+	dynamic     map[string]*Deployment   // DB model_id → deployment
 	strategy    Strategy
 	settings    RouterSettings
+	state       StateStore                // fixed at construction; Reconfigure keeps it
 	autoRouters map[string]AutoRouterFunc // prefix → router
+	clients     *httpclient.Factory       // upstream HTTP clients shared by deployments
 }
 
 // RouterSettings configures router behavior.
 type RouterSettings struct {
 	AllowedFails int
 	CooldownTime time.Duration
-	NumRetries   int
+
+	// NumRetries is how many more deployments of a model group are tried
+	// after the first fails. Nil uses the default of 2; 0 disables retries.
+	NumRetries *int
+
+	// RetryAfter is the minimum wait before retrying a failed upstream call.
+	// Overridden per model group by RetryPolicy.RetryAfterSeconds.
+	RetryAfter time.Duration
+
+	// MaxFallbacks caps how many fallback model groups Execute walks
+	// after the requested group is exhausted. Nil uses the default of 5;
+	// 0 disables fallbacks.
+	MaxFallbacks *int
+
+	// Timeout bounds an upstream call when neither the deployment's
+	// tianji_params.timeout nor the group's retry policy sets one.
+	Timeout time.Duration
+
+	// StreamTimeout aborts an upstream stream that produces nothing for this
+	// long, unless the deployment sets tianji_params.stream_timeout.
+	StreamTimeout time.Duration
 
 	// ContextWindowFallbacks maps model names to fallback models with larger
 	// context windows. When a request exceeds a model's context window,
@@ -74,11 +120,23 @@ type RouterSettings struct {
 	// ModelGroupRetryPolicy provides per-model-group retry/timeout config.
 	ModelGroupRetryPolicy map[string]RetryPolicy
 
+	// ModelGroupHedgePolicy enables hedged requests for latency-critical
+	// model groups. See HedgePolicy.
+	ModelGroupHedgePolicy map[string]HedgePolicy
+
 	// EnableTagFiltering enables tag-based deployment filtering.
 	EnableTagFiltering bool
 
 	// TagFilteringMatchAny uses OR logic for tag matching when true (AND when false).
 	TagFilteringMatchAny bool
+
+	// DefaultMaxParallelRequests caps in-flight requests per deployment
+	// when tianji_params.max_parallel_requests is unset. 0 means unlimited.
+	DefaultMaxParallelRequests int
+
+	// State shares cooldowns and strategy counters across replicas.
+	// Nil keeps all router state in process.
+	State StateStore
 }
 
 // ModelGroupAliasItem maps an alias to a target model group.
@@ -90,46 +148,80 @@ type ModelGroupAliasItem struct {
 
 // RetryPolicy configures per-model-group retry behavior.
 type RetryPolicy struct {
-	NumRetries        int
+	NumRetries        *int // nil uses RouterSettings.NumRetries
 	TimeoutSeconds    int
 	RetryAfterSeconds int
 }
 
 // New creates a Router from model configs and strategy.
 func New(models []config.ModelConfig, strategy Strategy, settings RouterSettings) *Router {
-	if settings.AllowedFails == 0 {
-		settings.AllowedFails = 3
-	}
-	if settings.CooldownTime == 0 {
-		settings.CooldownTime = 60 * time.Second
-	}
-	if settings.NumRetries == 0 {
-		settings.NumRetries = 2
+	applyDefaults(&settings)
+
+	r := &Router{
+		strategy: strategy,
+		settings: settings,
+		state:    settings.State,
+		clients:  httpclient.NewFactory(),
 	}
 
-	deployments := make(map[string][]*Deployment)
+	static := make(map[string][]*Deployment)
 	for i := range models {
 		m := &models[i]
-		providerName, modelName := provider.ParseModelName(m.TianjiParams.Model)
-
-		d := &Deployment{
-			ID:           fmt.Sprintf("%s-%d", m.ModelName, i),
-			ProviderName: providerName,
-			ModelName:    modelName,
-			Region:       m.TianjiParams.Region,
-			Config:       m,
-			allowedFails: settings.AllowedFails,
-			cooldownTime: settings.CooldownTime,
-		}
+		d := r.newDeployment(fmt.Sprintf("%s-%d", m.ModelName, i), m)
+		static[m.ModelName] = append(static[m.ModelName], d)
+	}
+	r.static = static
+	r.deployments = static
+
+	if sa, ok := strategy.(StateAware); ok && settings.State != nil {
+		sa.SetStateStore(settings.State)
+	}
+
+	return r
+}
+
+// newDeployment builds a deployment for model config m using the router's
+// health and capacity settings. Caller must hold r.mu once r is shared.
+func (r *Router) newDeployment(id string, m *config.ModelConfig) *Deployment {
+	providerName, modelName := provider.ParseModelName(m.TianjiParams.Model)
 
-		deployments[m.ModelName] = append(deployments[m.ModelName], d)
+	maxParallel := r.settings.DefaultMaxParallelRequests
+	if m.TianjiParams.MaxParallelRequests != nil {
+		maxParallel = *m.TianjiParams.MaxParallelRequests
 	}
 
-	return &Router{
-		deployments: deployments,
-		strategy:    strategy,
-		settings:    settings,
+	d := &Deployment{
+		ID:           id,
+		ProviderName: providerName,
+		ModelName:    modelName,
+		Region:       m.TianjiParams.Region,
+		Config:       m,
+		allowedFails: r.settings.AllowedFails,
+		cooldownTime: r.settings.CooldownTime,
+		maxParallel:  maxParallel,
+		state:        r.state,
+		client:       r.httpClient(m),
 	}
+	d.provider, d.providerErr = provider.GetConfigured(providerName, ProviderParams(&m.TianjiParams))
+	return d
+}
+
+// ProviderErr reports the config file deployments whose provider could not
+// be built, such as from unreadable vertex_credentials, or nil when every
+// provider was.
+func (r *Router) ProviderErr() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var errs []error
+	for _, deps := range r.static {
+		for _, d := range deps {
+			if d.providerErr != nil {
+				errs = append(errs, fmt.Errorf("deployment %s: %w", d.ID, d.providerErr))
+			}
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
 }
 
 // RegisterAutoRouter registers a semantic auto-router for a model prefix.
@@ -159,12 +251,20 @@ func (r *Router) Route(ctx context.Context, modelName string, req *model.ChatCom
 	}
 
 	// Resolve model group alias before deployment lookup
-	if alias, ok := r.settings.ModelGroupAlias[modelName]; ok {
+	if alias, ok := r.Settings().ModelGroupAlias[modelName]; ok {
 		modelName = alias.Model
 	}
 
+	return r.route(ctx, modelName, req, nil)
+}
+
+// route picks a deployment for an alias-resolved model name, skipping any
+// deployment IDs in exclude.
+func (r *Router) route(ctx context.Context, modelName string, req *model.ChatCompletionRequest, exclude map[string]bool) (*Deployment, provider.Provider, error) {
 	r.mu.RLock()
 	allDeployments := r.deployments[modelName]
+	strategy := r.strategy
+	tagFiltering, tagMatchAny := r.settings.EnableTagFiltering, r.settings.TagFilteringMatchAny
 	r.mu.RUnlock()
 
 	if len(allDeployments) == 0 {
@@ -180,19 +280,25 @@ func (r *Router) Route(ctx context.Context, modelName string, req *model.ChatCom
 		return nil, nil, fmt.Errorf("%w for model %q", ErrAccessDenied, modelName)
 	}
 
+	r.syncCooldowns(ctx, allDeployments)
 	healthy := r.healthyDeployments(allDeployments)
 	if len(healthy) == 0 {
 		// All in cooldown — try them anyway as last resort
 		healthy = allDeployments
 	}
 
-	// Use per-group retry policy if configured, else global
-	numRetries := r.settings.NumRetries
-	if groupPolicy, ok := r.settings.ModelGroupRetryPolicy[modelName]; ok && groupPolicy.NumRetries > 0 {
-		numRetries = groupPolicy.NumRetries
+	// Skip deployments at their max_parallel_requests cap.
+	healthy = withCapacity(healthy)
+	if len(healthy) == 0 {
+		return nil, nil, fmt.Errorf("%w for model %q", ErrAtCapacity, modelName)
 	}
 
-	tried := make(map[string]bool)
+	numRetries := r.retryPolicy(modelName).retries()
+
+	tried := make(map[string]bool, len(exclude))
+	for id := range exclude {
+		tried[id] = true
+	}
 	for attempt := 0; attempt <= numRetries; attempt++ {
 		available := filterUntried(healthy, tried)
 		if len(available) == 0 {
@@ -200,26 +306,22 @@ func (r *Router) Route(ctx context.Context, modelName string, req *model.ChatCom
 		}
 
 		var d *Deployment
-		if r.settings.EnableTagFiltering {
-			if tp, ok := r.strategy.(TagPicker); ok {
+		if tagFiltering {
+			if tp, ok := strategy.(TagPicker); ok {
 				tags := extractTags(req)
-				d = tp.PickWithTags(available, tags, r.settings.TagFilteringMatchAny)
+				d = tp.PickWithTags(available, tags, tagMatchAny)
 			} else {
-				d = r.strategy.Pick(available)
+				d = strategy.Pick(available)
 			}
 		} else {
-			d = r.strategy.Pick(available)
+			d = strategy.Pick(available)
 		}
 		if d == nil {
 			break
 		}
 		tried[d.ID] = true
 
-		apiBase := ""
-		if d.Config.TianjiParams.APIBase != nil {
-			apiBase = *d.Config.TianjiParams.APIBase
-		}
-		p, err := provider.GetWithBaseURL(d.ProviderName, apiBase)
+		p, err := d.Provider()
 		if err != nil {
 			d.RecordFailure()
 			continue
@@ -234,24 +336,42 @@ func (r *Router) Route(ctx context.Context, modelName string, req *model.ChatCom
 // ContextWindowFallback returns the fallback model deployments when a request
 // exceeds the current model's context window. Returns nil if no fallbacks configured.
 func (r *Router) ContextWindowFallback(modelName string) (*Deployment, provider.Provider, error) {
-	fallbacks := r.settings.ContextWindowFallbacks
-	if len(fallbacks) == 0 {
-		return nil, nil, fmt.Errorf("no context window fallbacks configured for %q", modelName)
-	}
+	return r.routeFallback("context window", modelName, r.ErrorFallbacks(modelName, model.ErrContextWindowExceeded))
+}
 
-	models, ok := fallbacks[modelName]
-	if !ok || len(models) == 0 {
-		return nil, nil, fmt.Errorf("no context window fallbacks configured for %q", modelName)
+// Acquire reserves an in-flight slot on d for one upstream call and notifies
+// an InflightTracker strategy. ok is false when d is at max_parallel_requests.
+// The returned release func is safe to call more than once.
+func (r *Router) Acquire(d *Deployment) (release func(), ok bool) {
+	if !d.tryAcquire() {
+		return func() {}, false
 	}
-
-	for _, fallbackModel := range models {
-		d, p, err := r.Route(context.Background(), fallbackModel, nil)
-		if err == nil {
-			return d, p, nil
-		}
+	id := d.health().ID
+	tracker, _ := r.currentStrategy().(InflightTracker)
+	if tracker != nil {
+		tracker.Acquire(id)
 	}
 
-	return nil, nil, fmt.Errorf("all context window fallbacks exhausted for %q", modelName)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.release()
+			if tracker != nil {
+				tracker.Release(id)
+			}
+		})
+	}, true
+}
+
+// RecordTokens adds the tokens a call to d used to a UsageRecorder
+// strategy's usage windows.
+func (r *Router) RecordTokens(d *Deployment, tokens int64) {
+	if d == nil || tokens <= 0 {
+		return
+	}
+	if u, ok := r.currentStrategy().(UsageRecorder); ok {
+		u.RecordTokens(d.health().ID, tokens)
+	}
 }
 
 // RecordSuccess records a successful call on a deployment.
@@ -264,9 +384,14 @@ func (r *Router) RecordFailure(d *Deployment) {
 	d.RecordFailure()
 }
 
+// RecordTTFT records the time to first token of a streaming call on a deployment.
+func (r *Router) RecordTTFT(d *Deployment, ttft time.Duration) {
+	d.RecordTTFT(ttft)
+}
+
 // ModelGroupAlias returns the configured model group alias map.
 func (r *Router) ModelGroupAlias() map[string]ModelGroupAliasItem {
-	return r.settings.ModelGroupAlias
+	return r.Settings().ModelGroupAlias
 }
 
 // GetDeployments returns all deployments for a model (for testing).
@@ -322,6 +447,16 @@ func (r *Router) healthyDeployments(all []*Deployment) []*Deployment {
 	return healthy
 }
 
+func withCapacity(deployments []*Deployment) []*Deployment {
+	result := make([]*Deployment, 0, len(deployments))
+	for _, d := range deployments {
+		if !d.AtCapacity() {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
 func filterUntried(deployments []*Deployment, tried map[string]bool) []*Deployment {
 	result := make([]*Deployment, 0, len(deployments))
 	for _, d := range deployments {
@@ -392,6 +527,7 @@ func (r *Router) wildcardMatch(modelName string) []*Deployment {
 			ModelName:    resolvedModelName,
 			Region:       d.Region,
 			Config:       d.Config,
+			base:         d.health(),
 			allowedFails: d.allowedFails,
 			cooldownTime: d.cooldownTime,
 		}
@@ -9,6 +9,7 @@ import (
 	"github.com/praxisllmlab/tianjiLLM/internal/config"
 	"github.com/praxisllmlab/tianjiLLM/internal/model"
 	"github.com/praxisllmlab/tianjiLLM/internal/provider"
+	_ "github.com/praxisllmlab/tianjiLLM/internal/provider/bedrock"
 	_ "github.com/praxisllmlab/tianjiLLM/internal/provider/openai"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -75,7 +76,7 @@ func TestRouter_Fallback_OnProviderFailure(t *testing.T) {
 		},
 	}
 
-	r := New(models, &roundRobinStrategy{}, RouterSettings{NumRetries: 2})
+	r := New(models, &roundRobinStrategy{}, RouterSettings{NumRetries: intPtr(2)})
 	req := &model.ChatCompletionRequest{Model: "gpt-4o"}
 
 	d, p, err := r.Route(context.Background(), "gpt-4o", req)
@@ -85,6 +86,19 @@ func TestRouter_Fallback_OnProviderFailure(t *testing.T) {
 	assert.Equal(t, "openai", d.ProviderName, "should fallback to openai deployment")
 }
 
+func TestRouter_ProviderErr(t *testing.T) {
+	apiKey := "sk-test"
+	models := []config.ModelConfig{
+		{ModelName: "gpt-4o", TianjiParams: config.TianjiParams{Model: "openai/gpt-4o", APIKey: &apiKey}},
+		{ModelName: "broken", TianjiParams: config.TianjiParams{Model: "unknown_provider/gpt-4o", APIKey: &apiKey}},
+	}
+	err := New(models, &roundRobinStrategy{}, RouterSettings{}).ProviderErr()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "deployment broken-1")
+
+	assert.NoError(t, New(models[:1], &roundRobinStrategy{}, RouterSettings{}).ProviderErr())
+}
+
 func TestRouter_Cooldown(t *testing.T) {
 	apiKey := "sk-test"
 	models := []config.ModelConfig{
@@ -195,6 +209,39 @@ func TestRouter_Route_ExactMatchOverWildcard(t *testing.T) {
 	assert.Equal(t, "claude-opus", d.ModelName)
 }
 
+func TestRouter_Route_PerDeploymentProvider(t *testing.T) {
+	models := []config.ModelConfig{
+		{
+			ModelName: "claude",
+			TianjiParams: config.TianjiParams{
+				Model:         "bedrock/anthropic.claude-3-haiku-20240307-v1:0",
+				AWSRegionName: "us-east-1",
+			},
+		},
+		{
+			ModelName: "claude",
+			TianjiParams: config.TianjiParams{
+				Model:         "bedrock/anthropic.claude-3-haiku-20240307-v1:0",
+				AWSRegionName: "eu-west-1",
+			},
+		},
+	}
+	r := New(models, &roundRobinStrategy{}, RouterSettings{})
+	req := &model.ChatCompletionRequest{Model: "claude"}
+
+	var urls []string
+	var providers []provider.Provider
+	for i := 0; i < 3; i++ {
+		_, p, err := r.Route(context.Background(), "claude", req)
+		require.NoError(t, err)
+		providers = append(providers, p)
+		urls = append(urls, p.GetRequestURL("m"))
+	}
+	assert.Contains(t, urls[0], "bedrock-runtime.us-east-1.")
+	assert.Contains(t, urls[1], "bedrock-runtime.eu-west-1.")
+	assert.Same(t, providers[0], providers[2], "a deployment keeps its provider")
+}
+
 func TestRouter_RecordSuccess_ResetsFailures(t *testing.T) {
 	// Verify provider is registered
 	_, err := provider.Get("openai")
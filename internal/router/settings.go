@@ -0,0 +1,267 @@
+package router
+
+import (
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+)
+
+// SettingsFromConfig converts the router_settings section of cfg into
+// RouterSettings. Fallbacks fall back to tianji_settings when router_settings
+// has none. State is left nil; callers wire a StateStore separately.
+func SettingsFromConfig(cfg *config.ProxyConfig) RouterSettings {
+	settings := RouterSettings{}
+	if t := cfg.TianjiSettings.RequestTimeout; t != nil {
+		settings.Timeout = time.Duration(*t) * time.Second
+	}
+	rs := cfg.RouterSettings
+	if rs == nil {
+		return settings
+	}
+
+	settings.NumRetries = copyInt(rs.NumRetries)
+	if rs.AllowedFails != nil {
+		settings.AllowedFails = *rs.AllowedFails
+	}
+	if rs.CooldownTime != nil {
+		settings.CooldownTime = time.Duration(*rs.CooldownTime) * time.Second
+	}
+	if rs.RetryAfter != nil {
+		settings.RetryAfter = time.Duration(*rs.RetryAfter) * time.Second
+	}
+	settings.MaxFallbacks = copyInt(rs.MaxFallbacks)
+	if rs.Timeout != nil {
+		settings.Timeout = time.Duration(*rs.Timeout) * time.Second
+	}
+	if rs.StreamTimeout != nil {
+		settings.StreamTimeout = time.Duration(*rs.StreamTimeout) * time.Second
+	}
+	if rs.DefaultMaxParallelRequests != nil {
+		settings.DefaultMaxParallelRequests = *rs.DefaultMaxParallelRequests
+	}
+
+	settings.ModelGroupAlias = parseModelGroupAlias(rs.ModelGroupAlias)
+
+	// Fallbacks from router_settings take precedence over tianji_settings.
+	settings.Fallbacks = parseFallbackMaps(rs.Fallbacks)
+	if len(settings.Fallbacks) == 0 {
+		settings.Fallbacks = parseFallbackStringMaps(cfg.TianjiSettings.Fallbacks)
+	}
+	settings.DefaultFallbacks = rs.DefaultFallbacks
+	if len(settings.DefaultFallbacks) == 0 {
+		settings.DefaultFallbacks = cfg.TianjiSettings.DefaultFallbacks
+	}
+	settings.ContentPolicyFallbacks = parseFallbackMaps(rs.ContentPolicyFallbacks)
+	settings.ContextWindowFallbacks = parseFallbackStringMaps(cfg.TianjiSettings.ContextWindowFallbacks)
+
+	settings.ModelGroupRetryPolicy = parseRetryPolicies(rs.ModelGroupRetryPolicy)
+	settings.ModelGroupHedgePolicy = parseHedgePolicies(rs.ModelGroupHedgePolicy)
+
+	settings.EnableTagFiltering = rs.EnableTagFiltering
+	settings.TagFilteringMatchAny = rs.TagFilteringMatchAny
+	return settings
+}
+
+// Settings returns a copy of the router's current settings.
+func (r *Router) Settings() RouterSettings {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.settings
+}
+
+// currentStrategy returns the router's current strategy.
+func (r *Router) currentStrategy() Strategy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.strategy
+}
+
+// Reconfigure atomically swaps the router's strategy and settings. Requests
+// already routed finish under the old configuration. Existing deployments
+// keep their health state but adopt the new failure and capacity limits.
+// The StateStore is fixed at construction and cannot be changed here.
+func (r *Router) Reconfigure(strategy Strategy, settings RouterSettings) {
+	applyDefaults(&settings)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	settings.State = r.state
+	if sa, ok := strategy.(StateAware); ok && settings.State != nil {
+		sa.SetStateStore(settings.State)
+	}
+	r.strategy = strategy
+	r.settings = settings
+
+	for _, deps := range r.static {
+		r.applyLimits(deps...)
+	}
+	for _, d := range r.dynamic {
+		r.applyLimits(d)
+	}
+}
+
+// applyLimits updates deployments to the router's failure, capacity and
+// timeout settings. Caller must hold r.mu.
+func (r *Router) applyLimits(deployments ...*Deployment) {
+	for _, d := range deployments {
+		maxParallel := r.settings.DefaultMaxParallelRequests
+		if d.Config.TianjiParams.MaxParallelRequests != nil {
+			maxParallel = *d.Config.TianjiParams.MaxParallelRequests
+		}
+		client := r.httpClient(d.Config)
+		d.mu.Lock()
+		d.allowedFails = r.settings.AllowedFails
+		d.cooldownTime = r.settings.CooldownTime
+		d.maxParallel = maxParallel
+		d.client = client
+		d.mu.Unlock()
+	}
+}
+
+// applyDefaults fills unset settings with the router defaults. An explicit
+// NumRetries or MaxFallbacks of 0 is kept.
+func applyDefaults(settings *RouterSettings) {
+	if settings.AllowedFails == 0 {
+		settings.AllowedFails = 3
+	}
+	if settings.CooldownTime == 0 {
+		settings.CooldownTime = 60 * time.Second
+	}
+	if settings.NumRetries == nil {
+		n := 2
+		settings.NumRetries = &n
+	}
+	if settings.MaxFallbacks == nil {
+		n := 5
+		settings.MaxFallbacks = &n
+	}
+}
+
+// copyInt returns a copy of *n, so settings never share a pointer with the
+// config they came from.
+func copyInt(n *int) *int {
+	if n == nil {
+		return nil
+	}
+	v := *n
+	return &v
+}
+
+// parseModelGroupAlias converts config map[string]any to typed alias map.
+// Supports both string shorthand ("alias": "model") and object form ("alias": {"model": "x", "hidden": true}).
+func parseModelGroupAlias(raw map[string]any) map[string]ModelGroupAliasItem {
+	if len(raw) == 0 {
+		return nil
+	}
+	result := make(map[string]ModelGroupAliasItem, len(raw))
+	for alias, v := range raw {
+		switch val := v.(type) {
+		case string:
+			result[alias] = ModelGroupAliasItem{Model: val}
+		case map[string]any:
+			item := ModelGroupAliasItem{}
+			if m, ok := val["model"].(string); ok {
+				item.Model = m
+			}
+			if h, ok := val["hidden"].(bool); ok {
+				item.Hidden = h
+			}
+			result[alias] = item
+		}
+	}
+	return result
+}
+
+// parseFallbackMaps converts []map[string]any to map[string][]string.
+// Input format: [{"gpt-4": ["claude-3", "gemini"]}]
+func parseFallbackMaps(raw []map[string]any) map[string][]string {
+	if len(raw) == 0 {
+		return nil
+	}
+	result := make(map[string][]string)
+	for _, m := range raw {
+		for k, v := range m {
+			if arr, ok := v.([]any); ok {
+				strs := make([]string, 0, len(arr))
+				for _, item := range arr {
+					if s, ok := item.(string); ok {
+						strs = append(strs, s)
+					}
+				}
+				result[k] = strs
+			}
+		}
+	}
+	return result
+}
+
+// parseFallbackStringMaps converts []map[string][]string to map[string][]string.
+func parseFallbackStringMaps(raw []map[string][]string) map[string][]string {
+	if len(raw) == 0 {
+		return nil
+	}
+	result := make(map[string][]string)
+	for _, m := range raw {
+		for k, v := range m {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// parseRetryPolicies converts config map[string]any to typed retry policies.
+func parseRetryPolicies(raw map[string]any) map[string]RetryPolicy {
+	if len(raw) == 0 {
+		return nil
+	}
+	result := make(map[string]RetryPolicy, len(raw))
+	for model, v := range raw {
+		m, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		p := RetryPolicy{}
+		if n, ok := intValue(m["num_retries"]); ok {
+			p.NumRetries = &n
+		}
+		if t, ok := intValue(m["timeout"]); ok {
+			p.TimeoutSeconds = t
+		}
+		if r, ok := intValue(m["retry_after"]); ok {
+			p.RetryAfterSeconds = r
+		}
+		result[model] = p
+	}
+	return result
+}
+
+// parseHedgePolicies converts config map[string]any to typed hedge policies.
+// Input format: {"gpt-4o": {"delay_ms": 500}}
+func parseHedgePolicies(raw map[string]any) map[string]HedgePolicy {
+	if len(raw) == 0 {
+		return nil
+	}
+	result := make(map[string]HedgePolicy, len(raw))
+	for model, v := range raw {
+		m, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		if ms, ok := intValue(m["delay_ms"]); ok && ms > 0 {
+			result[model] = HedgePolicy{Delay: time.Duration(ms) * time.Millisecond}
+		}
+	}
+	return result
+}
+
+// intValue accepts YAML ints and JSON numbers.
+func intValue(v any) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case float64:
+		return int(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettingsFromConfig(t *testing.T) {
+	retries, cooldown := 4, 30
+	cfg := &config.ProxyConfig{
+		RouterSettings: &config.RouterSettings{
+			NumRetries:   &retries,
+			CooldownTime: &cooldown,
+			Fallbacks:    []map[string]any{{"gpt-4o": []any{"claude-3"}}},
+			ModelGroupAlias: map[string]any{
+				"gpt4":   "gpt-4o",
+				"hidden": map[string]any{"model": "gpt-4o", "hidden": true},
+			},
+			ModelGroupRetryPolicy: map[string]any{
+				"gpt-4o": map[string]any{"num_retries": float64(1), "retry_after": 2},
+			},
+		},
+		TianjiSettings: config.TianjiSettings{DefaultFallbacks: []string{"claude-3"}},
+	}
+
+	s := SettingsFromConfig(cfg)
+	assert.Equal(t, 4, *s.NumRetries)
+	assert.Equal(t, 30*time.Second, s.CooldownTime)
+	assert.Equal(t, []string{"claude-3"}, s.Fallbacks["gpt-4o"])
+	assert.Equal(t, []string{"claude-3"}, s.DefaultFallbacks)
+	assert.Equal(t, ModelGroupAliasItem{Model: "gpt-4o"}, s.ModelGroupAlias["gpt4"])
+	assert.True(t, s.ModelGroupAlias["hidden"].Hidden)
+	assert.Equal(t, RetryPolicy{NumRetries: intPtr(1), RetryAfterSeconds: 2}, s.ModelGroupRetryPolicy["gpt-4o"])
+}
+
+func TestSettingsFromConfig_KeepsExplicitZero(t *testing.T) {
+	zero := 0
+	cfg := &config.ProxyConfig{
+		RouterSettings: &config.RouterSettings{NumRetries: &zero, MaxFallbacks: &zero},
+	}
+
+	r := New(nil, &roundRobinStrategy{}, SettingsFromConfig(cfg))
+	assert.Equal(t, 0, *r.Settings().NumRetries)
+	assert.Equal(t, 0, *r.Settings().MaxFallbacks)
+
+	r = New(nil, &roundRobinStrategy{}, SettingsFromConfig(&config.ProxyConfig{}))
+	assert.Equal(t, 2, *r.Settings().NumRetries, "unset settings use the defaults")
+	assert.Equal(t, 5, *r.Settings().MaxFallbacks)
+}
+
+func TestReconfigure_SwapsStrategyAndSettings(t *testing.T) {
+	tracker := &trackingStrategy{inflight: map[string]int{}}
+	r := New(executeModels("gpt-4o", "gpt-4o"), &roundRobinStrategy{}, RouterSettings{AllowedFails: 5})
+	d := r.GetDeployments("gpt-4o")[0]
+	d.RecordFailure()
+	require.True(t, d.IsHealthy())
+
+	r.Reconfigure(tracker, RouterSettings{
+		AllowedFails:    2,
+		NumRetries:      intPtr(1),
+		ModelGroupAlias: map[string]ModelGroupAliasItem{"gpt4": {Model: "gpt-4o"}},
+	})
+
+	assert.Equal(t, 1, *r.Settings().NumRetries)
+	assert.Equal(t, 60*time.Second, r.Settings().CooldownTime, "defaults apply to new settings")
+
+	d.RecordFailure()
+	assert.False(t, d.IsHealthy(), "existing deployments adopt the new allowed_fails")
+
+	got, _, err := r.Route(context.Background(), "gpt4", nil)
+	require.NoError(t, err)
+	release, ok := r.Acquire(got)
+	require.True(t, ok)
+	assert.Equal(t, 1, tracker.inflight[got.ID], "new strategy receives in-flight tracking")
+	release()
+}
@@ -0,0 +1,88 @@
+package router
+
+import (
+	"context"
+	"time"
+)
+
+// stateTimeout bounds StateStore calls made outside a request context.
+const stateTimeout = 250 * time.Millisecond
+
+// StateStore shares deployment state across proxy replicas so that
+// cooldowns, in-flight counts and usage windows observed by one replica are
+// honored by all of them. Deployment IDs are derived from the model list, so
+// replicas with the same config address the same deployments.
+//
+// Store errors are never fatal: callers fall back to the replica's local
+// state, the same way the rate limiter allows requests when Redis is down.
+type StateStore interface {
+	// SetCooldown puts a deployment into cooldown for d and clears its
+	// failure count.
+	SetCooldown(ctx context.Context, deploymentID string, d time.Duration) error
+	// Cooldowns returns the cooldown expiry of each listed deployment that
+	// is currently cooling down.
+	Cooldowns(ctx context.Context, deploymentIDs []string) (map[string]time.Time, error)
+	// IncrFailures increments a deployment's failure count, which expires
+	// after window, and returns the new count.
+	IncrFailures(ctx context.Context, deploymentID string, window time.Duration) (int64, error)
+	// ResetFailures clears a deployment's failure count.
+	ResetFailures(ctx context.Context, deploymentID string) error
+
+	// AddInflight adjusts a deployment's in-flight request count by delta.
+	AddInflight(ctx context.Context, deploymentID string, delta int64) error
+	// Inflight returns the in-flight request count of each listed deployment.
+	Inflight(ctx context.Context, deploymentIDs []string) (map[string]int64, error)
+
+	// AddUsage adds requests and tokens to a deployment's usage window,
+	// which starts on first use and lasts window.
+	AddUsage(ctx context.Context, deploymentID string, requests, tokens int64, window time.Duration) error
+	// Usage returns the current usage window of each listed deployment.
+	Usage(ctx context.Context, deploymentIDs []string) (map[string]DeploymentUsage, error)
+}
+
+// DeploymentUsage is a deployment's request and token count in the current
+// usage window.
+type DeploymentUsage struct {
+	Requests int64
+	Tokens   int64
+}
+
+// StateAware is implemented by strategies that keep per-deployment counters
+// and can share them through a StateStore. New hands the configured store to
+// the strategy via type assertion.
+type StateAware interface {
+	SetStateStore(store StateStore)
+}
+
+// StateContext returns a context bounded by the StateStore call timeout.
+func StateContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), stateTimeout)
+}
+
+// deploymentIDs returns the health-owning IDs of deployments.
+func deploymentIDs(deployments []*Deployment) []string {
+	ids := make([]string, len(deployments))
+	for i, d := range deployments {
+		ids[i] = d.health().ID
+	}
+	return ids
+}
+
+// syncCooldowns pulls shared cooldowns for deployments into their local state.
+func (r *Router) syncCooldowns(ctx context.Context, deployments []*Deployment) {
+	if r.state == nil || len(deployments) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, stateTimeout)
+	defer cancel()
+
+	cooldowns, err := r.state.Cooldowns(ctx, deploymentIDs(deployments))
+	if err != nil {
+		return
+	}
+	for _, d := range deployments {
+		if until, ok := cooldowns[d.health().ID]; ok {
+			d.coolDownUntil(until)
+		}
+	}
+}
@@ -2,12 +2,16 @@ package strategy
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
 	"github.com/praxisllmlab/tianjiLLM/internal/router"
 )
 
-// NewFromConfig creates a Strategy from a config string name.
+// NewFromConfig creates a Strategy from a config string name. The wrapping
+// strategies "region-pinned", "tag-based" and "budget-limited" need more
+// router_settings and are built by NewFromSettings only.
 func NewFromConfig(name string) (router.Strategy, error) {
 	switch name {
 	case "simple-shuffle", "":
@@ -24,7 +28,86 @@ func NewFromConfig(name string) (router.Strategy, error) {
 		return NewLowestTPMRPM(NewShuffle()), nil
 	case "priority":
 		return NewPriorityQueue(NewShuffle()), nil
+	case "region-pinned", "tag-based", "budget-limited":
+		return nil, fmt.Errorf("routing strategy %s wraps another strategy; build it from router_settings", name)
 	default:
 		return nil, fmt.Errorf("unknown routing strategy: %s", name)
 	}
 }
+
+// NewFromSettings creates the Strategy described by router_settings. The
+// base strategy comes from routing_strategy and is wrapped, innermost first:
+//
+//   - RegionPinned when routing_strategy_args.region is set
+//   - BudgetLimiter when provider_budget_config is set (spend must be non-nil)
+//   - TagBased when enable_tag_filtering is set
+//
+// For example, region-pinned lowest-latency routing with provider budgets:
+//
+//	router_settings:
+//	  routing_strategy: lowest-latency
+//	  routing_strategy_args:
+//	    region: eu
+//	  provider_budget_config:
+//	    openai: {budget_limit: 100, time_period: 1d}
+//
+// routing_strategy_args.usage_window (seconds) sets the usage-based window.
+//
+// routing_strategy may also name a wrapper directly, which then wraps
+// simple-shuffle: "region-pinned" requires routing_strategy_args.region,
+// "budget-limited" requires provider_budget_config and "tag-based" enables
+// tag filtering.
+func NewFromSettings(rs *config.RouterSettings, spend *ProviderSpend) (router.Strategy, error) {
+	if rs == nil {
+		return NewShuffle(), nil
+	}
+
+	region, _ := rs.RoutingStrategyArgs["region"].(string)
+	var s router.Strategy
+	switch {
+	case rs.RoutingStrategy == "region-pinned" && region == "":
+		return nil, fmt.Errorf("routing strategy region-pinned requires routing_strategy_args.region")
+	case rs.RoutingStrategy == "budget-limited" && len(rs.ProviderBudgetConfig) == 0:
+		return nil, fmt.Errorf("routing strategy budget-limited requires provider_budget_config")
+	case rs.RoutingStrategy == "region-pinned", rs.RoutingStrategy == "budget-limited", rs.RoutingStrategy == "tag-based":
+		s = NewShuffle()
+	case rs.RoutingStrategy == "usage-based":
+		window := time.Minute
+		if secs, ok := intArg(rs.RoutingStrategyArgs, "usage_window"); ok {
+			window = time.Duration(secs) * time.Second
+		}
+		s = NewUsageBased(window)
+	default:
+		var err error
+		if s, err = NewFromConfig(rs.RoutingStrategy); err != nil {
+			return nil, err
+		}
+	}
+
+	if region != "" {
+		s = NewRegionPinned(region, s)
+	}
+	if len(rs.ProviderBudgetConfig) > 0 {
+		if spend == nil {
+			return nil, fmt.Errorf("provider_budget_config requires a provider spend tracker")
+		}
+		s = NewBudgetLimiter(spend.Limits(), s, spend)
+	}
+	if rs.EnableTagFiltering || rs.RoutingStrategy == "tag-based" {
+		s = NewTagBased(s)
+	}
+	return s, nil
+}
+
+func intArg(args map[string]any, key string) (int, bool) {
+	switch v := args[key].(type) {
+	case int:
+		return v, v > 0
+	case float64:
+		return int(v), v > 0
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil && n > 0
+	}
+	return 0, false
+}
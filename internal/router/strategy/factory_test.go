@@ -0,0 +1,127 @@
+package strategy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/callback"
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func regionDeployments() []*router.Deployment {
+	return []*router.Deployment{
+		{ID: "us-openai", ProviderName: "openai", Region: "us-east1", Config: &config.ModelConfig{}},
+		{ID: "eu-openai", ProviderName: "openai", Region: "eu-west1", Config: &config.ModelConfig{}},
+		{ID: "eu-azure", ProviderName: "azure", Region: "eu-central1", Config: &config.ModelConfig{}},
+	}
+}
+
+func TestNewFromSettings_RegionLatencyWithBudgets(t *testing.T) {
+	rs := &config.RouterSettings{
+		RoutingStrategy:     "lowest-latency",
+		RoutingStrategyArgs: map[string]any{"region": "eu"},
+		ProviderBudgetConfig: map[string]any{
+			"openai": map[string]any{"budget_limit": 10, "time_period": "1d"},
+		},
+	}
+	budgets, err := ParseProviderBudgets(rs.ProviderBudgetConfig)
+	require.NoError(t, err)
+	spend := NewProviderSpend(budgets)
+
+	s, err := NewFromSettings(rs, spend)
+	require.NoError(t, err)
+	require.IsType(t, &BudgetLimiter{}, s)
+
+	// Only EU deployments are candidates; with no latency data the first wins.
+	assert.Equal(t, "eu-openai", s.Pick(regionDeployments()).ID)
+
+	// Exhausting the openai budget leaves the azure deployment.
+	spend.LogSuccess(callback.LogData{Provider: "openai", Cost: 12})
+	assert.Equal(t, "eu-azure", s.Pick(regionDeployments()).ID)
+}
+
+func TestNewFromSettings_TagFilteringWraps(t *testing.T) {
+	s, err := NewFromSettings(&config.RouterSettings{RoutingStrategy: "least-busy", EnableTagFiltering: true}, nil)
+	require.NoError(t, err)
+	_, ok := s.(router.TagPicker)
+	assert.True(t, ok)
+}
+
+func TestNewFromSettings_BudgetsRequireSpend(t *testing.T) {
+	_, err := NewFromSettings(&config.RouterSettings{
+		ProviderBudgetConfig: map[string]any{"openai": map[string]any{"budget_limit": 1}},
+	}, nil)
+	assert.Error(t, err)
+}
+
+func TestNewFromSettings_WrapperStrategiesRequireSettings(t *testing.T) {
+	for _, name := range []string{"region-pinned", "tag-based", "budget-limited"} {
+		_, err := NewFromConfig(name)
+		assert.Error(t, err, name)
+	}
+
+	_, err := NewFromSettings(&config.RouterSettings{RoutingStrategy: "region-pinned"}, nil)
+	assert.ErrorContains(t, err, "routing_strategy_args.region")
+	_, err = NewFromSettings(&config.RouterSettings{RoutingStrategy: "budget-limited"}, NewProviderSpend(nil))
+	assert.ErrorContains(t, err, "provider_budget_config")
+
+	s, err := NewFromSettings(&config.RouterSettings{
+		RoutingStrategy:     "region-pinned",
+		RoutingStrategyArgs: map[string]any{"region": "eu"},
+	}, nil)
+	require.NoError(t, err)
+	require.IsType(t, &RegionPinned{}, s)
+	assert.NotEqual(t, "us-openai", s.Pick(regionDeployments()).ID)
+
+	s, err = NewFromSettings(&config.RouterSettings{RoutingStrategy: "tag-based"}, nil)
+	require.NoError(t, err)
+	_, ok := s.(router.TagPicker)
+	assert.True(t, ok)
+}
+
+func TestNewFromSettings_UsageWindow(t *testing.T) {
+	s, err := NewFromSettings(&config.RouterSettings{
+		RoutingStrategy:     "usage-based",
+		RoutingStrategyArgs: map[string]any{"usage_window": 30},
+	}, nil)
+	require.NoError(t, err)
+	u, ok := s.(*UsageBased)
+	require.True(t, ok)
+	assert.Equal(t, 30*time.Second, u.window)
+}
+
+func TestWrappers_ForwardInflightTracking(t *testing.T) {
+	lb := NewLeastBusy()
+	wrapped := NewTagBased(NewRegionPinned("eu", lb))
+	tracker, ok := router.Strategy(wrapped).(router.InflightTracker)
+	require.True(t, ok)
+
+	tracker.Acquire("eu-openai")
+	assert.Equal(t, "eu-azure", wrapped.Pick(regionDeployments()).ID)
+	tracker.Release("eu-openai")
+	assert.Equal(t, "eu-openai", wrapped.Pick(regionDeployments()).ID)
+}
+
+func TestParseProviderBudgets_Invalid(t *testing.T) {
+	_, err := ParseProviderBudgets(map[string]any{"openai": "100"})
+	assert.Error(t, err)
+
+	_, err = ParseProviderBudgets(map[string]any{"openai": map[string]any{"budget_limit": 1, "time_period": "soon"}})
+	assert.Error(t, err)
+}
+
+func TestProviderSpend_ResetsAfterPeriod(t *testing.T) {
+	ps := NewProviderSpend(map[string]ProviderBudget{"openai": {Limit: 1, Period: time.Hour}})
+	ps.Add("openai", 0.5)
+	ps.Add("anthropic", 3) // no budget → not tracked
+	assert.InDelta(t, 0.5, ps.GetProviderSpend("openai"), 1e-9)
+	assert.Zero(t, ps.GetProviderSpend("anthropic"))
+
+	ps.mu.Lock()
+	ps.resetAt["openai"] = time.Now().Add(-time.Second)
+	ps.mu.Unlock()
+	assert.Zero(t, ps.GetProviderSpend("openai"))
+}
@@ -9,10 +9,11 @@ import (
 
 // LeastBusy picks the deployment with the fewest in-flight requests.
 // Satisfies Strategy interface (Pick) — no interface change needed.
-// Router calls Acquire/Release via type assertion.
+// Router calls Acquire/Release via the router.InflightTracker type assertion.
 type LeastBusy struct {
 	mu       sync.RWMutex
 	inflight map[string]*atomic.Int64
+	state    router.StateStore // shared counters across replicas, optional
 }
 
 // NewLeastBusy creates a least-busy strategy.
@@ -27,6 +28,8 @@ func (lb *LeastBusy) Pick(deployments []*router.Deployment) *router.Deployment {
 		return nil
 	}
 
+	shared := lb.sharedInflight(deployments)
+
 	lb.mu.RLock()
 	defer lb.mu.RUnlock()
 
@@ -35,7 +38,9 @@ func (lb *LeastBusy) Pick(deployments []*router.Deployment) *router.Deployment {
 
 	for _, d := range deployments {
 		count := int64(0)
-		if counter, ok := lb.inflight[d.ID]; ok {
+		if shared != nil {
+			count = shared[d.ID]
+		} else if counter, ok := lb.inflight[d.ID]; ok {
 			count = counter.Load()
 		}
 		if bestCount < 0 || count < bestCount {
@@ -57,6 +62,7 @@ func (lb *LeastBusy) Acquire(deploymentID string) {
 	}
 	lb.mu.Unlock()
 	counter.Add(1)
+	lb.shareInflight(deploymentID, 1)
 }
 
 // Release decrements the in-flight counter for a deployment.
@@ -67,5 +73,39 @@ func (lb *LeastBusy) Release(deploymentID string) {
 	lb.mu.RUnlock()
 	if ok {
 		counter.Add(-1)
+		lb.shareInflight(deploymentID, -1)
+	}
+}
+
+// SetStateStore shares in-flight counts across replicas through store.
+func (lb *LeastBusy) SetStateStore(store router.StateStore) {
+	lb.state = store
+}
+
+// sharedInflight returns cluster-wide in-flight counts, or nil when no
+// store is configured or it cannot be reached.
+func (lb *LeastBusy) sharedInflight(deployments []*router.Deployment) map[string]int64 {
+	if lb.state == nil {
+		return nil
+	}
+	ids := make([]string, len(deployments))
+	for i, d := range deployments {
+		ids[i] = d.ID
+	}
+	ctx, cancel := router.StateContext()
+	defer cancel()
+	counts, err := lb.state.Inflight(ctx, ids)
+	if err != nil {
+		return nil
+	}
+	return counts
+}
+
+func (lb *LeastBusy) shareInflight(deploymentID string, delta int64) {
+	if lb.state == nil {
+		return
 	}
+	ctx, cancel := router.StateContext()
+	defer cancel()
+	_ = lb.state.AddInflight(ctx, deploymentID, delta)
 }
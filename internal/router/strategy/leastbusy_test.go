@@ -4,8 +4,10 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/praxisllmlab/tianjiLLM/internal/config"
 	"github.com/praxisllmlab/tianjiLLM/internal/router"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -169,3 +171,23 @@ func (f *firstPicker) Pick(deps []*router.Deployment) *router.Deployment {
 	}
 	return deps[0]
 }
+
+func TestLeastBusy_SharedStateAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := router.NewRedisStateStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	replicaA, replicaB := NewLeastBusy(), NewLeastBusy()
+	replicaA.SetStateStore(store)
+	replicaB.SetStateStore(store)
+	deps := namedDeployments("a", "b")
+
+	// Replica B is busy on "a"; replica A must see it.
+	replicaB.Acquire("a")
+	replicaB.Acquire("a")
+	assert.Equal(t, "b", replicaA.Pick(deps).ID)
+
+	replicaB.Release("a")
+	replicaB.Release("a")
+	replicaA.Acquire("b")
+	assert.Equal(t, "a", replicaB.Pick(deps).ID)
+}
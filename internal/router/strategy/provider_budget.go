@@ -0,0 +1,152 @@
+package strategy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/callback"
+)
+
+// ProviderBudget is one provider's entry in provider_budget_config.
+type ProviderBudget struct {
+	Limit  float64       // budget_limit in USD
+	Period time.Duration // time_period, e.g. "1d"; 0 means the budget never resets
+}
+
+// ParseProviderBudgets parses router_settings.provider_budget_config:
+//
+//	provider_budget_config:
+//	  openai:
+//	    budget_limit: 100
+//	    time_period: 1d
+func ParseProviderBudgets(raw map[string]any) (map[string]ProviderBudget, error) {
+	budgets := make(map[string]ProviderBudget, len(raw))
+	for provider, v := range raw {
+		entry, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("provider_budget_config.%s: expected a mapping", provider)
+		}
+		limit, ok := toFloat(entry["budget_limit"])
+		if !ok {
+			return nil, fmt.Errorf("provider_budget_config.%s: budget_limit is required", provider)
+		}
+		var period time.Duration
+		if s, ok := entry["time_period"].(string); ok {
+			period = parsePeriod(s)
+			if period == 0 {
+				return nil, fmt.Errorf("provider_budget_config.%s: invalid time_period %q", provider, s)
+			}
+		}
+		budgets[provider] = ProviderBudget{Limit: limit, Period: period}
+	}
+	return budgets, nil
+}
+
+// ProviderSpend accumulates spend per provider over each provider's budget
+// period. It is registered as a callback so every successful call is
+// counted, and satisfies SpendQuerier for BudgetLimiter.
+type ProviderSpend struct {
+	mu      sync.Mutex
+	budgets map[string]ProviderBudget
+	spend   map[string]float64
+	resetAt map[string]time.Time
+}
+
+// NewProviderSpend creates a spend tracker for the given provider budgets.
+func NewProviderSpend(budgets map[string]ProviderBudget) *ProviderSpend {
+	return &ProviderSpend{
+		budgets: budgets,
+		spend:   make(map[string]float64),
+		resetAt: make(map[string]time.Time),
+	}
+}
+
+// Limits returns the budget limit per provider, as BudgetLimiter expects.
+func (ps *ProviderSpend) Limits() map[string]float64 {
+	limits := make(map[string]float64, len(ps.budgets))
+	for provider, b := range ps.budgets {
+		limits[provider] = b.Limit
+	}
+	return limits
+}
+
+// GetProviderSpend returns the provider's spend in its current period.
+func (ps *ProviderSpend) GetProviderSpend(provider string) float64 {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.rollover(provider, time.Now())
+	return ps.spend[provider]
+}
+
+// Add records cost against a provider's current period.
+func (ps *ProviderSpend) Add(provider string, cost float64) {
+	if _, ok := ps.budgets[provider]; !ok || cost <= 0 {
+		return
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.rollover(provider, time.Now())
+	ps.spend[provider] += cost
+}
+
+// LogSuccess implements callback.CustomLogger.
+func (ps *ProviderSpend) LogSuccess(data callback.LogData) {
+	ps.Add(data.Provider, data.Cost)
+}
+
+// LogFailure implements callback.CustomLogger — failed calls cost nothing.
+func (ps *ProviderSpend) LogFailure(callback.LogData) {}
+
+// rollover resets a provider's spend when its period has elapsed.
+// Caller must hold ps.mu.
+func (ps *ProviderSpend) rollover(provider string, now time.Time) {
+	period := ps.budgets[provider].Period
+	if period <= 0 {
+		return
+	}
+	if reset, ok := ps.resetAt[provider]; !ok || !now.Before(reset) {
+		ps.spend[provider] = 0
+		ps.resetAt[provider] = now.Add(period)
+	}
+}
+
+// parsePeriod parses durations like "30s", "10m", "1h" and "1d".
+func parsePeriod(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 {
+		return 0
+	}
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	switch s[len(s)-1] {
+	case 's':
+		return time.Duration(n) * time.Second
+	case 'm':
+		return time.Duration(n) * time.Minute
+	case 'h':
+		return time.Duration(n) * time.Hour
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour
+	}
+	return 0
+}
+
+func toFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case string:
+		f, err := strconv.ParseFloat(n, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
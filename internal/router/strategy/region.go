@@ -26,3 +26,20 @@ func FilterByRegion(deployments []*router.Deployment, allowedRegion string) []*r
 	}
 	return filtered
 }
+
+// RegionPinned restricts selection to deployments in an allowed region
+// prefix, then delegates to an inner strategy. Like FilterByRegion, it uses
+// all deployments when none are in the region.
+type RegionPinned struct {
+	region string
+	inner  router.Strategy
+}
+
+// NewRegionPinned creates a region-pinned strategy wrapping inner.
+func NewRegionPinned(region string, inner router.Strategy) *RegionPinned {
+	return &RegionPinned{region: region, inner: inner}
+}
+
+func (rp *RegionPinned) Pick(deployments []*router.Deployment) *router.Deployment {
+	return rp.inner.Pick(FilterByRegion(deployments, rp.region))
+}
@@ -2,6 +2,7 @@ package strategy
 
 import (
 	"sync"
+	"time"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/router"
 )
@@ -13,6 +14,7 @@ type LowestTPMRPM struct {
 	mu    sync.Mutex
 	usage map[string]int64 // deploymentID → current TPM usage
 	inner router.Strategy  // fallback when no limits configured
+	state router.StateStore
 }
 
 // NewLowestTPMRPM creates a new lowest-TPM/RPM strategy.
@@ -28,6 +30,8 @@ func (s *LowestTPMRPM) Pick(deployments []*router.Deployment) *router.Deployment
 		return nil
 	}
 
+	shared := sharedUsage(s.state, deployments)
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -44,6 +48,9 @@ func (s *LowestTPMRPM) Pick(deployments []*router.Deployment) *router.Deployment
 		}
 
 		current := s.usage[d.ID]
+		if shared != nil {
+			current = shared[d.ID].Tokens
+		}
 		utilization := float64(current) / float64(limit)
 		if utilization < bestUtil {
 			bestUtil = utilization
@@ -61,9 +68,37 @@ func (s *LowestTPMRPM) Pick(deployments []*router.Deployment) *router.Deployment
 
 // RecordUsage adds tokens to a deployment's current usage window.
 func (s *LowestTPMRPM) RecordUsage(deploymentID string, tokens int64) {
+	s.add(deploymentID, 1, tokens)
+}
+
+// RecordRequest counts a request in a deployment's shared usage window;
+// the Router calls it after each upstream call.
+func (s *LowestTPMRPM) RecordRequest(deploymentID string) {
+	s.add(deploymentID, 1, 0)
+}
+
+// RecordTokens adds the tokens a call to a deployment used.
+func (s *LowestTPMRPM) RecordTokens(deploymentID string, tokens int64) {
+	s.add(deploymentID, 0, tokens)
+}
+
+func (s *LowestTPMRPM) add(deploymentID string, requests, tokens int64) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.usage[deploymentID] += tokens
+	s.mu.Unlock()
+
+	if s.state != nil {
+		ctx, cancel := router.StateContext()
+		defer cancel()
+		_ = s.state.AddUsage(ctx, deploymentID, requests, tokens, time.Minute)
+	}
+}
+
+// SetStateStore shares usage across replicas through store. Shared usage
+// windows last one minute and expire on their own, so ResetUsage only
+// clears local counters.
+func (s *LowestTPMRPM) SetStateStore(store router.StateStore) {
+	s.state = store
 }
 
 // ResetUsage clears the usage window (called periodically, e.g. every minute).
@@ -14,6 +14,7 @@ type UsageBased struct {
 	mu       sync.RWMutex
 	counters map[string]*usageCounter
 	window   time.Duration
+	state    router.StateStore // shared usage windows across replicas, optional
 }
 
 type usageCounter struct {
@@ -38,10 +39,21 @@ func (u *UsageBased) Pick(deployments []*router.Deployment) *router.Deployment {
 		return nil
 	}
 
+	shared := sharedUsage(u.state, deployments)
+
 	var best *router.Deployment
 	var bestUsage float64 = -1
 
 	for _, d := range deployments {
+		if shared != nil {
+			usage := shared[d.ID]
+			if ratio := usageRatio(d, usage.Requests, usage.Tokens); bestUsage < 0 || ratio < bestUsage {
+				bestUsage = ratio
+				best = d
+			}
+			continue
+		}
+
 		c := u.getCounter(d.ID)
 
 		// Reset if window expired
@@ -68,9 +80,35 @@ func (u *UsageBased) Pick(deployments []*router.Deployment) *router.Deployment {
 
 // RecordUsage records a request and token count for a deployment.
 func (u *UsageBased) RecordUsage(deploymentID string, tokens int64) {
+	u.add(deploymentID, 1, tokens)
+}
+
+// RecordRequest records a request for a deployment; the Router calls it
+// after each upstream call.
+func (u *UsageBased) RecordRequest(deploymentID string) {
+	u.add(deploymentID, 1, 0)
+}
+
+// RecordTokens records the tokens a call to a deployment used.
+func (u *UsageBased) RecordTokens(deploymentID string, tokens int64) {
+	u.add(deploymentID, 0, tokens)
+}
+
+func (u *UsageBased) add(deploymentID string, requests, tokens int64) {
 	c := u.getCounter(deploymentID)
-	c.requests.Add(1)
+	c.requests.Add(requests)
 	c.tokens.Add(tokens)
+
+	if u.state != nil {
+		ctx, cancel := router.StateContext()
+		defer cancel()
+		_ = u.state.AddUsage(ctx, deploymentID, requests, tokens, u.window)
+	}
+}
+
+// SetStateStore shares usage windows across replicas through store.
+func (u *UsageBased) SetStateStore(store router.StateStore) {
+	u.state = store
 }
 
 func (u *UsageBased) getCounter(id string) *usageCounter {
@@ -111,3 +149,22 @@ func usageRatio(d *router.Deployment, rpm, tpm int64) float64 {
 	}
 	return tpmRatio
 }
+
+// sharedUsage returns cluster-wide usage windows from store, or nil when no
+// store is configured or it cannot be reached.
+func sharedUsage(store router.StateStore, deployments []*router.Deployment) map[string]router.DeploymentUsage {
+	if store == nil {
+		return nil
+	}
+	ids := make([]string, len(deployments))
+	for i, d := range deployments {
+		ids[i] = d.ID
+	}
+	ctx, cancel := router.StateContext()
+	defer cancel()
+	usage, err := store.Usage(ctx, ids)
+	if err != nil {
+		return nil
+	}
+	return usage
+}
@@ -1,11 +1,19 @@
 package strategy
 
 import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alicebob/miniredis/v2"
 	"github.com/praxisllmlab/tianjiLLM/internal/config"
 	"github.com/praxisllmlab/tianjiLLM/internal/router"
+	"github.com/redis/go-redis/v9"
+
+	_ "github.com/praxisllmlab/tianjiLLM/internal/provider/openai"
 )
 
 func TestUsageBasedPickEmpty(t *testing.T) {
@@ -55,3 +63,39 @@ func TestUsageRatio(t *testing.T) {
 		t.Fatalf("ratio = %f, want 0.5", ratio)
 	}
 }
+
+func TestUsageBased_ExecuteSharesUsageAcrossReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := router.NewRedisStateStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	apiKey := "sk-test"
+	models := []config.ModelConfig{
+		{ModelName: "gpt-4o", TianjiParams: config.TianjiParams{Model: "openai/gpt-4o", APIKey: &apiKey}},
+		{ModelName: "gpt-4o", TianjiParams: config.TianjiParams{Model: "openai/gpt-4o-mini", APIKey: &apiKey}},
+	}
+	replicaA, replicaB := NewUsageBased(time.Minute), NewUsageBased(time.Minute)
+	r := router.New(models, replicaA, router.RouterSettings{State: store})
+	replicaB.SetStateStore(store)
+
+	resp, exec, err := r.Execute(context.Background(), router.Attempt{ModelGroup: "gpt-4o"}, nil,
+		func(ctx context.Context, a router.Attempt) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	served := exec.Final.Deployment
+	r.RecordTokens(served, 5000)
+
+	usage, err := store.Usage(context.Background(), []string{served.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := usage[served.ID]; got.Requests != 1 || got.Tokens != 5000 {
+		t.Fatalf("shared usage = %+v, want 1 request and 5000 tokens", got)
+	}
+	if picked := replicaB.Pick(r.GetDeployments("gpt-4o")); picked.ID == served.ID {
+		t.Fatalf("replica B picked %s, which replica A just used", picked.ID)
+	}
+}
@@ -0,0 +1,89 @@
+package strategy
+
+import "github.com/praxisllmlab/tianjiLLM/internal/router"
+
+// Wrapping strategies (RegionPinned, BudgetLimiter, TagBased) forward the
+// optional router interfaces to their inner strategy, so that e.g. a
+// region-pinned least-busy strategy still receives Acquire/Release and a
+// wrapped usage-based strategy still sees the usage the Router records.
+
+func forwardAcquire(inner router.Strategy, deploymentID string) {
+	if t, ok := inner.(router.InflightTracker); ok {
+		t.Acquire(deploymentID)
+	}
+}
+
+func forwardRelease(inner router.Strategy, deploymentID string) {
+	if t, ok := inner.(router.InflightTracker); ok {
+		t.Release(deploymentID)
+	}
+}
+
+func forwardRequest(inner router.Strategy, deploymentID string) {
+	if u, ok := inner.(router.UsageRecorder); ok {
+		u.RecordRequest(deploymentID)
+	}
+}
+
+func forwardTokens(inner router.Strategy, deploymentID string, tokens int64) {
+	if u, ok := inner.(router.UsageRecorder); ok {
+		u.RecordTokens(deploymentID, tokens)
+	}
+}
+
+func forwardStateStore(inner router.Strategy, store router.StateStore) {
+	if sa, ok := inner.(router.StateAware); ok {
+		sa.SetStateStore(store)
+	}
+}
+
+// Acquire forwards to the inner strategy.
+func (rp *RegionPinned) Acquire(deploymentID string) { forwardAcquire(rp.inner, deploymentID) }
+
+// Release forwards to the inner strategy.
+func (rp *RegionPinned) Release(deploymentID string) { forwardRelease(rp.inner, deploymentID) }
+
+// RecordRequest forwards to the inner strategy.
+func (rp *RegionPinned) RecordRequest(deploymentID string) { forwardRequest(rp.inner, deploymentID) }
+
+// RecordTokens forwards to the inner strategy.
+func (rp *RegionPinned) RecordTokens(deploymentID string, tokens int64) {
+	forwardTokens(rp.inner, deploymentID, tokens)
+}
+
+// SetStateStore forwards to the inner strategy.
+func (rp *RegionPinned) SetStateStore(store router.StateStore) { forwardStateStore(rp.inner, store) }
+
+// Acquire forwards to the inner strategy.
+func (bl *BudgetLimiter) Acquire(deploymentID string) { forwardAcquire(bl.inner, deploymentID) }
+
+// Release forwards to the inner strategy.
+func (bl *BudgetLimiter) Release(deploymentID string) { forwardRelease(bl.inner, deploymentID) }
+
+// RecordRequest forwards to the inner strategy.
+func (bl *BudgetLimiter) RecordRequest(deploymentID string) { forwardRequest(bl.inner, deploymentID) }
+
+// RecordTokens forwards to the inner strategy.
+func (bl *BudgetLimiter) RecordTokens(deploymentID string, tokens int64) {
+	forwardTokens(bl.inner, deploymentID, tokens)
+}
+
+// SetStateStore forwards to the inner strategy.
+func (bl *BudgetLimiter) SetStateStore(store router.StateStore) { forwardStateStore(bl.inner, store) }
+
+// Acquire forwards to the inner strategy.
+func (t *TagBased) Acquire(deploymentID string) { forwardAcquire(t.inner, deploymentID) }
+
+// Release forwards to the inner strategy.
+func (t *TagBased) Release(deploymentID string) { forwardRelease(t.inner, deploymentID) }
+
+// RecordRequest forwards to the inner strategy.
+func (t *TagBased) RecordRequest(deploymentID string) { forwardRequest(t.inner, deploymentID) }
+
+// RecordTokens forwards to the inner strategy.
+func (t *TagBased) RecordTokens(deploymentID string, tokens int64) {
+	forwardTokens(t.inner, deploymentID, tokens)
+}
+
+// SetStateStore forwards to the inner strategy.
+func (t *TagBased) SetStateStore(store router.StateStore) { forwardStateStore(t.inner, store) }
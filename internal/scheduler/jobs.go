@@ -2,17 +2,21 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/praxisllmlab/tianjiLLM/internal/policy"
+	"github.com/praxisllmlab/tianjiLLM/internal/spend"
 )
 
-// BudgetResetJob resets spend for all keys whose budget_reset_at has passed.
-// Uses a single batch UPDATE for efficiency.
+// BudgetResetJob resets spend for all keys, users, teams and organizations
+// whose budget_reset_at has passed. Uses one batch UPDATE per table and
+// budget_duration, as the next reset time is computed here.
 type BudgetResetJob struct {
 	DB *db.Queries
 }
@@ -20,7 +24,29 @@ type BudgetResetJob struct {
 func (j *BudgetResetJob) Name() string { return "budget_reset" }
 
 func (j *BudgetResetJob) Run(ctx context.Context) error {
-	return j.DB.ResetBudgetForExpiredTokens(ctx)
+	durations, err := j.DB.ListExpiredBudgetDurations(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var errs []error
+	for _, d := range durations {
+		if d == nil {
+			continue
+		}
+		next, err := spend.BudgetResetAt(*d, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs,
+			j.DB.ResetBudgetForExpiredTokens(ctx, db.ResetBudgetForExpiredTokensParams{BudgetResetAt: next, BudgetDuration: d}),
+			j.DB.ResetBudgetForExpiredUsers(ctx, db.ResetBudgetForExpiredUsersParams{BudgetResetAt: next, BudgetDuration: d}),
+			j.DB.ResetBudgetForExpiredTeams(ctx, db.ResetBudgetForExpiredTeamsParams{BudgetResetAt: next, BudgetDuration: d}),
+			j.DB.ResetBudgetForExpiredOrganizations(ctx, db.ResetBudgetForExpiredOrganizationsParams{BudgetResetAt: next, BudgetDuration: d}),
+		)
+	}
+	return errors.Join(errs...)
 }
 
 // SpendLogCleanupJob deletes spend log entries older than the retention period.
@@ -47,6 +73,83 @@ func (j *PolicyHotReloadJob) Run(ctx context.Context) error {
 	return j.Engine.Load(ctx)
 }
 
+// ProxyModelLister lists DB-managed models; satisfied by *db.Queries.
+type ProxyModelLister interface {
+	ListProxyModels(ctx context.Context) ([]db.ProxyModelTable, error)
+}
+
+// ModelReloader swaps the DB-managed deployments of a live router;
+// satisfied by *router.Router.
+type ModelReloader interface {
+	ReplaceDynamicModels(models map[string]config.ModelConfig) error
+}
+
+// ModelReloadJob reloads models added via /model/new or the UI from
+// ProxyModelTable into the live router, so changes made on other replicas
+// take effect without a restart.
+type ModelReloadJob struct {
+	DB     ProxyModelLister
+	Router ModelReloader
+}
+
+func (j *ModelReloadJob) Name() string { return "model_reload" }
+
+func (j *ModelReloadJob) Run(ctx context.Context) error {
+	rows, err := j.DB.ListProxyModels(ctx)
+	if err != nil {
+		return err
+	}
+	models := make(map[string]config.ModelConfig, len(rows))
+	for _, row := range rows {
+		m, err := config.ModelConfigFromJSON(row.ModelName, row.TianjiParams, row.ModelInfo)
+		if err != nil {
+			log.Printf("scheduler: model_reload: skipping model %s: %v", row.ModelID, err)
+			continue
+		}
+		models[row.ModelID] = m
+	}
+	if err := j.Router.ReplaceDynamicModels(models); err != nil {
+		log.Printf("scheduler: model_reload: skipping models: %v", err)
+	}
+	return nil
+}
+
+// IPWhitelistLister lists the stored IP allow-list; satisfied by *db.Queries.
+type IPWhitelistLister interface {
+	ListIPWhitelist(ctx context.Context) ([]db.IPWhitelistTable, error)
+}
+
+// IPWhitelistReplacer swaps the stored entries of a live allow-list;
+// satisfied by *middleware.IPWhitelist.
+type IPWhitelistReplacer interface {
+	Replace(entries []string) error
+}
+
+// IPWhitelistReloadJob reloads the IP allow-list from IPWhitelistTable, so
+// entries added or removed on other replicas take effect. When the table
+// cannot be read the current list is kept; malformed entries are skipped.
+type IPWhitelistReloadJob struct {
+	DB        IPWhitelistLister
+	Whitelist IPWhitelistReplacer
+}
+
+func (j *IPWhitelistReloadJob) Name() string { return "ip_whitelist_reload" }
+
+func (j *IPWhitelistReloadJob) Run(ctx context.Context) error {
+	rows, err := j.DB.ListIPWhitelist(ctx)
+	if err != nil {
+		return err
+	}
+	entries := make([]string, len(rows))
+	for i, row := range rows {
+		entries[i] = row.IpAddress
+	}
+	if err := j.Whitelist.Replace(entries); err != nil {
+		log.Printf("scheduler: ip_whitelist_reload: skipping entries: %v", err)
+	}
+	return nil
+}
+
 // SpendArchivalJob archives old spend logs to cold storage.
 type SpendArchivalJob struct {
 	Archiver  SpendArchiver
@@ -6,6 +6,9 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
 )
 
 func TestJobNames(t *testing.T) {
@@ -21,6 +24,8 @@ func TestJobNames(t *testing.T) {
 		{&CredentialRefreshJob{}, "credential_refresh"},
 		{&KeyRotationJob{}, "key_rotation"},
 		{&HealthCheckJob{}, "health_check"},
+		{&ModelReloadJob{}, "model_reload"},
+		{&IPWhitelistReloadJob{}, "ip_whitelist_reload"},
 	}
 	for _, tt := range tests {
 		if got := tt.job.Name(); got != tt.want {
@@ -107,3 +112,71 @@ func TestHealthCheckJob_Run_BadEndpoint(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+type mockModelLister struct{ rows []db.ProxyModelTable }
+
+func (m *mockModelLister) ListProxyModels(context.Context) ([]db.ProxyModelTable, error) {
+	return m.rows, nil
+}
+
+type mockModelReloader struct{ models map[string]config.ModelConfig }
+
+func (m *mockModelReloader) ReplaceDynamicModels(models map[string]config.ModelConfig) error {
+	m.models = models
+	return nil
+}
+
+func TestModelReloadJob_Run(t *testing.T) {
+	lister := &mockModelLister{rows: []db.ProxyModelTable{
+		{ModelID: "m1", ModelName: "gpt-4o", TianjiParams: []byte(`{"model":"openai/gpt-4o","rpm":60}`)},
+		{ModelID: "m2", ModelName: "broken", TianjiParams: []byte(`{}`)},
+	}}
+	reloader := &mockModelReloader{}
+	j := &ModelReloadJob{DB: lister, Router: reloader}
+
+	if err := j.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(reloader.models) != 1 {
+		t.Fatalf("expected 1 model, got %d", len(reloader.models))
+	}
+	m := reloader.models["m1"]
+	if m.ModelName != "gpt-4o" || m.TianjiParams.Model != "openai/gpt-4o" {
+		t.Errorf("unexpected model: %+v", m)
+	}
+}
+
+type mockIPLister struct {
+	rows []db.IPWhitelistTable
+	err  error
+}
+
+func (m *mockIPLister) ListIPWhitelist(context.Context) ([]db.IPWhitelistTable, error) {
+	return m.rows, m.err
+}
+
+type mockIPReplacer struct{ entries []string }
+
+func (m *mockIPReplacer) Replace(entries []string) error {
+	m.entries = entries
+	return nil
+}
+
+func TestIPWhitelistReloadJob_Run(t *testing.T) {
+	replacer := &mockIPReplacer{entries: []string{"kept"}}
+	j := &IPWhitelistReloadJob{DB: &mockIPLister{err: context.DeadlineExceeded}, Whitelist: replacer}
+	if err := j.Run(context.Background()); err == nil {
+		t.Fatal("expected the list error")
+	}
+	if len(replacer.entries) != 1 {
+		t.Fatalf("entries replaced after a failed load: %v", replacer.entries)
+	}
+
+	j.DB = &mockIPLister{rows: []db.IPWhitelistTable{{IpAddress: "10.0.0.0/8"}, {IpAddress: "192.168.1.5"}}}
+	if err := j.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(replacer.entries) != 2 || replacer.entries[0] != "10.0.0.0/8" {
+		t.Errorf("unexpected entries: %v", replacer.entries)
+	}
+}
@@ -16,9 +16,13 @@ import (
 //   - displayName → team_alias
 //   - members[].value → team members
 //   - externalId → metadata["externalId"]
+//
+// Mappings assign teams and roles to the members of matching groups.
 type GroupHandler struct {
-	DB         *db.Queries
-	UpsertUser bool // auto-create missing users when true
+	DB          Store
+	UpsertUser  bool // auto-create missing users when true
+	Mappings    []GroupMapping
+	Invalidator KeyInvalidator
 }
 
 func (h *GroupHandler) Create(r *http.Request, attrs libscim.ResourceAttributes) (libscim.Resource, error) {
@@ -26,19 +30,27 @@ func (h *GroupHandler) Create(r *http.Request, attrs libscim.ResourceAttributes)
 
 	params := fromSCIMGroup(attrs)
 	params.TeamID = uuid.NewString()
+	eid := extractExternalID(attrs)
 
 	// Validate or auto-create members
 	if err := h.validateMembers(r, params.Members); err != nil {
 		return libscim.Resource{}, err
 	}
 
+	mapping, _ := h.groupMapping(derefStr(params.TeamAlias), eid.Value())
+	if mapping.TeamID != "" {
+		if existing, err := h.DB.GetTeam(ctx, mapping.TeamID); err == nil {
+			return h.adoptTeam(ctx, existing, params.Members, eid.Value())
+		}
+		params.TeamID = mapping.TeamID
+	}
+
 	team, err := h.DB.CreateTeam(ctx, params)
 	if err != nil {
 		return libscim.Resource{}, scimerrors.ScimErrorUniqueness
 	}
 
 	// Store externalId in metadata
-	eid := extractExternalID(attrs)
 	if eid.Present() {
 		meta := setMetadataField(team.Metadata, "externalId", eid.Value())
 		_ = h.DB.UpdateTeamMetadata(ctx, db.UpdateTeamMetadataParams{
@@ -48,6 +60,26 @@ func (h *GroupHandler) Create(r *http.Request, attrs libscim.ResourceAttributes)
 		team.Metadata = meta
 	}
 
+	h.applyRoles(ctx, team.TeamID, params.Members)
+	return toSCIMGroup(team), nil
+}
+
+// adoptTeam makes an existing team, named by a group mapping, the team of a
+// new group: its members become the group's.
+func (h *GroupHandler) adoptTeam(ctx context.Context, team db.TeamTable, members []string, externalID string) (libscim.Resource, error) {
+	changed := h.syncMembers(ctx, team.TeamID, team.Members, members)
+	if externalID != "" {
+		_ = h.DB.UpdateTeamMetadata(ctx, db.UpdateTeamMetadataParams{
+			TeamID:   team.TeamID,
+			Metadata: setMetadataField(team.Metadata, "externalId", externalID),
+		})
+	}
+	h.applyRoles(ctx, team.TeamID, changed)
+
+	team, err := h.DB.GetTeam(ctx, team.TeamID)
+	if err != nil {
+		return libscim.Resource{}, scimerrors.ScimError{Status: 500, Detail: "failed to re-fetch team"}
+	}
 	return toSCIMGroup(team), nil
 }
 
@@ -105,7 +137,7 @@ func (h *GroupHandler) GetAll(r *http.Request, params libscim.ListRequestParams)
 func (h *GroupHandler) Replace(r *http.Request, id string, attrs libscim.ResourceAttributes) (libscim.Resource, error) {
 	ctx := r.Context()
 
-	_, err := h.DB.GetTeam(ctx, id)
+	existing, err := h.DB.GetTeam(ctx, id)
 	if err != nil {
 		return libscim.Resource{}, scimerrors.ScimErrorResourceNotFound(id)
 	}
@@ -143,6 +175,10 @@ func (h *GroupHandler) Replace(r *http.Request, id string, attrs libscim.Resourc
 		team.Metadata = meta
 	}
 
+	// A new name or externalId may change the group's mapping, so every
+	// member's role is rechecked.
+	h.applyRoles(ctx, id, append(existing.Members, newMembers...))
+
 	// Re-fetch to get updated members
 	team, err = h.DB.GetTeam(ctx, id)
 	if err != nil {
@@ -153,22 +189,27 @@ func (h *GroupHandler) Replace(r *http.Request, id string, attrs libscim.Resourc
 }
 
 func (h *GroupHandler) Delete(r *http.Request, id string) error {
-	_, err := h.DB.GetTeam(r.Context(), id)
+	team, err := h.DB.GetTeam(r.Context(), id)
 	if err != nil {
 		return scimerrors.ScimErrorResourceNotFound(id)
 	}
-	return h.DB.DeleteTeam(r.Context(), id)
+	if err := h.DB.DeleteTeam(r.Context(), id); err != nil {
+		return err
+	}
+	h.syncUserRoles(r.Context(), team.Members)
+	return nil
 }
 
 func (h *GroupHandler) Patch(r *http.Request, id string, operations []libscim.PatchOperation) (libscim.Resource, error) {
 	ctx := r.Context()
 
-	_, err := h.DB.GetTeam(ctx, id)
+	existing, err := h.DB.GetTeam(ctx, id)
 	if err != nil {
 		return libscim.Resource{}, scimerrors.ScimErrorResourceNotFound(id)
 	}
 
 	changed := false
+	var affected []string
 	for _, op := range operations {
 		pathStr := ""
 		if op.Path != nil {
@@ -189,6 +230,7 @@ func (h *GroupHandler) Patch(r *http.Request, id string, operations []libscim.Pa
 						ArrayAppend: m,
 					})
 				}
+				affected = append(affected, members...)
 				changed = true
 			case "displayName":
 				if dn, ok := op.Value.(string); ok {
@@ -198,6 +240,8 @@ func (h *GroupHandler) Patch(r *http.Request, id string, operations []libscim.Pa
 						UpdatedBy: "scim",
 					})
 					changed = true
+					// The new name may change the group's mapping.
+					affected = append(affected, existing.Members...)
 				}
 			}
 
@@ -211,6 +255,8 @@ func (h *GroupHandler) Patch(r *http.Request, id string, operations []libscim.Pa
 						UpdatedBy: "scim",
 					})
 					changed = true
+					// The new name may change the group's mapping.
+					affected = append(affected, existing.Members...)
 				}
 			}
 
@@ -224,6 +270,7 @@ func (h *GroupHandler) Patch(r *http.Request, id string, operations []libscim.Pa
 						ArrayRemove: m,
 					})
 				}
+				affected = append(affected, members...)
 				changed = true
 			}
 		}
@@ -232,6 +279,7 @@ func (h *GroupHandler) Patch(r *http.Request, id string, operations []libscim.Pa
 	if !changed {
 		return libscim.Resource{}, nil // 204 No Content
 	}
+	h.applyRoles(ctx, id, affected)
 
 	team, err := h.DB.GetTeam(ctx, id)
 	if err != nil {
@@ -250,7 +298,7 @@ func (h *GroupHandler) validateMembers(r *http.Request, members []string) error
 			if !h.UpsertUser {
 				return scimerrors.ScimError{
 					Status: 400,
-					Detail: "member user not found: " + userID + " (set scim_upsert_user: true to auto-create)",
+					Detail: "member user not found: " + userID + " (set scim.upsert_user: true to auto-create)",
 				}
 			}
 			// Auto-create the user
@@ -268,7 +316,8 @@ func (h *GroupHandler) validateMembers(r *http.Request, members []string) error
 }
 
 // syncMembers removes old members not in newMembers and adds new members not in oldMembers.
-func (h *GroupHandler) syncMembers(ctx context.Context, teamID string, old, new_ []string) {
+// It returns the users removed or added.
+func (h *GroupHandler) syncMembers(ctx context.Context, teamID string, old, new_ []string) []string {
 	oldSet := make(map[string]bool, len(old))
 	for _, m := range old {
 		oldSet[m] = true
@@ -278,8 +327,10 @@ func (h *GroupHandler) syncMembers(ctx context.Context, teamID string, old, new_
 		newSet[m] = true
 	}
 
+	var changed []string
 	for _, m := range old {
 		if !newSet[m] {
+			changed = append(changed, m)
 			_ = h.DB.RemoveTeamMember(ctx, db.RemoveTeamMemberParams{
 				TeamID:      teamID,
 				ArrayRemove: m,
@@ -288,12 +339,14 @@ func (h *GroupHandler) syncMembers(ctx context.Context, teamID string, old, new_
 	}
 	for _, m := range new_ {
 		if !oldSet[m] {
+			changed = append(changed, m)
 			_ = h.DB.AddTeamMember(ctx, db.AddTeamMemberParams{
 				TeamID:      teamID,
 				ArrayAppend: m,
 			})
 		}
 	}
+	return changed
 }
 
 // extractPatchMembers gets member IDs from a patch operation value.
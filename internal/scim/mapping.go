@@ -0,0 +1,200 @@
+package scim
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+)
+
+// Team roles stored in members_with_roles.
+const (
+	teamRoleAdmin = "admin"
+	teamRoleUser  = "user"
+)
+
+// GroupMapping maps an IdP group, matched by displayName or externalId, to
+// a team and roles:
+//   - TeamID, when set, is an existing team the group manages; a group
+//     created for it adopts the team instead of creating one.
+//   - Role is the user role of members. A user in several mapped groups
+//     gets the highest; one who leaves them all reverts to internal_user.
+//   - TeamRole is the members' role in the team, "admin" or "user". Unset,
+//     new members join as "user" and existing members keep theirs.
+type GroupMapping struct {
+	Group    string
+	TeamID   string
+	Role     auth.Role
+	TeamRole string
+}
+
+type teamMember struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// groupMapping returns the mapping for a new group.
+func (h *GroupHandler) groupMapping(displayName, externalID string) (GroupMapping, bool) {
+	for _, m := range h.Mappings {
+		if m.Group == displayName || (externalID != "" && m.Group == externalID) {
+			return m, true
+		}
+	}
+	return GroupMapping{}, false
+}
+
+// teamMapping returns the mapping of an existing team: by team ID for
+// mappings naming one, else by the team's alias or externalId.
+func (h *GroupHandler) teamMapping(t db.TeamTable) (GroupMapping, bool) {
+	eid, _ := parseMetadata(t.Metadata)["externalId"].(string)
+	for _, m := range h.Mappings {
+		if m.TeamID != "" {
+			if m.TeamID == t.TeamID {
+				return m, true
+			}
+			continue
+		}
+		if m.Group == derefStr(t.TeamAlias) || (eid != "" && m.Group == eid) {
+			return m, true
+		}
+	}
+	return GroupMapping{}, false
+}
+
+// applyRoles brings the team's member roles, and the user roles of the
+// users whose membership changed, in line with the group mappings.
+func (h *GroupHandler) applyRoles(ctx context.Context, teamID string, changed []string) {
+	team, err := h.DB.GetTeam(ctx, teamID)
+	if err != nil {
+		log.Printf("scim: failed to get team %s: %v", teamID, err)
+		return
+	}
+	h.syncTeamRoles(ctx, team)
+	h.syncUserRoles(ctx, changed)
+}
+
+// syncTeamRoles rewrites members_with_roles to hold exactly the team's
+// members.
+func (h *GroupHandler) syncTeamRoles(ctx context.Context, team db.TeamTable) {
+	mapping, _ := h.teamMapping(team)
+
+	var current []teamMember
+	_ = json.Unmarshal(team.MembersWithRoles, &current)
+	existing := make(map[string]string, len(current))
+	for _, m := range current {
+		existing[m.UserID] = m.Role
+	}
+
+	members := make([]teamMember, 0, len(team.Members))
+	seen := make(map[string]bool, len(team.Members))
+	for _, id := range team.Members {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		role := mapping.TeamRole
+		if role == "" {
+			role = existing[id]
+		}
+		if role == "" {
+			role = teamRoleUser
+		}
+		members = append(members, teamMember{UserID: id, Role: role})
+	}
+
+	raw, _ := json.Marshal(members)
+	if err := h.DB.UpdateTeamMemberRole(ctx, db.UpdateTeamMemberRoleParams{
+		TeamID:           team.TeamID,
+		MembersWithRoles: raw,
+	}); err != nil {
+		log.Printf("scim: failed to update roles of team %s: %v", team.TeamID, err)
+	}
+}
+
+// syncUserRoles sets each user's role to the highest role of their mapped
+// groups. A role granted this way is recorded in metadata["scim_role"] so
+// that it, and only it, is taken back when the user leaves those groups.
+func (h *GroupHandler) syncUserRoles(ctx context.Context, userIDs []string) {
+	mapsRoles := false
+	for _, m := range h.Mappings {
+		mapsRoles = mapsRoles || m.Role != ""
+	}
+	if !mapsRoles || len(userIDs) == 0 {
+		return
+	}
+
+	teams, err := h.DB.ListTeams(ctx)
+	if err != nil {
+		log.Printf("scim: failed to list teams: %v", err)
+		return
+	}
+	best := make(map[string]auth.Role)
+	for _, t := range teams {
+		m, ok := h.teamMapping(t)
+		if !ok || m.Role == "" {
+			continue
+		}
+		for _, id := range t.Members {
+			if m.Role.Outranks(best[id]) {
+				best[id] = m.Role
+			}
+		}
+	}
+
+	done := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		if !done[id] {
+			done[id] = true
+			h.setUserRole(ctx, id, best[id])
+		}
+	}
+}
+
+// setUserRole gives the user role, or with no role takes back the one SCIM
+// granted, unless an admin has changed it since.
+func (h *GroupHandler) setUserRole(ctx context.Context, userID string, role auth.Role) {
+	user, err := h.DB.GetUser(ctx, userID)
+	if err != nil {
+		return
+	}
+	meta := parseMetadata(user.Metadata)
+	granted, _ := meta["scim_role"].(string)
+
+	switch {
+	case role != "":
+		if user.UserRole == string(role) && granted == string(role) {
+			return
+		}
+		meta["scim_role"] = string(role)
+	case granted != "":
+		delete(meta, "scim_role")
+		role = auth.Role(user.UserRole)
+		if user.UserRole == granted {
+			role = auth.RoleInternalUser
+		}
+	default:
+		return
+	}
+
+	if string(role) != user.UserRole {
+		if _, err := h.DB.UpdateUser(ctx, db.UpdateUserParams{
+			UserID:    userID,
+			UserRole:  string(role),
+			UpdatedBy: "scim",
+		}); err != nil {
+			log.Printf("scim: failed to set role of user %s: %v", userID, err)
+			return
+		}
+		// Cached key lookups carry the user's role.
+		invalidateUserKeys(ctx, h.DB, h.Invalidator, userID)
+	}
+	raw, _ := json.Marshal(meta)
+	if err := h.DB.UpdateUserMetadata(ctx, db.UpdateUserMetadataParams{
+		UserID:   userID,
+		Metadata: raw,
+	}); err != nil {
+		log.Printf("scim: failed to update metadata of user %s: %v", userID, err)
+	}
+}
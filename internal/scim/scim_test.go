@@ -1,13 +1,19 @@
 package scim
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	libscim "github.com/elimity-com/scim"
 	"github.com/elimity-com/scim/optional"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestToSCIMUser(t *testing.T) {
@@ -198,9 +204,253 @@ func TestResourceHandlerInterface(t *testing.T) {
 
 func TestNewSCIMServer(t *testing.T) {
 	// Verify SCIM server can be created with nil DB (for interface check)
-	server, err := NewSCIMServer(Config{})
+	server, err := NewSCIMServer(Config{Token: "scim-token"})
 	assert.NoError(t, err)
 	assert.NotNil(t, server)
+
+	_, err = NewSCIMServer(Config{})
+	assert.Error(t, err, "token is required")
+
+	_, err = NewSCIMServer(Config{Token: "scim-token", GroupMappings: []GroupMapping{{Group: "eng", Role: "superadmin"}}})
+	assert.Error(t, err)
+	_, err = NewSCIMServer(Config{Token: "scim-token", GroupMappings: []GroupMapping{{Group: "eng", TeamRole: "owner"}}})
+	assert.Error(t, err)
+}
+
+func TestSCIMServer_BearerToken(t *testing.T) {
+	server, err := NewSCIMServer(Config{Token: "scim-token"})
+	require.NoError(t, err)
+	// Mounted as the proxy does, under /scim/v2.
+	h := http.StripPrefix("/scim", server)
+
+	for _, tc := range []struct {
+		header string
+		want   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"scim-token", http.StatusUnauthorized},
+		{"Bearer scim-token", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/scim/v2/ServiceProviderConfig", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		assert.Equal(t, tc.want, w.Code, tc.header)
+		assert.Equal(t, "application/scim+json", w.Header().Get("Content-Type"))
+	}
+}
+
+// fakeStore is an in-memory Store for the handlers' provisioning logic.
+type fakeStore struct {
+	Store
+	users   map[string]db.UserTable
+	teams   map[string]db.TeamTable
+	tokens  map[string][]string // user_id → key hashes
+	blocked []string
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		users:  map[string]db.UserTable{},
+		teams:  map[string]db.TeamTable{},
+		tokens: map[string][]string{},
+	}
+}
+
+func (f *fakeStore) GetUser(_ context.Context, id string) (db.UserTable, error) {
+	u, ok := f.users[id]
+	if !ok {
+		return u, pgx.ErrNoRows
+	}
+	return u, nil
+}
+
+func (f *fakeStore) UpdateUser(_ context.Context, arg db.UpdateUserParams) (db.UserTable, error) {
+	u := f.users[arg.UserID]
+	u.UserRole = arg.UserRole
+	f.users[arg.UserID] = u
+	return u, nil
+}
+
+func (f *fakeStore) UpdateUserMetadata(_ context.Context, arg db.UpdateUserMetadataParams) error {
+	u := f.users[arg.UserID]
+	u.Metadata = arg.Metadata
+	f.users[arg.UserID] = u
+	return nil
+}
+
+func (f *fakeStore) ListVerificationTokensByUser(_ context.Context, userID *string) ([]db.VerificationToken, error) {
+	var out []db.VerificationToken
+	for _, h := range f.tokens[*userID] {
+		out = append(out, db.VerificationToken{Token: h, UserID: userID})
+	}
+	return out, nil
+}
+
+func (f *fakeStore) BlockVerificationToken(_ context.Context, token string) error {
+	f.blocked = append(f.blocked, token)
+	return nil
+}
+
+func (f *fakeStore) CreateTeam(_ context.Context, arg db.CreateTeamParams) (db.TeamTable, error) {
+	t := db.TeamTable{TeamID: arg.TeamID, TeamAlias: arg.TeamAlias, Members: arg.Members}
+	f.teams[t.TeamID] = t
+	return t, nil
+}
+
+func (f *fakeStore) GetTeam(_ context.Context, id string) (db.TeamTable, error) {
+	t, ok := f.teams[id]
+	if !ok {
+		return t, pgx.ErrNoRows
+	}
+	return t, nil
+}
+
+func (f *fakeStore) ListTeams(_ context.Context) ([]db.TeamTable, error) {
+	out := make([]db.TeamTable, 0, len(f.teams))
+	for _, t := range f.teams {
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+func (f *fakeStore) UpdateTeam(_ context.Context, arg db.UpdateTeamParams) (db.TeamTable, error) {
+	t := f.teams[arg.TeamID]
+	if arg.TeamAlias != nil {
+		t.TeamAlias = arg.TeamAlias
+	}
+	f.teams[arg.TeamID] = t
+	return t, nil
+}
+
+func (f *fakeStore) DeleteTeam(_ context.Context, id string) error {
+	delete(f.teams, id)
+	return nil
+}
+
+func (f *fakeStore) AddTeamMember(_ context.Context, arg db.AddTeamMemberParams) error {
+	t := f.teams[arg.TeamID]
+	t.Members = append(t.Members, arg.ArrayAppend.(string))
+	f.teams[arg.TeamID] = t
+	return nil
+}
+
+func (f *fakeStore) RemoveTeamMember(_ context.Context, arg db.RemoveTeamMemberParams) error {
+	t := f.teams[arg.TeamID]
+	var members []string
+	for _, m := range t.Members {
+		if m != arg.ArrayRemove.(string) {
+			members = append(members, m)
+		}
+	}
+	t.Members = members
+	f.teams[arg.TeamID] = t
+	return nil
+}
+
+func (f *fakeStore) UpdateTeamMetadata(_ context.Context, arg db.UpdateTeamMetadataParams) error {
+	t := f.teams[arg.TeamID]
+	t.Metadata = arg.Metadata
+	f.teams[arg.TeamID] = t
+	return nil
+}
+
+func (f *fakeStore) UpdateTeamMemberRole(_ context.Context, arg db.UpdateTeamMemberRoleParams) error {
+	t := f.teams[arg.TeamID]
+	t.MembersWithRoles = arg.MembersWithRoles
+	f.teams[arg.TeamID] = t
+	return nil
+}
+
+type fakeInvalidator struct{ hashes []string }
+
+func (f *fakeInvalidator) Invalidate(_ context.Context, hashes ...string) error {
+	f.hashes = append(f.hashes, hashes...)
+	return nil
+}
+
+func TestUserHandler_DeactivateBlocksKeys(t *testing.T) {
+	store := newFakeStore()
+	store.users["alice"] = db.UserTable{UserID: "alice", UserRole: "internal_user"}
+	store.tokens["alice"] = []string{"h1", "h2"}
+	inv := &fakeInvalidator{}
+	h := &UserHandler{DB: store, Invalidator: inv}
+
+	req := httptest.NewRequest(http.MethodPatch, "/v2/Users/alice", nil)
+	_, err := h.Patch(req, "alice", []libscim.PatchOperation{{
+		Op:    libscim.PatchOperationReplace,
+		Value: map[string]interface{}{"active": false},
+	}})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"h1", "h2"}, store.blocked)
+	assert.Equal(t, []string{"h1", "h2"}, inv.hashes)
+	assert.False(t, isUserActive(store.users["alice"]))
+}
+
+func TestGroupHandler_GroupMappings(t *testing.T) {
+	store := newFakeStore()
+	for _, id := range []string{"alice", "bob"} {
+		store.users[id] = db.UserTable{UserID: id, UserRole: "internal_user"}
+	}
+	store.teams["team-eng"] = db.TeamTable{TeamID: "team-eng", Members: []string{"bob"}}
+	h := &GroupHandler{
+		DB: store,
+		Mappings: []GroupMapping{
+			{Group: "Engineering", TeamID: "team-eng", Role: auth.RoleInternalUser},
+			{Group: "LLM Admins", Role: auth.RoleTeam, TeamRole: teamRoleAdmin},
+		},
+		Invalidator: &fakeInvalidator{},
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v2/Groups", nil)
+
+	// A group mapped to an existing team adopts it.
+	res, err := h.Create(req, libscim.ResourceAttributes{
+		"displayName": "Engineering",
+		"members":     []interface{}{map[string]interface{}{"value": "alice"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "team-eng", res.ID)
+	assert.Equal(t, []string{"alice"}, store.teams["team-eng"].Members)
+	assert.JSONEq(t, `[{"user_id":"alice","role":"user"}]`, string(store.teams["team-eng"].MembersWithRoles))
+
+	admins, err := h.Create(req, libscim.ResourceAttributes{
+		"displayName": "LLM Admins",
+		"externalId":  "okta-123",
+		"members":     []interface{}{map[string]interface{}{"value": "alice"}},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"user_id":"alice","role":"admin"}]`, string(store.teams[admins.ID].MembersWithRoles))
+	// The highest role of the user's groups wins.
+	assert.Equal(t, "team", store.users["alice"].UserRole)
+
+	// Leaving a group drops to the role of the remaining ones.
+	_, err = h.Replace(req, admins.ID, libscim.ResourceAttributes{"displayName": "LLM Admins"})
+	require.NoError(t, err)
+	assert.Equal(t, "internal_user", store.users["alice"].UserRole)
+	assert.Empty(t, store.teams[admins.ID].Members)
+
+	// Leaving every mapped group takes back the granted role.
+	require.NoError(t, h.Delete(req, "team-eng"))
+	assert.Equal(t, "internal_user", store.users["alice"].UserRole)
+	assert.NotContains(t, parseMetadata(store.users["alice"].Metadata), "scim_role")
+
+	// ...unless an admin has changed it since.
+	_, err = h.Replace(req, admins.ID, libscim.ResourceAttributes{
+		"displayName": "LLM Admins",
+		"members":     []interface{}{map[string]interface{}{"value": "alice"}},
+	})
+	require.NoError(t, err)
+	alice := store.users["alice"]
+	assert.Equal(t, "team", alice.UserRole)
+	alice.UserRole = "proxy_admin"
+	store.users["alice"] = alice
+	require.NoError(t, h.Delete(req, admins.ID))
+	assert.Equal(t, "proxy_admin", store.users["alice"].UserRole)
+	assert.NotContains(t, parseMetadata(store.users["alice"].Metadata), "scim_role")
 }
 
 func TestDerefStr(t *testing.T) {
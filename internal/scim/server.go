@@ -1,24 +1,91 @@
 package scim
 
 import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	libscim "github.com/elimity-com/scim"
 	"github.com/elimity-com/scim/optional"
 	"github.com/elimity-com/scim/schema"
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 )
 
+// Store is the subset of db.Queries the SCIM handlers use.
+type Store interface {
+	CreateUser(ctx context.Context, arg db.CreateUserParams) (db.UserTable, error)
+	GetUser(ctx context.Context, userID string) (db.UserTable, error)
+	ListUsers(ctx context.Context) ([]db.UserTable, error)
+	UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.UserTable, error)
+	UpdateUserMetadata(ctx context.Context, arg db.UpdateUserMetadataParams) error
+	DeleteUser(ctx context.Context, userID string) error
+	ListVerificationTokensByUser(ctx context.Context, userID *string) ([]db.VerificationToken, error)
+	BlockVerificationToken(ctx context.Context, token string) error
+
+	CreateTeam(ctx context.Context, arg db.CreateTeamParams) (db.TeamTable, error)
+	GetTeam(ctx context.Context, teamID string) (db.TeamTable, error)
+	ListTeams(ctx context.Context) ([]db.TeamTable, error)
+	UpdateTeam(ctx context.Context, arg db.UpdateTeamParams) (db.TeamTable, error)
+	DeleteTeam(ctx context.Context, teamID string) error
+	AddTeamMember(ctx context.Context, arg db.AddTeamMemberParams) error
+	RemoveTeamMember(ctx context.Context, arg db.RemoveTeamMemberParams) error
+	UpdateTeamMetadata(ctx context.Context, arg db.UpdateTeamMetadataParams) error
+	UpdateTeamMemberRole(ctx context.Context, arg db.UpdateTeamMemberRoleParams) error
+}
+
+// KeyInvalidator drops cached lookups of API keys, so keys blocked on
+// deprovisioning stop working before the auth cache expires.
+// middleware.AuthCache implements it.
+type KeyInvalidator interface {
+	Invalidate(ctx context.Context, tokenHashes ...string) error
+}
+
 // Config holds SCIM server configuration.
 type Config struct {
-	DB         *db.Queries
-	UpsertUser bool // auto-create missing users on group member add
+	DB Store
+	// Token is the bearer token the identity provider authenticates with.
+	Token         string
+	UpsertUser    bool // auto-create missing users on group member add
+	GroupMappings []GroupMapping
+	// Invalidator, if set, is told about keys blocked on deprovisioning.
+	Invalidator KeyInvalidator
 }
 
-// NewSCIMServer creates a SCIM 2.0 http.Handler with User and Group resource types.
+// NewSCIMServer creates a SCIM 2.0 http.Handler with User and Group resource
+// types. Requests must carry Config.Token as a bearer token. The handler
+// serves paths relative to its mount point, optionally under "/v2".
 func NewSCIMServer(cfg Config) (http.Handler, error) {
-	userHandler := &UserHandler{DB: cfg.DB}
-	groupHandler := &GroupHandler{DB: cfg.DB, UpsertUser: cfg.UpsertUser}
+	if cfg.Token == "" {
+		return nil, errors.New("token is required")
+	}
+	for _, m := range cfg.GroupMappings {
+		if m.Group == "" {
+			return nil, errors.New("group mapping without a group")
+		}
+		if m.Role != "" {
+			if _, err := auth.ParseRole(string(m.Role)); err != nil {
+				return nil, fmt.Errorf("group %q: role %q: %w", m.Group, m.Role, err)
+			}
+		}
+		switch m.TeamRole {
+		case "", teamRoleAdmin, teamRoleUser:
+		default:
+			return nil, fmt.Errorf("group %q: team role %q is not %q or %q", m.Group, m.TeamRole, teamRoleAdmin, teamRoleUser)
+		}
+	}
+
+	userHandler := &UserHandler{DB: cfg.DB, Invalidator: cfg.Invalidator}
+	groupHandler := &GroupHandler{
+		DB:          cfg.DB,
+		UpsertUser:  cfg.UpsertUser,
+		Mappings:    cfg.GroupMappings,
+		Invalidator: cfg.Invalidator,
+	}
 
 	server, err := libscim.NewServer(
 		&libscim.ServerArgs{
@@ -65,5 +132,25 @@ func NewSCIMServer(cfg Config) (http.Handler, error) {
 		return nil, err
 	}
 
-	return server, nil
+	return bearerAuth(cfg.Token, server), nil
+}
+
+// bearerAuth rejects requests without the SCIM bearer token with a SCIM
+// error response.
+func bearerAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(token)) != 1 {
+			w.Header().Set("Content-Type", "application/scim+json")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"schemas": []string{"urn:ietf:params:scim:api:messages:2.0:Error"},
+				"status":  "401",
+				"detail":  "invalid or missing SCIM bearer token",
+			})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
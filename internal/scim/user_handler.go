@@ -19,7 +19,8 @@ import (
 //   - emails[0].value → user_email
 //   - displayName → user_alias
 type UserHandler struct {
-	DB *db.Queries
+	DB          Store
+	Invalidator KeyInvalidator
 }
 
 func (h *UserHandler) Create(r *http.Request, attrs libscim.ResourceAttributes) (libscim.Resource, error) {
@@ -281,9 +282,38 @@ func (h *UserHandler) deactivateUser(ctx context.Context, userID string) {
 		log.Printf("scim: failed to list tokens for user %s: %v", userID, err)
 		return
 	}
+	hashes := make([]string, 0, len(tokens))
 	for _, tok := range tokens {
 		if err := h.DB.BlockVerificationToken(ctx, tok.Token); err != nil {
 			log.Printf("scim: failed to block token %s: %v", tok.Token, err)
 		}
+		hashes = append(hashes, tok.Token)
+	}
+	invalidate(ctx, h.Invalidator, hashes)
+}
+
+// invalidateUserKeys drops the cached lookups of the user's keys.
+func invalidateUserKeys(ctx context.Context, store Store, inv KeyInvalidator, userID string) {
+	if inv == nil {
+		return
+	}
+	tokens, err := store.ListVerificationTokensByUser(ctx, &userID)
+	if err != nil {
+		log.Printf("scim: failed to list tokens for user %s: %v", userID, err)
+		return
+	}
+	hashes := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		hashes = append(hashes, tok.Token)
+	}
+	invalidate(ctx, inv, hashes)
+}
+
+func invalidate(ctx context.Context, inv KeyInvalidator, hashes []string) {
+	if inv == nil || len(hashes) == 0 {
+		return
+	}
+	if err := inv.Invalidate(ctx, hashes...); err != nil {
+		log.Printf("scim: failed to invalidate cached keys: %v", err)
 	}
 }
@@ -0,0 +1,58 @@
+package spend
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// NextBudgetReset returns when a budget window of the given budget_duration
+// that starts at from ends. It accepts "daily", "weekly" and "monthly" as
+// set by the UI, a count with an s, m, h or d suffix such as "30d", and a
+// bare count of seconds.
+func NextBudgetReset(duration string, from time.Time) (time.Time, error) {
+	s := strings.TrimSpace(duration)
+	switch s {
+	case "daily":
+		return from.AddDate(0, 0, 1), nil
+	case "weekly":
+		return from.AddDate(0, 0, 7), nil
+	case "monthly":
+		return from.AddDate(0, 1, 0), nil
+	}
+
+	unit := time.Second
+	if s != "" {
+		switch s[len(s)-1] {
+		case 's':
+			s = s[:len(s)-1]
+		case 'm':
+			unit, s = time.Minute, s[:len(s)-1]
+		case 'h':
+			unit, s = time.Hour, s[:len(s)-1]
+		case 'd':
+			unit, s = 24*time.Hour, s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return time.Time{}, fmt.Errorf("invalid budget duration %q", duration)
+	}
+	return from.Add(time.Duration(n) * unit), nil
+}
+
+// BudgetResetAt returns the budget_reset_at of a budget with the given
+// budget_duration set at now. An empty duration never resets.
+func BudgetResetAt(duration string, now time.Time) (pgtype.Timestamptz, error) {
+	if duration == "" {
+		return pgtype.Timestamptz{}, nil
+	}
+	t, err := NextBudgetReset(duration, now)
+	if err != nil {
+		return pgtype.Timestamptz{}, err
+	}
+	return pgtype.Timestamptz{Time: t, Valid: true}, nil
+}
@@ -0,0 +1,52 @@
+package spend
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextBudgetReset(t *testing.T) {
+	from := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		duration string
+		want     time.Time
+	}{
+		{"daily", from.AddDate(0, 0, 1)},
+		{"weekly", from.AddDate(0, 0, 7)},
+		{"monthly", from.AddDate(0, 1, 0)},
+		{"30d", from.Add(30 * 24 * time.Hour)},
+		{"12h", from.Add(12 * time.Hour)},
+		{"15m", from.Add(15 * time.Minute)},
+		{"90s", from.Add(90 * time.Second)},
+		{"3600", from.Add(time.Hour)},
+	}
+	for _, tt := range tests {
+		got, err := NextBudgetReset(tt.duration, from)
+		require.NoError(t, err, tt.duration)
+		assert.Equal(t, tt.want, got, tt.duration)
+	}
+
+	for _, bad := range []string{"", "yearly", "d", "-1d", "0", "1w"} {
+		_, err := NextBudgetReset(bad, from)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestBudgetResetAt(t *testing.T) {
+	now := time.Now()
+
+	ts, err := BudgetResetAt("", now)
+	require.NoError(t, err)
+	assert.False(t, ts.Valid)
+
+	ts, err = BudgetResetAt("daily", now)
+	require.NoError(t, err)
+	assert.True(t, ts.Valid)
+	assert.Equal(t, now.AddDate(0, 0, 1), ts.Time)
+
+	_, err = BudgetResetAt("fortnightly", now)
+	assert.Error(t, err)
+}
@@ -13,18 +13,40 @@ import (
 	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
 )
 
-// Tracker records spend after each LLM call and updates key/team/user budgets.
+// spendStore is the subset of *db.Queries the tracker writes to.
+type spendStore interface {
+	CreateSpendLog(ctx context.Context, arg db.CreateSpendLogParams) error
+	UpdateVerificationTokenSpend(ctx context.Context, arg db.UpdateVerificationTokenSpendParams) error
+	UpdateUserSpend(ctx context.Context, arg db.UpdateUserSpendParams) error
+	UpdateTeamSpend(ctx context.Context, arg db.UpdateTeamSpendParams) error
+	UpdateOrganizationSpend(ctx context.Context, arg db.UpdateOrganizationSpendParams) error
+	UpdateEndUserSpend(ctx context.Context, arg db.UpdateEndUserSpendParams) error
+}
+
+// SpendInvalidator drops cached copies of the budget rows whose spend the
+// tracker changed, so budget checks do not read stale spend.
+// middleware.AuthCache implements it.
+type SpendInvalidator interface {
+	InvalidateSpend(ctx context.Context, keyHash, userID, teamID, orgID, endUserID string) error
+}
+
+// Tracker records spend after each LLM call and updates the key, user,
+// team, organization and end-user budgets it counts against.
 type Tracker struct {
-	db     *db.Queries
+	db     spendStore
 	buffer *RedisBuffer
+
+	// Invalidator, if set, is told about every budget whose spend changed.
+	Invalidator SpendInvalidator
 }
 
 // NewTracker creates a spend tracker.
 func NewTracker(database *db.Queries, buffer *RedisBuffer) *Tracker {
-	return &Tracker{
-		db:     database,
-		buffer: buffer,
+	t := &Tracker{buffer: buffer}
+	if database != nil {
+		t.db = database
 	}
+	return t
 }
 
 // SpendRecord holds the data needed to record spend.
@@ -40,6 +62,8 @@ type SpendRecord struct {
 	EndTime                  time.Time
 	User                     string
 	TeamID                   string
+	OrgID                    string
+	EndUser                  string
 	Tags                     []string
 	Metadata                 map[string]any
 	Cost                     float64
@@ -52,6 +76,7 @@ type SpendRecord struct {
 func (t *Tracker) LogSuccess(data callback.LogData) {
 	t.Record(context.Background(), SpendRecord{
 		Model:                    data.Model,
+		ModelGroup:               data.ModelGroup,
 		APIKey:                   data.APIKey,
 		PromptTokens:             data.PromptTokens,
 		CompletionTokens:         data.CompletionTokens,
@@ -62,6 +87,8 @@ func (t *Tracker) LogSuccess(data callback.LogData) {
 		EndTime:                  data.EndTime,
 		User:                     data.UserID,
 		TeamID:                   data.TeamID,
+		OrgID:                    data.OrgID,
+		EndUser:                  data.EndUserID,
 		Tags:                     data.RequestTags,
 		Cost:                     data.Cost,
 		CallType:                 data.CallType,
@@ -129,27 +156,63 @@ func (t *Tracker) Record(ctx context.Context, rec SpendRecord) {
 	if rec.TeamID != "" {
 		params.TeamID = &rec.TeamID
 	}
+	if rec.EndUser != "" {
+		params.EndUser = &rec.EndUser
+	}
 
-	// If Redis buffer is available, batch writes
+	// If Redis buffer is available, batch log writes
 	if t.buffer != nil {
 		t.buffer.Push(params)
-		return
-	}
-
-	// Direct DB write
-	if t.db != nil {
+	} else if t.db != nil {
 		if err := t.db.CreateSpendLog(ctx, params); err != nil {
 			log.Printf("warn: failed to write spend log: %v", err)
 		}
+	}
+
+	// Budget counters are never buffered: budget checks read them.
+	if t.db != nil {
+		t.updateBudgets(ctx, rec, cost)
+	}
+}
 
-		// Update key spend
-		if rec.APIKey != "" {
-			if err := t.db.UpdateVerificationTokenSpend(ctx, db.UpdateVerificationTokenSpendParams{
-				Token: rec.APIKey,
-				Spend: cost,
-			}); err != nil {
-				log.Printf("warn: failed to update key spend: %v", err)
-			}
+// updateBudgets adds cost to the spend of every budget level of rec. Each
+// update is a single atomic increment, so concurrent calls are not lost.
+func (t *Tracker) updateBudgets(ctx context.Context, rec SpendRecord, cost float64) {
+	if cost == 0 {
+		return
+	}
+	if rec.APIKey != "" {
+		if err := t.db.UpdateVerificationTokenSpend(ctx, db.UpdateVerificationTokenSpendParams{
+			Token: rec.APIKey,
+			Model: rec.ModelGroup,
+			Spend: cost,
+		}); err != nil {
+			log.Printf("warn: failed to update key spend: %v", err)
+		}
+	}
+	if rec.User != "" {
+		if err := t.db.UpdateUserSpend(ctx, db.UpdateUserSpendParams{UserID: rec.User, Spend: cost}); err != nil {
+			log.Printf("warn: failed to update user spend: %v", err)
+		}
+	}
+	if rec.TeamID != "" {
+		if err := t.db.UpdateTeamSpend(ctx, db.UpdateTeamSpendParams{TeamID: rec.TeamID, Spend: cost}); err != nil {
+			log.Printf("warn: failed to update team spend: %v", err)
+		}
+	}
+	if rec.OrgID != "" {
+		if err := t.db.UpdateOrganizationSpend(ctx, db.UpdateOrganizationSpendParams{OrganizationID: rec.OrgID, Spend: cost}); err != nil {
+			log.Printf("warn: failed to update organization spend: %v", err)
+		}
+	}
+	if rec.EndUser != "" {
+		if err := t.db.UpdateEndUserSpend(ctx, db.UpdateEndUserSpendParams{UserID: rec.EndUser, Spend: cost}); err != nil {
+			log.Printf("warn: failed to update end user spend: %v", err)
+		}
+	}
+	if t.Invalidator != nil {
+		if err := t.Invalidator.InvalidateSpend(ctx, rec.APIKey, rec.User, rec.TeamID, rec.OrgID, rec.EndUser); err != nil {
+			log.Printf("warn: failed to drop cached budget rows: %v", err)
 		}
 	}
 }
@@ -1,8 +1,11 @@
 package spend
 
 import (
+	"context"
 	"testing"
 
+	"github.com/praxisllmlab/tianjiLLM/internal/callback"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -33,3 +36,91 @@ func TestRecord_StripProviderPrefix(t *testing.T) {
 	cost := pricing.Default().TotalCost(model, pricing.TokenUsage{PromptTokens: 1000, CompletionTokens: 500})
 	require.Greater(t, cost, 0.0, "provider-prefixed model must exist in embedded pricing data")
 }
+
+// recordingStore records the spend updates made by a Tracker.
+type recordingStore struct {
+	logs     []db.CreateSpendLogParams
+	keys     []db.UpdateVerificationTokenSpendParams
+	users    []db.UpdateUserSpendParams
+	teams    []db.UpdateTeamSpendParams
+	orgs     []db.UpdateOrganizationSpendParams
+	endUsers []db.UpdateEndUserSpendParams
+}
+
+func (s *recordingStore) CreateSpendLog(_ context.Context, arg db.CreateSpendLogParams) error {
+	s.logs = append(s.logs, arg)
+	return nil
+}
+
+func (s *recordingStore) UpdateVerificationTokenSpend(_ context.Context, arg db.UpdateVerificationTokenSpendParams) error {
+	s.keys = append(s.keys, arg)
+	return nil
+}
+
+func (s *recordingStore) UpdateUserSpend(_ context.Context, arg db.UpdateUserSpendParams) error {
+	s.users = append(s.users, arg)
+	return nil
+}
+
+func (s *recordingStore) UpdateTeamSpend(_ context.Context, arg db.UpdateTeamSpendParams) error {
+	s.teams = append(s.teams, arg)
+	return nil
+}
+
+func (s *recordingStore) UpdateOrganizationSpend(_ context.Context, arg db.UpdateOrganizationSpendParams) error {
+	s.orgs = append(s.orgs, arg)
+	return nil
+}
+
+func (s *recordingStore) UpdateEndUserSpend(_ context.Context, arg db.UpdateEndUserSpendParams) error {
+	s.endUsers = append(s.endUsers, arg)
+	return nil
+}
+
+func TestLogSuccess_UpdatesEveryBudgetLevel(t *testing.T) {
+	store := &recordingStore{}
+	tracker := &Tracker{db: store}
+
+	tracker.LogSuccess(callback.LogData{
+		Model:            "gpt-4o-2024-08-06",
+		ModelGroup:       "gpt-4o",
+		APIKey:           "hash",
+		UserID:           "u1",
+		TeamID:           "t1",
+		OrgID:            "o1",
+		EndUserID:        "cust-1",
+		PromptTokens:     10,
+		CompletionTokens: 5,
+		Cost:             0.25,
+	})
+
+	require.Len(t, store.logs, 1)
+	require.NotNil(t, store.logs[0].EndUser)
+	assert.Equal(t, "cust-1", *store.logs[0].EndUser)
+	assert.Equal(t, []db.UpdateVerificationTokenSpendParams{{Token: "hash", Model: "gpt-4o", Spend: 0.25}}, store.keys)
+	assert.Equal(t, []db.UpdateUserSpendParams{{UserID: "u1", Spend: 0.25}}, store.users)
+	assert.Equal(t, []db.UpdateTeamSpendParams{{TeamID: "t1", Spend: 0.25}}, store.teams)
+	assert.Equal(t, []db.UpdateOrganizationSpendParams{{OrganizationID: "o1", Spend: 0.25}}, store.orgs)
+	assert.Equal(t, []db.UpdateEndUserSpendParams{{UserID: "cust-1", Spend: 0.25}}, store.endUsers)
+}
+
+// recordingInvalidator records the budget rows a Tracker drops.
+type recordingInvalidator struct {
+	calls [][]string
+}
+
+func (i *recordingInvalidator) InvalidateSpend(_ context.Context, keyHash, userID, teamID, orgID, endUserID string) error {
+	i.calls = append(i.calls, []string{keyHash, userID, teamID, orgID, endUserID})
+	return nil
+}
+
+func TestLogSuccess_DropsCachedBudgetRows(t *testing.T) {
+	inv := &recordingInvalidator{}
+	tracker := &Tracker{db: &recordingStore{}, Invalidator: inv}
+
+	tracker.LogSuccess(callback.LogData{Model: "gpt-4o", APIKey: "hash", TeamID: "t1", Cost: 0.25})
+	assert.Equal(t, [][]string{{"hash", "", "t1", "", ""}}, inv.calls)
+
+	tracker.LogSuccess(callback.LogData{Model: "gpt-4o", APIKey: "hash", Cost: 0})
+	assert.Len(t, inv.calls, 1, "free calls change no spend")
+}
@@ -17,6 +17,8 @@ import (
 	"github.com/praxisllmlab/tianjiLLM/internal/config"
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
 	"github.com/praxisllmlab/tianjiLLM/internal/pricing"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy/middleware"
+	"github.com/praxisllmlab/tianjiLLM/internal/router"
 	"github.com/praxisllmlab/tianjiLLM/internal/ui/pages"
 )
 
@@ -29,6 +31,9 @@ type UIHandler struct {
 	MasterKey      string
 	Pricing        *pricing.Calculator
 	RateLimitStore callback.RateLimitStore
+	Router         *router.Router         // optional; receives model changes immediately
+	AuthCache      *middleware.AuthCache  // optional; dropped on key changes
+	RoleGrants     *middleware.RoleGrants // optional; dropped on role changes
 	syncPricingMu  sync.Mutex
 }
 
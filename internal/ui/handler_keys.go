@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,6 +15,7 @@ import (
 	"github.com/go-chi/chi/v5"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/spend"
 	"github.com/praxisllmlab/tianjiLLM/internal/ui/components/toast"
 	"github.com/praxisllmlab/tianjiLLM/internal/ui/pages"
 )
@@ -234,6 +236,12 @@ func (h *UIHandler) handleKeyCreate(w http.ResponseWriter, r *http.Request) {
 	if budgetDuration != "" {
 		budgetDurationPtr = &budgetDuration
 	}
+	budgetResetAt, err := spend.BudgetResetAt(budgetDuration, time.Now())
+	if err != nil {
+		data := h.loadKeysPageData(r)
+		render(r.Context(), w, pages.KeysTableWithToast(data, "Invalid budget duration", toast.VariantError))
+		return
+	}
 
 	var teamIDPtr *string
 	if teamID != "" {
@@ -279,13 +287,14 @@ func (h *UIHandler) handleKeyCreate(w http.ResponseWriter, r *http.Request) {
 		TpmLimit:       tpmLimit,
 		RpmLimit:       rpmLimit,
 		BudgetDuration: budgetDurationPtr,
+		BudgetResetAt:  budgetResetAt,
 	}
 	if !expires.IsZero() {
 		params.Expires.Time = expires
 		params.Expires.Valid = true
 	}
 
-	_, err := h.DB.CreateVerificationToken(r.Context(), params)
+	_, err = h.DB.CreateVerificationToken(r.Context(), params)
 	if err != nil {
 		data := h.loadKeysPageData(r)
 		render(r.Context(), w, pages.KeysTableWithToast(data, "Failed to create key: "+err.Error(), toast.VariantError))
@@ -313,7 +322,9 @@ func (h *UIHandler) handleKeyDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	descendants := h.AuthCache.Descendants(r.Context(), h.DB, token)
 	_ = h.DB.DeleteVerificationToken(r.Context(), token)
+	h.invalidateKeys(r.Context(), append([]string{token}, descendants...)...)
 
 	data := h.loadKeysPageData(r)
 	render(r.Context(), w, pages.KeysTableWithToast(data, "Key deleted successfully", toast.VariantSuccess))
@@ -341,6 +352,7 @@ func (h *UIHandler) handleKeyBlock(w http.ResponseWriter, r *http.Request) {
 		render(r.Context(), w, pages.KeysTableWithToast(data, "Failed to block key: "+err.Error(), toast.VariantError))
 		return
 	}
+	h.invalidateKeys(r.Context(), append([]string{token}, h.AuthCache.Descendants(r.Context(), h.DB, token)...)...)
 
 	data := h.loadKeysPageData(r)
 	render(r.Context(), w, pages.KeysTableWithToast(data, "Key blocked successfully", toast.VariantSuccess))
@@ -368,6 +380,7 @@ func (h *UIHandler) handleKeyUnblock(w http.ResponseWriter, r *http.Request) {
 		render(r.Context(), w, pages.KeysTableWithToast(data, "Failed to unblock key: "+err.Error(), toast.VariantError))
 		return
 	}
+	h.invalidateKeys(r.Context(), append([]string{token}, h.AuthCache.Descendants(r.Context(), h.DB, token)...)...)
 
 	data := h.loadKeysPageData(r)
 	render(r.Context(), w, pages.KeysTableWithToast(data, "Key unblocked successfully", toast.VariantSuccess))
@@ -460,7 +473,17 @@ func (h *UIHandler) handleKeyUpdate(w http.ResponseWriter, r *http.Request) {
 	}
 	params.MaxBudget = parseOptionalFloat(maxBudgetStr)
 	if budgetDuration != "" {
+		resetAt, err := spend.BudgetResetAt(budgetDuration, time.Now())
+		if err != nil {
+			vt, _ := h.DB.GetVerificationToken(r.Context(), token)
+			data := buildKeyDetailData(vt)
+			data.Teams, data.Users = h.loadTeamsAndUsers(r)
+			data.AvailableModels = h.loadAvailableModelNames(r.Context())
+			render(r.Context(), w, pages.EditSettingsFormWithToast(data, "Invalid budget duration", toast.VariantError))
+			return
+		}
 		params.BudgetDuration = &budgetDuration
+		params.BudgetResetAt = resetAt
 	}
 	params.TpmLimit = parseOptionalInt64(tpmStr)
 	params.RpmLimit = parseOptionalInt64(rpmStr)
@@ -485,6 +508,7 @@ func (h *UIHandler) handleKeyUpdate(w http.ResponseWriter, r *http.Request) {
 		render(r.Context(), w, pages.EditSettingsFormWithToast(data, "Failed to update key: "+err.Error(), toast.VariantError))
 		return
 	}
+	h.invalidateKeys(r.Context(), token)
 
 	vt, _ := h.DB.GetVerificationToken(r.Context(), token)
 	data := buildKeyDetailData(vt)
@@ -499,7 +523,9 @@ func (h *UIHandler) handleKeyDetailDelete(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	descendants := h.AuthCache.Descendants(r.Context(), h.DB, token)
 	_ = h.DB.DeleteVerificationToken(r.Context(), token)
+	h.invalidateKeys(r.Context(), append([]string{token}, descendants...)...)
 
 	w.Header().Set("HX-Redirect", "/ui/keys")
 	w.WriteHeader(http.StatusOK)
@@ -545,7 +571,13 @@ func (h *UIHandler) handleKeyRegenerate(w http.ResponseWriter, r *http.Request)
 		}
 	}
 	if v := r.FormValue("budget_duration"); v != "" {
+		resetAt, err := spend.BudgetResetAt(v, time.Now())
+		if err != nil {
+			http.Error(w, "invalid budget duration", http.StatusBadRequest)
+			return
+		}
 		params.NewBudgetDuration = &v
+		params.NewBudgetResetAt = resetAt
 	}
 
 	_, err := h.DB.RegenerateVerificationTokenWithParams(r.Context(), params)
@@ -553,12 +585,20 @@ func (h *UIHandler) handleKeyRegenerate(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "failed to regenerate: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	h.invalidateKeys(r.Context(), token)
 
 	render(r.Context(), w, pages.RegenerateResultDialog(rawKey))
 }
 
 // --- helpers ---
 
+// invalidateKeys drops the cached auth state of changed keys on every replica.
+func (h *UIHandler) invalidateKeys(ctx context.Context, tokens ...string) {
+	if err := h.AuthCache.Invalidate(ctx, tokens...); err != nil {
+		log.Printf("warn: invalidate cached key: %v", err)
+	}
+}
+
 // loadAvailableModelNames returns deduplicated model names from DB + YAML config.
 // Follows the same merge logic as loadModelsPageData in handler_models.go.
 func (h *UIHandler) loadAvailableModelNames(ctx context.Context) []string {
@@ -220,7 +220,7 @@ func (h *UIHandler) handleModelCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = h.DB.CreateProxyModel(r.Context(), db.CreateProxyModelParams{
+	created, err := h.DB.CreateProxyModel(r.Context(), db.CreateProxyModelParams{
 		ModelID:      uuid.New().String(),
 		ModelName:    modelName,
 		TianjiParams: tianjiJSON,
@@ -232,6 +232,7 @@ func (h *UIHandler) handleModelCreate(w http.ResponseWriter, r *http.Request) {
 		render(r.Context(), w, pages.ModelsTableWithToast(data, "Failed to create model: "+err.Error(), toast.VariantError))
 		return
 	}
+	h.syncRouterModel(created)
 
 	w.Header().Set("HX-Trigger", "models-changed")
 	data := h.loadModelsPageData(r)
@@ -346,7 +347,7 @@ func (h *UIHandler) handleModelUpdate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = h.DB.UpdateProxyModel(r.Context(), db.UpdateProxyModelParams{
+	updated, err := h.DB.UpdateProxyModel(r.Context(), db.UpdateProxyModelParams{
 		ModelID:      modelID,
 		ModelName:    modelName,
 		TianjiParams: tianjiJSON,
@@ -358,6 +359,7 @@ func (h *UIHandler) handleModelUpdate(w http.ResponseWriter, r *http.Request) {
 		render(r.Context(), w, pages.ModelsTableWithToast(data, "Failed to update model: "+err.Error(), toast.VariantError))
 		return
 	}
+	h.syncRouterModel(updated)
 
 	w.Header().Set("HX-Trigger", "models-changed")
 	data := h.loadModelsPageData(r)
@@ -382,7 +384,9 @@ func (h *UIHandler) handleModelDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = h.DB.DeleteProxyModel(r.Context(), modelID)
+	if err := h.DB.DeleteProxyModel(r.Context(), modelID); err == nil && h.Router != nil {
+		h.Router.RemoveModel(modelID)
+	}
 
 	w.Header().Set("HX-Trigger", "models-changed")
 	data := h.loadModelsPageData(r)
@@ -391,6 +395,23 @@ func (h *UIHandler) handleModelDelete(w http.ResponseWriter, r *http.Request) {
 
 // --- helpers ---
 
+// syncRouterModel applies a saved model to the live router so it is routable
+// without waiting for the next model reload.
+func (h *UIHandler) syncRouterModel(m db.ProxyModelTable) {
+	if h.Router == nil {
+		return
+	}
+	cfg, err := config.ModelConfigFromJSON(m.ModelName, m.TianjiParams, m.ModelInfo)
+	if err != nil {
+		slog.Warn("model not routable", "model_id", m.ModelID, "err", err)
+		h.Router.RemoveModel(m.ModelID)
+		return
+	}
+	if err := h.Router.UpsertModel(m.ModelID, cfg); err != nil {
+		slog.Warn("model not routable", "model_id", m.ModelID, "err", err)
+	}
+}
+
 // maskAPIKey returns "sk-...XXXX" showing only the last 4 characters, or "" if empty.
 func maskAPIKey(key string) string {
 	if key == "" {
@@ -0,0 +1,219 @@
+package ui
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/praxisllmlab/tianjiLLM/internal/auth"
+	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/ui/components/toast"
+	"github.com/praxisllmlab/tianjiLLM/internal/ui/pages"
+)
+
+func (h *UIHandler) handleRoles(w http.ResponseWriter, r *http.Request) {
+	data := h.loadRolesPageData(r)
+	render(r.Context(), w, pages.RolesPage(data))
+}
+
+func (h *UIHandler) loadRolesPageData(r *http.Request) pages.RolesPageData {
+	var data pages.RolesPageData
+	if h.DB == nil {
+		return data
+	}
+
+	ctx := r.Context()
+	roles, err := h.DB.ListRoles(ctx)
+	if err != nil {
+		log.Printf("ui: failed to list roles: %v", err)
+		return data
+	}
+	assignments, err := h.DB.ListRoleAssignments(ctx, db.ListRoleAssignmentsParams{})
+	if err != nil {
+		log.Printf("ui: failed to list role assignments: %v", err)
+	}
+	byRole := make(map[string][]pages.RoleAssignmentRow)
+	for _, a := range assignments {
+		row := pages.RoleAssignmentRow{ID: a.ID, UserID: a.UserID}
+		if a.OrganizationID != nil {
+			row.OrganizationID = *a.OrganizationID
+		}
+		if a.TeamID != nil {
+			row.TeamID = *a.TeamID
+		}
+		byRole[a.RoleName] = append(byRole[a.RoleName], row)
+	}
+
+	for _, role := range roles {
+		row := pages.RoleRow{
+			RoleName:    role.RoleName,
+			Description: role.Description,
+			Permissions: role.Permissions,
+			Assignments: byRole[role.RoleName],
+		}
+		if role.CreatedAt.Valid {
+			row.CreatedAt = role.CreatedAt.Time
+		}
+		data.Roles = append(data.Roles, row)
+	}
+	return data
+}
+
+// renderRolesTable re-renders the roles table with a toast. Successful
+// changes drop the proxy's cached role grants.
+func (h *UIHandler) renderRolesTable(w http.ResponseWriter, r *http.Request, msg string, variant toast.Variant) {
+	if variant == toast.VariantSuccess {
+		h.RoleGrants.Invalidate()
+	}
+	data := h.loadRolesPageData(r)
+	render(r.Context(), w, pages.RolesTableWithToast(data, msg, variant))
+}
+
+// parsePermissions splits the permissions textarea into one permission per
+// non-blank line.
+func parsePermissions(s string) []string {
+	perms := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			perms = append(perms, line)
+		}
+	}
+	return perms
+}
+
+func (h *UIHandler) handleRoleCreate(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		http.Error(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.TrimSpace(r.FormValue("role_name"))
+	perms := parsePermissions(r.FormValue("permissions"))
+	if err := auth.ValidateCustomRole(name, perms); err != nil {
+		h.renderRolesTable(w, r, err.Error(), toast.VariantError)
+		return
+	}
+
+	createdBy := "admin"
+	if session, ok := getSessionFromRequest(r, h.sessionKey()); ok && session.UserID != "" {
+		createdBy = session.UserID
+	}
+	_, err := h.DB.CreateRole(r.Context(), db.CreateRoleParams{
+		RoleName:    name,
+		Description: strings.TrimSpace(r.FormValue("description")),
+		Permissions: perms,
+		CreatedBy:   createdBy,
+	})
+	if err != nil {
+		h.renderRolesTable(w, r, "Failed to create role: "+err.Error(), toast.VariantError)
+		return
+	}
+	h.renderRolesTable(w, r, "Role created successfully", toast.VariantSuccess)
+}
+
+func (h *UIHandler) handleRoleUpdate(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		http.Error(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	name := chi.URLParam(r, "role_name")
+	perms := parsePermissions(r.FormValue("permissions"))
+	if err := auth.ValidateCustomRole(name, perms); err != nil {
+		h.renderRolesTable(w, r, err.Error(), toast.VariantError)
+		return
+	}
+
+	updatedBy := "admin"
+	if session, ok := getSessionFromRequest(r, h.sessionKey()); ok && session.UserID != "" {
+		updatedBy = session.UserID
+	}
+	description := strings.TrimSpace(r.FormValue("description"))
+	_, err := h.DB.UpdateRole(r.Context(), db.UpdateRoleParams{
+		RoleName:    name,
+		Description: &description,
+		Permissions: perms,
+		UpdatedBy:   updatedBy,
+	})
+	if err != nil {
+		h.renderRolesTable(w, r, "Failed to update role: "+err.Error(), toast.VariantError)
+		return
+	}
+	h.renderRolesTable(w, r, "Role updated successfully", toast.VariantSuccess)
+}
+
+func (h *UIHandler) handleRoleDelete(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		http.Error(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := h.DB.DeleteRole(r.Context(), chi.URLParam(r, "role_name")); err != nil {
+		h.renderRolesTable(w, r, "Failed to delete role: "+err.Error(), toast.VariantError)
+		return
+	}
+	h.renderRolesTable(w, r, "Role deleted successfully", toast.VariantSuccess)
+}
+
+func (h *UIHandler) handleRoleAssign(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		http.Error(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	userID := strings.TrimSpace(r.FormValue("user_id"))
+	if userID == "" {
+		h.renderRolesTable(w, r, "User ID is required", toast.VariantError)
+		return
+	}
+	params := db.CreateRoleAssignmentParams{
+		RoleName:  chi.URLParam(r, "role_name"),
+		UserID:    userID,
+		CreatedBy: "admin",
+	}
+	if session, ok := getSessionFromRequest(r, h.sessionKey()); ok && session.UserID != "" {
+		params.CreatedBy = session.UserID
+	}
+	if org := strings.TrimSpace(r.FormValue("organization_id")); org != "" {
+		params.OrganizationID = &org
+	}
+	if team := strings.TrimSpace(r.FormValue("team_id")); team != "" {
+		params.TeamID = &team
+	}
+	if _, err := h.DB.CreateRoleAssignment(r.Context(), params); err != nil {
+		h.renderRolesTable(w, r, "Failed to assign role: "+err.Error(), toast.VariantError)
+		return
+	}
+	h.renderRolesTable(w, r, "Role assigned to "+userID, toast.VariantSuccess)
+}
+
+func (h *UIHandler) handleRoleUnassign(w http.ResponseWriter, r *http.Request) {
+	if h.DB == nil {
+		http.Error(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.DB.DeleteRoleAssignment(r.Context(), r.FormValue("id")); err != nil {
+		h.renderRolesTable(w, r, "Failed to remove assignment: "+err.Error(), toast.VariantError)
+		return
+	}
+	h.renderRolesTable(w, r, "Assignment removed", toast.VariantSuccess)
+}
@@ -5,11 +5,13 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/db"
+	"github.com/praxisllmlab/tianjiLLM/internal/spend"
 	"github.com/praxisllmlab/tianjiLLM/internal/ui/components/toast"
 	"github.com/praxisllmlab/tianjiLLM/internal/ui/pages"
 )
@@ -167,6 +169,12 @@ func (h *UIHandler) handleTeamCreate(w http.ResponseWriter, r *http.Request) {
 	if budgetDuration != "" {
 		budgetDurationPtr = &budgetDuration
 	}
+	budgetResetAt, err := spend.BudgetResetAt(budgetDuration, time.Now())
+	if err != nil {
+		data := h.loadTeamsPageData(r)
+		render(r.Context(), w, pages.TeamsTableWithToast(data, "Invalid budget duration", toast.VariantError))
+		return
+	}
 
 	models := parseModelSelection(r.FormValue("all_models"), r.Form["models"])
 
@@ -184,6 +192,7 @@ func (h *UIHandler) handleTeamCreate(w http.ResponseWriter, r *http.Request) {
 		RpmLimit:       rpmLimit,
 		BudgetDuration: budgetDurationPtr,
 		CreatedBy:      "admin",
+		BudgetResetAt:  budgetResetAt,
 	}
 
 	_, err = h.DB.CreateTeam(r.Context(), params)
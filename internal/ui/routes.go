@@ -128,6 +128,14 @@ func (h *UIHandler) RegisterRoutes(r chi.Router) {
 			r.Post("/users/{user_id}/block", h.handleUserBlock)
 			r.Post("/users/{user_id}/unblock", h.handleUserUnblock)
 			r.Post("/users/{user_id}/delete", h.handleUserDelete)
+
+			// Roles
+			r.Get("/roles", h.handleRoles)
+			r.Post("/roles/create", h.handleRoleCreate)
+			r.Post("/roles/{role_name}/update", h.handleRoleUpdate)
+			r.Post("/roles/{role_name}/delete", h.handleRoleDelete)
+			r.Post("/roles/{role_name}/assign", h.handleRoleAssign)
+			r.Post("/roles/{role_name}/unassign", h.handleRoleUnassign)
 		})
 	})
 }
@@ -1,9 +1,15 @@
 package contract
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/praxisllmlab/tianjiLLM/internal/a2a"
+	"github.com/praxisllmlab/tianjiLLM/internal/config"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy"
+	"github.com/praxisllmlab/tianjiLLM/internal/proxy/handler"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -146,3 +152,21 @@ func TestJSONRPCSuccessResponse(t *testing.T) {
 	assert.Nil(t, resp.Error)
 	assert.NotNil(t, resp.Result)
 }
+
+func TestA2A_MessageRequiresAuth(t *testing.T) {
+	reg := a2a.NewAgentRegistry()
+	reg.RegisterAgent(&a2a.AgentConfig{AgentID: "agent-1", AgentName: "test-agent"})
+	srv := proxy.NewServer(proxy.ServerConfig{
+		Handlers:  &handler.Handlers{Config: &config.ProxyConfig{}, AgentRegistry: reg},
+		MasterKey: "sk-test",
+	})
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a2a/agent-1/.well-known/agent-card.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "the agent card is public")
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/a2a/agent-1/",
+		strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"message/send","params":{}}`)))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
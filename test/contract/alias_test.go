@@ -36,7 +36,7 @@ func newAliasTestServer(t *testing.T, upstreamURL string) *proxy.Server {
 	}
 
 	rtr := router.New(cfg.ModelList, strategy.NewShuffle(), router.RouterSettings{
-		NumRetries: 1,
+		NumRetries: intPtr(1),
 		ModelGroupAlias: map[string]router.ModelGroupAliasItem{
 			"my-alias":     {Model: "gpt-4o", Hidden: false},
 			"hidden-alias": {Model: "gpt-4o", Hidden: true},
@@ -164,3 +164,4 @@ func TestCacheHandler_DifferentMessages(t *testing.T) {
 }
 
 func strPtr(s string) *string { return &s }
+func intPtr(n int) *int       { return &n }
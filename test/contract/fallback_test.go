@@ -40,7 +40,7 @@ func TestFallback_ModelSpecific(t *testing.T) {
 	}
 
 	rtr := router.New(cfg.ModelList, strategy.NewShuffle(), router.RouterSettings{
-		NumRetries: 0,
+		NumRetries: intPtr(0),
 		Fallbacks: map[string][]string{
 			"missing-model": {"claude-3"},
 		},
@@ -91,7 +91,7 @@ func TestFallback_DefaultFallbacks(t *testing.T) {
 	}
 
 	rtr := router.New(cfg.ModelList, strategy.NewShuffle(), router.RouterSettings{
-		NumRetries:       0,
+		NumRetries:       intPtr(0),
 		DefaultFallbacks: []string{"fallback-model"},
 	})
 
@@ -126,7 +126,7 @@ func TestFallback_AllFail(t *testing.T) {
 	}
 
 	rtr := router.New(cfg.ModelList, strategy.NewShuffle(), router.RouterSettings{
-		NumRetries: 0,
+		NumRetries: intPtr(0),
 		Fallbacks: map[string][]string{
 			"main": {"nonexistent"},
 		},
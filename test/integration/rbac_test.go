@@ -153,8 +153,8 @@ func TestRBAC_InternalUserCannotAccessAdminRoutes(t *testing.T) {
 		},
 	}, privateKey)
 
-	// Internal user should NOT access /key/generate (admin route)
-	req := httptest.NewRequest(http.MethodPost, "/key/generate", strings.NewReader(`{}`))
+	// Internal user should NOT access /key/delete (admin route)
+	req := httptest.NewRequest(http.MethodPost, "/key/delete", strings.NewReader(`{}`))
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+tokenStr)
 
@@ -2,6 +2,8 @@ package integration
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+func intPtr(n int) *int             { return &n }
 func int64Ptr(n int64) *int64       { return &n }
 func float64Ptr(f float64) *float64 { return &f }
 
@@ -47,7 +50,7 @@ func TestRouter_MultiDeployment_Fallback(t *testing.T) {
 	r := router.New(models, strategy.NewShuffle(), router.RouterSettings{
 		AllowedFails: 3,
 		CooldownTime: time.Second,
-		NumRetries:   2,
+		NumRetries:   intPtr(2),
 	})
 
 	req := &model.ChatCompletionRequest{Model: "gpt-4o"}
@@ -237,6 +240,52 @@ func TestRouter_ContextWindowFallback(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestRouter_ErrorFallbacks(t *testing.T) {
+	apiKey := "sk-test"
+	models := []config.ModelConfig{
+		{
+			ModelName: "gpt-3.5-turbo",
+			TianjiParams: config.TianjiParams{
+				Model:  "openai/gpt-3.5-turbo",
+				APIKey: &apiKey,
+			},
+		},
+		{
+			ModelName: "gpt-4-turbo",
+			TianjiParams: config.TianjiParams{
+				Model:  "openai/gpt-4-turbo",
+				APIKey: &apiKey,
+			},
+		},
+	}
+
+	r := router.New(models, strategy.NewShuffle(), router.RouterSettings{
+		ContextWindowFallbacks: map[string][]string{
+			"gpt-3.5-turbo": {"gpt-4-turbo"},
+		},
+		ContentPolicyFallbacks: map[string][]string{
+			"gpt-4-turbo": {"gpt-3.5-turbo"},
+		},
+	})
+
+	// Context window errors map to the configured fallback groups
+	err := fmt.Errorf("prompt too long: %w", model.ErrContextWindowExceeded)
+	assert.Equal(t, []string{"gpt-4-turbo"}, r.ErrorFallbacks("gpt-3.5-turbo", err))
+	assert.Empty(t, r.ErrorFallbacks("gpt-4o", err))
+
+	// Content policy errors use their own fallbacks, as ContentPolicyFallback does
+	err = fmt.Errorf("flagged: %w", model.ErrContentPolicyViolation)
+	assert.Equal(t, []string{"gpt-3.5-turbo"}, r.ErrorFallbacks("gpt-4-turbo", err))
+	d, _, err := r.ContentPolicyFallback("gpt-4-turbo")
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-3.5-turbo", d.ModelName)
+	_, _, err = r.ContentPolicyFallback("gpt-3.5-turbo")
+	assert.Error(t, err)
+
+	// Other errors have no error-specific fallbacks
+	assert.Empty(t, r.ErrorFallbacks("gpt-3.5-turbo", errors.New("boom")))
+}
+
 func TestPolicyEngine_MatchesConditions(t *testing.T) {
 	routingStrategy := "cost-optimized"
 	policies := []router.Policy{
@@ -10,17 +10,20 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+const scimToken = "scim-test-token"
+
 func TestSCIMServer_Creation(t *testing.T) {
-	server, err := scim.NewSCIMServer(scim.Config{})
+	server, err := scim.NewSCIMServer(scim.Config{Token: scimToken})
 	require.NoError(t, err)
 	assert.NotNil(t, server)
 }
 
 func TestSCIMServer_ServiceProviderConfig(t *testing.T) {
-	server, err := scim.NewSCIMServer(scim.Config{})
+	server, err := scim.NewSCIMServer(scim.Config{Token: scimToken})
 	require.NoError(t, err)
 
 	req := httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+	req.Header.Set("Authorization", "Bearer "+scimToken)
 	w := httptest.NewRecorder()
 	server.ServeHTTP(w, req)
 
@@ -30,10 +33,11 @@ func TestSCIMServer_ServiceProviderConfig(t *testing.T) {
 }
 
 func TestSCIMServer_Schemas(t *testing.T) {
-	server, err := scim.NewSCIMServer(scim.Config{})
+	server, err := scim.NewSCIMServer(scim.Config{Token: scimToken})
 	require.NoError(t, err)
 
 	req := httptest.NewRequest(http.MethodGet, "/Schemas", nil)
+	req.Header.Set("Authorization", "Bearer "+scimToken)
 	w := httptest.NewRecorder()
 	server.ServeHTTP(w, req)
 
@@ -43,10 +47,11 @@ func TestSCIMServer_Schemas(t *testing.T) {
 }
 
 func TestSCIMServer_ResourceTypes(t *testing.T) {
-	server, err := scim.NewSCIMServer(scim.Config{})
+	server, err := scim.NewSCIMServer(scim.Config{Token: scimToken})
 	require.NoError(t, err)
 
 	req := httptest.NewRequest(http.MethodGet, "/ResourceTypes", nil)
+	req.Header.Set("Authorization", "Bearer "+scimToken)
 	w := httptest.NewRecorder()
 	server.ServeHTTP(w, req)
 
@@ -56,17 +61,36 @@ func TestSCIMServer_ResourceTypes(t *testing.T) {
 }
 
 func TestSCIMServer_V2PrefixStripping(t *testing.T) {
-	server, err := scim.NewSCIMServer(scim.Config{})
+	server, err := scim.NewSCIMServer(scim.Config{Token: scimToken})
 	require.NoError(t, err)
 
 	// SCIM server supports /v2 prefix auto-stripping
 	req := httptest.NewRequest(http.MethodGet, "/v2/ServiceProviderConfig", nil)
+	req.Header.Set("Authorization", "Bearer "+scimToken)
 	w := httptest.NewRecorder()
 	server.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestSCIMServer_RequiresToken(t *testing.T) {
+	_, err := scim.NewSCIMServer(scim.Config{})
+	require.Error(t, err)
+
+	server, err := scim.NewSCIMServer(scim.Config{Token: scimToken})
+	require.NoError(t, err)
+
+	for _, auth := range []string{"", "Bearer sk-master"} {
+		req := httptest.NewRequest(http.MethodGet, "/ServiceProviderConfig", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	}
+}
+
 func TestSCIMServer_MountedOnProxy(t *testing.T) {
 	srv := newIntegrationServer(t)
 